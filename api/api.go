@@ -194,6 +194,18 @@ func truthy(value interface{}) bool {
 	return true
 }
 
+// labelSelector parses `label` query parameters of list requests,
+// aborting request with 400 if selector is invalid
+func labelSelector(c *gin.Context) (deb.LabelSelector, bool) {
+	selector, err := deb.ParseLabelSelector(c.QueryArray("label"))
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	return selector, true
+}
+
 func maybeRunTaskInBackground(c *gin.Context, name string, resources []string, proc task.Process) {
 	// Run this task in background if configured globally or per-request
 	background := truthy(c.DefaultQuery("_async", strconv.FormatBool(context.Config().AsyncAPI)))
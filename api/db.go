@@ -30,6 +30,7 @@ func apiDBCleanup(c *gin.Context) {
 
 		// collect information about referenced packages...
 		existingPackageRefs := deb.NewPackageRefList()
+		referencedReleaseFiles := []string{}
 
 		out.Printf("Loading mirrors, local repos, snapshots and published repos...")
 		err = collectionFactory.RemoteRepoCollection().ForEach(func(repo *deb.RemoteRepo) error {
@@ -41,6 +42,12 @@ func apiDBCleanup(c *gin.Context) {
 				existingPackageRefs = existingPackageRefs.Merge(repo.RefList(), false, true)
 			}
 
+			e = collectionFactory.RemoteRepoCollection().LoadReleaseHistory(repo)
+			if e != nil {
+				return e
+			}
+			referencedReleaseFiles = append(referencedReleaseFiles, repo.ReleaseHistoryPoolPaths()...)
+
 			return nil
 		})
 		if err != nil {
@@ -142,6 +149,7 @@ func apiDBCleanup(c *gin.Context) {
 			return nil, err
 		}
 
+		referencedFiles = append(referencedFiles, referencedReleaseFiles...)
 		sort.Strings(referencedFiles)
 
 		// build a list of files in the package pool
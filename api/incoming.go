@@ -0,0 +1,181 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// IncomingWatcher processes uploads placed into incoming queues from configuration
+type IncomingWatcher struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// incomingLogReporter reports results of incoming queue processing to the log
+type incomingLogReporter struct {
+	queue string
+}
+
+func (r *incomingLogReporter) Warning(msg string, a ...interface{}) {
+	log.Warn().Msgf("incoming %s: %s", r.queue, fmt.Sprintf(msg, a...))
+}
+
+func (r *incomingLogReporter) Removed(msg string, a ...interface{}) {
+	log.Info().Msgf("incoming %s: removed %s", r.queue, fmt.Sprintf(msg, a...))
+}
+
+func (r *incomingLogReporter) Added(msg string, a ...interface{}) {
+	log.Info().Msgf("incoming %s: added %s", r.queue, fmt.Sprintf(msg, a...))
+}
+
+// newIncomingQueue prepares incoming queue which imports uploads in background tasks
+func newIncomingQueue(name string, config utils.IncomingQueue, reporter aptly.ResultReporter) (*deb.IncomingQueue, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("path is not set")
+	}
+
+	verifier, err := getVerifier(config.Keyrings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	debsigVerifier, err := getDebsigVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	repoTemplateString := config.Repo
+	if repoTemplateString == "" {
+		repoTemplateString = "{{.Distribution}}"
+	}
+
+	repoTemplate, err := template.New("repo").Parse(repoTemplateString)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing repo template: %s", err)
+	}
+
+	var uploaders *deb.Uploaders
+	if config.UploadersFile != "" {
+		uploaders, err = deb.NewUploadersFromFile(config.UploadersFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = uploaders.Compile(query.Parse); err != nil {
+			return nil, err
+		}
+	}
+
+	// progress of the task holding the queue lock, imports are running only under the lock
+	var out aptly.Progress
+
+	importer := func(changesFiles []string, reporter aptly.ResultReporter) ([]string, error) {
+		collectionFactory := context.NewCollectionFactory()
+
+		_, failedFiles, err := deb.ImportChangesFiles(
+			changesFiles, reporter, config.AcceptUnsigned, config.IgnoreSignatures, config.ForceReplace, false, verifier, debsigVerifier,
+			repoTemplate, out, collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
+			context.PackagePool(), collectionFactory.ChecksumCollection, uploaders, query.Parse,
+			collectionFactory.PackageHistoryCollection(), "api:incoming "+name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to import changes files: %s", err)
+		}
+
+		if len(failedFiles) > 0 {
+			out.Printf("Failed files: %s\n", strings.Join(failedFiles, ", "))
+		}
+
+		return failedFiles, nil
+	}
+
+	// uploads are processed in tasks locking the queue, so that watcher and uploads via API
+	// never process the same upload at the same time
+	lock := func(changesFile string, process func() error) error {
+		taskName := fmt.Sprintf("Process upload %s from incoming queue %s", filepath.Base(changesFile), name)
+		resources := []string{task.AllLocalReposResourcesKey, config.Path}
+
+		t, _ := runTaskInBackground(taskName, resources, func(taskOut aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+			out = taskOut
+
+			if err := process(); err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+
+			return &task.ProcessReturnValue{Code: http.StatusOK, Value: nil}, nil
+		})
+
+		if _, err := context.TaskList().WaitForTaskByID(t.ID); err != nil {
+			return err
+		}
+
+		taskErr, err := context.TaskList().GetTaskErrorByID(t.ID)
+		if err != nil {
+			return err
+		}
+
+		return taskErr
+	}
+
+	queue := deb.NewIncomingQueue(config.Path, config.QuarantinePath, verifier, importer, reporter)
+	queue.Lock = lock
+
+	return queue, nil
+}
+
+// StartIncomingWatcher starts watching incoming queues from incomingQueues section of configuration,
+// complete uploads are included into local repositories in background tasks
+//
+// Nil is returned if there are no incoming queues configured.
+func StartIncomingWatcher() (*IncomingWatcher, error) {
+	queuesConfig := context.Config().IncomingQueues
+	if len(queuesConfig) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(queuesConfig))
+	for name := range queuesConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queues := make([]*deb.IncomingQueue, 0, len(names))
+	for _, name := range names {
+		queue, err := newIncomingQueue(name, queuesConfig[name], &incomingLogReporter{queue: name})
+		if err != nil {
+			return nil, fmt.Errorf("unable to set up incoming queue %s: %s", name, err)
+		}
+		queues = append(queues, queue)
+	}
+
+	watcher := &IncomingWatcher{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(watcher.done)
+
+		if err := deb.WatchIncomingQueues(queues, watcher.stop); err != nil {
+			log.Error().Msgf("incoming: %s", err)
+		}
+	}()
+
+	return watcher, nil
+}
+
+// Stop stops watching incoming queues, waiting for imports in progress to finish
+func (watcher *IncomingWatcher) Stop() {
+	close(watcher.stop)
+	<-watcher.done
+}
@@ -7,6 +7,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -31,6 +32,17 @@ func getVerifier(keyRings []string) (pgp.Verifier, error) {
 	return verifier, nil
 }
 
+// getDebsigVerifier returns verifier for signatures embedded into .deb packages
+//
+// Only keyrings from debsigKeyrings are used, nil is returned if none are configured.
+func getDebsigVerifier() (pgp.Verifier, error) {
+	if len(context.Config().DebsigKeyrings) == 0 {
+		return nil, nil
+	}
+
+	return getVerifier(context.Config().DebsigKeyrings)
+}
+
 // stringSlicesEqual compares two string slices for equality (order matters)
 func stringSlicesEqual(a, b []string) bool {
 	if len(a) != len(b) {
@@ -64,16 +76,28 @@ func uniqueStrings(input []string) []string {
 // @Summary List Mirrors
 // @Description **Show list of currently available mirrors**
 // @Description Each mirror is returned as in “show” API.
+// @Description Mirrors could be filtered by labels with one or more `label` query parameters: `key=value`, `key!=value`, `key` or `!key`.
 // @Tags Mirrors
+// @Param label query []string false "Label selector"
 // @Produce json
 // @Success 200 {array} remoteRepoResponse
+// @Failure 400 {object} Error "Bad Request"
 // @Router /api/mirrors [get]
 func apiMirrorsList(c *gin.Context) {
+	selector, ok := labelSelector(c)
+	if !ok {
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.RemoteRepoCollection()
 
 	result := []remoteRepoResponse{}
 	err := collection.ForEach(func(repo *deb.RemoteRepo) error {
+		if !selector.Matches(repo.Labels) {
+			return nil
+		}
+
 		err := collection.LoadComplete(repo)
 		if err != nil {
 			return err
@@ -95,6 +119,8 @@ type mirrorCreateParams struct {
 	Name string `binding:"required"          json:"Name"              example:"mirror2"`
 	// Url of the archive to mirror
 	ArchiveURL string `binding:"required"    json:"ArchiveURL"        example:"http://deb.debian.org/debian"`
+	// Equivalent archive urls tried in order if download from `ArchiveURL` fails
+	FallbackArchiveURLs []string `           json:"FallbackArchiveURLs" example:"http://ftp.de.debian.org/debian"`
 	// Distribution name to mirror
 	Distribution string `                    json:"Distribution"      example:"'buster', for flat repositories use './'"`
 	// Package query that is applied to mirror packages
@@ -115,12 +141,16 @@ type mirrorCreateParams struct {
 	DownloadAppStream bool `                 json:"DownloadAppStream"`
 	// Set "true" to include dependencies of matching packages when filtering
 	FilterWithDeps bool `                    json:"FilterWithDeps"`
+	// Keep only N newest versions of each package (per architecture), 0 keeps all versions
+	KeepVersions int `                       json:"KeepVersions"      example:"3"`
 	// Set "true" to skip if the given components are in the Release file
 	SkipComponentCheck bool `                json:"SkipComponentCheck"`
 	// Set "true" to skip the verification of architectures
 	SkipArchitectureCheck bool `             json:"SkipArchitectureCheck"`
 	// Set "true" to skip the verification of Release file signatures
 	IgnoreSignatures bool `                  json:"IgnoreSignatures"`
+	// User-defined labels (optional)
+	Labels map[string]string `               json:"Labels"            example:"env:prod"`
 }
 
 // @Summary Create Mirror
@@ -163,6 +193,11 @@ func apiMirrorsCreate(c *gin.Context) {
 		}
 	}
 
+	if b.KeepVersions < 0 {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: number of versions to keep should not be negative"))
+		return
+	}
+
 	repo, err := deb.NewRemoteRepo(b.Name, b.ArchiveURL, b.Distribution, b.Components, b.Architectures,
 		b.DownloadSources, b.DownloadUdebs, b.DownloadInstaller, b.DownloadAppStream)
 
@@ -173,18 +208,32 @@ func apiMirrorsCreate(c *gin.Context) {
 
 	repo.Filter = b.Filter
 	repo.FilterWithDeps = b.FilterWithDeps
+	repo.KeepVersions = b.KeepVersions
 	repo.SkipComponentCheck = b.SkipComponentCheck
 	repo.SkipArchitectureCheck = b.SkipArchitectureCheck
 	repo.DownloadSources = b.DownloadSources
 	repo.DownloadUdebs = b.DownloadUdebs
 
+	err = repo.SetFallbackArchiveRoots(b.FallbackArchiveURLs)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+
+	err = deb.ValidateLabels(b.Labels)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create mirror: %s", err))
+		return
+	}
+	repo.Labels = deb.UpdateLabels(nil, b.Labels, nil)
+
 	verifier, err := getVerifier(b.Keyrings)
 	if err != nil {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
 		return
 	}
 
-	downloader := context.NewDownloader(nil)
+	downloader := repo.Downloader(context.NewDownloader(nil))
 	err = repo.Fetch(downloader, verifier, b.IgnoreSignatures)
 	if err != nil {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to fetch mirror: %s", err))
@@ -226,6 +275,7 @@ func apiMirrorsDrop(c *gin.Context) {
 		return
 	}
 
+	actor := historyActor(c)
 	resources := []string{string(repo.Key())}
 	taskName := fmt.Sprintf("Delete mirror %s", name)
 
@@ -255,10 +305,25 @@ func apiMirrorsDrop(c *gin.Context) {
 			}
 		}
 
+		err = taskMirrorCollection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %v", err)
+		}
+
 		err = taskMirrorCollection.Drop(repo)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %v", err)
 		}
+
+		err = recordMirrorHistory(taskCollectionFactory, repo.RefList(), nil, deb.PackageEventRemove, "", repo.Name, actor)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		err = taskCollectionFactory.MirrorScheduleCollection().DropMirror(repo.Name)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop schedules: %v", err)
+		}
 		return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
 	})
 }
@@ -292,6 +357,36 @@ func apiMirrorsShow(c *gin.Context) {
 	c.JSON(200, repo)
 }
 
+// @Summary Get Mirror Release History
+// @Description **Get history of upstream Release files synced by the mirror**
+// @Description
+// @Description Entries are sorted from the oldest to the most recent one, files are stored in the package pool.
+// @Tags Mirrors
+// @Param name path string true "mirror name"
+// @Produce json
+// @Success 200 {array} deb.ReleaseHistoryEntry
+// @Failure 404 {object} Error "Mirror not found"
+// @Router /api/mirrors/{name}/history [get]
+func apiMirrorsHistory(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.RemoteRepoCollection()
+
+	name := c.Params.ByName("name")
+	repo, err := collection.ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to show: %s", err))
+		return
+	}
+
+	err = collection.LoadReleaseHistory(repo)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to show: %s", err))
+		return
+	}
+
+	c.JSON(200, repo.ReleaseHistory)
+}
+
 // @Summary List Mirror Packages
 // @Description **Get a list of packages from a mirror**
 // @Tags Mirrors
@@ -390,6 +485,8 @@ type mirrorEditParams struct {
 	Filter *string `         json:"Filter" example:"xserver-xorg"`
 	// Set "true" to include dependencies of matching packages when filtering
 	FilterWithDeps *bool `   json:"FilterWithDeps"`
+	// Keep only N newest versions of each package (per architecture), 0 keeps all versions
+	KeepVersions *int `      json:"KeepVersions"   example:"3"`
 	// Set "true" to mirror installer files
 	DownloadInstaller *bool `json:"DownloadInstaller"`
 	// Set "true" to mirror source packages
@@ -398,12 +495,16 @@ type mirrorEditParams struct {
 	DownloadUdebs *bool `    json:"DownloadUdebs"`
 	// URL of the archive to mirror
 	ArchiveURL *string `     json:"ArchiveURL"     example:"http://deb.debian.org/debian"`
+	// Equivalent archive URLs tried in order if download from archive URL fails, replaces current list
+	FallbackArchiveURLs *[]string `json:"FallbackArchiveURLs" example:"http://ftp.de.debian.org/debian"`
 	// Comma separated list of architectures
 	Architectures *[]string `json:"Architectures"  example:"amd64"`
 	// Gpg keyring(s) for verifying Release file if a mirror update is required.
 	Keyrings []string `      json:"Keyrings"       example:"trustedkeys.gpg"`
 	// Set "true" to skip the verification of Release file signatures
 	IgnoreSignatures *bool ` json:"IgnoreSignatures"`
+	// Replace labels of mirror
+	Labels *map[string]string `json:"Labels"        example:"env:prod"`
 }
 
 // @Summary Edit Mirror
@@ -455,6 +556,13 @@ func apiMirrorsEdit(c *gin.Context) {
 	if b.FilterWithDeps != nil {
 		repo.FilterWithDeps = *b.FilterWithDeps
 	}
+	if b.KeepVersions != nil {
+		if *b.KeepVersions < 0 {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: number of versions to keep should not be negative"))
+			return
+		}
+		repo.KeepVersions = *b.KeepVersions
+	}
 	if b.DownloadInstaller != nil {
 		repo.DownloadInstaller = *b.DownloadInstaller
 	}
@@ -468,6 +576,13 @@ func apiMirrorsEdit(c *gin.Context) {
 		repo.SetArchiveRoot(*b.ArchiveURL)
 		fetchMirror = true
 	}
+	if b.FallbackArchiveURLs != nil {
+		err = repo.SetFallbackArchiveRoots(*b.FallbackArchiveURLs)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+			return
+		}
+	}
 	if b.Architectures != nil {
 		uniqueArchitectures := uniqueStrings(*b.Architectures)
 		if !stringSlicesEqual(uniqueArchitectures, uniqueStrings(repo.Architectures)) {
@@ -478,6 +593,14 @@ func apiMirrorsEdit(c *gin.Context) {
 	if b.IgnoreSignatures != nil {
 		ignoreSignatures = *b.IgnoreSignatures
 	}
+	if b.Labels != nil {
+		err = deb.ValidateLabels(*b.Labels)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+			return
+		}
+		repo.Labels = deb.UpdateLabels(nil, *b.Labels, nil)
+	}
 
 	if repo.IsFlat() && repo.DownloadUdebs {
 		AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: flat mirrors don't support udebs"))
@@ -491,7 +614,7 @@ func apiMirrorsEdit(c *gin.Context) {
 			return
 		}
 
-		err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+		err = repo.Fetch(repo.Downloader(context.Downloader()), verifier, ignoreSignatures)
 		if err != nil {
 			AbortWithJSONError(c, 500, fmt.Errorf("unable to edit: %s", err))
 			return
@@ -522,6 +645,8 @@ type mirrorUpdateParams struct {
 	SkipExistingPackages bool `   json:"SkipExistingPackages"`
 	// Set "true" to download only the latest version per package/architecture
 	LatestOnly bool `             json:"LatestOnly"`
+	// Set "true" to accept Release file older than previously synced one or with expired Valid-Until
+	ForceRelease bool `           json:"ForceRelease"`
 }
 
 // @Summary Update Mirror
@@ -580,245 +705,411 @@ func apiMirrorsUpdate(c *gin.Context) {
 	}
 
 	resources := []string{string(remote.Key())}
+	actor := historyActor(c)
 	maybeRunTaskInBackground(c, "Update mirror "+b.Name, resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
-		// Phase 2: Inside task lock - create fresh factory
-		taskCollectionFactory := context.NewCollectionFactory()
-		taskCollection := taskCollectionFactory.RemoteRepoCollection()
+		return mirrorUpdate(out, detail, name, b, verifier, actor)
+	})
+}
 
-		// Fresh load after lock acquired (use captured `name` variable, not gin context)
-		remote, err := taskCollection.ByName(name)
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+// mirrorUpdate fetches mirror metadata and downloads packages, should be run as a task
+// holding the mirror resource, actor is recorded in package history
+func mirrorUpdate(out aptly.Progress, detail *task.Detail, name string, b mirrorUpdateParams, verifier pgp.Verifier, actor string) (*task.ProcessReturnValue, error) {
+	// Phase 2: Inside task lock - create fresh factory
+	taskCollectionFactory := context.NewCollectionFactory()
+	taskCollection := taskCollectionFactory.RemoteRepoCollection()
+
+	// Fresh load after lock acquired
+	remote, err := taskCollection.ByName(name)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	// Fresh rename check inside lock (if renaming)
+	if b.Name != remote.Name {
+		_, err := taskCollection.ByName(b.Name)
+		if err == nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to rename: mirror %s already exists", b.Name)
 		}
+	}
 
-		// Fresh rename check inside lock (if renaming)
-		if b.Name != remote.Name {
-			_, err := taskCollection.ByName(b.Name)
-			if err == nil {
-				return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to rename: mirror %s already exists", b.Name)
-			}
+	err = taskCollection.LoadReleaseHistory(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	downloader := remote.Downloader(context.NewDownloader(out))
+	err = remote.Fetch(downloader, verifier, b.IgnoreSignatures)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	if !b.ForceRelease {
+		err = remote.CheckReleaseFreshness(time.Now())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
+	}
 
-		downloader := context.NewDownloader(out)
-		err = remote.Fetch(downloader, verifier, b.IgnoreSignatures)
+	if !b.ForceUpdate {
+		err = remote.CheckLock()
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
+	}
 
-		if !b.ForceUpdate {
-			err = remote.CheckLock()
-			if err != nil {
-				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
-			}
-		}
+	err = remote.DownloadPackageIndexes(out, downloader, verifier, taskCollectionFactory, b.IgnoreSignatures, remote.SkipComponentCheck)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
 
-		err = remote.DownloadPackageIndexes(out, downloader, verifier, taskCollectionFactory, b.IgnoreSignatures, remote.SkipComponentCheck)
+	if remote.DownloadAppStream && !remote.IsFlat() {
+		err = remote.DownloadAppStreamFiles(out, downloader,
+			context.PackagePool(), taskCollectionFactory.ChecksumCollection(nil), b.IgnoreChecksums)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
+	}
 
-		if remote.DownloadAppStream && !remote.IsFlat() {
-			err = remote.DownloadAppStreamFiles(out, downloader,
-				context.PackagePool(), taskCollectionFactory.ChecksumCollection(nil), b.IgnoreChecksums)
-			if err != nil {
-				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
-			}
-		}
-
-		if remote.Filter != "" {
-			var filterQuery deb.PackageQuery
+	if remote.Filter != "" {
+		var filterQuery deb.PackageQuery
 
-			filterQuery, err = query.Parse(remote.Filter)
-			if err != nil {
-				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
-			}
-
-			_, _, err = remote.ApplyFilter(context.DependencyOptions(), filterQuery, out)
-			if err != nil {
-				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
-			}
+		filterQuery, err = query.Parse(remote.Filter)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
 
-		queue, downloadSize, err := remote.BuildDownloadQueue(context.PackagePool(), taskCollectionFactory.PackageCollection(),
-			taskCollectionFactory.ChecksumCollection(nil), b.SkipExistingPackages, b.LatestOnly)
+		_, _, err = remote.ApplyFilter(context.DependencyOptions(), filterQuery, out)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
 		}
+	}
 
-		defer func() {
-			// on any interruption, unlock the mirror
-			e := context.ReOpenDatabase()
-			if e == nil {
-				remote.MarkAsIdle()
-				_ = taskCollection.Update(remote)
-			}
-		}()
+	queue, downloadSize, err := remote.BuildDownloadQueue(context.PackagePool(), taskCollectionFactory.PackageCollection(),
+		taskCollectionFactory.ChecksumCollection(nil), b.SkipExistingPackages, b.LatestOnly)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
 
-		remote.MarkAsUpdating()
-		err = taskCollection.Update(remote)
-		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	defer func() {
+		// on any interruption, unlock the mirror
+		e := context.ReOpenDatabase()
+		if e == nil {
+			remote.MarkAsIdle()
+			_ = taskCollection.Update(remote)
 		}
+	}()
+
+	remote.MarkAsUpdating()
+	err = taskCollection.Update(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
+
+	context.GoContextHandleSignals()
+
+	count := len(queue)
+	taskDetail := struct {
+		TotalDownloadSize         int64
+		RemainingDownloadSize     int64
+		TotalNumberOfPackages     int
+		RemainingNumberOfPackages int
+	}{
+		downloadSize, downloadSize, count, count,
+	}
+	detail.Store(taskDetail)
 
-		context.GoContextHandleSignals()
+	downloadQueue := make(chan int)
+	taskFinished := make(chan *deb.PackageDownloadTask)
 
-		count := len(queue)
-		taskDetail := struct {
-			TotalDownloadSize         int64
-			RemainingDownloadSize     int64
-			TotalNumberOfPackages     int
-			RemainingNumberOfPackages int
-		}{
-			downloadSize, downloadSize, count, count,
+	var (
+		errors  []string
+		errLock sync.Mutex
+	)
+
+	pushError := func(err error) {
+		errLock.Lock()
+		errors = append(errors, err.Error())
+		errLock.Unlock()
+	}
+
+	go func() {
+		for idx := range queue {
+			select {
+			case downloadQueue <- idx:
+			case <-context.Done():
+				return
+			}
 		}
-		detail.Store(taskDetail)
 
-		downloadQueue := make(chan int)
-		taskFinished := make(chan *deb.PackageDownloadTask)
+		close(downloadQueue)
+	}()
 
-		var (
-			errors  []string
-			errLock sync.Mutex
-		)
+	// update of task details need to be done in order
+	go func() {
+		for {
+			task, ok := <-taskFinished
+			if !ok {
+				return
+			}
 
-		pushError := func(err error) {
-			errLock.Lock()
-			errors = append(errors, err.Error())
-			errLock.Unlock()
+			taskDetail.RemainingDownloadSize -= task.File.Checksums.Size
+			taskDetail.RemainingNumberOfPackages--
+			detail.Store(taskDetail)
 		}
+	}()
+
+	packageDownloader := remote.Downloader(context.Downloader())
 
+	log.Info().Msgf("%s: Spawning background processes...", b.Name)
+	var wg sync.WaitGroup
+	for i := 0; i < context.Config().DownloadConcurrency; i++ {
+		wg.Add(1)
 		go func() {
-			for idx := range queue {
+			defer wg.Done()
+			for {
 				select {
-				case downloadQueue <- idx:
+				case idx, ok := <-downloadQueue:
+					if !ok {
+						return
+					}
+
+					task := &queue[idx]
+
+					var e error
+
+					// provision download location
+					if pp, ok := context.PackagePool().(aptly.LocalPackagePool); ok {
+						task.TempDownPath, e = pp.GenerateTempPath(task.File.Filename)
+					} else {
+						var file *os.File
+						file, e = os.CreateTemp("", task.File.Filename)
+						if e == nil {
+							task.TempDownPath = file.Name()
+							_ = file.Close()
+						}
+					}
+					if e != nil {
+						pushError(e)
+						continue
+					}
+
+					// download file...
+					e = packageDownloader.DownloadWithChecksum(
+						context,
+						remote.PackageURL(task.File.DownloadURL()).String(),
+						task.TempDownPath,
+						&task.File.Checksums,
+						b.IgnoreChecksums)
+					if e != nil {
+						pushError(e)
+						continue
+					}
+
+					// and import it back to the pool
+					task.File.PoolPath, err = context.PackagePool().Import(task.TempDownPath, task.File.Filename, &task.File.Checksums, true, taskCollectionFactory.ChecksumCollection(nil))
+					if err != nil {
+						//return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to import file: %s", err)
+						pushError(err)
+						continue
+					}
+
+					// update "attached" files if any
+					for _, additionalAtask := range task.Additional {
+						additionalAtask.File.PoolPath = task.File.PoolPath
+						additionalAtask.File.Checksums = task.File.Checksums
+					}
+
+					task.Done = true
+					taskFinished <- task
 				case <-context.Done():
 					return
 				}
-			}
 
-			close(downloadQueue)
+			}
 		}()
+	}
 
-		// update of task details need to be done in order
-		go func() {
-			for {
-				task, ok := <-taskFinished
-				if !ok {
-					return
-				}
+	// Wait for all download goroutines to finish
+	log.Info().Msgf("%s: Waiting for background processes to finish...", b.Name)
+	wg.Wait()
+	log.Info().Msgf("%s: Background processes finished", b.Name)
+	close(taskFinished)
 
-				taskDetail.RemainingDownloadSize -= task.File.Checksums.Size
-				taskDetail.RemainingNumberOfPackages--
-				detail.Store(taskDetail)
+	defer func() {
+		for _, task := range queue {
+			if task.TempDownPath == "" {
+				continue
 			}
-		}()
 
-		log.Info().Msgf("%s: Spawning background processes...", b.Name)
-		var wg sync.WaitGroup
-		for i := 0; i < context.Config().DownloadConcurrency; i++ {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				for {
-					select {
-					case idx, ok := <-downloadQueue:
-						if !ok {
-							return
-						}
+			if err := os.Remove(task.TempDownPath); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Failed to delete %s: %v\n", task.TempDownPath, err)
+			}
+		}
+	}()
 
-						task := &queue[idx]
+	select {
+	case <-context.Done():
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: interrupted")
+	default:
+	}
 
-						var e error
+	if len(errors) > 0 {
+		log.Info().Msgf("%s: Unable to update because of previous errors", b.Name)
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
+	}
 
-						// provision download location
-						if pp, ok := context.PackagePool().(aptly.LocalPackagePool); ok {
-							task.TempDownPath, e = pp.GenerateTempPath(task.File.Filename)
-						} else {
-							var file *os.File
-							file, e = os.CreateTemp("", task.File.Filename)
-							if e == nil {
-								task.TempDownPath = file.Name()
-								_ = file.Close()
-							}
-						}
-						if e != nil {
-							pushError(e)
-							continue
-						}
+	err = remote.RecordRelease(context.PackagePool(), taskCollectionFactory.ChecksumCollection(nil), time.Now())
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
 
-						// download file...
-						e = context.Downloader().DownloadWithChecksum(
-							context,
-							remote.PackageURL(task.File.DownloadURL()).String(),
-							task.TempDownPath,
-							&task.File.Checksums,
-							b.IgnoreChecksums)
-						if e != nil {
-							pushError(e)
-							continue
-						}
+	log.Info().Msgf("%s: Finalizing download...", b.Name)
+	err = taskCollection.LoadComplete(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
+	before := remote.RefList()
 
-						// and import it back to the pool
-						task.File.PoolPath, err = context.PackagePool().Import(task.TempDownPath, task.File.Filename, &task.File.Checksums, true, taskCollectionFactory.ChecksumCollection(nil))
-						if err != nil {
-							//return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to import file: %s", err)
-							pushError(err)
-							continue
-						}
+	_ = remote.FinalizeDownload(taskCollectionFactory, out)
+	err = taskCollection.Update(remote)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+	}
 
-						// update "attached" files if any
-						for _, additionalAtask := range task.Additional {
-							additionalAtask.File.PoolPath = task.File.PoolPath
-							additionalAtask.File.Checksums = task.File.Checksums
-						}
+	err = recordMirrorHistory(taskCollectionFactory, before, remote.RefList(), deb.PackageEventMirrorImport, remote.ArchiveRoot, remote.Name, actor)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+	}
 
-						task.Done = true
-						taskFinished <- task
-					case <-context.Done():
-						return
-					}
+	log.Info().Msgf("%s: Mirror updated successfully", b.Name)
+	return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
+}
 
-				}
-			}()
+type mirrorVerifyParams struct {
+	// Set "true" to re-download missing and corrupt files from upstream
+	Repair bool `          json:"Repair"`
+	// Gpg keyring(s) for verifying Release file when repairing
+	Keyrings []string `    json:"Keyrings"          example:"trustedkeys.gpg"`
+	// Set "true" to skip the verification of Release file signatures
+	IgnoreSignatures bool `json:"IgnoreSignatures"`
+}
+
+// @Summary Verify Mirror
+// @Description **Verify package files of the mirror**
+// @Description
+// @Description Checks that all the package files of the mirror are present in the package pool and match their checksums.
+// @Description With `Repair`, missing and corrupt files are downloaded again from upstream.
+// @Tags Mirrors
+// @Param name path string true "mirror name to verify"
+// @Consume json
+// @Param request body mirrorVerifyParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.VerifyReport "Verification report"
+// @Success 202 {object} task.Task "Mirror is being verified"
+// @Failure 404 {object} Error "Mirror not found"
+// @Failure 409 {object} Error "Mirror is being updated"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/mirrors/{name}/verify [post]
+func apiMirrorsVerify(c *gin.Context) {
+	var b mirrorVerifyParams
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.RemoteRepoCollection()
+
+	name := c.Params.ByName("name")
+	remote, err := collection.ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to verify: %s", err))
+		return
+	}
+
+	b.IgnoreSignatures = context.Config().GpgDisableVerify
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	var verifier pgp.Verifier
+	if b.Repair {
+		verifier, err = getVerifier(b.Keyrings)
+		if err != nil {
+			AbortWithJSONError(c, 400, fmt.Errorf("unable to initialize GPG verifier: %s", err))
+			return
 		}
+	}
 
-		// Wait for all download goroutines to finish
-		log.Info().Msgf("%s: Waiting for background processes to finish...", b.Name)
-		wg.Wait()
-		log.Info().Msgf("%s: Background processes finished", b.Name)
-		close(taskFinished)
+	resources := []string{string(remote.Key())}
+	maybeRunTaskInBackground(c, "Verify mirror "+name, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		taskCollectionFactory := context.NewCollectionFactory()
+		taskCollection := taskCollectionFactory.RemoteRepoCollection()
 
-		defer func() {
-			for _, task := range queue {
-				if task.TempDownPath == "" {
-					continue
-				}
+		remote, err := taskCollection.ByName(name)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, fmt.Errorf("unable to verify: %s", err)
+		}
 
-				if err := os.Remove(task.TempDownPath); err != nil && !os.IsNotExist(err) {
-					fmt.Fprintf(os.Stderr, "Failed to delete %s: %v\n", task.TempDownPath, err)
-				}
-			}
-		}()
+		err = taskCollection.LoadComplete(remote)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to verify: %s", err)
+		}
 
-		select {
-		case <-context.Done():
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: interrupted")
-		default:
+		if remote.RefList() == nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to verify: mirror not updated")
 		}
 
-		if len(errors) > 0 {
-			log.Info().Msgf("%s: Unable to update because of previous errors", b.Name)
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
+		list, err := deb.NewPackageListFromRefList(remote.RefList(), taskCollectionFactory.PackageCollection(), out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to verify: %s", err)
 		}
 
-		log.Info().Msgf("%s: Finalizing download...", b.Name)
-		_ = remote.FinalizeDownload(taskCollectionFactory, out)
-		err = taskCollection.Update(remote)
+		checksumStorage := taskCollectionFactory.ChecksumCollection(nil)
+
+		report, err := deb.VerifyPackageFiles(list, context.PackagePool(), checksumStorage, out)
 		if err != nil {
-			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to verify: %s", err)
 		}
 
-		log.Info().Msgf("%s: Mirror updated successfully", b.Name)
-		return &task.ProcessReturnValue{Code: http.StatusNoContent, Value: nil}, nil
+		if b.Repair && len(report.Problems) > 0 {
+			err = remote.CheckLock()
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to repair: %s", err)
+			}
+
+			// metadata fetched for repair is not saved, mirror is restored as it was before
+			saved := remote.Encode()
+			defer func() {
+				idle := &deb.RemoteRepo{}
+				if e := idle.Decode(saved); e == nil {
+					idle.MarkAsIdle()
+					_ = taskCollection.Update(idle)
+				}
+			}()
+
+			remote.MarkAsUpdating()
+			err = taskCollection.Update(remote)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to repair: %s", err)
+			}
+
+			downloader := remote.Downloader(context.NewDownloader(out))
+
+			err = remote.Fetch(downloader, verifier, b.IgnoreSignatures)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to repair: %s", err)
+			}
+
+			err = remote.DownloadPackageIndexes(out, downloader, verifier, taskCollectionFactory, b.IgnoreSignatures, false)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to repair: %s", err)
+			}
+
+			err = remote.RepairFiles(report, downloader, context.PackagePool(), checksumStorage, out)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to repair: %s", err)
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: report}, nil
 	})
 }
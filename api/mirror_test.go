@@ -30,6 +30,70 @@ func (s *MirrorSuite) TestDeleteMirrorNonExisting(c *C) {
 	c.Check(response.Body.String(), Equals, "{\"error\":\"unable to drop: mirror with name does-not-exist not found\"}")
 }
 
+func (s *MirrorSuite) TestMirrorHistory(c *C) {
+	response, _ := s.HTTPRequest("GET", "/api/mirrors/does-not-exist/history", nil)
+	c.Check(response.Code, Equals, 404)
+
+	repo, err := deb.NewRemoteRepo("history-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	repo.ReleaseHistory = []deb.ReleaseHistoryEntry{{Date: "Thu, 05 Dec 2013 08:14:32 UTC", Filename: "InRelease", PoolPath: "ab/cd/InRelease"}}
+	collection := s.context.NewCollectionFactory().RemoteRepoCollection()
+	c.Assert(collection.Add(repo), IsNil)
+	defer func() { _ = collection.Drop(repo) }()
+
+	response, _ = s.HTTPRequest("GET", "/api/mirrors/history-mirror/history", nil)
+	c.Assert(response.Code, Equals, 200)
+
+	var history []map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &history), IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Check(history[0]["Filename"], Equals, "InRelease")
+	c.Check(history[0]["PoolPath"], Equals, "ab/cd/InRelease")
+}
+
+func (s *MirrorSuite) TestMirrorVerify(c *C) {
+	response, _ := s.HTTPRequest("POST", "/api/mirrors/does-not-exist/verify", bytes.NewReader([]byte("{}")))
+	c.Check(response.Code, Equals, 404)
+
+	repo, err := deb.NewRemoteRepo("verify-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	collection := s.context.NewCollectionFactory().RemoteRepoCollection()
+	c.Assert(collection.Add(repo), IsNil)
+	defer func() { _ = collection.Drop(repo) }()
+
+	response, _ = s.HTTPRequest("POST", "/api/mirrors/verify-mirror/verify", bytes.NewReader([]byte("{}")))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*mirror not updated.*")
+}
+
+func (s *MirrorSuite) TestMirrorFallbackArchiveURLs(c *C) {
+	body, _ := json.Marshal(gin.H{
+		"Name":                "test-fallback",
+		"ArchiveURL":          "http://example.com/debian",
+		"Distribution":        "stable",
+		"FallbackArchiveURLs": []string{"example.org/debian"},
+	})
+	response, _ := s.HTTPRequest("POST", "/api/mirrors", bytes.NewReader(body))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*absolute URL required.*")
+
+	repo, err := deb.NewRemoteRepo("fallback-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	collection := s.context.NewCollectionFactory().RemoteRepoCollection()
+	c.Assert(collection.Add(repo), IsNil)
+	defer func() { _ = collection.Drop(repo) }()
+
+	body, _ = json.Marshal(gin.H{"FallbackArchiveURLs": []string{"http://example.org/debian", "http://example.net/debian/"}})
+	response, _ = s.HTTPRequest("POST", "/api/mirrors/fallback-mirror", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+
+	response, _ = s.HTTPRequest("GET", "/api/mirrors/fallback-mirror", nil)
+	c.Assert(response.Code, Equals, 200)
+	var mirror map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &mirror), IsNil)
+	c.Check(mirror["FallbackArchiveRoots"], DeepEquals, []interface{}{"http://example.org/debian/", "http://example.net/debian/"})
+}
+
 func (s *MirrorSuite) TestCreateMirrorFlatWithAppStream(c *C) {
 	body, err := json.Marshal(gin.H{
 		"Name":              "test-flat-appstream",
@@ -1,10 +1,59 @@
 package api
 
 import (
-	_ "github.com/aptly-dev/aptly/deb" // for swagger
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/gin-gonic/gin"
 )
 
+// historyActor identifies API client in package history: HTTP basic auth user
+// (if any, e.g. set by reverse proxy) and client address
+func historyActor(c *gin.Context) string {
+	client := c.ClientIP()
+	if client == "" {
+		client = "unknown"
+	}
+
+	if user, _, ok := c.Request.BasicAuth(); ok && user != "" {
+		return "api:" + user + "@" + client
+	}
+
+	return "api:" + client
+}
+
+// recordPackageHistory records changes between reflists of the target local repository
+func recordPackageHistory(collectionFactory *deb.CollectionFactory, before, after *deb.PackageRefList, action, source, target, actor string) error {
+	err := collectionFactory.PackageHistoryCollection().RecordRefListChange(before, after, deb.PackageEvent{
+		Action:     action,
+		Actor:      actor,
+		Source:     source,
+		Target:     target,
+		TargetType: deb.PackageEventTargetRepo,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record package history: %s", err)
+	}
+
+	return nil
+}
+
+// recordMirrorHistory records changes between reflists of the target mirror
+func recordMirrorHistory(collectionFactory *deb.CollectionFactory, before, after *deb.PackageRefList, action, source, target, actor string) error {
+	err := collectionFactory.PackageHistoryCollection().RecordRefListChange(before, after, deb.PackageEvent{
+		Action:     action,
+		Actor:      actor,
+		Source:     source,
+		Target:     target,
+		TargetType: deb.PackageEventTargetMirror,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record package history: %s", err)
+	}
+
+	return nil
+}
+
 // @Summary Get Package Info
 // @Description **Show information about package by package key**
 // @Description Package keys could be obtained from various GET .../packages APIs.
@@ -25,6 +74,28 @@ func apiPackagesShow(c *gin.Context) {
 	c.JSON(200, p)
 }
 
+// @Summary Get Package History
+// @Description **Show provenance history of the package by package key**
+// @Description Events (add, remove, copy, move, include, mirror-import) are listed oldest first,
+// @Description with the time, actor (CLI user or API client), source and target repository.
+// @Description Packages of dropped local repositories and mirrors are recorded as removed.
+// @Tags Packages
+// @Produce json
+// @Param key path string true "package key (unique package identifier)"
+// @Success 200 {array} deb.PackageEvent "OK"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/packages/{key}/history [get]
+func apiPackagesHistory(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	events, err := collectionFactory.PackageHistoryCollection().ByKey([]byte(c.Params.ByName("key")))
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, events)
+}
+
 // @Summary List Packages
 // @Description **Get list of packages**
 // @Tags Packages
@@ -1,6 +1,13 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/gin-gonic/gin"
+
 	. "gopkg.in/check.v1"
 )
 
@@ -16,3 +23,64 @@ func (s *PackagesSuite) TestPackagesGetMaximumVersion(c *C) {
 	c.Check(response.Code, Equals, 200)
 	c.Check(response.Body.String(), Equals, "[]")
 }
+
+func (s *PackagesSuite) TestPackageHistory(c *C) {
+	p := &deb.Package{Name: "libhistory", Version: "1.0", Architecture: "amd64"}
+	c.Assert(s.context.NewCollectionFactory().PackageCollection().Update(p), IsNil)
+	key := string(p.Key(""))
+
+	response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "history-repo"}`)))
+	c.Assert(response.Code, Equals, 201)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/repos/history-repo?force=1", nil) }()
+
+	body, err := json.Marshal(gin.H{"PackageRefs": []string{key}})
+	c.Assert(err, IsNil)
+	response, _ = s.HTTPRequest("POST", "/api/repos/history-repo/packages", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+	response, _ = s.HTTPRequest("DELETE", "/api/repos/history-repo/packages", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+
+	response, _ = s.HTTPRequest("GET", "/api/packages/"+url.PathEscape(key)+"/history", nil)
+	c.Assert(response.Code, Equals, 200)
+
+	var events []deb.PackageEvent
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &events), IsNil)
+	// history is kept in the DB shared between the tests, check most recent events
+	c.Assert(len(events) >= 2, Equals, true)
+	events = events[len(events)-2:]
+	c.Check(events[0].Key, Equals, key)
+	c.Check(events[0].Action, Equals, deb.PackageEventAdd)
+	c.Check(events[0].Target, Equals, "history-repo")
+	c.Check(events[0].Actor, Equals, "api:unknown")
+	c.Check(events[1].Action, Equals, deb.PackageEventRemove)
+}
+
+func (s *PackagesSuite) TestPackageHistoryRepoDrop(c *C) {
+	p := &deb.Package{Name: "libhistory-drop", Version: "1.0", Architecture: "amd64"}
+	c.Assert(s.context.NewCollectionFactory().PackageCollection().Update(p), IsNil)
+	key := string(p.Key(""))
+
+	response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "history-drop-repo"}`)))
+	c.Assert(response.Code, Equals, 201)
+
+	body, err := json.Marshal(gin.H{"PackageRefs": []string{key}})
+	c.Assert(err, IsNil)
+	response, _ = s.HTTPRequest("POST", "/api/repos/history-drop-repo/packages", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+
+	response, _ = s.HTTPRequest("DELETE", "/api/repos/history-drop-repo", nil)
+	c.Assert(response.Code, Equals, 200)
+
+	response, _ = s.HTTPRequest("GET", "/api/packages/"+url.PathEscape(key)+"/history", nil)
+	c.Assert(response.Code, Equals, 200)
+
+	// history survives the repository: its packages are recorded as removed
+	var events []deb.PackageEvent
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &events), IsNil)
+	c.Assert(len(events) >= 2, Equals, true)
+	events = events[len(events)-2:]
+	c.Check(events[0].Action, Equals, deb.PackageEventAdd)
+	c.Check(events[1].Action, Equals, deb.PackageEventRemove)
+	c.Check(events[1].Target, Equals, "history-drop-repo")
+	c.Check(events[1].TargetType, Equals, deb.PackageEventTargetRepo)
+}
@@ -153,6 +153,59 @@ func apiPublishShow(c *gin.Context) {
 	c.JSON(http.StatusOK, published)
 }
 
+// @Summary Published Repository SBOM
+// @Description **Software bill of materials for published repository**
+// @Description
+// @Description SBOM lists packages of all the components of the published repository, see snapshot SBOM for details.
+// @Tags Publish
+// @Param prefix path string true "publishing prefix"
+// @Param distribution path string true "distribution name"
+// @Param format query string false "SBOM format: `spdx-json` (default) or `cyclonedx-json`"
+// @Param vendor query string false "Vendor (namespace) used in package URLs, defaults to `debian`"
+// @Param skipLicenses query string false "Set to 1 to skip extraction of license information from package files"
+// @Produce json
+// @Success 200 {object} string "SBOM document"
+// @Failure 400 {object} Error "Unknown Format"
+// @Failure 404 {object} Error "Published repository not found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/publish/{prefix}/{distribution}/sbom [get]
+func apiPublishSBOM(c *gin.Context) {
+	param := slashEscape(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := slashEscape(c.Params.ByName("distribution"))
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to build SBOM: %s", err))
+		return
+	}
+
+	err = collection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusInternalServerError, fmt.Errorf("unable to build SBOM: %s", err))
+		return
+	}
+
+	// same package might be published in several components
+	list := deb.NewPackageListWithDuplicates(true, 0)
+	for _, component := range published.Components() {
+		componentList, err := deb.NewPackageListFromRefList(published.RefList(component), collectionFactory.PackageCollection(), nil)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusInternalServerError, fmt.Errorf("unable to build SBOM: %s", err))
+			return
+		}
+
+		_ = componentList.ForEach(func(p *deb.Package) error {
+			return list.Add(p)
+		})
+	}
+
+	writeSBOM(c, published.StoragePrefix()+"/"+published.Distribution, list)
+}
+
 type publishedRepoCreateParams struct {
 	// 'local' for local repositories and 'snapshot' for snapshots
 	SourceKind string `binding:"required"         json:"SourceKind"    example:"snapshot"`
@@ -186,6 +239,8 @@ type publishedRepoCreateParams struct {
 	SignedBy *string `                            json:"SignedBy"              example:""`
 	// Enable multiple packages with the same filename in different distributions
 	MultiDist *bool `                             json:"MultiDist"             example:"false"`
+	// Publish .buildinfo files attached to packages under buildinfo/
+	PublishBuildinfo *bool `                      json:"PublishBuildinfo"      example:"false"`
 	// Version of the release
 	Version string `                              json:"Version"               example:""`
 }
@@ -374,6 +429,10 @@ func apiPublishRepoOrSnapshot(c *gin.Context) {
 			published.SignedBy = *b.SignedBy
 		}
 
+		if b.PublishBuildinfo != nil {
+			published.PublishBuildinfo = *b.PublishBuildinfo
+		}
+
 		if b.Version != "" {
 			published.Version = b.Version
 		}
@@ -417,6 +476,8 @@ type publishedRepoUpdateSwitchParams struct {
 	SignedBy *string `                            json:"SignedBy"  example:""`
 	// Enable multiple packages with the same filename in different distributions
 	MultiDist *bool `                             json:"MultiDist"      example:"false"`
+	// Publish .buildinfo files attached to packages under buildinfo/
+	PublishBuildinfo *bool `                      json:"PublishBuildinfo" example:"false"`
     // Value of Label: field in published repository stanza
     Label *string `                               json:"Label"          example:"Debian"`
     // Value of Origin: field in published repository stanza
@@ -436,6 +497,8 @@ type publishedRepoUpdateSwitchParams struct {
 // @Description For published snapshots:
 // @Description * switch components to new snapshot
 // @Description
+// @Description Protected published repository can't be switched to other sources.
+// @Description
 // @Description See also: `aptly publish update` / `aptly publish switch`
 // @Tags Publish
 // @Param prefix path string true "publishing prefix"
@@ -447,6 +510,7 @@ type publishedRepoUpdateSwitchParams struct {
 // @Success 200 {object} deb.PublishedRepo
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Published repository or source not found"
+// @Failure 409 {object} Error "Published repository is protected"
 // @Failure 500 {object} Error "Internal Error"
 // @Router /api/publish/{prefix}/{distribution} [put]
 func apiPublishUpdateSwitch(c *gin.Context) {
@@ -549,6 +613,9 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 		if b.MultiDist != nil {
 			published.MultiDist = *b.MultiDist
 		}
+		if b.PublishBuildinfo != nil {
+			published.PublishBuildinfo = *b.PublishBuildinfo
+		}
 		if b.Label != nil {
 			published.Label = *b.Label
 		}
@@ -570,6 +637,10 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 			}
 		}
 
+		if err = published.CheckRevisionAllowed(); err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+
 		result, err := published.Update(taskCollectionFactory, out)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
@@ -614,6 +685,8 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 // @Description
 // @Description If no other published repositories share the same prefix, all files inside the prefix will be removed.
 // @Description
+// @Description Protected published repository can't be deleted.
+// @Description
 // @Description See also: `aptly publish drop`
 // @Tags Publish
 // @Produce json
@@ -625,6 +698,7 @@ func apiPublishUpdateSwitch(c *gin.Context) {
 // @Success 200
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Published repository not found"
+// @Failure 409 {object} Error "Published repository is protected"
 // @Failure 500 {object} Error "Internal Error"
 // @Router /api/publish/{prefix}/{distribution} [delete]
 func apiPublishDrop(c *gin.Context) {
@@ -656,7 +730,16 @@ func apiPublishDrop(c *gin.Context) {
 		taskCollectionFactory := context.NewCollectionFactory()
 		taskCollection := taskCollectionFactory.PublishedRepoCollection()
 
-		err := taskCollection.Remove(context, storage, prefix, distribution,
+		published, err := taskCollection.ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, fmt.Errorf("unable to drop: %s", err)
+		}
+
+		if err = published.CheckUnprotected(); err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to drop: %s", err)
+		}
+
+		err = taskCollection.Remove(context, storage, prefix, distribution,
 			taskCollectionFactory, out, force, skipCleanup)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %s", err)
@@ -1104,6 +1187,8 @@ type publishedRepoUpdateParams struct {
 	SignedBy *string `                            json:"SignedBy"   example:""`
 	// Enable multiple packages with the same filename in different distributions
 	MultiDist *bool `                             json:"MultiDist"       example:"false"`
+	// Publish .buildinfo files attached to packages under buildinfo/
+	PublishBuildinfo *bool `                      json:"PublishBuildinfo" example:"false"`
     // Value of Label: field in published repository stanza
     Label *string `                               json:"Label"          example:"Debian"`
     // Value of Origin: field in published repository stanza
@@ -1117,6 +1202,8 @@ type publishedRepoUpdateParams struct {
 // @Description
 // @Description Publish pending source component changes which were added with `Add/Remove/Replace Source Components`
 // @Description
+// @Description Changes of sources can't be published for protected published repository.
+// @Description
 // @Description See also: `aptly publish update`
 // @Tags Publish
 // @Param prefix path string true "publishing prefix"
@@ -1128,6 +1215,7 @@ type publishedRepoUpdateParams struct {
 // @Success 200 {object} deb.PublishedRepo
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Published repository/component not found"
+// @Failure 409 {object} Error "Published repository is protected"
 // @Failure 500 {object} Error "Internal Error"
 // @Router /api/publish/{prefix}/{distribution}/update [post]
 func apiPublishUpdate(c *gin.Context) {
@@ -1227,6 +1315,9 @@ func apiPublishUpdate(c *gin.Context) {
 		if b.MultiDist != nil {
 			published.MultiDist = *b.MultiDist
 		}
+		if b.PublishBuildinfo != nil {
+			published.PublishBuildinfo = *b.PublishBuildinfo
+		}
 		if b.Label != nil {
 			published.Label = *b.Label
 		}
@@ -1237,6 +1328,10 @@ func apiPublishUpdate(c *gin.Context) {
 			published.Version = *b.Version
 		}
 
+		if err = published.CheckRevisionAllowed(); err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+
 		result, err := published.Update(taskCollectionFactory, out)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
@@ -1273,3 +1368,73 @@ func apiPublishUpdate(c *gin.Context) {
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
 	})
 }
+
+type publishedRepoProtectionParams struct {
+	// Protect published repository from being dropped or switched to other sources
+	Protected bool `json:"Protected" example:"true"`
+}
+
+// @Summary Protect Published Repository
+// @Description **Protect or unprotect published repository**
+// @Description
+// @Description Protected published repository can't be deleted or switched to other sources (with `PUT /api/publish/{prefix}/{distribution}` or by publishing pending source changes), until it is unprotected.
+// @Description
+// @Description See also: `aptly publish protect` / `aptly publish unprotect`
+// @Tags Publish
+// @Param prefix path string true "publishing prefix"
+// @Param distribution path string true "distribution name"
+// @Consume json
+// @Param request body publishedRepoProtectionParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} deb.PublishedRepo
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Published repository not found"
+// @Failure 500 {object} Error "Internal Error"
+// @Router /api/publish/{prefix}/{distribution}/protection [put]
+func apiPublishProtection(c *gin.Context) {
+	var b publishedRepoProtectionParams
+
+	param := slashEscape(c.Params.ByName("prefix"))
+	storage, prefix := deb.ParsePrefix(param)
+	distribution := slashEscape(c.Params.ByName("distribution"))
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.PublishedRepoCollection()
+
+	published, err := collection.ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, fmt.Errorf("unable to update: %s", err))
+		return
+	}
+
+	resources := []string{string(published.Key())}
+	taskName := fmt.Sprintf("Update published %s repository %s/%s", published.SourceKind, published.StoragePrefix(), published.Distribution)
+	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		taskCollectionFactory := context.NewCollectionFactory()
+		taskCollection := taskCollectionFactory.PublishedRepoCollection()
+
+		published, err := taskCollection.ByStoragePrefixDistribution(storage, prefix, distribution)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+
+		published.Protected = b.Protected
+
+		err = taskCollection.Update(published)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save to DB: %s", err)
+		}
+
+		err = taskCollection.LoadShallow(published, taskCollectionFactory)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to update: %s", err)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: published}, nil
+	})
+}
@@ -8,10 +8,12 @@ import (
 	"sort"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
 	"github.com/aptly-dev/aptly/utils"
@@ -72,16 +74,28 @@ func reposServeInAPIMode(c *gin.Context) {
 // @Summary List Repositories
 // @Description **Get list of available repos**
 // @Description Each repo is returned as in “show” API.
+// @Description Repos could be filtered by labels with one or more `label` query parameters: `key=value`, `key!=value`, `key` or `!key`.
 // @Tags Repos
+// @Param label query []string false "Label selector"
 // @Produce  json
 // @Success 200 {array} localRepoResponse
+// @Failure 400 {object} Error "Bad Request"
 // @Router /api/repos [get]
 func apiReposList(c *gin.Context) {
+	selector, ok := labelSelector(c)
+	if !ok {
+		return
+	}
+
 	result := []localRepoResponse{}
 
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.LocalRepoCollection()
 	err := collection.ForEach(func(r *deb.LocalRepo) error {
+		if !selector.Matches(r.Labels) {
+			return nil
+		}
+
 		err := collection.LoadComplete(r)
 		if err != nil {
 			return err
@@ -109,6 +123,14 @@ type repoCreateParams struct {
 	DefaultComponent string `        json:"DefaultComponent"     example:"main"`
 	// Snapshot name to create repository from (optional)
 	FromSnapshot string `            json:"FromSnapshot"         example:""`
+	// User-defined labels (optional)
+	Labels map[string]string `       json:"Labels"               example:"env:prod"`
+	// Policy on versions of packages being added: none, reject-older or reject-equal-different-content (optional)
+	VersionPolicy string `            json:"VersionPolicy"        example:"reject-older"`
+	// Checks applied to packages being added (optional)
+	LintPolicy *deb.LintPolicy `       json:"LintPolicy"`
+	// Require valid signatures embedded into .deb packages being added (optional)
+	RequireDebsig bool `               json:"RequireDebsig"        example:"false"`
 }
 
 // @Summary Create Repository
@@ -136,6 +158,24 @@ func apiReposCreate(c *gin.Context) {
 		return
 	}
 
+	if err := deb.ValidateLabels(b.Labels); err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	versionPolicy, err := deb.ParseVersionPolicy(b.VersionPolicy)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if b.LintPolicy != nil {
+		if err = b.LintPolicy.Validate(); err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	// Handler: Pre-task validations (shallow)
 	collectionFactory := context.NewCollectionFactory()
 
@@ -150,6 +190,7 @@ func apiReposCreate(c *gin.Context) {
 	}
 
 	taskName := fmt.Sprintf("Create repository %s", b.Name)
+	actor := historyActor(c)
 
 	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
 		// Task: Create fresh collection and check/create ATOMIC inside task
@@ -166,6 +207,10 @@ func apiReposCreate(c *gin.Context) {
 		repo := deb.NewLocalRepo(b.Name, b.Comment)
 		repo.DefaultComponent = b.DefaultComponent
 		repo.DefaultDistribution = b.DefaultDistribution
+		repo.Labels = deb.UpdateLabels(nil, b.Labels, nil)
+		repo.VersionPolicy = versionPolicy
+		repo.LintPolicy = b.LintPolicy
+		repo.RequireDebsig = b.RequireDebsig
 
 		if b.FromSnapshot != "" {
 			snapshotCollection := taskCollectionFactory.SnapshotCollection()
@@ -190,6 +235,13 @@ func apiReposCreate(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 		}
 
+		if b.FromSnapshot != "" {
+			err = recordPackageHistory(taskCollectionFactory, nil, repo.RefList(), deb.PackageEventCopy, "snapshot "+b.FromSnapshot, repo.Name, actor)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+		}
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: repo}, nil
 	})
 }
@@ -203,6 +255,14 @@ type reposEditParams struct {
 	DefaultDistribution *string `        json:"DefaultDistribution"  example:""`
 	// Change Default Component for publishing
 	DefaultComponent *string `        json:"DefaultComponent"     example:""`
+	// Replace labels of repository
+	Labels *map[string]string `       json:"Labels"               example:"env:prod"`
+	// Change policy on versions of packages being added
+	VersionPolicy *string `            json:"VersionPolicy"        example:"reject-older"`
+	// Replace checks applied to packages being added, policy without checks removes it
+	LintPolicy *deb.LintPolicy `       json:"LintPolicy"`
+	// Change requirement of valid signatures embedded into .deb packages being added
+	RequireDebsig *bool `              json:"RequireDebsig"        example:"true"`
 }
 
 // @Summary Update Repository
@@ -221,6 +281,27 @@ func apiReposEdit(c *gin.Context) {
 	if c.Bind(&b) != nil {
 		return
 	}
+	if b.Labels != nil {
+		if err := deb.ValidateLabels(*b.Labels); err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+	var versionPolicy *deb.VersionPolicy
+	if b.VersionPolicy != nil {
+		policy, err := deb.ParseVersionPolicy(*b.VersionPolicy)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+		versionPolicy = &policy
+	}
+	if b.LintPolicy != nil {
+		if err := b.LintPolicy.Validate(); err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
 	// Load shallowly for 404 check and resource key.
 	// Mutation and duplicate check happen inside the task for atomicity.
 	collectionFactory := context.NewCollectionFactory()
@@ -273,6 +354,22 @@ func apiReposEdit(c *gin.Context) {
 		if b.DefaultComponent != nil {
 			repo.DefaultComponent = *b.DefaultComponent
 		}
+		if b.Labels != nil {
+			repo.Labels = deb.UpdateLabels(nil, *b.Labels, nil)
+		}
+		if versionPolicy != nil {
+			repo.VersionPolicy = *versionPolicy
+		}
+		if b.RequireDebsig != nil {
+			repo.RequireDebsig = *b.RequireDebsig
+		}
+		if b.LintPolicy != nil {
+			if len(b.LintPolicy.Checks) > 0 {
+				repo.LintPolicy = b.LintPolicy
+			} else {
+				repo.LintPolicy = nil
+			}
+		}
 
 		err = taskCollection.Update(repo)
 		if err != nil {
@@ -333,6 +430,7 @@ func apiReposDrop(c *gin.Context) {
 		return
 	}
 
+	actor := historyActor(c)
 	resources := []string{string(repo.Key())}
 	taskName := fmt.Sprintf("Delete repo %s", name)
 	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
@@ -361,7 +459,22 @@ func apiReposDrop(c *gin.Context) {
 			}
 		}
 
-		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{}}, taskCollection.Drop(repo)
+		err = taskCollection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop: %s", err)
+		}
+
+		err = taskCollection.Drop(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		err = recordPackageHistory(taskCollectionFactory, repo.RefList(), nil, deb.PackageEventRemove, "", repo.Name, actor)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{}}, nil
 	})
 }
 
@@ -411,7 +524,7 @@ type reposPackagesAddDeleteParams struct {
 }
 
 // Handler for both add and delete
-func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error) {
+func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(repo *deb.LocalRepo, list *deb.PackageList, p *deb.Package, out aptly.Progress) error) {
 	var b reposPackagesAddDeleteParams
 
 	if c.Bind(&b) != nil {
@@ -431,6 +544,7 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 	}
 
 	resources := []string{string(repo.Key())}
+	actor := historyActor(c)
 
 	maybeRunTaskInBackground(c, taskNamePrefix+repo.Name, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
 		// Task: Create fresh factory and collection inside task after lock
@@ -466,18 +580,25 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 			}
-			err = cb(list, p, out)
+			err = cb(repo, list, p, out)
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
 			}
 		}
 
+		before := repo.RefList()
 		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 		err = taskCollection.Update(repo)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
 		}
+
+		// packages removed from the list are recorded as removals
+		err = recordPackageHistory(taskCollectionFactory, before, repo.RefList(), deb.PackageEventAdd, "", repo.Name, actor)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: repo}, nil
 	})
 }
@@ -500,8 +621,11 @@ func apiReposPackagesAddDelete(c *gin.Context, taskNamePrefix string, cb func(li
 // @Failure 400 {object} Error "Internal Server Error"
 // @Router /api/repos/{name}/packages [post]
 func apiReposPackagesAdd(c *gin.Context) {
-	apiReposPackagesAddDelete(c, "Add packages to repo ", func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
+	apiReposPackagesAddDelete(c, "Add packages to repo ", func(repo *deb.LocalRepo, list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
 		out.Printf("Adding package %s\n", p.Name)
+		if err := repo.VersionPolicy.Check(list, p); err != nil {
+			return err
+		}
 		return list.Add(p)
 	})
 }
@@ -522,7 +646,7 @@ func apiReposPackagesAdd(c *gin.Context) {
 // @Failure 400 {object} Error "Internal Server Error"
 // @Router /api/repos/{name}/packages [delete]
 func apiReposPackagesDelete(c *gin.Context) {
-	apiReposPackagesAddDelete(c, "Delete packages from repo ", func(list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
+	apiReposPackagesAddDelete(c, "Delete packages from repo ", func(_ *deb.LocalRepo, list *deb.PackageList, p *deb.Package, out aptly.Progress) error {
 		out.Printf("Removing package %s\n", p.Name)
 		list.Remove(p)
 		return nil
@@ -537,6 +661,7 @@ func apiReposPackagesDelete(c *gin.Context) {
 // @Param name path string true "Repository name"
 // @Param dir path string true "Directory of packages"
 // @Param file path string true "Filename"
+// @Param requireDebsig query string false "when value is set to 1, require valid signatures embedded into .deb packages"
 // @Param _async query bool false "Run in background and return task object"
 // @Produce json
 // @Success 200 {string} string "OK"
@@ -559,6 +684,7 @@ func apiReposPackageFromFile(c *gin.Context) {
 // @Consume  json
 // @Param noRemove query string false "when value is set to 1, don’t remove any files"
 // @Param forceReplace query string false "when value is set to 1, remove packages conflicting with package being added (in local repository)"
+// @Param requireDebsig query string false "when value is set to 1, require valid signatures embedded into .deb packages (always required if repository requires them)"
 // @Param _async query bool false "Run in background and return task object"
 // @Produce  json
 // @Success 200 {string} string "OK"
@@ -569,6 +695,7 @@ func apiReposPackageFromFile(c *gin.Context) {
 func apiReposPackageFromDir(c *gin.Context) {
 	forceReplace := c.Request.URL.Query().Get("forceReplace") == "1"
 	noRemove := c.Request.URL.Query().Get("noRemove") == "1"
+	requireDebsig := c.Request.URL.Query().Get("requireDebsig") == "1"
 
 	if !verifyDir(c) {
 		return
@@ -605,6 +732,7 @@ func apiReposPackageFromDir(c *gin.Context) {
 
 	resources := []string{string(repo.Key())}
 	resources = append(resources, sources...)
+	actor := historyActor(c)
 	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
 		// Task: Create fresh factory and collection inside task after lock
 		taskCollectionFactory := context.NewCollectionFactory()
@@ -623,6 +751,18 @@ func apiReposPackageFromDir(c *gin.Context) {
 
 		verifier := context.GetVerifier()
 
+		var debsigVerifier pgp.Verifier
+		if requireDebsig || repo.RequireDebsig {
+			debsigVerifier, err = getDebsigVerifier()
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+			}
+			if debsigVerifier == nil {
+				return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil},
+					fmt.Errorf("embedded .deb signatures are required, but debsigKeyrings are not configured")
+			}
+		}
+
 		var (
 			packageFiles, failedFiles    []string
 			otherFiles                   []string
@@ -643,14 +783,24 @@ func apiReposPackageFromDir(c *gin.Context) {
 		}
 
 		processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
-			taskCollectionFactory.PackageCollection(), reporter, nil, taskCollectionFactory.ChecksumCollection)
+			taskCollectionFactory.PackageCollection(), reporter, nil, repo.VersionPolicy, repo.LintPolicy, debsigVerifier, taskCollectionFactory.ChecksumCollection)
 		failedFiles = append(failedFiles, failedFiles2...)
-		processedFiles = append(processedFiles, otherFiles...)
 
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to import package files: %s", err)
 		}
 
+		var processedFiles2 []string
+		processedFiles2, failedFiles2, err = deb.ImportBuildinfoFiles(list, repo.RefList(), otherFiles, verifier, context.PackagePool(),
+			taskCollectionFactory.PackageCollection(), reporter, taskCollectionFactory.ChecksumCollection)
+		processedFiles = append(processedFiles, processedFiles2...)
+		failedFiles = append(failedFiles, failedFiles2...)
+
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to import .buildinfo files: %s", err)
+		}
+
+		before := repo.RefList()
 		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 		err = taskCollection.Update(repo)
@@ -658,6 +808,11 @@ func apiReposPackageFromDir(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
 		}
 
+		err = recordPackageHistory(taskCollectionFactory, before, repo.RefList(), deb.PackageEventAdd, "upload "+dirParam, repo.Name, actor)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
 		if !noRemove {
 			processedFiles = utils.StrSliceDeduplicate(processedFiles)
 
@@ -714,6 +869,7 @@ type reposCopyPackageParams struct {
 // @Success 200 {object} task.ProcessReturnValue "msg"
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Not Found"
+// @Failure 409 {object} Error "Rejected by version policy of destination repo"
 // @Failure 422 {object} Error "Unprocessable Entity"
 // @Failure 500 {object} Error "Internal Server Error"
 // @Router /api/repos/{name}/copy/{src}/{file} [post]
@@ -755,6 +911,7 @@ func apiReposCopyPackage(c *gin.Context) {
 
 	taskName := fmt.Sprintf("Copy packages from repo %s to repo %s", srcRepoName, dstRepoName)
 	resources := []string{string(dstRepo.Key()), string(srcRepo.Key())}
+	actor := historyActor(c)
 
 	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
 		// Task: Create fresh factory and collections inside task after lock
@@ -843,6 +1000,14 @@ func apiReposCopyPackage(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("no package found for filter: '%s'", fileName)
 		}
 
+		// check against the contents of the repo before the packages are added
+		err = toProcess.ForEach(func(p *deb.Package) error {
+			return dstRepo.VersionPolicy.Check(dstList, p)
+		})
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, err
+		}
+
 		err = toProcess.ForEach(func(p *deb.Package) error {
 			err = dstList.Add(p)
 			if err != nil {
@@ -860,12 +1025,18 @@ func apiReposCopyPackage(c *gin.Context) {
 		if jsonBody.DryRun {
 			reporter.Warning("Changes not saved, as dry run has been requested")
 		} else {
+			before := dstRepo.RefList()
 			dstRepo.UpdateRefList(deb.NewPackageRefListFromPackageList(dstList))
 
 			err = taskCollectionFactory.LocalRepoCollection().Update(dstRepo)
 			if err != nil {
 				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
 			}
+
+			err = recordPackageHistory(taskCollectionFactory, before, dstRepo.RefList(), deb.PackageEventCopy, srcRepo.Name, dstRepo.Name, actor)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
 		}
 
 		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
@@ -874,6 +1045,143 @@ func apiReposCopyPackage(c *gin.Context) {
 	})
 }
 
+type reposCleanupParams struct {
+	// Number of newest versions of each package (per architecture) to keep
+	Keep int `binding:"required" json:"Keep"      example:"3"`
+	// Remove only versions added to the repository longer ago than this age, e.g. 72h, 30d, 4w (optional)
+	OlderThan string `          json:"OlderThan" example:"30d"`
+	// Don't remove anything, just return decisions
+	DryRun bool `              json:"DryRun"`
+}
+
+// @Summary Cleanup Repository
+// @Description **Remove old versions of packages from local repository**
+// @Description
+// @Description All but `Keep` newest versions of each package (per architecture) are removed. With `OlderThan`
+// @Description only versions added to the repository longer ago than specified age are removed.
+// @Description Versions referenced by protected or published snapshots are never removed.
+// @Description
+// @Description Response contains decisions for versions beyond `Keep` newest ones.
+// @Tags Repos
+// @Param name path string true "Repository name"
+// @Consume json
+// @Param request body reposCleanupParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {array} deb.LocalRepoCleanupDecision "Decisions for package versions"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/repos/{name}/cleanup [post]
+func apiReposCleanup(c *gin.Context) {
+	var b reposCleanupParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if b.Keep < 1 {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("number of versions to keep should be positive"))
+		return
+	}
+
+	var age time.Duration
+	if b.OlderThan != "" {
+		var err error
+		age, err = deb.ParseRetentionAge(b.OlderThan)
+		if err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	// Load shallowly for 404 check and resource key.
+	// Full load and mutations happen inside the task.
+	collectionFactory := context.NewCollectionFactory()
+
+	name := c.Params.ByName("name")
+	repo, err := collectionFactory.LocalRepoCollection().ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	resources := []string{string(repo.Key())}
+	taskName := fmt.Sprintf("Cleanup repository %s", name)
+	actor := historyActor(c)
+
+	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		// Task: Create fresh factory and collection inside task after lock
+		taskCollectionFactory := context.NewCollectionFactory()
+		taskCollection := taskCollectionFactory.LocalRepoCollection()
+
+		repo, err := taskCollection.ByName(name)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, err
+		}
+
+		err = taskCollection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		options := deb.LocalRepoCleanupOptions{
+			Keep: b.Keep,
+			Repo: repo,
+		}
+		if age > 0 {
+			options.OlderThan = time.Now().Add(-age)
+		}
+
+		options.Pinned, err = taskCollectionFactory.SnapshotCollection().PinnedPackageRefs(taskCollectionFactory.PublishedRepoCollection())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		list, err := deb.NewPackageListFromRefList(repo.RefList(), taskCollectionFactory.PackageCollection(), nil)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		decisions, err := deb.PlanLocalRepoCleanup(list, options)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		removed := 0
+		for _, decision := range decisions {
+			if decision.Remove {
+				list.Remove(decision.Package())
+				removed++
+				if !b.DryRun {
+					out.Printf("Package %s removed\n", decision.Name)
+				}
+			}
+		}
+
+		if removed > 0 && !b.DryRun {
+			before := repo.RefList()
+			repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
+
+			err = taskCollection.Update(repo)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+			}
+
+			err = recordPackageHistory(taskCollectionFactory, before, repo.RefList(), deb.PackageEventRemove, "", repo.Name, actor)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+		}
+
+		if decisions == nil {
+			decisions = []*deb.LocalRepoCleanupDecision{}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: decisions}, nil
+	})
+}
+
 // @Summary Include File from Directory
 // @Description Allows automatic processing of .changes file controlling package upload (uploaded using File Upload API) to the local repository. i.e. Exposes repo include command in api.
 // @Tags Repos
@@ -963,6 +1271,7 @@ func apiReposIncludePackageFromDir(c *gin.Context) {
 		resources = append(resources, string(repo.Key()))
 	}
 	resources = append(resources, sources...)
+	actor := historyActor(c)
 
 	maybeRunTaskInBackground(c, taskName, resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
 		// Task: Create fresh factory and collection inside task after lock
@@ -980,11 +1289,17 @@ func apiReposIncludePackageFromDir(c *gin.Context) {
 			}
 		)
 
+		debsigVerifier, err := getDebsigVerifier()
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+		}
+
 		changesFiles, failedFiles = deb.CollectChangesFiles(sources, reporter)
 		_, failedFiles2, err = deb.ImportChangesFiles(
-			changesFiles, reporter, acceptUnsigned, ignoreSignature, forceReplace, noRemoveFiles, verifier,
+			changesFiles, reporter, acceptUnsigned, ignoreSignature, forceReplace, noRemoveFiles, verifier, debsigVerifier,
 			repoTemplate, context.Progress(), taskCollectionFactory.LocalRepoCollection(), taskCollectionFactory.PackageCollection(),
-			context.PackagePool(), taskCollectionFactory.ChecksumCollection, nil, query.Parse)
+			context.PackagePool(), taskCollectionFactory.ChecksumCollection, nil, query.Parse,
+			taskCollectionFactory.PackageHistoryCollection(), actor)
 		failedFiles = append(failedFiles, failedFiles2...)
 
 		if err != nil {
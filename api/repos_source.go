@@ -0,0 +1,358 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+)
+
+type reposSourceParams struct {
+	// Version of the source package, all versions are processed if empty
+	Version string `json:"Version" example:"0.1.12"`
+	// Also copy or move dependencies of the source package and its binaries
+	WithDeps bool `json:"WithDeps"`
+	// Don't change repositories, just report packages which would be processed
+	DryRun bool `json:"DryRun"`
+}
+
+// @Summary Remove Source Package
+// @Description **Remove source package and all the binary packages built from it from local repository**
+// @Description
+// @Description Binary packages (including -dbgsym packages and udebs) are matched by their Source field.
+// @Description If `Version` is not specified, all versions of the source package are removed.
+// @Tags Repos
+// @Param name path string true "Repository name"
+// @Param source path string true "Source package name"
+// @Consume json
+// @Param request body reposSourceParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} task.ProcessReturnValue "msg"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 422 {object} Error "Source package not found in repository"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/repos/{name}/remove-source/{source} [post]
+func apiReposRemoveSource(c *gin.Context) {
+	var b reposSourceParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	name := c.Params.ByName("name")
+	source := c.Params.ByName("source")
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.LocalRepoCollection().ByName(name)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusNotFound, err)
+		return
+	}
+
+	resources := []string{string(repo.Key())}
+	taskName := fmt.Sprintf("Remove source package %s from repo %s", source, name)
+	actor := historyActor(c)
+
+	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		// Task: Create fresh factory and collection inside task after lock
+		taskCollectionFactory := context.NewCollectionFactory()
+		taskCollection := taskCollectionFactory.LocalRepoCollection()
+
+		repo, err := taskCollection.ByName(name)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, err
+		}
+
+		err = taskCollection.LoadComplete(repo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		list, err := deb.NewPackageListFromRefList(repo.RefList(), taskCollectionFactory.PackageCollection(), nil)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		list.PrepareIndex()
+		toRemove, err := list.Filter(deb.FilterOptions{Queries: []deb.PackageQuery{deb.SourcePackageQuery(source, b.Version)}})
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to remove: %s", err)
+		}
+
+		if toRemove.Len() == 0 {
+			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("source package %s not found in repo %s", source, name)
+		}
+
+		reporter := &aptly.RecordingResultReporter{
+			Warnings:     []string{},
+			AddedLines:   []string{},
+			RemovedLines: []string{},
+		}
+
+		_ = toRemove.ForEach(func(p *deb.Package) error {
+			list.Remove(p)
+			reporter.Removed("%s removed", p)
+			return nil
+		})
+
+		if b.DryRun {
+			reporter.Warning("Changes not saved, as dry run has been requested")
+		} else {
+			before := repo.RefList()
+			repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
+
+			err = taskCollection.Update(repo)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+			}
+
+			err = recordPackageHistory(taskCollectionFactory, before, repo.RefList(), deb.PackageEventRemove, "", repo.Name, actor)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
+			"Report": reporter,
+		}}, nil
+	})
+}
+
+// @Summary Copy Source Package
+// @Description **Copy source package and all the binary packages built from it from source to destination repository**
+// @Description
+// @Description Binary packages (including -dbgsym packages and udebs) are matched by their Source field.
+// @Description If `Version` is not specified, all versions of the source package are copied.
+// @Tags Repos
+// @Param name path string true "Destination repo"
+// @Param src path string true "Source repo"
+// @Param source path string true "Source package name"
+// @Consume json
+// @Param request body reposSourceParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} task.ProcessReturnValue "msg"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 409 {object} Error "Rejected by version policy of destination repo"
+// @Failure 422 {object} Error "Source package not found in repository"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/repos/{name}/copy-source/{src}/{source} [post]
+func apiReposCopySource(c *gin.Context) {
+	apiReposMoveCopySource(c, "copy")
+}
+
+// @Summary Move Source Package
+// @Description **Move source package and all the binary packages built from it from source to destination repository**
+// @Description
+// @Description Binary packages (including -dbgsym packages and udebs) are matched by their Source field.
+// @Description If `Version` is not specified, all versions of the source package are moved.
+// @Tags Repos
+// @Param name path string true "Destination repo"
+// @Param src path string true "Source repo"
+// @Param source path string true "Source package name"
+// @Consume json
+// @Param request body reposSourceParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {object} task.ProcessReturnValue "msg"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 409 {object} Error "Rejected by version policy of destination repo"
+// @Failure 422 {object} Error "Source package not found in repository"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/repos/{name}/move-source/{src}/{source} [post]
+func apiReposMoveSource(c *gin.Context) {
+	apiReposMoveCopySource(c, "move")
+}
+
+// Handler for both copy-source and move-source
+func apiReposMoveCopySource(c *gin.Context, command string) {
+	var b reposSourceParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	dstRepoName := c.Params.ByName("name")
+	srcRepoName := c.Params.ByName("src")
+	source := c.Params.ByName("source")
+
+	collectionFactory := context.NewCollectionFactory()
+	dstRepo, err := collectionFactory.LocalRepoCollection().ByName(dstRepoName)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("dest repo error: %s", err))
+		return
+	}
+
+	srcRepo, err := collectionFactory.LocalRepoCollection().ByName(srcRepoName)
+	if err != nil {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("src repo error: %s", err))
+		return
+	}
+
+	if srcRepo.UUID == dstRepo.UUID {
+		AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("dest and source are identical"))
+		return
+	}
+
+	verb := "Copy"
+	if command == "move" {
+		verb = "Move"
+	}
+
+	taskName := fmt.Sprintf("%s source package %s from repo %s to repo %s", verb, source, srcRepoName, dstRepoName)
+	resources := []string{string(dstRepo.Key()), string(srcRepo.Key())}
+	actor := historyActor(c)
+
+	maybeRunTaskInBackground(c, taskName, resources, func(_ aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		// Task: Create fresh factory and collections inside task after lock
+		taskCollectionFactory := context.NewCollectionFactory()
+		taskCollection := taskCollectionFactory.LocalRepoCollection()
+
+		dstRepo, err := taskCollection.ByName(dstRepoName)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("dest repo error: %s", err)
+		}
+
+		srcRepo, err := taskCollection.ByName(srcRepoName)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("src repo error: %s", err)
+		}
+
+		err = taskCollection.LoadComplete(dstRepo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("dest repo error: %s", err)
+		}
+
+		err = taskCollection.LoadComplete(srcRepo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("src repo error: %s", err)
+		}
+
+		dstList, err := deb.NewPackageListFromRefList(dstRepo.RefList(), taskCollectionFactory.PackageCollection(), nil)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages in dest: %s", err)
+		}
+
+		srcList, err := deb.NewPackageListFromRefList(srcRepo.RefList(), taskCollectionFactory.PackageCollection(), nil)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to load packages in src: %s", err)
+		}
+
+		srcList.PrepareIndex()
+
+		var architecturesList []string
+
+		if b.WithDeps {
+			dstList.PrepareIndex()
+
+			// Calculate architectures
+			if len(context.ArchitecturesList()) > 0 {
+				architecturesList = context.ArchitecturesList()
+			} else {
+				architecturesList = dstList.Architectures(false)
+			}
+
+			sort.Strings(architecturesList)
+
+			if len(architecturesList) == 0 {
+				return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("unable to determine list of architectures, please specify explicitly")
+			}
+		}
+
+		toProcess, err := srcList.Filter(deb.FilterOptions{
+			Queries:           []deb.PackageQuery{deb.SourcePackageQuery(source, b.Version)},
+			WithDependencies:  b.WithDeps,
+			Source:            dstList,
+			DependencyOptions: context.DependencyOptions(),
+			Architectures:     architecturesList,
+		})
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("filter error: %s", err)
+		}
+
+		if toProcess.Len() == 0 {
+			return &task.ProcessReturnValue{Code: http.StatusUnprocessableEntity, Value: nil}, fmt.Errorf("source package %s not found in repo %s", source, srcRepoName)
+		}
+
+		// check against the contents of the repo before the packages are added
+		err = toProcess.ForEach(func(p *deb.Package) error {
+			return dstRepo.VersionPolicy.Check(dstList, p)
+		})
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, err
+		}
+
+		reporter := &aptly.RecordingResultReporter{
+			Warnings:     []string{},
+			AddedLines:   []string{},
+			RemovedLines: []string{},
+		}
+
+		err = toProcess.ForEach(func(p *deb.Package) error {
+			err = dstList.Add(p)
+			if err != nil {
+				return err
+			}
+
+			if command == "move" {
+				srcList.Remove(p)
+				reporter.Removed("%s removed from %s", p, srcRepo.Name)
+			}
+			reporter.Added("%s added to %s", p, dstRepo.Name)
+			return nil
+		})
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("error processing dest add: %s", err)
+		}
+
+		if b.DryRun {
+			reporter.Warning("Changes not saved, as dry run has been requested")
+			return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
+				"Report": reporter,
+			}}, nil
+		}
+
+		action := deb.PackageEventCopy
+		if command == "move" {
+			action = deb.PackageEventMove
+		}
+
+		before := dstRepo.RefList()
+		dstRepo.UpdateRefList(deb.NewPackageRefListFromPackageList(dstList))
+
+		err = taskCollection.Update(dstRepo)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+		}
+
+		err = recordPackageHistory(taskCollectionFactory, before, dstRepo.RefList(), action, srcRepo.Name, dstRepo.Name, actor)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		if command == "move" {
+			before = srcRepo.RefList()
+			srcRepo.UpdateRefList(deb.NewPackageRefListFromPackageList(srcList))
+
+			err = taskCollection.Update(srcRepo)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to save: %s", err)
+			}
+
+			err = recordPackageHistory(taskCollectionFactory, before, srcRepo.RefList(), deb.PackageEventRemove, "", srcRepo.Name, actor)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+			}
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: gin.H{
+			"Report": reporter,
+		}}, nil
+	})
+}
@@ -3,8 +3,13 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/utils"
 	"github.com/gin-gonic/gin"
 	. "gopkg.in/check.v1"
 )
@@ -61,3 +66,238 @@ func (s *ReposSuite) TestGetReposReturns500OnCorruptRefList(c *C) {
 	c.Assert(response.Code, Equals, 500)
 	c.Assert(response.Body.String(), Matches, ".*msgpack.*|.*decode.*")
 }
+
+func (s *ReposSuite) TestVersionPolicy(c *C) {
+	packages := s.context.NewCollectionFactory().PackageCollection()
+	older := &deb.Package{Name: "libpolicy", Version: "1.0", Architecture: "amd64"}
+	newer := &deb.Package{Name: "libpolicy", Version: "2.0", Architecture: "amd64"}
+	c.Assert(packages.Update(older), IsNil)
+	c.Assert(packages.Update(newer), IsNil)
+
+	response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "policy-repo", "VersionPolicy": "bogus"}`)))
+	c.Check(response.Code, Equals, 400)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "policy-repo", "VersionPolicy": "reject-older"}`)))
+	c.Assert(response.Code, Equals, 201)
+	c.Check(response.Body.String(), Matches, `.*"VersionPolicy":"reject-older".*`)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/repos/policy-repo?force=1", nil) }()
+
+	addPackage := func(p *deb.Package) *httptest.ResponseRecorder {
+		body, err := json.Marshal(gin.H{"PackageRefs": []string{string(p.Key(""))}})
+		c.Assert(err, IsNil)
+		response, _ := s.HTTPRequest("POST", "/api/repos/policy-repo/packages", bytes.NewReader(body))
+		return response
+	}
+
+	c.Check(addPackage(newer).Code, Equals, 200)
+
+	response = addPackage(older)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches,
+		`.*libpolicy_1.0_amd64 rejected by version policy: repository already contains newer version libpolicy_2.0_amd64.*`)
+
+	response, _ = s.HTTPRequest("PUT", "/api/repos/policy-repo", bytes.NewReader([]byte(`{"VersionPolicy": "none"}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Not(Matches), `.*VersionPolicy.*`)
+
+	c.Check(addPackage(older).Code, Equals, 200)
+}
+
+func (s *ReposSuite) TestLintPolicy(c *C) {
+	response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "lint-repo", "LintPolicy": {"checks": {"spelling": "error"}}}`)))
+	c.Check(response.Code, Equals, 400)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "lint-repo", "LintPolicy": {"checks": {"filename": "error"}}}`)))
+	c.Assert(response.Code, Equals, 201)
+	c.Check(response.Body.String(), Matches, `.*"LintPolicy":\{"checks":\{"filename":"error"\}\}.*`)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/repos/lint-repo?force=1", nil) }()
+
+	upload := func() *httptest.ResponseRecorder {
+		dir := filepath.Join(s.context.UploadPath(), "lint")
+		c.Assert(os.MkdirAll(dir, 0755), IsNil)
+		c.Assert(utils.CopyFile("../deb/testdata/changes/hardlink_0.2.1_amd64.deb", filepath.Join(dir, "hardlink_0.2.1_i386.deb")), IsNil)
+		response, _ := s.HTTPRequest("POST", "/api/repos/lint-repo/file/lint", nil)
+		return response
+	}
+
+	response = upload()
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches,
+		`.*"FailedFiles":\["[^"]*/hardlink_0.2.1_i386.deb"\].*filename \(error\): architecture i386 in file name doesn't match package architecture amd64.*`)
+
+	response, _ = s.HTTPRequest("PUT", "/api/repos/lint-repo", bytes.NewReader([]byte(`{"LintPolicy": {"checks": {}}}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Not(Matches), `.*LintPolicy.*`)
+
+	response = upload()
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"Added":\["hardlink_0.2.1_amd64 added"\].*`)
+}
+
+func (s *ReposSuite) TestRequireDebsig(c *C) {
+	response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "debsig-repo"}`)))
+	c.Assert(response.Code, Equals, 201)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/repos/debsig-repo?force=1", nil) }()
+
+	upload := func(query string) *httptest.ResponseRecorder {
+		dir := filepath.Join(s.context.UploadPath(), "debsig")
+		c.Assert(os.MkdirAll(dir, 0755), IsNil)
+		c.Assert(utils.CopyFile("../deb/testdata/changes/hardlink_0.2.0_i386.deb", filepath.Join(dir, "hardlink_0.2.0_i386.deb")), IsNil)
+		response, _ := s.HTTPRequest("POST", "/api/repos/debsig-repo/file/debsig"+query, nil)
+		return response
+	}
+
+	// default keyring is never used to verify embedded signatures
+	response = upload("?requireDebsig=1&noRemove=1")
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, `.*debsigKeyrings are not configured.*`)
+
+	keyring, err := filepath.Abs("../system/files/debian-archive-keyring.gpg")
+	c.Assert(err, IsNil)
+	s.context.Config().DebsigKeyrings = []string{keyring}
+	defer func() { s.context.Config().DebsigKeyrings = nil }()
+
+	response = upload("?requireDebsig=1&noRemove=1")
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"FailedFiles":\["[^"]*/hardlink_0.2.0_i386.deb"\].*package has no embedded signatures.*`)
+
+	response, _ = s.HTTPRequest("PUT", "/api/repos/debsig-repo", bytes.NewReader([]byte(`{"RequireDebsig": true}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"RequireDebsig":true.*`)
+
+	response = upload("")
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*package has no embedded signatures.*`)
+
+	response, _ = s.HTTPRequest("PUT", "/api/repos/debsig-repo", bytes.NewReader([]byte(`{"RequireDebsig": false}`)))
+	c.Assert(response.Code, Equals, 200)
+
+	response = upload("")
+	c.Check(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `.*"Added":\["hardlink_0.2.0_i386 added"\].*`)
+}
+
+func (s *ReposSuite) TestCleanup(c *C) {
+	packages := s.context.NewCollectionFactory().PackageCollection()
+	var refs []string
+	for _, version := range []string{"1.0", "1.1", "1.2"} {
+		p := &deb.Package{Name: "libcleanup", Version: version, Architecture: "amd64"}
+		c.Assert(packages.Update(p), IsNil)
+		refs = append(refs, string(p.Key("")))
+	}
+
+	response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "cleanup-repo"}`)))
+	c.Assert(response.Code, Equals, 201)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/repos/cleanup-repo?force=1", nil) }()
+
+	body, err := json.Marshal(gin.H{"PackageRefs": refs})
+	c.Assert(err, IsNil)
+	response, _ = s.HTTPRequest("POST", "/api/repos/cleanup-repo/packages", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/cleanup-repo/cleanup", bytes.NewReader([]byte(`{"Keep": 0}`)))
+	c.Check(response.Code, Equals, 400)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/cleanup-repo/cleanup", bytes.NewReader([]byte(`{"Keep": 1, "OlderThan": "soon"}`)))
+	c.Check(response.Code, Equals, 400)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/missing-repo/cleanup", bytes.NewReader([]byte(`{"Keep": 1}`)))
+	c.Check(response.Code, Equals, 404)
+
+	cleanup := func(request string) []deb.LocalRepoCleanupDecision {
+		response, _ := s.HTTPRequest("POST", "/api/repos/cleanup-repo/cleanup", bytes.NewReader([]byte(request)))
+		c.Assert(response.Code, Equals, 200, Commentf("%s", response.Body.String()))
+
+		var decisions []deb.LocalRepoCleanupDecision
+		c.Assert(json.Unmarshal(response.Body.Bytes(), &decisions), IsNil)
+		return decisions
+	}
+
+	decisions := cleanup(`{"Keep": 1, "DryRun": true}`)
+	c.Assert(decisions, HasLen, 2)
+	c.Check(decisions[0].Name, Equals, "libcleanup_1.1_amd64")
+	c.Check(decisions[0].Remove, Equals, true)
+
+	decisions = cleanup(`{"Keep": 2}`)
+	c.Assert(decisions, HasLen, 1)
+	c.Check(decisions[0].Name, Equals, "libcleanup_1.0_amd64")
+
+	decisions = cleanup(`{"Keep": 2}`)
+	c.Check(decisions, HasLen, 0)
+}
+
+func (s *ReposSuite) TestSourceOperations(c *C) {
+	packages := s.context.NewCollectionFactory().PackageCollection()
+	var refs []string
+	for _, p := range []*deb.Package{
+		{Name: "srcops", Version: "1.0", Architecture: "source", SourceArchitecture: "any", IsSource: true},
+		{Name: "srcops", Version: "1.0", Architecture: "amd64"},
+		{Name: "srcops-dbgsym", Version: "1.0", Architecture: "amd64", Source: "srcops"},
+		{Name: "libsrcops", Version: "1.0+b1", Architecture: "amd64", Source: "srcops (1.0)"},
+		{Name: "srcops", Version: "2.0", Architecture: "source", SourceArchitecture: "any", IsSource: true},
+		{Name: "srcops-other", Version: "1.0", Architecture: "amd64", Source: "other"},
+	} {
+		c.Assert(packages.Update(p), IsNil)
+		refs = append(refs, string(p.Key("")))
+	}
+
+	for _, name := range []string{"srcops-from", "srcops-to"} {
+		response, _ := s.HTTPRequest("POST", "/api/repos", bytes.NewReader([]byte(`{"Name": "`+name+`"}`)))
+		c.Assert(response.Code, Equals, 201)
+		defer func(name string) { _, _ = s.HTTPRequest("DELETE", "/api/repos/"+name+"?force=1", nil) }(name)
+	}
+
+	body, err := json.Marshal(gin.H{"PackageRefs": refs})
+	c.Assert(err, IsNil)
+	response, _ := s.HTTPRequest("POST", "/api/repos/srcops-from/packages", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+
+	repoPackages := func(name string) []string {
+		response, _ := s.HTTPRequest("GET", "/api/repos/"+name+"/packages", nil)
+		c.Assert(response.Code, Equals, 200)
+
+		var result []string
+		c.Assert(json.Unmarshal(response.Body.Bytes(), &result), IsNil)
+		sort.Strings(result)
+		return result
+	}
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/copy-source/srcops-from/missing", bytes.NewReader([]byte(`{}`)))
+	c.Check(response.Code, Equals, 422)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/copy-source/srcops-to/srcops", bytes.NewReader([]byte(`{}`)))
+	c.Check(response.Code, Equals, 400)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/copy-source/srcops-from/srcops", bytes.NewReader([]byte(`{"Version": "1.0", "DryRun": true}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(repoPackages("srcops-to"), HasLen, 0)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/copy-source/srcops-from/srcops", bytes.NewReader([]byte(`{"Version": "1.0"}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(repoPackages("srcops-to"), DeepEquals, []string{
+		"Pamd64 libsrcops 1.0+b1", "Pamd64 srcops 1.0", "Pamd64 srcops-dbgsym 1.0", "Psource srcops 1.0"})
+	c.Check(repoPackages("srcops-from"), HasLen, 6)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/remove-source/srcops", bytes.NewReader([]byte(`{}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(repoPackages("srcops-to"), HasLen, 0)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/remove-source/srcops", bytes.NewReader([]byte(`{}`)))
+	c.Check(response.Code, Equals, 422)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/missing-repo/remove-source/srcops", bytes.NewReader([]byte(`{}`)))
+	c.Check(response.Code, Equals, 404)
+
+	response, _ = s.HTTPRequest("POST", "/api/repos/srcops-to/move-source/srcops-from/srcops", bytes.NewReader([]byte(`{}`)))
+	c.Assert(response.Code, Equals, 200)
+	c.Check(repoPackages("srcops-to"), HasLen, 5)
+	c.Check(repoPackages("srcops-from"), DeepEquals, []string{"Pamd64 srcops-other 1.0"})
+
+	events, err := s.context.NewCollectionFactory().PackageHistoryCollection().ByKey([]byte("Psource srcops 2.0"))
+	c.Assert(err, IsNil)
+	c.Assert(len(events) >= 2, Equals, true)
+	c.Check(events[len(events)-2].Action, Equals, deb.PackageEventMove)
+	c.Check(events[len(events)-2].Source, Equals, "srcops-from")
+	c.Check(events[len(events)-1].Action, Equals, deb.PackageEventRemove)
+	c.Check(events[len(events)-1].Target, Equals, "srcops-from")
+}
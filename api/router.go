@@ -148,6 +148,10 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.POST("/repos/:name/file/:dir/:file", apiReposPackageFromFile)
 		api.POST("/repos/:name/file/:dir", apiReposPackageFromDir)
 		api.POST("/repos/:name/copy/:src/:file", apiReposCopyPackage)
+		api.POST("/repos/:name/copy-source/:src/:source", apiReposCopySource)
+		api.POST("/repos/:name/move-source/:src/:source", apiReposMoveSource)
+		api.POST("/repos/:name/remove-source/:source", apiReposRemoveSource)
+		api.POST("/repos/:name/cleanup", apiReposCleanup)
 
 		api.POST("/repos/:name/include/:dir/:file", apiReposIncludePackageFromFile)
 		api.POST("/repos/:name/include/:dir", apiReposIncludePackageFromDir)
@@ -163,12 +167,23 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.GET("/mirrors", apiMirrorsList)
 		api.GET("/mirrors/:name", apiMirrorsShow)
 		api.GET("/mirrors/:name/packages", apiMirrorsPackages)
+		api.GET("/mirrors/:name/history", apiMirrorsHistory)
+		api.POST("/mirrors/:name/verify", apiMirrorsVerify)
 		api.POST("/mirrors", apiMirrorsCreate)
 		api.POST("/mirrors/:name", apiMirrorsEdit)
 		api.PUT("/mirrors/:name", apiMirrorsUpdate)
 		api.DELETE("/mirrors/:name", apiMirrorsDrop)
 	}
 
+	{
+		api.GET("/schedules", apiSchedulesList)
+		api.POST("/schedules", apiSchedulesCreate)
+		api.GET("/schedules/:name", apiSchedulesShow)
+		api.PUT("/schedules/:name", apiSchedulesEdit)
+		api.DELETE("/schedules/:name", apiSchedulesDrop)
+		api.POST("/schedules/:name/run", apiSchedulesRun)
+	}
+
 	{
 		api.GET("/gpg/keys", apiGPGListKeys)
 		api.POST("/gpg/key", apiGPGAddKey)
@@ -190,6 +205,10 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.DELETE("/files/:dir/:name", apiFilesDeleteFile)
 	}
 
+	{
+		api.PUT("/upload/:queue/:file", apiUploadFile)
+	}
+
 	{
 		api.GET("/publish", apiPublishList)
 		api.GET("/publish/:prefix/:distribution", apiPublishShow)
@@ -204,14 +223,20 @@ func Router(c *ctx.AptlyContext) http.Handler {
 		api.PUT("/publish/:prefix/:distribution/sources/:component", apiPublishUpdateSource)
 		api.DELETE("/publish/:prefix/:distribution/sources/:component", apiPublishRemoveSource)
 		api.POST("/publish/:prefix/:distribution/update", apiPublishUpdate)
+		api.PUT("/publish/:prefix/:distribution/protection", apiPublishProtection)
+		api.GET("/publish/:prefix/:distribution/sbom", apiPublishSBOM)
 	}
 
 	{
 		api.GET("/snapshots", apiSnapshotsList)
 		api.POST("/snapshots", apiSnapshotsCreate)
+		api.POST("/snapshots/prune", apiSnapshotsPrune)
+		api.POST("/snapshots/lockfile", apiSnapshotsCreateFromLockfile)
 		api.PUT("/snapshots/:name", apiSnapshotsUpdate)
 		api.GET("/snapshots/:name", apiSnapshotsShow)
 		api.GET("/snapshots/:name/packages", apiSnapshotsSearchPackages)
+		api.GET("/snapshots/:name/lockfile", apiSnapshotsLockfile)
+		api.GET("/snapshots/:name/sbom", apiSnapshotsSBOM)
 		api.DELETE("/snapshots/:name", apiSnapshotsDrop)
 		api.GET("/snapshots/:name/diff/:withSnapshot", apiSnapshotsDiff)
 		api.POST("/snapshots/:name/merge", apiSnapshotsMerge)
@@ -220,6 +245,7 @@ func Router(c *ctx.AptlyContext) http.Handler {
 
 	{
 		api.GET("/packages/:key", apiPackagesShow)
+		api.GET("/packages/:key/history", apiPackagesHistory)
 		api.GET("/packages", apiPackages)
 	}
 
@@ -0,0 +1,652 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+type scheduleResponse struct {
+	*deb.MirrorSchedule
+	// Next time schedule would be triggered
+	NextRun *time.Time `json:"NextRun,omitempty"`
+}
+
+func newScheduleResponse(schedule *deb.MirrorSchedule) scheduleResponse {
+	response := scheduleResponse{MirrorSchedule: schedule}
+	if next := schedule.NextRun(time.Now()); !next.IsZero() {
+		response.NextRun = &next
+	}
+	return response
+}
+
+type scheduleParams struct {
+	// Name of the schedule
+	Name string `                       json:"Name"                 example:"nightly-buster"`
+	// Name of the mirror to update
+	Mirror string `                     json:"Mirror"               example:"buster-main"`
+	// Cron expression (minute hour day-of-month month day-of-week)
+	Cron string `                       json:"Cron"                 example:"30 2 * * *"`
+	// Set "true" to stop triggering the schedule automatically
+	Disabled *bool `                    json:"Disabled"`
+	// Gpg keyring(s) for verifying Release file
+	Keyrings *[]string `                json:"Keyrings"             example:"trustedkeys.gpg"`
+	// Set "true" to skip the verification of Release file signatures
+	IgnoreSignatures *bool `            json:"IgnoreSignatures"`
+	// Set "true" to ignore checksum errors
+	IgnoreChecksums *bool `             json:"IgnoreChecksums"`
+	// Set "true" to skip downloading already downloaded packages
+	SkipExistingPackages *bool `        json:"SkipExistingPackages"`
+	// Set "true" to download only the latest version per package/architecture
+	LatestOnly *bool `                  json:"LatestOnly"`
+	// Snapshot name template ({mirror}, {schedule}, {date}, {time}, {timestamp}), empty to skip snapshot creation;
+	// template should contain {timestamp}, or {date} (and {time} if schedule runs more than once a day)
+	SnapshotName *string `              json:"SnapshotName"         example:"{mirror}-{date}"`
+	// Description of created snapshots
+	SnapshotDescription *string `       json:"SnapshotDescription"`
+	// Prefix of published repository to switch to the new snapshot
+	PublishPrefix *string `             json:"PublishPrefix"        example:"s3:debian"`
+	// Distribution of published repository to switch to the new snapshot, empty to skip publishing
+	PublishDistribution *string `       json:"PublishDistribution"  example:"buster"`
+	// Component of published repository to switch, required if published repository has several components
+	PublishComponent *string `          json:"PublishComponent"     example:"main"`
+}
+
+func (b *scheduleParams) apply(schedule *deb.MirrorSchedule) {
+	if b.Mirror != "" {
+		schedule.Mirror = b.Mirror
+	}
+	if b.Cron != "" {
+		schedule.Cron = b.Cron
+	}
+	if b.Disabled != nil {
+		schedule.Disabled = *b.Disabled
+	}
+	if b.Keyrings != nil {
+		schedule.Keyrings = *b.Keyrings
+	}
+	if b.IgnoreSignatures != nil {
+		schedule.IgnoreSignatures = *b.IgnoreSignatures
+	}
+	if b.IgnoreChecksums != nil {
+		schedule.IgnoreChecksums = *b.IgnoreChecksums
+	}
+	if b.SkipExistingPackages != nil {
+		schedule.SkipExistingPackages = *b.SkipExistingPackages
+	}
+	if b.LatestOnly != nil {
+		schedule.LatestOnly = *b.LatestOnly
+	}
+	if b.SnapshotName != nil {
+		schedule.SnapshotName = *b.SnapshotName
+	}
+	if b.SnapshotDescription != nil {
+		schedule.SnapshotDescription = *b.SnapshotDescription
+	}
+	if b.PublishPrefix != nil {
+		schedule.PublishPrefix = *b.PublishPrefix
+	}
+	if b.PublishDistribution != nil {
+		schedule.PublishDistribution = *b.PublishDistribution
+	}
+	if b.PublishComponent != nil {
+		schedule.PublishComponent = *b.PublishComponent
+	}
+}
+
+// @Summary List Schedules
+// @Description **Show list of mirror update schedules**
+// @Tags Schedules
+// @Produce json
+// @Success 200 {array} scheduleResponse
+// @Router /api/schedules [get]
+func apiSchedulesList(c *gin.Context) {
+	collection := context.NewCollectionFactory().MirrorScheduleCollection()
+
+	result := []scheduleResponse{}
+	err := collection.ForEach(func(schedule *deb.MirrorSchedule) error {
+		result = append(result, newScheduleResponse(schedule))
+		return nil
+	})
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+
+	c.JSON(200, result)
+}
+
+// @Summary Create Schedule
+// @Description **Create mirror update schedule**
+// @Description
+// @Description Schedule periodically updates the mirror, optionally creates a snapshot and switches
+// @Description published repository to it. Each run is executed as a task.
+// @Description Snapshot name template should expand to a new name on each run: it should contain {timestamp},
+// @Description or {date} (plus {time} if schedule runs more than once a day). Run fails before updating
+// @Description the mirror if snapshot with expanded name already exists.
+// @Tags Schedules
+// @Consume json
+// @Param request body scheduleParams true "Parameters"
+// @Produce json
+// @Success 201 {object} scheduleResponse
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Mirror not found"
+// @Router /api/schedules [post]
+func apiSchedulesCreate(c *gin.Context) {
+	var b scheduleParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if b.Name == "" {
+		AbortWithJSONError(c, 400, fmt.Errorf("schedule name is required"))
+		return
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.MirrorScheduleCollection()
+
+	schedule, err := deb.NewMirrorSchedule(b.Name, b.Mirror, b.Cron)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create schedule: %s", err))
+		return
+	}
+	b.apply(schedule)
+
+	err = schedule.Validate()
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create schedule: %s", err))
+		return
+	}
+
+	_, err = collectionFactory.RemoteRepoCollection().ByName(schedule.Mirror)
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to create schedule: %s", err))
+		return
+	}
+
+	err = collection.Add(schedule)
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to create schedule: %s", err))
+		return
+	}
+
+	c.JSON(201, newScheduleResponse(schedule))
+}
+
+// @Summary Get Schedule
+// @Description **Get mirror update schedule by name**
+// @Tags Schedules
+// @Param name path string true "schedule name"
+// @Produce json
+// @Success 200 {object} scheduleResponse
+// @Failure 404 {object} Error "Schedule not found"
+// @Router /api/schedules/{name} [get]
+func apiSchedulesShow(c *gin.Context) {
+	collection := context.NewCollectionFactory().MirrorScheduleCollection()
+
+	schedule, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	c.JSON(200, newScheduleResponse(schedule))
+}
+
+// @Summary Edit Schedule
+// @Description **Edit mirror update schedule**
+// @Tags Schedules
+// @Param name path string true "schedule name"
+// @Consume json
+// @Param request body scheduleParams true "Parameters"
+// @Produce json
+// @Success 200 {object} scheduleResponse
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 404 {object} Error "Schedule or mirror not found"
+// @Router /api/schedules/{name} [put]
+func apiSchedulesEdit(c *gin.Context) {
+	var b scheduleParams
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.MirrorScheduleCollection()
+
+	schedule, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if b.Name != "" && b.Name != schedule.Name {
+		_, err = collection.ByName(b.Name)
+		if err == nil {
+			AbortWithJSONError(c, 409, fmt.Errorf("unable to rename: schedule %s already exists", b.Name))
+			return
+		}
+		schedule.Name = b.Name
+	}
+
+	b.apply(schedule)
+
+	err = schedule.Validate()
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to edit: %s", err))
+		return
+	}
+
+	_, err = collectionFactory.RemoteRepoCollection().ByName(schedule.Mirror)
+	if err != nil {
+		AbortWithJSONError(c, 404, fmt.Errorf("unable to edit: %s", err))
+		return
+	}
+
+	err = collection.Update(schedule)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to edit: %s", err))
+		return
+	}
+
+	c.JSON(200, newScheduleResponse(schedule))
+}
+
+// @Summary Delete Schedule
+// @Description **Delete mirror update schedule**
+// @Description
+// @Description Tasks already started by the schedule are not affected.
+// @Tags Schedules
+// @Param name path string true "schedule name"
+// @Produce json
+// @Success 200 ""
+// @Failure 404 {object} Error "Schedule not found"
+// @Router /api/schedules/{name} [delete]
+func apiSchedulesDrop(c *gin.Context) {
+	collection := context.NewCollectionFactory().MirrorScheduleCollection()
+
+	schedule, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	err = collection.Drop(schedule)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to drop: %s", err))
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// @Summary Run Schedule
+// @Description **Trigger mirror update schedule immediately**
+// @Description
+// @Description Returns the task which was started, schedule run could be followed with tasks API.
+// @Tags Schedules
+// @Param name path string true "schedule name"
+// @Produce json
+// @Success 202 {object} task.Task
+// @Failure 404 {object} Error "Schedule or mirror not found"
+// @Failure 409 {object} Error "Mirror or published repository is busy"
+// @Router /api/schedules/{name}/run [post]
+func apiSchedulesRun(c *gin.Context) {
+	collection := context.NewCollectionFactory().MirrorScheduleCollection()
+
+	schedule, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	t, err := runSchedule(schedule, time.Now())
+	if err != nil {
+		if _, ok := err.(*task.ResourceConflictError); ok {
+			AbortWithJSONError(c, 409, err)
+		} else {
+			AbortWithJSONError(c, 404, err)
+		}
+		return
+	}
+
+	c.JSON(202, t)
+}
+
+type scheduleRunResult struct {
+	// Name of the updated mirror
+	Mirror string
+	// Name of the snapshot created, if any
+	Snapshot string `json:",omitempty"`
+	// Published repository switched to the snapshot, if any
+	Published *deb.PublishedRepo `json:",omitempty"`
+}
+
+// runSchedule starts task executing the schedule and records the run in the schedule
+func runSchedule(schedule *deb.MirrorSchedule, now time.Time) (task.Task, error) {
+	collectionFactory := context.NewCollectionFactory()
+
+	mirror, err := collectionFactory.RemoteRepoCollection().ByName(schedule.Mirror)
+	if err != nil {
+		return task.Task{}, fmt.Errorf("unable to run schedule %s: %s", schedule.Name, err)
+	}
+
+	resources := []string{string(mirror.Key())}
+
+	if schedule.PublishDistribution != "" {
+		published, err := schedulePublishedRepo(collectionFactory, schedule)
+		if err != nil {
+			return task.Task{}, fmt.Errorf("unable to run schedule %s: %s", schedule.Name, err)
+		}
+
+		resources = append(resources, string(published.Key()))
+		if !published.MultiDist {
+			resources = append(resources, deb.PrefixPoolLockKey(published.StoragePrefix()))
+		}
+	}
+
+	scheduleName := schedule.Name
+	snapshotName := schedule.ExpandSnapshotName(now)
+
+	taskName := fmt.Sprintf("Scheduled update of mirror %s (schedule %s)", schedule.Mirror, schedule.Name)
+	t, conflictErr := runTaskInBackground(taskName, resources, func(out aptly.Progress, detail *task.Detail) (*task.ProcessReturnValue, error) {
+		return scheduleExecute(out, detail, scheduleName, snapshotName)
+	})
+	if conflictErr != nil {
+		return task.Task{}, conflictErr
+	}
+
+	schedule.LastRun = now
+	schedule.LastTaskID = t.ID
+	err = collectionFactory.MirrorScheduleCollection().Update(schedule)
+	if err != nil {
+		log.Error().Msgf("unable to save schedule %s: %s", schedule.Name, err)
+	}
+
+	return t, nil
+}
+
+// scheduleExecute updates mirror, creates snapshot and switches published repository
+// as configured in the schedule
+func scheduleExecute(out aptly.Progress, detail *task.Detail, scheduleName, snapshotName string) (*task.ProcessReturnValue, error) {
+	collectionFactory := context.NewCollectionFactory()
+	scheduleCollection := collectionFactory.MirrorScheduleCollection()
+
+	schedule, err := scheduleCollection.ByName(scheduleName)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, err
+	}
+
+	_, err = collectionFactory.RemoteRepoCollection().ByName(schedule.Mirror)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, fmt.Errorf("unable to run schedule %s: %s", schedule.Name, err)
+	}
+
+	ignoreSignatures := schedule.IgnoreSignatures || context.Config().GpgDisableVerify
+
+	verifier, err := getVerifier(schedule.Keyrings)
+	if err != nil {
+		return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	if schedule.SnapshotName != "" {
+		if _, err = collectionFactory.SnapshotCollection().ByName(snapshotName); err == nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to run schedule %s: snapshot %s already exists", schedule.Name, snapshotName)
+		}
+	}
+
+	out.Printf("Updating mirror %s...\n", schedule.Mirror)
+	retValue, err := mirrorUpdate(out, detail, schedule.Mirror, mirrorUpdateParams{
+		Name:                 schedule.Mirror,
+		IgnoreChecksums:      schedule.IgnoreChecksums,
+		IgnoreSignatures:     ignoreSignatures,
+		SkipExistingPackages: schedule.SkipExistingPackages,
+		LatestOnly:           schedule.LatestOnly,
+	}, verifier, "api:schedule "+schedule.Name)
+	if err != nil {
+		return retValue, err
+	}
+
+	result := scheduleRunResult{Mirror: schedule.Mirror}
+
+	if schedule.SnapshotName != "" {
+		out.Printf("Creating snapshot %s...\n", snapshotName)
+
+		mirrorCollection := collectionFactory.RemoteRepoCollection()
+		mirror, err := mirrorCollection.ByName(schedule.Mirror)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		err = mirrorCollection.LoadComplete(mirror)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		snapshot, err := deb.NewSnapshotFromRepository(snapshotName, mirror)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		if schedule.SnapshotDescription != "" {
+			snapshot.Description = schedule.SnapshotDescription
+		}
+
+		err = collectionFactory.SnapshotCollection().Add(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		result.Snapshot = snapshot.Name
+	}
+
+	if schedule.PublishDistribution != "" {
+		out.Printf("Switching published repository %s/%s to snapshot %s...\n",
+			schedule.PublishPrefix, schedule.PublishDistribution, result.Snapshot)
+
+		result.Published, err = schedulePublishSwitch(collectionFactory, schedule, result.Snapshot, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to switch published repository: %s", err)
+		}
+	}
+
+	// reload schedule, as it might have been updated while task was running
+	schedule, err = scheduleCollection.ByName(scheduleName)
+	if err == nil && result.Snapshot != "" {
+		schedule.LastSnapshot = result.Snapshot
+		_ = scheduleCollection.Update(schedule)
+	}
+
+	return &task.ProcessReturnValue{Code: http.StatusOK, Value: result}, nil
+}
+
+func schedulePublishedRepo(collectionFactory *deb.CollectionFactory, schedule *deb.MirrorSchedule) (*deb.PublishedRepo, error) {
+	param := schedule.PublishPrefix
+	if param == "" {
+		param = "."
+	}
+	storage, prefix := deb.ParsePrefix(param)
+
+	published, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, schedule.PublishDistribution)
+	if err != nil {
+		return nil, err
+	}
+
+	if published.SourceKind != deb.SourceSnapshot {
+		return nil, fmt.Errorf("published repository %s is not published from snapshots", published.String())
+	}
+
+	return published, nil
+}
+
+func schedulePublishSwitch(collectionFactory *deb.CollectionFactory, schedule *deb.MirrorSchedule, snapshotName string, out aptly.Progress) (*deb.PublishedRepo, error) {
+	collection := collectionFactory.PublishedRepoCollection()
+
+	published, err := schedulePublishedRepo(collectionFactory, schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	err = collection.LoadComplete(published, collectionFactory)
+	if err != nil {
+		return nil, err
+	}
+
+	component := schedule.PublishComponent
+	if component == "" {
+		components := published.Components()
+		if len(components) != 1 {
+			return nil, fmt.Errorf("published repository has %d components, please specify component", len(components))
+		}
+		component = components[0]
+	}
+
+	revision := published.ObtainRevision()
+	if _, exists := revision.Sources[component]; !exists {
+		return nil, fmt.Errorf("component %s is not published in %s", component, published.String())
+	}
+	revision.Sources[component] = snapshotName
+
+	result, err := published.Update(collectionFactory, out)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := getSigner(&signingParams{Skip: context.Config().GpgDisableSign})
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize GPG signer: %s", err)
+	}
+
+	err = published.Publish(context.PackagePool(), context, collectionFactory, signer, out, false, context.SkelPath())
+	if err != nil {
+		return nil, err
+	}
+
+	err = collection.Update(published)
+	if err != nil {
+		return nil, fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	err = collection.CleanupPrefixComponentFiles(context, published, result.UpdatedComponents(), collectionFactory, out)
+	if err != nil {
+		return nil, err
+	}
+
+	return published, nil
+}
+
+// Scheduler triggers mirror update schedules
+type Scheduler struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartScheduler starts goroutine checking mirror update schedules every minute
+//
+// Router should be set up before starting the scheduler.
+func StartScheduler() *Scheduler {
+	scheduler := &Scheduler{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go scheduler.run()
+
+	return scheduler
+}
+
+// Stop stops the scheduler, tasks already started are not affected
+func (scheduler *Scheduler) Stop() {
+	close(scheduler.stop)
+	<-scheduler.done
+}
+
+func (scheduler *Scheduler) run() {
+	defer close(scheduler.done)
+
+	// last minute schedules were checked for, so that minutes are not skipped
+	// when timer fires late (e.g. after system suspend or under heavy load)
+	last := time.Now().Truncate(time.Minute)
+
+	for {
+		next := last.Add(time.Minute)
+
+		select {
+		case <-time.After(time.Until(next)):
+			current := time.Now().Truncate(time.Minute)
+			if current.Before(next) {
+				current = next
+			}
+			triggerDueSchedules(next, current)
+			last = current
+		case <-scheduler.stop:
+			return
+		}
+	}
+}
+
+// maxScheduleCatchUp limits how many missed minutes are checked after the timer fired late
+const maxScheduleCatchUp = 24 * time.Hour
+
+// dueMinute returns first minute in [from, to] when schedule is due, or zero time
+func dueMinute(schedule *deb.MirrorSchedule, from, to time.Time) time.Time {
+	if to.Sub(from) > maxScheduleCatchUp {
+		from = to.Add(-maxScheduleCatchUp)
+	}
+
+	for t := from; !t.After(to); t = t.Add(time.Minute) {
+		if schedule.IsDue(t) {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// triggerDueSchedules runs schedules which are due in any minute from..to, each schedule
+// is run at most once
+func triggerDueSchedules(from, to time.Time) {
+	err := acquireDatabaseConnection()
+	if err != nil {
+		log.Error().Msgf("scheduler: unable to open database: %s", err)
+		return
+	}
+	defer func() { _ = releaseDatabaseConnection() }()
+
+	var (
+		due     []*deb.MirrorSchedule
+		dueTime []time.Time
+	)
+
+	err = context.NewCollectionFactory().MirrorScheduleCollection().ForEach(func(schedule *deb.MirrorSchedule) error {
+		if t := dueMinute(schedule, from, to); !t.IsZero() {
+			due = append(due, schedule)
+			dueTime = append(dueTime, t)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error().Msgf("scheduler: unable to load schedules: %s", err)
+		return
+	}
+
+	for i, schedule := range due {
+		t, err := runSchedule(schedule, dueTime[i])
+		if err != nil {
+			log.Error().Msgf("scheduler: %s", err)
+			continue
+		}
+		log.Info().Msgf("scheduler: schedule %s started task %d", schedule.Name, t.ID)
+	}
+}
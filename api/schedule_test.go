@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/task"
+	"github.com/gin-gonic/gin"
+	. "gopkg.in/check.v1"
+)
+
+type ScheduleSuite struct {
+	APISuite
+}
+
+var _ = Suite(&ScheduleSuite{})
+
+func (s *ScheduleSuite) TestScheduleLifecycle(c *C) {
+	repo, err := deb.NewRemoteRepo("schedule-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	collection := s.context.NewCollectionFactory().RemoteRepoCollection()
+	c.Assert(collection.Add(repo), IsNil)
+	defer func() { _ = collection.Drop(repo) }()
+
+	body, _ := json.Marshal(gin.H{"Name": "nightly", "Mirror": "no-such-mirror", "Cron": "@daily"})
+	response, err := s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+
+	body, _ = json.Marshal(gin.H{"Name": "nightly", "Mirror": "schedule-mirror", "Cron": "61 * * * *"})
+	response, _ = s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Check(response.Code, Equals, 400)
+
+	body, _ = json.Marshal(gin.H{"Name": "nightly", "Mirror": "schedule-mirror", "Cron": "@daily", "PublishDistribution": "stable"})
+	response, _ = s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*requires snapshot name template.*")
+
+	body, _ = json.Marshal(gin.H{"Name": "nightly", "Mirror": "schedule-mirror", "Cron": "30 2 * * *", "SnapshotName": "{mirror}-{date}"})
+	response, _ = s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 201)
+
+	var schedule map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &schedule), IsNil)
+	c.Check(schedule["Name"], Equals, "nightly")
+	c.Check(schedule["SnapshotName"], Equals, "{mirror}-{date}")
+	c.Check(schedule["NextRun"], NotNil)
+
+	response, _ = s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Check(response.Code, Equals, 400)
+
+	body, _ = json.Marshal(gin.H{"Disabled": true})
+	response, _ = s.HTTPRequest("PUT", "/api/schedules/nightly", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 200)
+	schedule = nil
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &schedule), IsNil)
+	c.Check(schedule["Disabled"], Equals, true)
+	c.Check(schedule["NextRun"], IsNil)
+	c.Check(schedule["Cron"], Equals, "30 2 * * *")
+
+	response, _ = s.HTTPRequest("GET", "/api/schedules", nil)
+	c.Assert(response.Code, Equals, 200)
+	var schedules []map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &schedules), IsNil)
+	c.Check(schedules, HasLen, 1)
+
+	response, _ = s.HTTPRequest("DELETE", "/api/schedules/nightly", nil)
+	c.Check(response.Code, Equals, 200)
+
+	response, _ = s.HTTPRequest("GET", "/api/schedules/nightly", nil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *ScheduleSuite) TestScheduleSnapshotNameTaken(c *C) {
+	collectionFactory := s.context.NewCollectionFactory()
+
+	repo, err := deb.NewRemoteRepo("schedule-taken-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(collectionFactory.RemoteRepoCollection().Add(repo), IsNil)
+	defer func() { _ = collectionFactory.RemoteRepoCollection().Drop(repo) }()
+
+	body, _ := json.Marshal(gin.H{"Name": "taken-hourly", "Mirror": "schedule-taken-mirror", "Cron": "@hourly", "SnapshotName": "{mirror}-{date}"})
+	response, _ := s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*runs more than once a day.*")
+
+	body, _ = json.Marshal(gin.H{"Name": "taken-nightly", "Mirror": "schedule-taken-mirror", "Cron": "@daily", "SnapshotName": "{mirror}-{date}"})
+	response, _ = s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 201)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/schedules/taken-nightly", nil) }()
+
+	// snapshot of the first run today already exists, second run fails before updating the mirror
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := deb.NewSnapshotFromRefList("schedule-taken-mirror-20240301", nil, deb.NewPackageRefList(), "")
+	c.Assert(collectionFactory.SnapshotCollection().Add(snapshot), IsNil)
+	defer func() { _ = collectionFactory.SnapshotCollection().Drop(snapshot) }()
+
+	schedule, err := collectionFactory.MirrorScheduleCollection().ByName("taken-nightly")
+	c.Assert(err, IsNil)
+
+	retValue, err := scheduleExecute(s.context.Progress(), &task.Detail{}, "taken-nightly", schedule.ExpandSnapshotName(now))
+	c.Check(err, ErrorMatches, "unable to run schedule taken-nightly: snapshot schedule-taken-mirror-20240301 already exists")
+	c.Check(retValue.Code, Equals, 409)
+}
+
+func (s *ScheduleSuite) TestScheduleDroppedWithMirror(c *C) {
+	repo, err := deb.NewRemoteRepo("schedule-drop-mirror", "http://example.com/debian", "stable", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.context.NewCollectionFactory().RemoteRepoCollection().Add(repo), IsNil)
+
+	body, _ := json.Marshal(gin.H{"Name": "drop-nightly", "Mirror": "schedule-drop-mirror", "Cron": "@daily"})
+	response, _ := s.HTTPRequest("POST", "/api/schedules", bytes.NewReader(body))
+	c.Assert(response.Code, Equals, 201)
+
+	response, _ = s.HTTPRequest("DELETE", "/api/mirrors/schedule-drop-mirror", nil)
+	c.Assert(response.Code, Equals, 204)
+
+	response, _ = s.HTTPRequest("GET", "/api/schedules/drop-nightly", nil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *ScheduleSuite) TestDueMinute(c *C) {
+	schedule, err := deb.NewMirrorSchedule("hourly", "wheezy-main", "30 * * * *")
+	c.Assert(err, IsNil)
+
+	from := time.Date(2024, 3, 1, 10, 29, 0, 0, time.UTC)
+	c.Check(dueMinute(schedule, from, from), DeepEquals, time.Time{})
+	// timer fired late, minute 10:30 is not skipped
+	c.Check(dueMinute(schedule, from, from.Add(2*time.Minute)), DeepEquals, from.Add(time.Minute))
+
+	schedule.LastRun = from.Add(time.Minute)
+	c.Check(dueMinute(schedule, from, from.Add(2*time.Minute)), DeepEquals, time.Time{})
+}
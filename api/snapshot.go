@@ -5,12 +5,14 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/database"
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/aptly-dev/aptly/query"
 	"github.com/aptly-dev/aptly/task"
+	"github.com/aptly-dev/aptly/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,13 +20,21 @@ import (
 // @Description **Get list of snapshots**
 // @Description
 // @Description Each snapshot is returned as in “show” API.
+// @Description Snapshots could be filtered by labels with one or more `label` query parameters: `key=value`, `key!=value`, `key` or `!key`.
 // @Tags Snapshots
+// @Param label query []string false "Label selector"
 // @Produce  json
 // @Success 200 {array} snapshotResponse
+// @Failure 400 {object} Error "Bad Request"
 // @Router /api/snapshots [get]
 func apiSnapshotsList(c *gin.Context) {
 	SortMethodString := c.Request.URL.Query().Get("sort")
 
+	selector, ok := labelSelector(c)
+	if !ok {
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.SnapshotCollection()
 
@@ -34,6 +44,10 @@ func apiSnapshotsList(c *gin.Context) {
 
 	result := []snapshotResponse{}
 	err := collection.ForEachSorted(SortMethodString, func(snapshot *deb.Snapshot) error {
+		if !selector.Matches(snapshot.Labels) {
+			return nil
+		}
+
 		err := collection.LoadComplete(snapshot)
 		if err != nil {
 			return err
@@ -55,6 +69,8 @@ type snapshotsCreateFromMirrorParams struct {
 	Name string `binding:"required"     json:"Name"                 example:"snap1"`
 	// Description of snapshot
 	Description string `                json:"Description"`
+	// User-defined labels (optional)
+	Labels map[string]string `          json:"Labels"               example:"env:prod"`
 }
 
 // @Summary Snapshot Mirror
@@ -82,6 +98,11 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 		return
 	}
 
+	if err = deb.ValidateLabels(b.Labels); err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	name := c.Params.ByName("name")
 
@@ -122,6 +143,7 @@ func apiSnapshotsCreateFromMirror(c *gin.Context) {
 		if b.Description != "" {
 			snapshot.Description = b.Description
 		}
+		snapshot.Labels = deb.UpdateLabels(nil, b.Labels, nil)
 
 		err = taskSnapshotCollection.Add(snapshot)
 		if err != nil {
@@ -140,6 +162,8 @@ type snapshotsCreateParams struct {
 	SourceSnapshots []string `       json:"SourceSnapshots"      example:"snap1"`
 	// List of package refs
 	PackageRefs []string `           json:"PackageRefs"          example:""`
+	// User-defined labels (optional)
+	Labels map[string]string `       json:"Labels"               example:"env:prod"`
 }
 
 // @Summary Snapshot Packages
@@ -166,6 +190,11 @@ func apiSnapshotsCreate(c *gin.Context) {
 		return
 	}
 
+	if err = deb.ValidateLabels(b.Labels); err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	if b.Description == "" {
 		if len(b.SourceSnapshots)+len(b.PackageRefs) == 0 {
 			b.Description = "Created as empty"
@@ -240,6 +269,7 @@ func apiSnapshotsCreate(c *gin.Context) {
 		}
 
 		snapshot = deb.NewSnapshotFromRefList(b.Name, freshSources, refList, b.Description)
+		snapshot.Labels = deb.UpdateLabels(nil, b.Labels, nil)
 
 		err = taskSnapshotCollection.Add(snapshot)
 		if err != nil {
@@ -254,6 +284,8 @@ type snapshotsCreateFromRepositoryParams struct {
 	Name string `binding:"required"               json:"Name"                 example:"snap1"`
 	// Description of snapshot
 	Description string `                          json:"Description"`
+	// User-defined labels (optional)
+	Labels map[string]string `                    json:"Labels"               example:"env:prod"`
 }
 
 // @Summary Snapshot Repository
@@ -281,6 +313,11 @@ func apiSnapshotsCreateFromRepository(c *gin.Context) {
 		return
 	}
 
+	if err = deb.ValidateLabels(b.Labels); err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	name := c.Params.ByName("name")
 
@@ -316,6 +353,7 @@ func apiSnapshotsCreateFromRepository(c *gin.Context) {
 		if b.Description != "" {
 			snapshot.Description = b.Description
 		}
+		snapshot.Labels = deb.UpdateLabels(nil, b.Labels, nil)
 
 		err = taskSnapshotCollection.Add(snapshot)
 		if err != nil {
@@ -330,10 +368,16 @@ type snapshotsUpdateParams struct {
 	Name string `       json:"Name"  example:"snap2"`
 	// Change Description of snapshot
 	Description string `json:"Description"`
+	// Replace labels of snapshot
+	Labels *map[string]string `json:"Labels" example:"env:prod"`
+	// Protect snapshot from being dropped or renamed
+	Protected *bool `json:"Protected"`
 }
 
 // @Summary Update Snapshot
-// @Description **Update snapshot metadata (Name, Description)**
+// @Description **Update snapshot metadata (Name, Description, Labels, Protected)**
+// @Description
+// @Description Protected snapshot can't be dropped or renamed, it should be unprotected first.
 // @Tags Snapshots
 // @Param request body snapshotsUpdateParams true "Parameters"
 // @Param name path string true "Snapshot name"
@@ -341,7 +385,7 @@ type snapshotsUpdateParams struct {
 // @Produce json
 // @Success 200 {object} deb.Snapshot "Updated snapshot object"
 // @Failure 404 {object} Error "Snapshot Not Found"
-// @Failure 409 {object} Error "Conflicting snapshot"
+// @Failure 409 {object} Error "Conflicting snapshot or snapshot is protected"
 // @Failure 500 {object} Error "Internal Server Error"
 // @Router /api/snapshots/{name} [put]
 func apiSnapshotsUpdate(c *gin.Context) {
@@ -355,6 +399,13 @@ func apiSnapshotsUpdate(c *gin.Context) {
 		return
 	}
 
+	if b.Labels != nil {
+		if err = deb.ValidateLabels(*b.Labels); err != nil {
+			AbortWithJSONError(c, 400, err)
+			return
+		}
+	}
+
 	// Phase 1: Pre-task validation (shallow load for 404 check only)
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.SnapshotCollection()
@@ -389,6 +440,12 @@ func apiSnapshotsUpdate(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 		}
 
+		if b.Name != "" && b.Name != name {
+			if err = snapshot.CheckUnprotected(); err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to rename: %s", err)
+			}
+		}
+
 		// Fresh duplicate check inside lock
 		if b.Name != "" {
 			_, err := taskCollection.ByName(b.Name)
@@ -406,6 +463,14 @@ func apiSnapshotsUpdate(c *gin.Context) {
 			snapshot.Description = b.Description
 		}
 
+		if b.Labels != nil {
+			snapshot.Labels = deb.UpdateLabels(nil, *b.Labels, nil)
+		}
+
+		if b.Protected != nil {
+			snapshot.Protected = *b.Protected
+		}
+
 		err = taskCollection.Update(snapshot)
 		if err != nil {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
@@ -444,7 +509,7 @@ func apiSnapshotsShow(c *gin.Context) {
 
 // @Summary Delete Snapshot
 // @Description **Delete snapshot by name**
-// @Description Cannot drop snapshots that are published.
+// @Description Cannot drop snapshots that are published or protected.
 // @Description Needs force=1 to drop snapshots used as source by other snapshots.
 // @Tags Snapshots
 // @Param name path string true "Snapshot name"
@@ -453,7 +518,7 @@ func apiSnapshotsShow(c *gin.Context) {
 // @Produce json
 // @Success 200 ""
 // @Failure 404 {object} Error "Snapshot Not Found"
-// @Failure 409 {object} Error "Snapshot in use"
+// @Failure 409 {object} Error "Snapshot in use or protected"
 // @Failure 500 {object} Error "Internal Server Error"
 // @Router /api/snapshots/{name} [delete]
 func apiSnapshotsDrop(c *gin.Context) {
@@ -485,6 +550,10 @@ func apiSnapshotsDrop(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
 		}
 
+		if err = snapshot.CheckUnprotected(); err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusConflict, Value: nil}, fmt.Errorf("unable to drop: %s", err)
+		}
+
 		// Fresh checks with current collections
 		published := taskPublishedCollection.BySnapshot(snapshot)
 
@@ -508,16 +577,112 @@ func apiSnapshotsDrop(c *gin.Context) {
 	})
 }
 
+type snapshotsPruneParams struct {
+	// Don't drop anything, just return decisions
+	DryRun bool `json:"DryRun"`
+	// Retention rules to apply instead of rules from configuration
+	Rules []utils.SnapshotRetentionRule `json:"Rules"`
+}
+
+// @Summary Prune Snapshots
+// @Description **Drop snapshots according to retention rules**
+// @Description
+// @Description Rules are taken from `snapshotRetention` configuration, unless specified in the request.
+// @Description Each snapshot is matched by the first rule with matching name pattern, snapshots not matching any rule are never dropped.
+// @Description Snapshots which are protected, published or used as source of other snapshots which are kept are never dropped.
+// @Tags Snapshots
+// @Consume json
+// @Param request body snapshotsPruneParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 200 {array} deb.SnapshotPruneDecision "Decisions for snapshots matching retention rules"
+// @Failure 400 {object} Error "Bad Request"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/prune [post]
+func apiSnapshotsPrune(c *gin.Context) {
+	var b snapshotsPruneParams
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	rules := b.Rules
+	if len(rules) == 0 {
+		rules = context.Config().SnapshotRetention
+	}
+
+	if len(rules) == 0 {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to prune: no retention rules configured"))
+		return
+	}
+
+	// Phase 1: figure out which snapshots are going to be dropped to lock them
+	collectionFactory := context.NewCollectionFactory()
+	decisions, err := collectionFactory.SnapshotCollection().PlanPrune(rules, collectionFactory.PublishedRepoCollection(), time.Now())
+	if err != nil {
+		AbortWithJSONError(c, 400, fmt.Errorf("unable to prune: %s", err))
+		return
+	}
+
+	resources := []string{}
+	locked := map[string]bool{}
+	for _, decision := range decisions {
+		if decision.Prune {
+			resources = append(resources, string(decision.Snapshot().Key()))
+			locked[decision.Snapshot().UUID] = true
+		}
+	}
+
+	maybeRunTaskInBackground(c, "Prune snapshots", resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		// Phase 2: Inside task lock - re-evaluate rules with fresh collections
+		taskCollectionFactory := context.NewCollectionFactory()
+		taskSnapshotCollection := taskCollectionFactory.SnapshotCollection()
+
+		decisions, err := taskSnapshotCollection.PlanPrune(rules, taskCollectionFactory.PublishedRepoCollection(), time.Now())
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to prune: %s", err)
+		}
+
+		for _, decision := range decisions {
+			if !decision.Prune {
+				continue
+			}
+
+			// snapshot appeared after the lock was taken, it will be pruned next time
+			if !locked[decision.Snapshot().UUID] {
+				decision.Prune = false
+				decision.Reason = "created while pruning"
+				continue
+			}
+
+			if b.DryRun {
+				continue
+			}
+
+			err = taskSnapshotCollection.Drop(decision.Snapshot())
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to drop snapshot %s: %s", decision.Name, err)
+			}
+			out.Printf("Snapshot %s has been dropped (%s)\n", decision.Name, decision.Reason)
+		}
+
+		return &task.ProcessReturnValue{Code: http.StatusOK, Value: decisions}, nil
+	})
+}
+
 // @Summary Snapshot diff
 // @Description **Return the diff between two snapshots (name & withSnapshot)**
 // @Description Provide `onlyMatching=1` to return only packages present in both snapshots.
 // @Description Otherwise, returns a `left` and `right` result providing packages only in the first and second snapshots
+// @Description Provide `changelog=1` to return changelog entries (extracted from `changelog.Debian.gz`) for every package upgraded in the second snapshot.
 // @Tags Snapshots
 // @Produce json
 // @Param name path string true "Snapshot name"
 // @Param withSnapshot path string true "Snapshot name to diff against"
 // @Param onlyMatching query string false "Only return packages present in both snapshots"
+// @Param changelog query string false "Set to `1` to return changelog entries of packages upgraded in `withSnapshot` instead of package diff"
 // @Success 200 {array} deb.PackageDiff "Package Diff"
+// @Success 200 {array} deb.PackageChangelog "Changelog of upgraded packages (with `changelog=1`)"
 // @Failure 404 {object} Error "Snapshot Not Found"
 // @Failure 500 {object} Error "Internal Server Error"
 // @Router /api/snapshots/{name}/diff/{withSnapshot} [get]
@@ -558,6 +723,11 @@ func apiSnapshotsDiff(c *gin.Context) {
 		return
 	}
 
+	if c.Request.URL.Query().Get("changelog") == "1" {
+		c.JSON(200, deb.ChangelogsForDiff(diff, context.PackagePool(), nil))
+		return
+	}
+
 	result := []deb.PackageDiff{}
 
 	for _, pdiff := range diff {
@@ -604,9 +774,211 @@ func apiSnapshotsSearchPackages(c *gin.Context) {
 	showPackages(c, snapshot.RefList(), collectionFactory)
 }
 
+// @Summary Snapshot Lockfile
+// @Description **Export lockfile of snapshot**
+// @Description
+// @Description Lockfile lists name, version, architecture and SHA256 checksum of every package in the snapshot.
+// @Tags Snapshots
+// @Param name path string true "Snapshot name"
+// @Produce json
+// @Success 200 {object} deb.Lockfile "Lockfile"
+// @Failure 400 {object} Error "Snapshot has packages without SHA256 checksum"
+// @Failure 404 {object} Error "Snapshot Not Found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/{name}/lockfile [get]
+func apiSnapshotsLockfile(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	err = collection.LoadComplete(snapshot)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), nil)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	lockfile, err := deb.NewLockfile(snapshot.Name, list)
+	if err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	c.JSON(200, lockfile)
+}
+
+// @Summary Snapshot SBOM
+// @Description **Software bill of materials for snapshot**
+// @Description
+// @Description Every package is listed with version, architecture, source package and checksums. License information
+// @Description is extracted from machine-readable `usr/share/doc/<package>/copyright` in package files when available.
+// @Tags Snapshots
+// @Param name path string true "Snapshot name"
+// @Param format query string false "SBOM format: `spdx-json` (default) or `cyclonedx-json`"
+// @Param vendor query string false "Vendor (namespace) used in package URLs, defaults to `debian`"
+// @Param skipLicenses query string false "Set to 1 to skip extraction of license information from package files"
+// @Produce json
+// @Success 200 {object} string "SBOM document"
+// @Failure 400 {object} Error "Unknown Format"
+// @Failure 404 {object} Error "Snapshot Not Found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/{name}/sbom [get]
+func apiSnapshotsSBOM(c *gin.Context) {
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(c.Params.ByName("name"))
+	if err != nil {
+		AbortWithJSONError(c, 404, err)
+		return
+	}
+
+	err = collection.LoadComplete(snapshot)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), nil)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	writeSBOM(c, snapshot.Name, list)
+}
+
+// writeSBOM responds with SBOM of the package list in format requested by query parameters
+func writeSBOM(c *gin.Context, name string, list *deb.PackageList) {
+	format := c.DefaultQuery("format", deb.SBOMFormatSPDX)
+	if format != deb.SBOMFormatSPDX && format != deb.SBOMFormatCycloneDX {
+		AbortWithJSONError(c, 400, fmt.Errorf("unknown SBOM format %s", format))
+		return
+	}
+
+	var packagePool aptly.PackagePool
+	if c.Query("skipLicenses") != "1" {
+		packagePool = context.PackagePool()
+	}
+
+	sbom := deb.NewSBOM(name, c.DefaultQuery("vendor", "debian"), list, packagePool, nil)
+
+	c.Status(200)
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	if err := sbom.Write(c.Writer, format); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+type snapshotsCreateFromLockfileParams struct {
+	// Name of snapshot to create
+	Name string `binding:"required" json:"Name"        example:"snap2"`
+	// Description of snapshot (optional)
+	Description string `             json:"Description"`
+	// User-defined labels (optional)
+	Labels map[string]string `       json:"Labels"      example:"env:prod"`
+	// Snapshots, local repos or mirrors to look packages up in, optionally prefixed with snapshot:, repo: or mirror:
+	Sources []string `binding:"required" json:"Sources"     example:"snapshot:snap1"`
+	// Lockfile, as returned by /api/snapshots/{name}/lockfile
+	Lockfile deb.Lockfile `json:"Lockfile"`
+}
+
+// @Summary Snapshot from Lockfile
+// @Description **Create a snapshot with exact set of packages listed in lockfile**
+// @Description
+// @Description Packages are looked up in sources, first match wins. Snapshot is not created if any
+// @Description package is missing or has different SHA256 checksum.
+// @Tags Snapshots
+// @Consume json
+// @Param request body snapshotsCreateFromLockfileParams true "Parameters"
+// @Param _async query bool false "Run in background and return task object"
+// @Produce json
+// @Success 201 {object} deb.Snapshot "Created snapshot"
+// @Failure 400 {object} Error "Bad Request, or lockfile could not be resolved"
+// @Failure 404 {object} Error "Source Not Found"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/snapshots/lockfile [post]
+func apiSnapshotsCreateFromLockfile(c *gin.Context) {
+	var (
+		err error
+		b   snapshotsCreateFromLockfileParams
+	)
+
+	if c.Bind(&b) != nil {
+		return
+	}
+
+	if err = deb.ValidateLabels(b.Labels); err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	if err = b.Lockfile.Validate(); err != nil {
+		AbortWithJSONError(c, 400, err)
+		return
+	}
+
+	resources, err := deb.LockfileSourceKeys(b.Sources, context.NewCollectionFactory())
+	if err != nil {
+		if _, ok := err.(*deb.LockfileSourceNotFoundError); ok {
+			AbortWithJSONError(c, 404, err)
+		} else {
+			AbortWithJSONError(c, 400, err)
+		}
+		return
+	}
+
+	maybeRunTaskInBackground(c, "Create snapshot "+b.Name+" from lockfile", resources, func(out aptly.Progress, _ *task.Detail) (*task.ProcessReturnValue, error) {
+		taskCollectionFactory := context.NewCollectionFactory()
+
+		sources, err := deb.LoadLockfileSources(b.Sources, taskCollectionFactory, out)
+		if err != nil {
+			if _, ok := err.(*deb.LockfileSourceNotFoundError); ok {
+				return &task.ProcessReturnValue{Code: http.StatusNotFound, Value: nil}, err
+			}
+			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, err
+		}
+
+		snapshot, err := deb.NewSnapshotFromLockfile(b.Name, &b.Lockfile, sources, out)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
+		}
+
+		if b.Description != "" {
+			snapshot.Description = b.Description
+		}
+		snapshot.Labels = deb.UpdateLabels(nil, b.Labels, nil)
+
+		err = taskCollectionFactory.SnapshotCollection().Add(snapshot)
+		if err != nil {
+			return &task.ProcessReturnValue{Code: http.StatusBadRequest, Value: nil}, err
+		}
+		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
+	})
+}
+
 type snapshotsMergeParams struct {
 	// List of snapshot names to be merged
 	Sources []string `binding:"required" json:"Sources"     example:"snapshot1"`
+	// Merge policy: priorities of source snapshots and package pins (optional)
+	Policy *deb.MergePolicy `json:"Policy"`
+}
+
+type snapshotsMergeResult struct {
+	// Resulting snapshot
+	Snapshot *deb.Snapshot
+	// Conflicts resolved by merge policy
+	Conflicts []deb.MergeConflict
 }
 
 // @Summary Snapshot Merge
@@ -615,6 +987,10 @@ type snapshotsMergeParams struct {
 // @Description Merge happens from left to right. By default, packages with the same name-architecture pair are replaced during merge (package from latest snapshot on the list wins).
 // @Description
 // @Description If only one snapshot is specified, merge copies source into destination.
+// @Description
+// @Description With `Policy` packages are chosen by priority, similar to apt pinning: each package gets priority of its source snapshot (`Priorities`, default is 500), unless it matches one of `Pins` (first matching pin wins).
+// @Description For each name-architecture pair the package with highest priority is chosen, even if its version is lower; for equal priorities highest version wins. Packages with negative priority are never chosen.
+// @Description With `Policy` response contains resulting snapshot and the list of resolved conflicts with the source which won each conflict.
 // @Tags Snapshots
 // @Consume json
 // @Produce json
@@ -624,6 +1000,7 @@ type snapshotsMergeParams struct {
 // @Param request body snapshotsMergeParams true "Parameters"
 // @Param _async query bool false "Run in background and return task object"
 // @Success 201 {object} deb.Snapshot "Resulting snapshot object"
+// @Success 201 {object} snapshotsMergeResult "Resulting snapshot object and conflicts (if Policy is specified)"
 // @Failure 400 {object} Error "Bad Request"
 // @Failure 404 {object} Error "Not Found"
 // @Failure 500 {object} Error "Internal Error"
@@ -655,6 +1032,18 @@ func apiSnapshotsMerge(c *gin.Context) {
 		return
 	}
 
+	if body.Policy != nil {
+		if latest || noRemove {
+			AbortWithJSONError(c, http.StatusBadRequest, fmt.Errorf("merge policy can't be used together with latest or no-remove"))
+			return
+		}
+
+		if err = body.Policy.Validate(body.Sources); err != nil {
+			AbortWithJSONError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
 	// Phase 1: Pre-task validation (shallow load for 404 checks only)
 	collectionFactory := context.NewCollectionFactory()
 	snapshotCollection := collectionFactory.SnapshotCollection()
@@ -691,13 +1080,25 @@ func apiSnapshotsMerge(c *gin.Context) {
 		}
 
 		// Merge using fresh sources
-		result := freshSources[0].RefList()
-		for i := 1; i < len(freshSources); i++ {
-			result = result.Merge(freshSources[i].RefList(), overrideMatching, false)
-		}
+		var (
+			result    *deb.PackageRefList
+			conflicts []deb.MergeConflict
+		)
+
+		if body.Policy != nil {
+			result, conflicts, err = deb.MergeWithPolicy(freshSources, body.Policy)
+			if err != nil {
+				return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to merge: %s", err)
+			}
+		} else {
+			result = freshSources[0].RefList()
+			for i := 1; i < len(freshSources); i++ {
+				result = result.Merge(freshSources[i].RefList(), overrideMatching, false)
+			}
 
-		if latest {
-			result.FilterLatestRefs()
+			if latest {
+				result.FilterLatestRefs()
+			}
 		}
 
 		sourceDescription := make([]string, len(freshSources))
@@ -713,6 +1114,14 @@ func apiSnapshotsMerge(c *gin.Context) {
 			return &task.ProcessReturnValue{Code: http.StatusInternalServerError, Value: nil}, fmt.Errorf("unable to create snapshot: %s", err)
 		}
 
+		if body.Policy != nil {
+			if conflicts == nil {
+				conflicts = []deb.MergeConflict{}
+			}
+
+			return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshotsMergeResult{Snapshot: snapshot, Conflicts: conflicts}}, nil
+		}
+
 		return &task.ProcessReturnValue{Code: http.StatusCreated, Value: snapshot}, nil
 	})
 }
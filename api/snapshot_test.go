@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 
 	"github.com/aptly-dev/aptly/deb"
@@ -51,3 +52,298 @@ func (s *SnapshotsSuite) TestGetSnapshotsReturns500OnCorruptRefList(c *C) {
 	c.Assert(response.Code, Equals, 500)
 	c.Assert(response.Body.String(), Matches, ".*msgpack.*|.*decode.*")
 }
+
+func (s *SnapshotsSuite) TestPruneSnapshots(c *C) {
+	collection := s.context.NewCollectionFactory().SnapshotCollection()
+	for _, name := range []string{"prune-test-1", "prune-test-2", "prune-test-3"} {
+		snapshot := deb.NewSnapshotFromRefList(name, nil, deb.NewPackageRefList(), "")
+		c.Assert(collection.Add(snapshot), IsNil)
+		defer func(snapshot *deb.Snapshot) { _ = collection.Drop(snapshot) }(snapshot)
+	}
+
+	response, err := s.HTTPRequest("POST", "/api/snapshots/prune", bytes.NewReader([]byte(`{"Rules": [{"pattern": "prune-test-*"}]}`)))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	body := []byte(`{"DryRun": true, "Rules": [{"pattern": "prune-test-*", "keepLast": 1}]}`)
+	response, err = s.HTTPRequest("POST", "/api/snapshots/prune", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var decisions []map[string]interface{}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &decisions), IsNil)
+	c.Assert(decisions, HasLen, 3)
+	c.Check(decisions[0]["Prune"], Equals, false)
+	c.Check(decisions[1]["Prune"], Equals, true)
+	c.Check(decisions[2]["Prune"], Equals, true)
+
+	_, err = s.context.NewCollectionFactory().SnapshotCollection().ByName(decisions[1]["Name"].(string))
+	c.Check(err, IsNil)
+
+	body = []byte(`{"Rules": [{"pattern": "prune-test-*", "keepLast": 1}]}`)
+	response, err = s.HTTPRequest("POST", "/api/snapshots/prune", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	_, err = s.context.NewCollectionFactory().SnapshotCollection().ByName(decisions[0]["Name"].(string))
+	c.Check(err, IsNil)
+	_, err = s.context.NewCollectionFactory().SnapshotCollection().ByName(decisions[1]["Name"].(string))
+	c.Check(err, ErrorMatches, ".*not found")
+}
+
+func (s *SnapshotsSuite) TestSnapshotLabels(c *C) {
+	drop := func(name string) {
+		collection := s.context.NewCollectionFactory().SnapshotCollection()
+		if snapshot, err := collection.ByName(name); err == nil {
+			_ = collection.Drop(snapshot)
+		}
+	}
+
+	body := []byte(`{"Name": "labels-test-1", "Labels": {"test": "labels", "env": "prod"}}`)
+	response, err := s.HTTPRequest("POST", "/api/snapshots", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer drop("labels-test-1")
+
+	snapshot := deb.NewSnapshotFromRefList("labels-test-2", nil, deb.NewPackageRefList(), "")
+	snapshot.Labels = map[string]string{"test": "labels"}
+	c.Assert(s.context.NewCollectionFactory().SnapshotCollection().Add(snapshot), IsNil)
+	defer drop("labels-test-2")
+
+	response, err = s.HTTPRequest("POST", "/api/snapshots", bytes.NewReader([]byte(`{"Name": "labels-test-3", "Labels": {"-bad": "x"}}`)))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	names := func(query string) []string {
+		response, err := s.HTTPRequest("GET", "/api/snapshots?label=test=labels&"+query, nil)
+		c.Assert(err, IsNil)
+		c.Assert(response.Code, Equals, 200)
+
+		var snapshots []map[string]interface{}
+		c.Assert(json.Unmarshal(response.Body.Bytes(), &snapshots), IsNil)
+
+		result := []string{}
+		for _, snapshot := range snapshots {
+			result = append(result, snapshot["Name"].(string))
+		}
+		return result
+	}
+
+	c.Check(names("label=env=prod"), DeepEquals, []string{"labels-test-1"})
+	c.Check(names("label=!env"), DeepEquals, []string{"labels-test-2"})
+
+	response, err = s.HTTPRequest("PUT", "/api/snapshots/labels-test-2", bytes.NewReader([]byte(`{"Labels": {"test": "labels", "env": "stage"}}`)))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	c.Check(names("label=env"), DeepEquals, []string{"labels-test-1", "labels-test-2"})
+	c.Check(names("label=env!=prod"), DeepEquals, []string{"labels-test-2"})
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots?label=%3Dprod", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+}
+
+func (s *SnapshotsSuite) TestMergeWithPolicy(c *C) {
+	drop := func(name string) {
+		collection := s.context.NewCollectionFactory().SnapshotCollection()
+		if snapshot, err := collection.ByName(name); err == nil {
+			_ = collection.Drop(snapshot)
+		}
+	}
+
+	list := deb.NewPackageList()
+	c.Assert(list.Add(&deb.Package{Name: "libcount", Version: "0.9-internal1", Architecture: "amd64"}), IsNil)
+	internal := deb.NewSnapshotFromRefList("merge-policy-internal", nil, deb.NewPackageRefListFromPackageList(list), "")
+	ubuntu := deb.NewSnapshotFromRefList("merge-policy-ubuntu", nil, makePackageRefList(c), "")
+	for _, snapshot := range []*deb.Snapshot{internal, ubuntu} {
+		c.Assert(s.context.NewCollectionFactory().SnapshotCollection().Add(snapshot), IsNil)
+		defer drop(snapshot.Name)
+	}
+
+	body := []byte(`{"Sources": ["merge-policy-ubuntu", "merge-policy-internal"], "Policy": {"Priorities": {"merge-policy-internal": 1001}}}`)
+	response, err := s.HTTPRequest("POST", "/api/snapshots/merge-policy-result/merge", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+	defer drop("merge-policy-result")
+
+	var result struct {
+		Snapshot  deb.Snapshot
+		Conflicts []deb.MergeConflict
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), IsNil)
+	c.Check(result.Snapshot.Name, Equals, "merge-policy-result")
+	c.Assert(result.Conflicts, HasLen, 1)
+	c.Check(result.Conflicts[0].Winner, DeepEquals, &deb.MergeCandidate{Package: "libcount_0.9-internal1_amd64", Source: "merge-policy-internal", Priority: 1001})
+
+	merged, err := s.context.NewCollectionFactory().SnapshotCollection().ByName("merge-policy-result")
+	c.Assert(err, IsNil)
+	c.Assert(s.context.NewCollectionFactory().SnapshotCollection().LoadComplete(merged), IsNil)
+	c.Check(merged.RefList().Strings(), DeepEquals, []string{"Pall appcount 2.0", "Pamd64 libcount 0.9-internal1"})
+
+	body = []byte(`{"Sources": ["merge-policy-ubuntu"], "Policy": {"Priorities": {"merge-policy-internal": 1001}}}`)
+	response, err = s.HTTPRequest("POST", "/api/snapshots/merge-policy-result2/merge", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	body = []byte(`{"Sources": ["merge-policy-ubuntu"], "Policy": {}}`)
+	response, err = s.HTTPRequest("POST", "/api/snapshots/merge-policy-result2/merge?latest=1", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+}
+
+func (s *SnapshotsSuite) TestProtectedSnapshot(c *C) {
+	snapshot := deb.NewSnapshotFromRefList("protected-test", nil, deb.NewPackageRefList(), "")
+	c.Assert(s.context.NewCollectionFactory().SnapshotCollection().Add(snapshot), IsNil)
+	defer func() {
+		collection := s.context.NewCollectionFactory().SnapshotCollection()
+		for _, name := range []string{"protected-test", "protected-test-renamed"} {
+			if snapshot, err := collection.ByName(name); err == nil {
+				_ = collection.Drop(snapshot)
+			}
+		}
+	}()
+
+	response, err := s.HTTPRequest("PUT", "/api/snapshots/protected-test", bytes.NewReader([]byte(`{"Protected": true}`)))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, ".*\"Protected\":true.*")
+
+	response, err = s.HTTPRequest("DELETE", "/api/snapshots/protected-test?force=1", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 409)
+	c.Check(response.Body.String(), Matches, ".*snapshot protected-test is protected, unprotect it first.*")
+
+	response, err = s.HTTPRequest("PUT", "/api/snapshots/protected-test", bytes.NewReader([]byte(`{"Name": "protected-test-renamed"}`)))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 409)
+
+	// labels and description could still be changed
+	response, err = s.HTTPRequest("PUT", "/api/snapshots/protected-test", bytes.NewReader([]byte(`{"Description": "release"}`)))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+
+	response, err = s.HTTPRequest("PUT", "/api/snapshots/protected-test", bytes.NewReader([]byte(`{"Protected": false}`)))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	response, err = s.HTTPRequest("DELETE", "/api/snapshots/protected-test", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 200)
+}
+
+func (s *SnapshotsSuite) TestLockfile(c *C) {
+	packageCollection := s.context.NewCollectionFactory().PackageCollection()
+	list := deb.NewPackageList()
+	for _, version := range []string{"1.0", "1.1"} {
+		p := deb.NewPackageFromControlFile(deb.Stanza{
+			"Package":      "liblock",
+			"Version":      version,
+			"Architecture": "amd64",
+			"Filename":     "pool/main/l/liblock/liblock_" + version + "_amd64.deb",
+			"Size":         "10",
+			"SHA256":       "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		})
+		c.Assert(packageCollection.Update(p), IsNil)
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	snapshot := deb.NewSnapshotFromPackageList("lockfile-source", nil, list, "")
+	c.Assert(s.context.NewCollectionFactory().SnapshotCollection().Add(snapshot), IsNil)
+	defer func() {
+		collection := s.context.NewCollectionFactory().SnapshotCollection()
+		for _, name := range []string{"lockfile-source", "lockfile-result"} {
+			if snapshot, err := collection.ByName(name); err == nil {
+				_ = collection.Drop(snapshot)
+			}
+		}
+	}()
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/lockfile-source/lockfile", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var lockfile deb.Lockfile
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &lockfile), IsNil)
+	c.Check(lockfile.Snapshot, Equals, "lockfile-source")
+	c.Assert(lockfile.Packages, HasLen, 2)
+	c.Check(lockfile.Packages[0].String(), Equals, "liblock_1.0_amd64")
+
+	lockfile.Packages = lockfile.Packages[:1]
+	body, _ := json.Marshal(map[string]interface{}{"Name": "lockfile-result", "Sources": []string{"snapshot:lockfile-source"}, "Lockfile": lockfile})
+	response, err = s.HTTPRequest("POST", "/api/snapshots/lockfile", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 201)
+
+	result, err := s.context.NewCollectionFactory().SnapshotCollection().ByName("lockfile-result")
+	c.Assert(err, IsNil)
+	c.Assert(s.context.NewCollectionFactory().SnapshotCollection().LoadComplete(result), IsNil)
+	c.Check(result.NumPackages(), Equals, 1)
+
+	lockfile.Packages[0].SHA256 = "0000"
+	body, _ = json.Marshal(map[string]interface{}{"Name": "lockfile-result2", "Sources": []string{"lockfile-source"}, "Lockfile": lockfile})
+	response, err = s.HTTPRequest("POST", "/api/snapshots/lockfile", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*checksum mismatch for liblock_1.0_amd64.*")
+
+	body, _ = json.Marshal(map[string]interface{}{"Name": "lockfile-result2", "Sources": []string{"lockfile-missing"}, "Lockfile": lockfile})
+	response, err = s.HTTPRequest("POST", "/api/snapshots/lockfile", bytes.NewReader(body))
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
+
+func (s *SnapshotsSuite) TestSBOM(c *C) {
+	list := deb.NewPackageList()
+	p := deb.NewPackageFromControlFile(deb.Stanza{
+		"Package":      "libsbom1",
+		"Version":      "1.0-1",
+		"Architecture": "amd64",
+		"Source":       "sbom",
+		"Filename":     "pool/main/s/sbom/libsbom1_1.0-1_amd64.deb",
+		"Size":         "10",
+		"SHA256":       "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+	})
+	c.Assert(s.context.NewCollectionFactory().PackageCollection().Update(p), IsNil)
+	c.Assert(list.Add(p), IsNil)
+
+	snapshot := deb.NewSnapshotFromPackageList("sbom-test", nil, list, "")
+	c.Assert(s.context.NewCollectionFactory().SnapshotCollection().Add(snapshot), IsNil)
+	defer func() {
+		collection := s.context.NewCollectionFactory().SnapshotCollection()
+		if snapshot, err := collection.ByName("sbom-test"); err == nil {
+			_ = collection.Drop(snapshot)
+		}
+	}()
+
+	response, err := s.HTTPRequest("GET", "/api/snapshots/sbom-test/sbom", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+
+	var spdx struct {
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			Name        string `json:"name"`
+			VersionInfo string `json:"versionInfo"`
+			SourceInfo  string `json:"sourceInfo"`
+		} `json:"packages"`
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &spdx), IsNil)
+	c.Check(spdx.SPDXVersion, Equals, "SPDX-2.3")
+	c.Assert(spdx.Packages, HasLen, 1)
+	c.Check(spdx.Packages[0].Name, Equals, "libsbom1")
+	c.Check(spdx.Packages[0].SourceInfo, Equals, "built package from: sbom 1.0-1")
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/sbom-test/sbom?format=cyclonedx-json&vendor=ubuntu&skipLicenses=1", nil)
+	c.Assert(err, IsNil)
+	c.Assert(response.Code, Equals, 200)
+	c.Check(response.Body.String(), Matches, `(?s).*"bomFormat": "CycloneDX".*"purl": "pkg:deb/ubuntu/libsbom1@1.0-1\?arch=amd64&upstream=sbom".*`)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/sbom-test/sbom?format=xml", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 400)
+
+	response, err = s.HTTPRequest("GET", "/api/snapshots/sbom-missing/sbom", nil)
+	c.Assert(err, IsNil)
+	c.Check(response.Code, Equals, 404)
+}
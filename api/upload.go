@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultMaxUploadSize limits size of the file uploaded into incoming queue, unless set in queue configuration
+const defaultMaxUploadSize = 1 << 30
+
+// uploadLogReporter collects processing log of uploads in the order messages were reported
+type uploadLogReporter struct {
+	lines []string
+}
+
+func (r *uploadLogReporter) Warning(msg string, a ...interface{}) {
+	r.lines = append(r.lines, "[!] "+fmt.Sprintf(msg, a...))
+}
+
+func (r *uploadLogReporter) Notice(msg string, a ...interface{}) {
+	r.lines = append(r.lines, "[i] "+fmt.Sprintf(msg, a...))
+}
+
+func (r *uploadLogReporter) Removed(msg string, a ...interface{}) {
+	r.lines = append(r.lines, "[-] "+fmt.Sprintf(msg, a...))
+}
+
+func (r *uploadLogReporter) Added(msg string, a ...interface{}) {
+	r.lines = append(r.lines, "[+] "+fmt.Sprintf(msg, a...))
+}
+
+type uploadResponse struct {
+	// Name of the stored file
+	File string `json:"File"`
+	// Processing log of the upload completed by this file, empty if upload is still incomplete
+	Log []string `json:"Log"`
+}
+
+// @Summary Upload File to Incoming Queue
+// @Description **Upload one file to incoming queue, compatible with dput `http` upload method**
+// @Description
+// @Description Incoming queues are configured in `incomingQueues` section of configuration file.
+// @Description Once .changes file and all the files it references are uploaded and checksums match,
+// @Description upload is included into local repository: .changes signature is verified and uploaders
+// @Description rules are applied according to queue settings. Rejected uploads are moved to quarantine directory.
+// @Description
+// @Description Only the upload which references the file is processed. Response contains its processing log,
+// @Description if some files of the upload are rejected, status is 400.
+// @Description
+// @Description Size of the file is limited by `maxUploadSize` queue setting (1 GiB by default).
+// @Description
+// @Description **Example:**
+// @Description  ```
+// @Description $ cat ~/.dput.cf
+// @Description [aptly]
+// @Description fqdn = localhost:8080
+// @Description method = http
+// @Description incoming = /api/upload/main
+// @Description allow_unsigned_uploads = 0
+// @Description $ dput aptly aptly_0.9~dev+217+ge5d646c_i386.changes
+// @Description  ```
+// @Tags Files
+// @Param queue path string true "Name of incoming queue"
+// @Param file path string true "File to upload"
+// @Produce json
+// @Success 200 {object} uploadResponse
+// @Failure 400 {object} uploadResponse "Upload rejected"
+// @Failure 404 {object} Error "Not Found"
+// @Failure 413 {object} Error "File is too large"
+// @Failure 500 {object} Error "Internal Server Error"
+// @Router /api/upload/{queue}/{file} [put]
+func apiUploadFile(c *gin.Context) {
+	name := c.Params.ByName("queue")
+	config, ok := context.Config().IncomingQueues[name]
+	if !ok {
+		AbortWithJSONError(c, 404, fmt.Errorf("incoming queue %s not found", name))
+		return
+	}
+
+	fileName := c.Params.ByName("file")
+	if !verifyPath(fileName) || strings.ContainsAny(fileName, `/\`) || strings.HasPrefix(fileName, ".") {
+		AbortWithJSONError(c, 400, fmt.Errorf("wrong file"))
+		return
+	}
+
+	reporter := &uploadLogReporter{lines: []string{}}
+
+	queue, err := newIncomingQueue(name, config, reporter)
+	if err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("unable to set up incoming queue %s: %s", name, err))
+		return
+	}
+
+	err = os.MkdirAll(queue.Path, 0777)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	// file is written under temporary name, so that incomplete file is never seen by the watcher
+	dst, err := os.CreateTemp(queue.Path, "."+fileName+".*")
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+	defer func() {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+	}()
+
+	maxSize := config.MaxUploadSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxUploadSize
+	}
+
+	if _, err = io.Copy(dst, http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			AbortWithJSONError(c, http.StatusRequestEntityTooLarge, fmt.Errorf("file %s is larger than %d bytes", fileName, maxSize))
+			return
+		}
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	if err = syncFile(dst); err != nil {
+		AbortWithJSONError(c, 500, fmt.Errorf("error syncing file %s: %s", fileName, err))
+		return
+	}
+
+	if err = os.Rename(dst.Name(), filepath.Join(queue.Path, fileName)); err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	changesFiles, err := queue.FindUploads(fileName)
+	if err != nil {
+		AbortWithJSONError(c, 500, err)
+		return
+	}
+
+	code := http.StatusOK
+
+	for _, changesFile := range changesFiles {
+		var rejected []string
+
+		rejected, err = queue.ProcessUpload(changesFile, time.Now())
+		if err != nil {
+			AbortWithJSONError(c, 500, err)
+			return
+		}
+
+		if len(rejected) > 0 {
+			code = http.StatusBadRequest
+		}
+	}
+
+	c.JSON(code, uploadResponse{File: fileName, Log: reporter.lines})
+}
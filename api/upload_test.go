@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/utils"
+	. "gopkg.in/check.v1"
+)
+
+type UploadSuite struct {
+	APISuite
+}
+
+var _ = Suite(&UploadSuite{})
+
+func (s *UploadSuite) TestUpload(c *C) {
+	response, _ := s.HTTPRequest("POST", "/api/repos", strings.NewReader(`{"Name": "upload-test"}`))
+	c.Assert(response.Code, Equals, 201)
+	defer func() { _, _ = s.HTTPRequest("DELETE", "/api/repos/upload-test?force=1", nil) }()
+
+	dir := c.MkDir()
+	s.context.Config().IncomingQueues = map[string]utils.IncomingQueue{
+		"main": {
+			Path:             dir,
+			Repo:             "upload-test",
+			AcceptUnsigned:   true,
+			IgnoreSignatures: true,
+		},
+	}
+	defer func() { s.context.Config().IncomingQueues = nil }()
+
+	response, _ = s.HTTPRequest("PUT", "/api/upload/other/foo.changes", strings.NewReader(""))
+	c.Check(response.Code, Equals, 404)
+
+	response, _ = s.HTTPRequest("PUT", "/api/upload/main/.foo.changes", strings.NewReader(""))
+	c.Check(response.Code, Equals, 400)
+
+	// upload without hardlink_0.2.0_i386.deb, which doesn't match .changes
+	original, err := os.ReadFile("../deb/testdata/changes/hardlink_0.2.1_amd64.changes")
+	c.Assert(err, IsNil)
+	var changes []string
+	for _, line := range strings.Split(string(original), "\n") {
+		if !strings.Contains(line, "hardlink_0.2.0_i386.deb") {
+			changes = append(changes, line)
+		}
+	}
+
+	upload := func(name string, data []byte) uploadResponse {
+		response, _ := s.HTTPRequest("PUT", "/api/upload/main/"+name, bytes.NewReader(data))
+		var result uploadResponse
+		c.Assert(json.Unmarshal(response.Body.Bytes(), &result), IsNil, Commentf("%s", response.Body.String()))
+		c.Check(response.Code, Equals, 200, Commentf("%s", response.Body.String()))
+		return result
+	}
+
+	result := upload("hardlink_0.2.1_amd64.changes", []byte(strings.Join(changes, "\n")))
+	c.Check(result.File, Equals, "hardlink_0.2.1_amd64.changes")
+	c.Check(result.Log, HasLen, 0)
+
+	for _, name := range []string{"hardlink_0.2.1.dsc", "hardlink_0.2.1.tar.gz", "hardlink_0.2.1_amd64.deb"} {
+		data, err := os.ReadFile(filepath.Join("../deb/testdata/changes", name))
+		c.Assert(err, IsNil)
+		result = upload(name, data)
+		c.Check(result.Log, HasLen, 0)
+	}
+
+	data, err := os.ReadFile("../deb/testdata/changes/hardlink_0.2.1_amd64.buildinfo")
+	c.Assert(err, IsNil)
+	result = upload("hardlink_0.2.1_amd64.buildinfo", data)
+	c.Check(result.Log, DeepEquals, []string{"[+] hardlink_0.2.1_source added", "[+] hardlink_0.2.1_amd64 added",
+		"[+] hardlink_0.2.1_amd64.buildinfo attached to hardlink_0.2.1_amd64"})
+
+	entries, _ := os.ReadDir(dir)
+	c.Check(entries, HasLen, 0)
+
+	// upload with checksum mismatch is rejected
+	result = upload("broken_1.0_amd64.changes", []byte(
+		"Format: 1.8\nSource: broken\nDistribution: unstable\nFiles:\n 900150983cd24fb0d6963f7d28e17f72 3 utils optional broken_1.0.dsc\n"))
+	c.Check(result.Log, HasLen, 0)
+
+	response, _ = s.HTTPRequest("PUT", "/api/upload/main/broken_1.0.dsc", strings.NewReader("xyz"))
+	c.Check(response.Code, Equals, 400)
+	c.Check(response.Body.String(), Matches, ".*rejected: checksum mismatch for broken_1.0.dsc.*")
+
+	_, err = os.Stat(filepath.Join(dir, "quarantine", "broken_1.0_amd64.changes.reason"))
+	c.Check(err, IsNil)
+}
+
+func (s *UploadSuite) TestUploadProcessesOwnUpload(c *C) {
+	dir := c.MkDir()
+	s.context.Config().IncomingQueues = map[string]utils.IncomingQueue{
+		"main": {
+			Path:             dir,
+			AcceptUnsigned:   true,
+			IgnoreSignatures: true,
+			MaxUploadSize:    1024,
+		},
+	}
+	defer func() { s.context.Config().IncomingQueues = nil }()
+
+	// broken upload of somebody else is left for the watcher
+	c.Assert(os.WriteFile(filepath.Join(dir, "other.changes"), []byte("Format: 1.8\nFiles:\n 0123"), 0644), IsNil)
+
+	response, _ := s.HTTPRequest("PUT", "/api/upload/main/foo_1.0.dsc", strings.NewReader("xyz"))
+	c.Check(response.Code, Equals, 200, Commentf("%s", response.Body.String()))
+	c.Check(response.Body.String(), Equals, `{"File":"foo_1.0.dsc","Log":[]}`)
+
+	_, err := os.Stat(filepath.Join(dir, "other.changes"))
+	c.Check(err, IsNil)
+
+	response, _ = s.HTTPRequest("PUT", "/api/upload/main/foo_1.0.tar.gz", bytes.NewReader(make([]byte, 1025)))
+	c.Check(response.Code, Equals, 413)
+
+	_, err = os.Stat(filepath.Join(dir, "foo_1.0.tar.gz"))
+	c.Check(os.IsNotExist(err), Equals, true)
+}
@@ -127,6 +127,8 @@ const (
 	BarPublishGeneratePackageFiles
 	// BarPublishFinalizeIndexes identifies bar for finalizing index files
 	BarPublishFinalizeIndexes
+	// BarMirrorVerifyFiles identifies bar for verifying package files in the pool
+	BarMirrorVerifyFiles
 )
 
 // Progress is a progress displaying entity, it allows progress bars & simple prints
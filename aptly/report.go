@@ -14,6 +14,23 @@ type ResultReporter interface {
 	Added(msg string, a ...interface{})
 }
 
+// NoticeReporter is optionally implemented by ResultReporter to tell findings which
+// don't affect processing (e.g. lint warnings) apart from warnings about failures
+type NoticeReporter interface {
+	// Notice is informational message
+	Notice(msg string, a ...interface{})
+}
+
+// ReportNotice reports notice if reporter supports it, falling back to Warning otherwise
+func ReportNotice(reporter ResultReporter, msg string, a ...interface{}) {
+	if noticeReporter, ok := reporter.(NoticeReporter); ok {
+		noticeReporter.Notice(msg, a...)
+		return
+	}
+
+	reporter.Warning(msg, a...)
+}
+
 // ConsoleResultReporter is implementation of ResultReporter that prints in colors to console
 type ConsoleResultReporter struct {
 	Progress Progress
@@ -44,11 +61,13 @@ type RecordingResultReporter struct {
 	Warnings     []string
 	AddedLines   []string `json:"Added"`
 	RemovedLines []string `json:"Removed"`
+	Notices      []string `json:",omitempty"`
 }
 
 // Check interface
 var (
 	_ ResultReporter = &RecordingResultReporter{}
+	_ NoticeReporter = &RecordingResultReporter{}
 )
 
 // Warning is non-fatal error message
@@ -56,6 +75,11 @@ func (r *RecordingResultReporter) Warning(msg string, a ...interface{}) {
 	r.Warnings = append(r.Warnings, fmt.Sprintf(msg, a...))
 }
 
+// Notice is informational message
+func (r *RecordingResultReporter) Notice(msg string, a ...interface{}) {
+	r.Notices = append(r.Notices, fmt.Sprintf(msg, a...))
+}
+
 // Removed is signal that something has been removed
 func (r *RecordingResultReporter) Removed(msg string, a ...interface{}) {
 	r.RemovedLines = append(r.RemovedLines, fmt.Sprintf(msg, a...))
@@ -39,6 +39,23 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 		return err
 	}
 
+	router := api.Router(context)
+
+	if !context.Flags().Lookup("no-schedules").Value.Get().(bool) {
+		scheduler := api.StartScheduler()
+		defer scheduler.Stop()
+	}
+
+	if !context.Flags().Lookup("no-incoming").Value.Get().(bool) {
+		watcher, err := api.StartIncomingWatcher()
+		if err != nil {
+			return err
+		}
+		if watcher != nil {
+			defer watcher.Stop()
+		}
+	}
+
 	// Try to recycle systemd fds for listening
 	listeners, err := activation.Listeners(true)
 	if len(listeners) > 1 {
@@ -48,7 +65,7 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 		listener := listeners[0]
 		defer func() { _ = listener.Close() }()
 		fmt.Printf("\nTaking over web server at: %s (press Ctrl+C to quit)...\n", listener.Addr().String())
-		err = http.Serve(listener, api.Router(context))
+		err = http.Serve(listener, router)
 		if err != nil {
 			return fmt.Errorf("unable to serve: %s", err)
 		}
@@ -59,7 +76,7 @@ func aptlyAPIServe(cmd *commander.Command, args []string) error {
 	listen := context.Flags().Lookup("listen").Value.String()
 	fmt.Printf("\nStarting web server at: %s (press Ctrl+C to quit)...\n", listen)
 
-	server := http.Server{Handler: api.Router(context)}
+	server := http.Server{Handler: router}
 
 	sigchan := make(chan os.Signal, 1)
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
@@ -108,6 +125,12 @@ or Unix domain socket. When using a socket, Aptly will fully manage the socket
 file. This command also supports taking over from a systemd file descriptors to
 enable systemd socket activation.
 
+Mirror update schedules (see /api/schedules) are triggered by the server
+unless disabled with -no-schedules. Uploads placed into incoming queues from
+incomingQueues section of configuration are included into local repositories
+unless disabled with -no-incoming (see 'aptly incoming watch'). Files could
+be uploaded into incoming queues with dput 'http' method via /api/upload/<queue>.
+
 Example:
 
   $ aptly api serve -listen=:8080
@@ -118,6 +141,8 @@ Example:
 
 	cmd.Flag.String("listen", ":8080", "host:port for HTTP listening or unix://path to listen on a Unix domain socket")
 	cmd.Flag.Bool("no-lock", false, "don't lock the database")
+	cmd.Flag.Bool("no-schedules", false, "don't trigger mirror update schedules")
+	cmd.Flag.Bool("no-incoming", false, "don't process uploads in configured incoming queues")
 
 	return cmd
 
@@ -99,6 +99,7 @@ package environment to new version.`,
 			makeCmdConfig(),
 			makeCmdDB(),
 			makeCmdGraph(),
+			makeCmdIncoming(),
 			makeCmdMirror(),
 			makeCmdRepo(),
 			makeCmdServe(),
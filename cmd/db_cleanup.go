@@ -27,6 +27,7 @@ func aptlyDBCleanup(cmd *commander.Command, args []string) error {
 	// collect information about references packages...
 	existingPackageRefs := deb.NewPackageRefList()
 	referencedAppStreamFiles := []string{}
+	referencedReleaseFiles := []string{}
 
 	// used only in verbose mode to report package use source
 	packageRefSources := map[string][]string{}
@@ -60,6 +61,12 @@ func aptlyDBCleanup(cmd *commander.Command, args []string) error {
 			referencedAppStreamFiles = append(referencedAppStreamFiles, poolPath)
 		}
 
+		e = collectionFactory.RemoteRepoCollection().LoadReleaseHistory(repo)
+		if e != nil {
+			return e
+		}
+		referencedReleaseFiles = append(referencedReleaseFiles, repo.ReleaseHistoryPoolPaths()...)
+
 		return nil
 	})
 	if err != nil {
@@ -247,6 +254,7 @@ func aptlyDBCleanup(cmd *commander.Command, args []string) error {
 	}
 
 	referencedFiles = append(referencedFiles, referencedAppStreamFiles...)
+	referencedFiles = append(referencedFiles, referencedReleaseFiles...)
 	sort.Strings(referencedFiles)
 	context.Progress().ShutdownBar()
 
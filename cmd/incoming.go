@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/smira/commander"
+)
+
+func makeCmdIncoming() *commander.Command {
+	return &commander.Command{
+		UsageLine: "incoming",
+		Short:     "process uploads placed into incoming directories",
+		Subcommands: []*commander.Command{
+			makeCmdIncomingWatch(),
+		},
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// newIncomingQueue prepares incoming queue importing uploads into local repositories
+func newIncomingQueue(config utils.IncomingQueue, reporter aptly.ResultReporter) (*deb.IncomingQueue, error) {
+	verifier := context.GetVerifier()
+	for _, keyRing := range config.Keyrings {
+		verifier.AddKeyring(keyRing)
+	}
+
+	err := verifier.InitKeyring(!config.IgnoreSignatures)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	debsigVerifier, err := getDebsigVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	repoTemplateString := config.Repo
+	if repoTemplateString == "" {
+		repoTemplateString = "{{.Distribution}}"
+	}
+
+	repoTemplate, err := template.New("repo").Parse(repoTemplateString)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing repo template: %s", err)
+	}
+
+	uploaders, err := loadUploaders(config.UploadersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	importer := func(changesFiles []string, reporter aptly.ResultReporter) ([]string, error) {
+		// database is kept closed between imports, so that other aptly commands could run
+		err := context.ReOpenDatabase()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = context.CloseDatabase() }()
+
+		collectionFactory := context.NewCollectionFactory()
+
+		_, failedFiles, err := deb.ImportChangesFiles(
+			changesFiles, reporter, config.AcceptUnsigned, config.IgnoreSignatures, config.ForceReplace, false, verifier, debsigVerifier, repoTemplate,
+			context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
+			context.PackagePool(), collectionFactory.ChecksumCollection,
+			uploaders, query.Parse, collectionFactory.PackageHistoryCollection(), historyActor())
+
+		return failedFiles, err
+	}
+
+	return deb.NewIncomingQueue(config.Path, config.QuarantinePath, verifier, importer, reporter), nil
+}
+
+func aptlyIncomingWatch(cmd *commander.Command, args []string) error {
+	var configs []utils.IncomingQueue
+
+	if len(args) > 0 {
+		for _, dir := range args {
+			configs = append(configs, utils.IncomingQueue{
+				Path:             dir,
+				QuarantinePath:   context.Flags().Lookup("quarantine").Value.Get().(string),
+				Repo:             context.Flags().Lookup("repo").Value.Get().(string),
+				UploadersFile:    context.Flags().Lookup("uploaders-file").Value.Get().(string),
+				Keyrings:         context.Flags().Lookup("keyring").Value.Get().([]string),
+				AcceptUnsigned:   context.Flags().Lookup("accept-unsigned").Value.Get().(bool),
+				IgnoreSignatures: LookupOption(context.Config().GpgDisableVerify, context.Flags(), "ignore-signatures"),
+				ForceReplace:     context.Flags().Lookup("force-replace").Value.Get().(bool),
+			})
+		}
+	} else {
+		names := make([]string, 0, len(context.Config().IncomingQueues))
+		for name := range context.Config().IncomingQueues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			configs = append(configs, context.Config().IncomingQueues[name])
+		}
+
+		if len(configs) == 0 {
+			return fmt.Errorf("unable to watch: no directories specified and no incomingQueues configured")
+		}
+	}
+
+	settle := context.Flags().Lookup("settle").Value.Get().(time.Duration)
+	timeout := context.Flags().Lookup("timeout").Value.Get().(time.Duration)
+	reporter := &aptly.ConsoleResultReporter{Progress: context.Progress()}
+
+	queues := make([]*deb.IncomingQueue, len(configs))
+	paths := make([]string, len(configs))
+	for i, config := range configs {
+		if config.Path == "" {
+			return fmt.Errorf("unable to watch: incoming queue without path")
+		}
+
+		queue, err := newIncomingQueue(config, reporter)
+		if err != nil {
+			return fmt.Errorf("unable to watch %s: %s", config.Path, err)
+		}
+
+		queue.Settle, queue.Timeout = settle, timeout
+		queues[i], paths[i] = queue, queue.Path
+	}
+
+	// database is opened only for imports
+	_ = context.CloseDatabase()
+
+	context.GoContextHandleSignals()
+	context.Progress().Printf("Watching %s for uploads (press Ctrl+C to quit)...\n", strings.Join(paths, ", "))
+
+	return deb.WatchIncomingQueues(queues, context.Done())
+}
+
+func makeCmdIncomingWatch() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyIncomingWatch,
+		UsageLine: "watch [<directory>...]",
+		Short:     "watch incoming directories and include uploads into local repositories",
+		Long: `
+Command watch monitors incoming directories for .changes files. Once every file referenced
+by .changes file has been uploaded completely and checksums match, upload is included into
+local repository the same way as 'aptly repo include' does. Successfully imported files are
+removed.
+
+Rejected uploads (failed signature verification, uploaders rules, checksum mismatch or
+uploads which stay incomplete longer than -timeout) are moved into quarantine directory
+with <name>.changes.reason file describing the reason.
+
+If no directories are given, queues from incomingQueues section of configuration file
+are watched, each with its own settings. Command runs until interrupted.
+
+Example:
+
+  $ aptly incoming watch -repo=foo-release -uploaders-file=uploaders.json /srv/incoming
+`,
+		Flag: *flag.NewFlagSet("aptly-incoming-watch", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("force-replace", false, "when adding package that conflicts with existing package, remove existing package")
+	cmd.Flag.String("repo", "{{.Distribution}}", "which repo should files go to, defaults to Distribution field of .changes file")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying .changes file (could be specified multiple times)")
+	cmd.Flag.Bool("ignore-signatures", false, "disable verification of .changes file signature")
+	cmd.Flag.Bool("accept-unsigned", false, "accept unsigned .changes files")
+	cmd.Flag.String("uploaders-file", "", "path to uploaders.json file")
+	cmd.Flag.String("quarantine", "", "directory to move rejected uploads to (default: quarantine subdirectory of incoming directory)")
+	cmd.Flag.Duration("settle", deb.DefaultIncomingSettle, "process uploads once directory hasn't changed for this duration")
+	cmd.Flag.Duration("timeout", deb.DefaultIncomingTimeout, "reject uploads which are still incomplete after this duration")
+
+	return cmd
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/flag"
+)
+
+// labelsFromFlag splits comma-separated list of labels in the flag
+//
+// Flag is a plain string, as -label flag of publish commands is a string
+// and flags with the same name should have the same type across all commands.
+// As a consequence label values can't contain commas (deb.ValidateLabels rejects them).
+func labelsFromFlag(flags *flag.FlagSet, name string) []string {
+	f := flags.Lookup(name)
+	if f == nil {
+		return nil
+	}
+
+	var result []string
+	for _, label := range strings.Split(f.Value.String(), ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			result = append(result, label)
+		}
+	}
+
+	return result
+}
+
+// addLabelFlags adds -label and (for editing) -remove-label flags
+func addLabelFlags(flags *flag.FlagSet, edit bool) {
+	flags.String("label", "", "set labels in key=value form (comma-separated, values can't contain commas)")
+	if edit {
+		flags.String("remove-label", "", "remove labels with specified keys (comma-separated)")
+	}
+}
+
+// addLabelSelectorFlag adds -label flag used to filter objects in list commands
+func addLabelSelectorFlag(flags *flag.FlagSet) {
+	flags.String("label", "", "show only objects with matching labels: key=value, key!=value, key or !key (comma-separated)")
+}
+
+// updateLabelsFromFlags applies -label and -remove-label flags to labels
+func updateLabelsFromFlags(flags *flag.FlagSet, labels map[string]string) (map[string]string, error) {
+	set := map[string]string{}
+	for _, label := range labelsFromFlag(flags, "label") {
+		if !strings.Contains(label, "=") {
+			return nil, fmt.Errorf("invalid label %#v, expected key=value (label values can't contain commas)", label)
+		}
+
+		key, value, err := deb.ParseLabel(label)
+		if err != nil {
+			return nil, err
+		}
+		set[key] = value
+	}
+
+	return deb.UpdateLabels(labels, set, labelsFromFlag(flags, "remove-label")), nil
+}
+
+// labelSelectorFromFlags parses -label flag of list commands
+func labelSelectorFromFlags(flags *flag.FlagSet) (deb.LabelSelector, error) {
+	selector, err := deb.ParseLabelSelector(labelsFromFlag(flags, "label"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list: %s", err)
+	}
+
+	return selector, nil
+}
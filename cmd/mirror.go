@@ -45,6 +45,23 @@ func (k *keyRingsFlag) String() string {
 	return strings.Join(k.keyRings, ",")
 }
 
+type fallbackURLsFlag struct {
+	urls []string
+}
+
+func (f *fallbackURLsFlag) Set(value string) error {
+	f.urls = append(f.urls, value)
+	return nil
+}
+
+func (f *fallbackURLsFlag) Get() interface{} {
+	return f.urls
+}
+
+func (f *fallbackURLsFlag) String() string {
+	return strings.Join(f.urls, ",")
+}
+
 func makeCmdMirror() *commander.Command {
 	return &commander.Command{
 		UsageLine: "mirror",
@@ -58,6 +75,8 @@ func makeCmdMirror() *commander.Command {
 			makeCmdMirrorRename(),
 			makeCmdMirrorEdit(),
 			makeCmdMirrorSearch(),
+			makeCmdMirrorHistory(),
+			makeCmdMirrorVerify(),
 		},
 	}
 }
@@ -51,6 +51,21 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 	repo.FilterWithDeps = context.Flags().Lookup("filter-with-deps").Value.Get().(bool)
 	repo.SkipComponentCheck = context.Flags().Lookup("force-components").Value.Get().(bool)
 	repo.SkipArchitectureCheck = context.Flags().Lookup("force-architectures").Value.Get().(bool)
+	repo.KeepVersions = context.Flags().Lookup("keep-versions").Value.Get().(int)
+
+	if repo.KeepVersions < 0 {
+		return fmt.Errorf("unable to create mirror: number of versions to keep should not be negative")
+	}
+
+	err = repo.SetFallbackArchiveRoots(context.Flags().Lookup("fallback-url").Value.Get().([]string))
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
+
+	repo.Labels, err = updateLabelsFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create mirror: %s", err)
+	}
 
 	if repo.Filter != "" {
 		_, err = query.Parse(repo.Filter)
@@ -64,7 +79,7 @@ func aptlyMirrorCreate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+	err = repo.Fetch(repo.Downloader(context.Downloader()), verifier, ignoreSignatures)
 	if err != nil {
 		return fmt.Errorf("unable to fetch mirror: %s", err)
 	}
@@ -93,6 +108,9 @@ PPA urls could specified in short format:
 
   $ aptly mirror create <name> ppa:<user>/<project>
 
+Equivalent archive urls could be specified with -fallback-url, aptly would try them in order
+if download from the archive url fails with connection error or HTTP 404/5xx.
+
 Example:
 
   $ aptly mirror create wheezy-main http://mirror.yandex.ru/debian/ wheezy main
@@ -109,8 +127,11 @@ Example:
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
 	cmd.Flag.Bool("force-components", false, "(only with component list) skip check that requested components are listed in Release file")
 	cmd.Flag.Bool("force-architectures", false, "(only with architecture list) skip check that requested architectures are listed in Release file")
+	cmd.Flag.Int("keep-versions", 0, "keep only N newest versions of each package (per architecture), 0 keeps all versions")
 	cmd.Flag.Int("max-tries", 1, "max download tries till process fails with download error")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+	cmd.Flag.Var(&fallbackURLsFlag{}, "fallback-url", "archive url to fail over to if archive url is not available (could be specified multiple times)")
+	addLabelFlags(&cmd.Flag, false)
 
 	return cmd
 }
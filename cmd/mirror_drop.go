@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -41,11 +42,26 @@ func aptlyMirrorDrop(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
 	err = collectionFactory.RemoteRepoCollection().Drop(repo)
 	if err != nil {
 		return fmt.Errorf("unable to drop: %s", err)
 	}
 
+	err = recordMirrorHistory(collectionFactory, repo.RefList(), nil, deb.PackageEventRemove, "", repo.Name)
+	if err != nil {
+		return err
+	}
+
+	err = collectionFactory.MirrorScheduleCollection().DropMirror(repo.Name)
+	if err != nil {
+		return fmt.Errorf("unable to drop schedules: %s", err)
+	}
+
 	fmt.Printf("Mirror `%s` has been removed.\n", repo.Name)
 
 	return err
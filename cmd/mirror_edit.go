@@ -35,6 +35,8 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 			repo.Filter = flag.Value.String() // allows file/stdin with @
 		case "filter-with-deps":
 			repo.FilterWithDeps = flag.Value.Get().(bool)
+		case "keep-versions":
+			repo.KeepVersions = flag.Value.Get().(int)
 		case "with-appstream":
 			repo.DownloadAppStream = flag.Value.Get().(bool)
 		case "with-installer":
@@ -46,11 +48,26 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 		case "archive-url":
 			repo.SetArchiveRoot(flag.Value.String())
 			fetchMirror = true
+		case "fallback-url":
+			err = repo.SetFallbackArchiveRoots(flag.Value.Get().([]string))
 		case "ignore-signatures":
 			ignoreSignatures = true
 		}
 	})
 
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	repo.Labels, err = updateLabelsFromFlags(context.Flags(), repo.Labels)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	if repo.KeepVersions < 0 {
+		return fmt.Errorf("unable to edit: number of versions to keep should not be negative")
+	}
+
 	if repo.IsFlat() && repo.DownloadUdebs {
 		return fmt.Errorf("unable to edit: flat mirrors don't support udebs")
 	}
@@ -78,7 +95,7 @@ func aptlyMirrorEdit(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 		}
 
-		err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+		err = repo.Fetch(repo.Downloader(context.Downloader()), verifier, ignoreSignatures)
 		if err != nil {
 			return fmt.Errorf("unable to edit: %s", err)
 		}
@@ -100,7 +117,8 @@ func makeCmdMirrorEdit() *commander.Command {
 		Short:     "edit mirror settings",
 		Long: `
 Command edit allows one to change settings of mirror:
-filters, list of architectures.
+filters, list of architectures, archive url and fallback archive urls.
+Specifying -fallback-url replaces whole list of fallback urls, use -fallback-url= to clear it.
 
 Example:
 
@@ -113,11 +131,14 @@ Example:
 	AddStringOrFileFlag(&cmd.Flag, "filter", "", "filter packages in mirror, use '@file' to read filter from file or '@-' for stdin")
 	cmd.Flag.Bool("filter-with-deps", false, "when filtering, include dependencies of matching packages as well")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
+	cmd.Flag.Int("keep-versions", 0, "keep only N newest versions of each package (per architecture), 0 keeps all versions")
 	cmd.Flag.Bool("with-appstream", false, "download AppStream (DEP-11) metadata")
 	cmd.Flag.Bool("with-installer", false, "download additional not packaged installer files")
 	cmd.Flag.Bool("with-sources", false, "download source packages in addition to binary packages")
 	cmd.Flag.Bool("with-udebs", false, "download .udeb packages (Debian installer support)")
 	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+	cmd.Flag.Var(&fallbackURLsFlag{}, "fallback-url", "archive url to fail over to if archive url is not available (could be specified multiple times)")
+	addLabelFlags(&cmd.Flag, true)
 
 	return cmd
 }
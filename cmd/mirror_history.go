@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyMirrorHistory(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name := args[0]
+
+	collection := context.NewCollectionFactory().RemoteRepoCollection()
+	repo, err := collection.ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to show history: %s", err)
+	}
+
+	err = collection.LoadReleaseHistory(repo)
+	if err != nil {
+		return fmt.Errorf("unable to show history: %s", err)
+	}
+
+	history := repo.ReleaseHistory
+
+	if context.Flags().Lookup("json").Value.Get().(bool) {
+		var output []byte
+		if output, err = json.MarshalIndent(history, "", "  "); err == nil {
+			fmt.Println(string(output))
+		}
+		return err
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("No Release files have been synced for mirror %s yet.\n", repo.Name)
+		return err
+	}
+
+	fmt.Printf("Release history of mirror %s:\n", repo.Name)
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+
+		fmt.Printf("\n * Date: %s\n", entry.Date)
+		if entry.ValidUntil != "" {
+			fmt.Printf("   Valid-Until: %s\n", entry.ValidUntil)
+		}
+		fmt.Printf("   First synced: %s\n", entry.FirstSynced.Format("2006-01-02 15:04:05 MST"))
+		fmt.Printf("   Last synced: %s\n", entry.LastSynced.Format("2006-01-02 15:04:05 MST"))
+		fmt.Printf("   %s SHA256: %s\n", entry.Filename, entry.Checksums.SHA256)
+		fmt.Printf("   Pool path: %s\n", entry.PoolPath)
+		if entry.SignaturePoolPath != "" {
+			fmt.Printf("   Signature pool path: %s\n", entry.SignaturePoolPath)
+		}
+	}
+
+	return err
+}
+
+func makeCmdMirrorHistory() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyMirrorHistory,
+		UsageLine: "history <name>",
+		Short:     "show history of upstream Release files",
+		Long: `
+Shows upstream Release (InRelease) files synced by the mirror, most recent first.
+Each distinct Release file is stored in the package pool when mirror is updated.
+
+Example:
+
+  $ aptly mirror history wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-mirror-history", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display history in JSON format")
+
+	return cmd
+}
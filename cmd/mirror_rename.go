@@ -42,6 +42,11 @@ func aptlyMirrorRename(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to rename: %s", err)
 	}
 
+	err = collectionFactory.MirrorScheduleCollection().RenameMirror(oldName, newName)
+	if err != nil {
+		return fmt.Errorf("unable to rename: %s", err)
+	}
+
 	fmt.Printf("\nMirror %s -> %s has been successfully renamed.\n", oldName, newName)
 
 	return err
@@ -48,6 +48,9 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Status: In Update (PID %d)\n", repo.WorkerPID)
 	}
 	fmt.Printf("Archive Root URL: %s\n", repo.ArchiveRoot)
+	if len(repo.FallbackArchiveRoots) > 0 {
+		fmt.Printf("Fallback Archive Root URLs: %s\n", strings.Join(repo.FallbackArchiveRoots, ", "))
+	}
 	fmt.Printf("Distribution: %s\n", repo.Distribution)
 	fmt.Printf("Components: %s\n", strings.Join(repo.Components, ", "))
 	fmt.Printf("Architectures: %s\n", strings.Join(repo.Architectures, ", "))
@@ -74,6 +77,12 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		}
 		fmt.Printf("Filter With Deps: %s\n", filterWithDeps)
 	}
+	if repo.KeepVersions > 0 {
+		fmt.Printf("Keep Versions: %d\n", repo.KeepVersions)
+	}
+	if len(repo.Labels) > 0 {
+		fmt.Printf("Labels: %s\n", deb.FormatLabels(repo.Labels))
+	}
 	if repo.LastDownloadDate.IsZero() {
 		fmt.Printf("Last update: never\n")
 	} else {
@@ -81,6 +90,22 @@ func aptlyMirrorShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Number of packages: %d\n", repo.NumPackages())
 	}
 
+	if len(repo.ArchiveRootHealth) > 0 {
+		fmt.Printf("\nArchive root health:\n")
+		for _, root := range repo.ArchiveRoots() {
+			health := repo.HealthOf(root)
+			switch {
+			case health == nil:
+				fmt.Printf("  %s: unknown\n", root)
+			case health.Healthy():
+				fmt.Printf("  %s: OK, last success %s\n", root, health.LastSuccess.Format("2006-01-02 15:04:05 MST"))
+			default:
+				fmt.Printf("  %s: FAILING, %d consecutive failure(s), last at %s: %s\n", root, health.ConsecutiveFailures,
+					health.LastFailure.Format("2006-01-02 15:04:05 MST"), health.LastError)
+			}
+		}
+	}
+
 	fmt.Printf("\nInformation from release file:\n")
 	for _, k := range utils.StrMapSortedKeys(repo.Meta) {
 		fmt.Printf("%s: %s\n", k, repo.Meta[k])
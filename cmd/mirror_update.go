@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
@@ -34,6 +35,11 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	err = collectionFactory.RemoteRepoCollection().LoadReleaseHistory(repo)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
 	force := context.Flags().Lookup("force").Value.Get().(bool)
 	if !force {
 		err = repo.CheckLock()
@@ -53,20 +59,29 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
-	err = repo.Fetch(context.Downloader(), verifier, ignoreSignatures)
+	downloader := repo.Downloader(context.Downloader())
+
+	err = repo.Fetch(downloader, verifier, ignoreSignatures)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	if !context.Flags().Lookup("force-release").Value.Get().(bool) {
+		err = repo.CheckReleaseFreshness(time.Now())
+		if err != nil {
+			return fmt.Errorf("unable to update: %s", err)
+		}
+	}
+
 	context.Progress().Printf("Downloading & parsing package files...\n")
-	err = repo.DownloadPackageIndexes(context.Progress(), context.Downloader(), verifier, collectionFactory, ignoreSignatures, ignoreChecksums)
+	err = repo.DownloadPackageIndexes(context.Progress(), downloader, verifier, collectionFactory, ignoreSignatures, ignoreChecksums)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
 	if repo.DownloadAppStream && !repo.IsFlat() {
 		context.Progress().Printf("Downloading AppStream metadata...\n")
-		err = repo.DownloadAppStreamFiles(context.Progress(), context.Downloader(),
+		err = repo.DownloadAppStreamFiles(context.Progress(), downloader,
 			context.PackagePool(), collectionFactory.ChecksumCollection(nil), ignoreChecksums)
 		if err != nil {
 			return fmt.Errorf("unable to update: %s", err)
@@ -192,7 +207,7 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 					}
 
 					// download file...
-					e = context.Downloader().DownloadWithChecksum(
+					e = downloader.DownloadWithChecksum(
 						context,
 						repo.PackageURL(task.File.DownloadURL()).String(),
 						task.TempDownPath,
@@ -271,12 +286,24 @@ func aptlyMirrorUpdate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to update: download errors:\n  %s", strings.Join(errors, "\n  "))
 	}
 
+	err = repo.RecordRelease(context.PackagePool(), collectionFactory.ChecksumCollection(nil), time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	before := repo.RefList()
+
 	_ = repo.FinalizeDownload(collectionFactory, context.Progress())
 	err = collectionFactory.RemoteRepoCollection().Update(repo)
 	if err != nil {
 		return fmt.Errorf("unable to update: %s", err)
 	}
 
+	err = recordMirrorHistory(collectionFactory, before, repo.RefList(), deb.PackageEventMirrorImport, repo.ArchiveRoot, repo.Name)
+	if err != nil {
+		return err
+	}
+
 	context.Progress().Printf("\nMirror `%s` has been updated successfully.\n", repo.Name)
 	return err
 }
@@ -291,6 +318,10 @@ Updates remote mirror (downloads package files and meta information). When mirro
 this command should be run for the first time to fetch mirror contents. This command can be
 run multiple times to get updated repository contents. If interrupted, command can be safely restarted.
 
+Every synced upstream Release file is kept in the mirror history (see aptly mirror history).
+Update is rejected if Release file date is older than previously synced one or if its
+Valid-Until date has passed, unless -force-release is specified.
+
 Example:
 
   $ aptly mirror update wheezy-main
@@ -299,6 +330,7 @@ Example:
 	}
 
 	cmd.Flag.Bool("force", false, "force update mirror even if it is locked by another process")
+	cmd.Flag.Bool("force-release", false, "accept Release file older than previously synced one or with expired Valid-Until")
 	cmd.Flag.Bool("ignore-checksums", false, "ignore checksum mismatches while downloading package files and metadata")
 	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
 	cmd.Flag.Bool("skip-existing-packages", false, "do not check file existence for packages listed in the internal database of the mirror")
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyMirrorVerify(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	name := args[0]
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.RemoteRepoCollection().ByName(name)
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	err = collectionFactory.RemoteRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	if repo.RefList() == nil {
+		return fmt.Errorf("unable to verify: mirror not updated")
+	}
+
+	list, err := deb.NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	context.Progress().Printf("Verifying package files...\n")
+	report, err := deb.VerifyPackageFiles(list, context.PackagePool(), collectionFactory.ChecksumCollection(nil), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to verify: %s", err)
+	}
+
+	for _, problem := range report.Problems {
+		if problem.Details != "" {
+			context.Progress().ColoredPrintf("@r[!]@| %s (%s): %s, %s", problem.PoolPath, problem.Package, problem.Problem, problem.Details)
+		} else {
+			context.Progress().ColoredPrintf("@r[!]@| %s (%s): %s", problem.PoolPath, problem.Package, problem.Problem)
+		}
+	}
+
+	context.Progress().Printf("Verified %d files of %d packages, %d problems found.\n", report.Files, report.Packages, len(report.Problems))
+
+	if len(report.Problems) > 0 && context.Flags().Lookup("repair").Value.Get().(bool) {
+		err = repo.CheckLock()
+		if err != nil {
+			return fmt.Errorf("unable to repair: %s", err)
+		}
+
+		// metadata fetched for repair is not saved, mirror is restored as it was before
+		saved := repo.Encode()
+		defer func() {
+			idle := &deb.RemoteRepo{}
+			if e := idle.Decode(saved); e == nil {
+				idle.MarkAsIdle()
+				_ = collectionFactory.RemoteRepoCollection().Update(idle)
+			}
+		}()
+
+		repo.MarkAsUpdating()
+		err = collectionFactory.RemoteRepoCollection().Update(repo)
+		if err != nil {
+			return fmt.Errorf("unable to repair: %s", err)
+		}
+
+		ignoreSignatures := context.Config().GpgDisableVerify
+		if context.Flags().IsSet("ignore-signatures") {
+			ignoreSignatures = context.Flags().Lookup("ignore-signatures").Value.Get().(bool)
+		}
+
+		verifier, err := getVerifier(context.Flags())
+		if err != nil {
+			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+		}
+
+		downloader := repo.Downloader(context.Downloader())
+
+		err = repo.Fetch(downloader, verifier, ignoreSignatures)
+		if err != nil {
+			return fmt.Errorf("unable to repair: %s", err)
+		}
+
+		context.Progress().Printf("Downloading & parsing package files...\n")
+		err = repo.DownloadPackageIndexes(context.Progress(), downloader, verifier, collectionFactory, ignoreSignatures, false)
+		if err != nil {
+			return fmt.Errorf("unable to repair: %s", err)
+		}
+
+		context.Progress().Printf("Repairing package files...\n")
+		err = repo.RepairFiles(report, downloader, context.PackagePool(), collectionFactory.ChecksumCollection(nil), context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to repair: %s", err)
+		}
+	}
+
+	if unrepaired := report.Unrepaired(); unrepaired > 0 {
+		return fmt.Errorf("mirror %s has %d missing or corrupt files", repo.Name, unrepaired)
+	}
+
+	context.Progress().Printf("\nMirror `%s` has been verified successfully.\n", repo.Name)
+	return err
+}
+
+func makeCmdMirrorVerify() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyMirrorVerify,
+		UsageLine: "verify <name>",
+		Short:     "verify package files of the mirror",
+		Long: `
+Verifies that all the package files of the mirror are present in the package pool
+and match their checksums. With -repair, missing and corrupt files are downloaded
+again from the upstream repository.
+
+Example:
+
+  $ aptly mirror verify -repair wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-mirror-verify", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("repair", false, "re-download missing and corrupt files from upstream")
+	cmd.Flag.Bool("ignore-signatures", false, "disable verification of Release file signatures")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying Release file (could be specified multiple times)")
+
+	return cmd
+}
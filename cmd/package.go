@@ -11,6 +11,7 @@ func makeCmdPackage() *commander.Command {
 		Subcommands: []*commander.Command{
 			makeCmdPackageSearch(),
 			makeCmdPackageShow(),
+			makeCmdPackageHistory(),
 		},
 	}
 }
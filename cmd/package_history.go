@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+// historyActor identifies user running aptly command in package history
+func historyActor() string {
+	name := os.Getenv("USER")
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if name == "" {
+		name = "unknown"
+	}
+
+	return "cli:" + name
+}
+
+// recordPackageHistory records changes between reflists of the target local repository
+func recordPackageHistory(collectionFactory *deb.CollectionFactory, before, after *deb.PackageRefList, action, source, target string) error {
+	err := collectionFactory.PackageHistoryCollection().RecordRefListChange(before, after, deb.PackageEvent{
+		Action:     action,
+		Actor:      historyActor(),
+		Source:     source,
+		Target:     target,
+		TargetType: deb.PackageEventTargetRepo,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record package history: %s", err)
+	}
+
+	return nil
+}
+
+// recordMirrorHistory records changes between reflists of the target mirror
+func recordMirrorHistory(collectionFactory *deb.CollectionFactory, before, after *deb.PackageRefList, action, source, target string) error {
+	err := collectionFactory.PackageHistoryCollection().RecordRefListChange(before, after, deb.PackageEvent{
+		Action:     action,
+		Actor:      historyActor(),
+		Source:     source,
+		Target:     target,
+		TargetType: deb.PackageEventTargetMirror,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to record package history: %s", err)
+	}
+
+	return nil
+}
+
+func aptlyPackageHistory(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	events, err := context.NewCollectionFactory().PackageHistoryCollection().ByKey([]byte(args[0]))
+	if err != nil {
+		return fmt.Errorf("unable to show history: %s", err)
+	}
+
+	if context.Flags().Lookup("json").Value.Get().(bool) {
+		var output []byte
+		if output, err = json.MarshalIndent(events, "", "  "); err == nil {
+			fmt.Println(string(output))
+		}
+		return err
+	}
+
+	if len(events) == 0 {
+		fmt.Printf("No history has been recorded for package %s.\n", args[0])
+		return err
+	}
+
+	fmt.Printf("History of package %s:\n", args[0])
+	for _, event := range events {
+		fmt.Printf("\n * %s: %s\n", event.Timestamp.Local().Format("2006-01-02 15:04:05 MST"), event.Action)
+		fmt.Printf("   Actor: %s\n", event.Actor)
+		if event.Source != "" {
+			fmt.Printf("   Source: %s\n", event.Source)
+		}
+		fmt.Printf("   Target: %s\n", event.Target)
+	}
+
+	return err
+}
+
+func makeCmdPackageHistory() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPackageHistory,
+		UsageLine: "history <key>",
+		Short:     "show provenance history of the package",
+		Long: `
+Shows the events recorded for the package, oldest first: when package has been added to,
+copied, moved or removed from local repositories, included with .changes files or imported
+from mirrors, and who has done that (CLI user or API client). History is kept after
+local repository or mirror is dropped, its packages are recorded as removed.
+
+Package is identified by its key, which could be displayed with
+'aptly package search -format "{{.Key}}"' or by the API.
+
+Example:
+
+  $ aptly package history 'Pi386 libpam-doc 1.1.3-7 3a2b71c9d1e8ab5e'
+`,
+		Flag: *flag.NewFlagSet("aptly-package-history", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display history in JSON format")
+
+	return cmd
+}
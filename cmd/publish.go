@@ -65,11 +65,13 @@ func makeCmdPublish() *commander.Command {
 		Subcommands: []*commander.Command{
 			makeCmdPublishDrop(),
 			makeCmdPublishList(),
+			makeCmdPublishProtect(),
 			makeCmdPublishRepo(),
 			makeCmdPublishShow(),
 			makeCmdPublishSnapshot(),
 			makeCmdPublishSource(),
 			makeCmdPublishSwitch(),
+			makeCmdPublishUnprotect(),
 			makeCmdPublishUpdate(),
 		},
 	}
@@ -44,7 +44,8 @@ func makeCmdPublishDrop() *commander.Command {
 		Short:     "remove published repository",
 		Long: `
 Command removes whatever has been published under specified <prefix>,
-publishing <endpoint> and <distribution> name.
+publishing <endpoint> and <distribution> name. Protected published
+repository can't be removed.
 
 Example:
 
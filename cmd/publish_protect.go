@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+func aptlyPublishProtectUnprotect(cmd *commander.Command, args []string, protect bool) error {
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	distribution := args[0]
+	param := "."
+
+	if len(args) == 2 {
+		param = args[1]
+	}
+
+	storage, prefix := deb.ParsePrefix(param)
+
+	collectionFactory := context.NewCollectionFactory()
+	published, err := collectionFactory.PublishedRepoCollection().ByStoragePrefixDistribution(storage, prefix, distribution)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	published.Protected = protect
+	err = collectionFactory.PublishedRepoCollection().Update(published)
+	if err != nil {
+		return fmt.Errorf("unable to save to DB: %s", err)
+	}
+
+	if protect {
+		context.Progress().Printf("Published repository %s/%s is protected now.\n", published.StoragePrefix(), published.Distribution)
+	} else {
+		context.Progress().Printf("Published repository %s/%s is not protected anymore.\n", published.StoragePrefix(), published.Distribution)
+	}
+
+	return err
+}
+
+func aptlyPublishProtect(cmd *commander.Command, args []string) error {
+	return aptlyPublishProtectUnprotect(cmd, args, true)
+}
+
+func aptlyPublishUnprotect(cmd *commander.Command, args []string) error {
+	return aptlyPublishProtectUnprotect(cmd, args, false)
+}
+
+func makeCmdPublishProtect() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishProtect,
+		UsageLine: "protect <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "protect published repository from being dropped or switched",
+		Long: `
+Command protect marks published repository as protected: it can't be dropped,
+switched to other snapshots or updated with changed sources (staged with
+'aptly publish source' commands) until it is unprotected with 'aptly publish unprotect'.
+Published local repositories could still be updated with new contents.
+
+Example:
+
+    $ aptly publish protect wheezy ppa
+`,
+	}
+
+	return cmd
+}
+
+func makeCmdPublishUnprotect() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyPublishUnprotect,
+		UsageLine: "unprotect <distribution> [[<endpoint>:]<prefix>]",
+		Short:     "remove protection from published repository",
+		Long: `
+Command unprotect removes protection set with 'aptly publish protect'.
+
+Example:
+
+    $ aptly publish unprotect wheezy ppa
+`,
+	}
+
+	return cmd
+}
@@ -50,6 +50,12 @@ func aptlyPublishShowTxt(_ *commander.Command, args []string) error {
 		fmt.Printf("Distribution: %s\n", repo.Distribution)
 	}
 	fmt.Printf("Architectures: %s\n", strings.Join(repo.Architectures, " "))
+	if repo.Protected {
+		fmt.Printf("Protected: yes\n")
+	}
+	if repo.PublishBuildinfo {
+		fmt.Printf("Publish .buildinfo: yes\n")
+	}
 
 	fmt.Printf("Sources:\n")
 	for _, component := range repo.Components() {
@@ -158,6 +158,10 @@ func aptlyPublishSnapshotOrRepo(cmd *commander.Command, args []string) error {
 		published.MultiDist = context.Flags().Lookup("multi-dist").Value.Get().(bool)
 	}
 
+	if context.Flags().IsSet("buildinfo") {
+		published.PublishBuildinfo = context.Flags().Lookup("buildinfo").Value.Get().(bool)
+	}
+
 	if context.Flags().IsSet("version") {
 		published.Version = context.Flags().Lookup("version").Value.String()
 	}
@@ -260,6 +264,7 @@ Example:
 	cmd.Flag.String("signed-by", "", "an optional field containing a comma separated list of OpenPGP key fingerprints to be used for validating the next Release file")
 	cmd.Flag.String("version", "", "version of the release")
 	cmd.Flag.Bool("multi-dist", false, "enable multiple packages with the same filename in different distributions")
+	cmd.Flag.Bool("buildinfo", false, "publish .buildinfo files attached to packages under buildinfo/")
 
 	return cmd
 }
@@ -47,6 +47,11 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to switch: not a published snapshot repository")
 	}
 
+	err = published.CheckUnprotected()
+	if err != nil {
+		return fmt.Errorf("unable to switch: %s", err)
+	}
+
 	err = collectionFactory.PublishedRepoCollection().LoadComplete(published, collectionFactory)
 	if err != nil {
 		return fmt.Errorf("unable to switch: %s", err)
@@ -111,6 +116,10 @@ func aptlyPublishSwitch(cmd *commander.Command, args []string) error {
 		published.MultiDist = context.Flags().Lookup("multi-dist").Value.Get().(bool)
 	}
 
+	if context.Flags().IsSet("buildinfo") {
+		published.PublishBuildinfo = context.Flags().Lookup("buildinfo").Value.Get().(bool)
+	}
+
 	err = published.Publish(context.PackagePool(), context, collectionFactory, signer, context.Progress(), forceOverwrite, context.SkelPath())
 	if err != nil {
 		return fmt.Errorf("unable to publish: %s", err)
@@ -142,7 +151,7 @@ func makeCmdPublishSwitch() *commander.Command {
 		Long: `
 Command switches in-place published snapshots with new source contents. All
 publishing parameters are preserved (architecture list, distribution,
-component).
+component). Protected published repository can't be switched.
 
 For multiple component repositories, flag -component should be given with
 list of components to update. Corresponding sources should be given in the
@@ -174,6 +183,7 @@ This command would switch published repository (with one component) named ppa/wh
 	cmd.Flag.String("version", "", "version of the release")
 	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
 	cmd.Flag.Bool("multi-dist", false, "enable multiple packages with the same filename in different distributions")
+	cmd.Flag.Bool("buildinfo", false, "publish .buildinfo files attached to packages under buildinfo/")
 
 	return cmd
 }
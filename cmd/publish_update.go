@@ -76,6 +76,10 @@ func aptlyPublishUpdate(cmd *commander.Command, args []string) error {
 		published.MultiDist = context.Flags().Lookup("multi-dist").Value.Get().(bool)
 	}
 
+	if context.Flags().IsSet("buildinfo") {
+		published.PublishBuildinfo = context.Flags().Lookup("buildinfo").Value.Get().(bool)
+	}
+
 	if context.Flags().IsSet("version") {
 		published.Version = context.Flags().Lookup("version").Value.String()
 	}
@@ -144,6 +148,7 @@ Example:
 	cmd.Flag.String("signed-by", "", "an optional field containing a comma separated list of OpenPGP key fingerprints to be used for validating the next Release file")
 	cmd.Flag.Bool("skip-cleanup", false, "don't remove unreferenced files in prefix/component")
 	cmd.Flag.Bool("multi-dist", false, "enable multiple packages with the same filename in different distributions")
+	cmd.Flag.Bool("buildinfo", false, "publish .buildinfo files attached to packages under buildinfo/")
     cmd.Flag.String("origin", "", "overwrite origin name to publish")
     cmd.Flag.String("label", "", "overwrite label to publish")
     cmd.Flag.String("version", "", "version of the release")
@@ -10,18 +10,23 @@ func makeCmdRepo() *commander.Command {
 		Short:     "manage local package repositories",
 		Subcommands: []*commander.Command{
 			makeCmdRepoAdd(),
+			makeCmdRepoCleanup(),
 			makeCmdRepoCopy(),
+			makeCmdRepoCopySource(),
 			makeCmdRepoCreate(),
 			makeCmdRepoDrop(),
 			makeCmdRepoEdit(),
 			makeCmdRepoImport(),
 			makeCmdRepoList(),
 			makeCmdRepoMove(),
+			makeCmdRepoMoveSource(),
 			makeCmdRepoRemove(),
+			makeCmdRepoRemoveSource(),
 			makeCmdRepoShow(),
 			makeCmdRepoRename(),
 			makeCmdRepoSearch(),
 			makeCmdRepoInclude(),
+			makeCmdRepoUploaders(),
 		},
 	}
 }
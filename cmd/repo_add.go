@@ -3,9 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/pgp"
 	"github.com/aptly-dev/aptly/utils"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
@@ -46,18 +48,38 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 
 	packageFiles, otherFiles, failedFiles = deb.CollectPackageFiles(args[1:], &aptly.ConsoleResultReporter{Progress: context.Progress()})
 
+	var debsigVerifier pgp.Verifier
+	if repo.RequireDebsig || context.Flags().Lookup("require-debsig").Value.Get().(bool) {
+		debsigVerifier, err = getDebsigVerifier()
+		if err != nil {
+			return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+		}
+		if debsigVerifier == nil {
+			return fmt.Errorf("unable to add: embedded .deb signatures are required, but debsigKeyrings are not configured")
+		}
+	}
+
 	var processedFiles, failedFiles2 []string
 
 	processedFiles, failedFiles2, err = deb.ImportPackageFiles(list, packageFiles, forceReplace, verifier, context.PackagePool(),
 		collectionFactory.PackageCollection(), &aptly.ConsoleResultReporter{Progress: context.Progress()}, nil,
-		collectionFactory.ChecksumCollection)
+		repo.VersionPolicy, repo.LintPolicy, debsigVerifier, collectionFactory.ChecksumCollection)
 	failedFiles = append(failedFiles, failedFiles2...)
 	if err != nil {
 		return fmt.Errorf("unable to import package files: %s", err)
 	}
 
-	processedFiles = append(processedFiles, otherFiles...)
+	var processedFiles2 []string
 
+	processedFiles2, failedFiles2, err = deb.ImportBuildinfoFiles(list, repo.RefList(), otherFiles, verifier, context.PackagePool(),
+		collectionFactory.PackageCollection(), &aptly.ConsoleResultReporter{Progress: context.Progress()}, collectionFactory.ChecksumCollection)
+	processedFiles = append(processedFiles, processedFiles2...)
+	failedFiles = append(failedFiles, failedFiles2...)
+	if err != nil {
+		return fmt.Errorf("unable to import .buildinfo files: %s", err)
+	}
+
+	before := repo.RefList()
 	repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 	err = collectionFactory.LocalRepoCollection().Update(repo)
@@ -65,6 +87,11 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to save: %s", err)
 	}
 
+	err = recordPackageHistory(collectionFactory, before, repo.RefList(), deb.PackageEventAdd, strings.Join(args[1:], " "), repo.Name)
+	if err != nil {
+		return err
+	}
+
 	if context.Flags().Lookup("remove-files").Value.Get().(bool) {
 		processedFiles = utils.StrSliceDeduplicate(processedFiles)
 
@@ -88,6 +115,27 @@ func aptlyRepoAdd(cmd *commander.Command, args []string) error {
 	return err
 }
 
+// getDebsigVerifier returns verifier for signatures embedded into .deb packages
+//
+// Only keyrings from debsigKeyrings are used, nil is returned if none are configured.
+func getDebsigVerifier() (pgp.Verifier, error) {
+	if len(context.Config().DebsigKeyrings) == 0 {
+		return nil, nil
+	}
+
+	verifier := context.GetVerifier()
+	for _, keyRing := range context.Config().DebsigKeyrings {
+		verifier.AddKeyring(keyRing)
+	}
+
+	err := verifier.InitKeyring(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifier, nil
+}
+
 func makeCmdRepoAdd() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyRepoAdd,
@@ -99,6 +147,10 @@ When importing from directory aptly would do recursive scan looking for all file
 patterns. Every file discovered would be analyzed to extract metadata, package would then be created and added
 to the database. Files would be imported to internal package pool. For source packages, all required files are
 added automatically as well. Extra files for source package should be in the same directory as *.dsc file.
+.buildinfo files are attached to the packages they describe.
+
+With -require-debsig (or if repository requires it), .deb packages should carry valid embedded signatures
+(_gpgorigin, _gpgbuilder, ... created by debsigs), fingerprints of signing keys are recorded as $SignedBy.
 
 Example:
 
@@ -107,6 +159,7 @@ Example:
 		Flag: *flag.NewFlagSet("aptly-repo-add", flag.ExitOnError),
 	}
 
+	cmd.Flag.Bool("require-debsig", false, "require valid signatures embedded into .deb packages (verified with debsigKeyrings from configuration)")
 	cmd.Flag.Bool("remove-files", false, "remove files that have been imported successfully into repository")
 	cmd.Flag.Bool("force-replace", false, "when adding package that conflicts with existing package, remove existing package")
 
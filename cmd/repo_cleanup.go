@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyRepoCleanup(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	options := deb.LocalRepoCleanupOptions{
+		Keep: context.Flags().Lookup("keep").Value.Get().(int),
+	}
+
+	if options.Keep < 1 {
+		return fmt.Errorf("unable to cleanup: -keep should be positive")
+	}
+
+	if olderThan := context.Flags().Lookup("older-than").Value.Get().(string); olderThan != "" {
+		age, err := deb.ParseRetentionAge(olderThan)
+		if err != nil {
+			return fmt.Errorf("unable to cleanup: %s", err)
+		}
+		options.OlderThan = time.Now().Add(-age)
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.LocalRepoCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to cleanup: %s", err)
+	}
+
+	err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to cleanup: %s", err)
+	}
+
+	options.Repo = repo
+
+	options.Pinned, err = collectionFactory.SnapshotCollection().PinnedPackageRefs(collectionFactory.PublishedRepoCollection())
+	if err != nil {
+		return fmt.Errorf("unable to cleanup: %s", err)
+	}
+
+	context.Progress().Printf("Loading packages...\n")
+
+	list, err := deb.NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	decisions, err := deb.PlanLocalRepoCleanup(list, options)
+	if err != nil {
+		return fmt.Errorf("unable to cleanup: %s", err)
+	}
+
+	removed := 0
+	for _, decision := range decisions {
+		if !decision.Remove {
+			context.Progress().ColoredPrintf("@g[keep]@| %s (%s)", decision.Name, decision.Reason)
+			continue
+		}
+
+		removed++
+		list.Remove(decision.Package())
+		if dryRun {
+			context.Progress().ColoredPrintf("@r[-]@| %s would be removed", decision.Name)
+		} else {
+			context.Progress().ColoredPrintf("@r[-]@| %s removed", decision.Name)
+		}
+	}
+
+	if dryRun {
+		context.Progress().Printf("\n%d packages would be removed (dry run).\n", removed)
+		return nil
+	}
+
+	if removed > 0 {
+		before := repo.RefList()
+		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
+
+		err = collectionFactory.LocalRepoCollection().Update(repo)
+		if err != nil {
+			return fmt.Errorf("unable to save: %s", err)
+		}
+
+		err = recordPackageHistory(collectionFactory, before, repo.RefList(), deb.PackageEventRemove, "", repo.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	context.Progress().Printf("\n%d packages removed.\n", removed)
+	return err
+}
+
+func makeCmdRepoCleanup() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoCleanup,
+		UsageLine: "cleanup <name>",
+		Short:     "remove old versions of packages from local repository",
+		Long: `
+Command cleanup removes all but N newest versions of each package (per
+architecture) from local repository. With -older-than only versions added
+to the repository longer ago than specified age (e.g. 72h, 30d, 4w) are removed.
+
+Versions referenced by protected or published snapshots are never removed.
+Package files are not deleted from the pool until 'aptly db cleanup' is run.
+
+Example:
+
+  $ aptly repo cleanup -keep=3 -older-than=30d nightly
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-cleanup", flag.ExitOnError),
+	}
+
+	cmd.Flag.Int("keep", 0, "number of newest versions of each package to keep")
+	cmd.Flag.String("older-than", "", "remove only versions older than this age")
+	cmd.Flag.Bool("dry-run", false, "don't remove, just show what would be removed")
+
+	return cmd
+}
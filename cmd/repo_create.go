@@ -19,6 +19,16 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 	repo.DefaultDistribution = context.Flags().Lookup("distribution").Value.String()
 	repo.DefaultComponent = context.Flags().Lookup("component").Value.String()
 
+	repo.Labels, err = updateLabelsFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to add local repo: %s", err)
+	}
+
+	repo.VersionPolicy, err = deb.ParseVersionPolicy(context.Flags().Lookup("version-policy").Value.String())
+	if err != nil {
+		return fmt.Errorf("unable to add local repo: %s", err)
+	}
+
 	uploadersFile := context.Flags().Lookup("uploaders-file").Value.Get().(string)
 	if uploadersFile != "" {
 		repo.Uploaders, err = deb.NewUploadersFromFile(uploadersFile)
@@ -27,6 +37,16 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	repo.RequireDebsig = context.Flags().Lookup("require-debsig").Value.Get().(bool)
+
+	lintPolicyFile := context.Flags().Lookup("lint-policy-file").Value.Get().(string)
+	if lintPolicyFile != "" {
+		repo.LintPolicy, err = deb.NewLintPolicyFromFile(lintPolicyFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	collectionFactory := context.NewCollectionFactory()
 	if len(args) == 4 {
 		var snapshot *deb.Snapshot
@@ -49,6 +69,13 @@ func aptlyRepoCreate(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to add local repo: %s", err)
 	}
 
+	if len(args) == 4 {
+		err = recordPackageHistory(collectionFactory, nil, repo.RefList(), deb.PackageEventCopy, "snapshot "+args[3], repo.Name)
+		if err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("\nLocal repo %s successfully added.\nYou can run 'aptly repo add %s ...' to add packages to repository.\n", repo, repo.Name)
 	return err
 }
@@ -66,11 +93,29 @@ another local repository or imported from the mirror.
 If local package repository is created from snapshot, repo initial
 contents are copied from snapsot contents.
 
+Version policy protects repository from accidental downgrades: with policy
+reject-older, package can't be added, copied, moved or imported if the
+repository contains newer version of the package with the same name and
+architecture; policy reject-equal-different-content additionally rejects
+packages with the same version, but different contents, even with -force-replace.
+
+Lint policy (JSON file with severity of each check) is applied to package files
+added to the repository: checks with severity "error" prevent package from being
+imported, "warning" checks are only reported. Supported checks: control-fields,
+trailing-whitespace, required-fields, filename, dsc-checksums, max-size, e.g.:
+
+  {"checks": {"filename": "error", "required-fields": "warning"}, "requiredFields": ["Maintainer"]}
+
+With -require-debsig, .deb packages added to the repository should carry valid embedded
+signatures (debsig) made by keys from debsigKeyrings configuration option.
+
 Example:
 
   $ aptly repo create testing
 
   $ aptly repo create mysql35 from snapshot mysql-35-2017
+
+  $ aptly repo create -version-policy=reject-older stable
 `,
 		Flag: *flag.NewFlagSet("aptly-repo-create", flag.ExitOnError),
 	}
@@ -79,6 +124,10 @@ Example:
 	cmd.Flag.String("distribution", "", "default distribution when publishing")
 	cmd.Flag.String("component", "main", "default component when publishing")
 	cmd.Flag.String("uploaders-file", "", "uploaders.json to be used when including .changes into this repository")
+	cmd.Flag.Bool("require-debsig", false, "require valid signatures embedded into .deb packages being added")
+	cmd.Flag.String("lint-policy-file", "", "lint policy .json file with checks applied to packages being added")
+	cmd.Flag.String("version-policy", "none", "policy on versions of packages being added: none, reject-older, reject-equal-different-content")
+	addLabelFlags(&cmd.Flag, false)
 
 	return cmd
 }
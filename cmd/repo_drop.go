@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
@@ -50,11 +51,21 @@ func aptlyRepoDrop(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
 	err = collectionFactory.LocalRepoCollection().Drop(repo)
 	if err != nil {
 		return fmt.Errorf("unable to drop: %s", err)
 	}
 
+	err = recordPackageHistory(collectionFactory, repo.RefList(), nil, deb.PackageEventRemove, "", repo.Name)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("Local repo `%s` has been removed.\n", repo.Name)
 
 	return err
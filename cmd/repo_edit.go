@@ -27,7 +27,7 @@ func aptlyRepoEdit(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to edit: %s", err)
 	}
 
-	var uploadersFile *string
+	var uploadersFile, versionPolicy, lintPolicyFile *string
 
 	context.Flags().Visit(func(flag *flag.Flag) {
 		switch flag.Name {
@@ -39,9 +39,27 @@ func aptlyRepoEdit(cmd *commander.Command, args []string) error {
 			repo.DefaultComponent = flag.Value.String()
 		case "uploaders-file":
 			uploadersFile = pointer.ToString(flag.Value.String())
+		case "version-policy":
+			versionPolicy = pointer.ToString(flag.Value.String())
+		case "lint-policy-file":
+			lintPolicyFile = pointer.ToString(flag.Value.String())
+		case "require-debsig":
+			repo.RequireDebsig = flag.Value.Get().(bool)
 		}
 	})
 
+	repo.Labels, err = updateLabelsFromFlags(context.Flags(), repo.Labels)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	if versionPolicy != nil {
+		repo.VersionPolicy, err = deb.ParseVersionPolicy(*versionPolicy)
+		if err != nil {
+			return fmt.Errorf("unable to edit: %s", err)
+		}
+	}
+
 	if uploadersFile != nil {
 		if *uploadersFile != "" {
 			repo.Uploaders, err = deb.NewUploadersFromFile(*uploadersFile)
@@ -53,6 +71,17 @@ func aptlyRepoEdit(cmd *commander.Command, args []string) error {
 		}
 	}
 
+	if lintPolicyFile != nil {
+		if *lintPolicyFile != "" {
+			repo.LintPolicy, err = deb.NewLintPolicyFromFile(*lintPolicyFile)
+			if err != nil {
+				return err
+			}
+		} else {
+			repo.LintPolicy = nil
+		}
+	}
+
 	err = collectionFactory.LocalRepoCollection().Update(repo)
 	if err != nil {
 		return fmt.Errorf("unable to edit: %s", err)
@@ -69,11 +98,15 @@ func makeCmdRepoEdit() *commander.Command {
 		Short:     "edit properties of local repository",
 		Long: `
 Command edit allows one to change metadata of local repository:
-comment, default distribution and component.
+comment, default distribution and component, labels, version and lint policies,
+requirement of embedded .deb signatures.
+Empty -uploaders-file or -lint-policy-file removes the setting.
 
 Example:
 
   $ aptly repo edit -distribution=wheezy testing
+
+  $ aptly repo edit -label team=core -remove-label owner testing
 `,
 		Flag: *flag.NewFlagSet("aptly-repo-edit", flag.ExitOnError),
 	}
@@ -82,6 +115,10 @@ Example:
 	cmd.Flag.String("distribution", "", "default distribution when publishing")
 	cmd.Flag.String("component", "", "default component when publishing")
 	cmd.Flag.String("uploaders-file", "", "uploaders.json to be used when including .changes into this repository")
+	cmd.Flag.Bool("require-debsig", false, "require valid signatures embedded into .deb packages being added")
+	cmd.Flag.String("lint-policy-file", "", "lint policy .json file with checks applied to packages being added")
+	cmd.Flag.String("version-policy", "", "policy on versions of packages being added: none, reject-older, reject-equal-different-content")
+	addLabelFlags(&cmd.Flag, true)
 
 	return cmd
 }
@@ -43,20 +43,14 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("error parsing -repo template: %s", err)
 	}
 
-	uploaders := (*deb.Uploaders)(nil)
-	uploadersFile := context.Flags().Lookup("uploaders-file").Value.Get().(string)
-	if uploadersFile != "" {
-		uploaders, err = deb.NewUploadersFromFile(uploadersFile)
-		if err != nil {
-			return err
-		}
+	uploaders, err := loadUploaders(context.Flags().Lookup("uploaders-file").Value.Get().(string))
+	if err != nil {
+		return err
+	}
 
-		for i := range uploaders.Rules {
-			uploaders.Rules[i].CompiledCondition, err = query.Parse(uploaders.Rules[i].Condition)
-			if err != nil {
-				return fmt.Errorf("error parsing query %s: %s", uploaders.Rules[i].Condition, err)
-			}
-		}
+	debsigVerifier, err := getDebsigVerifier()
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
 	}
 
 	reporter := &aptly.ConsoleResultReporter{Progress: context.Progress()}
@@ -65,10 +59,10 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 
 	changesFiles, failedFiles = deb.CollectChangesFiles(args, reporter)
 	_, failedFiles2, err = deb.ImportChangesFiles(
-		changesFiles, reporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles, verifier, repoTemplate,
+		changesFiles, reporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles, verifier, debsigVerifier, repoTemplate,
 		context.Progress(), collectionFactory.LocalRepoCollection(), collectionFactory.PackageCollection(),
 		context.PackagePool(), collectionFactory.ChecksumCollection,
-		uploaders, query.Parse)
+		uploaders, query.Parse, collectionFactory.PackageHistoryCollection(), historyActor())
 	failedFiles = append(failedFiles, failedFiles2...)
 
 	if len(failedFiles) > 0 {
@@ -83,6 +77,24 @@ func aptlyRepoInclude(cmd *commander.Command, args []string) error {
 	return err
 }
 
+// loadUploaders loads uploaders.json file and compiles conditions, nil is returned for empty filename
+func loadUploaders(uploadersFile string) (*deb.Uploaders, error) {
+	if uploadersFile == "" {
+		return nil, nil
+	}
+
+	uploaders, err := deb.NewUploadersFromFile(uploadersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = uploaders.Compile(query.Parse); err != nil {
+		return nil, err
+	}
+
+	return uploaders, nil
+}
+
 func makeCmdRepoInclude() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlyRepoInclude,
@@ -93,6 +105,9 @@ Command include looks for .changes files in list of arguments or specified direc
 .changes file is verified, parsed, referenced files are put into separate temporary directory
 and added into local repository. Successfully imported files are removed by default.
 
+.buildinfo files referenced by .changes file are imported into the package pool and attached
+to the packages they describe, they could be published with 'aptly publish -buildinfo'.
+
 Additionally uploads could be restricted with 'uploaders.json' file. Rules in this file control
 uploads based on GPG key ID of .changes file signature and queries on .changes file fields.
 
@@ -5,13 +5,11 @@ import (
 	"sort"
 
 	"github.com/aptly-dev/aptly/deb"
-	"github.com/aptly-dev/aptly/query"
 	"github.com/smira/commander"
 	"github.com/smira/flag"
 )
 
 func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
-	var err error
 	if len(args) < 3 {
 		cmd.Usage()
 		return commander.ErrCommandError
@@ -19,8 +17,20 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 
 	command := cmd.Name()
 
+	return repoMoveCopyImport(command, args[0], args[1], func() ([]deb.PackageQuery, error) {
+		return parsePackageQueries(command, args[2:])
+	})
+}
+
+// repoMoveCopyImport processes packages matching queries from local repo or mirror srcName
+// to local repo dstName, command is one of move, copy or import
+//
+// Queries are built once packages are loaded.
+func repoMoveCopyImport(command, srcName, dstName string, buildQueries func() ([]deb.PackageQuery, error)) error {
+	var err error
+
 	collectionFactory := context.NewCollectionFactory()
-	dstRepo, err := collectionFactory.LocalRepoCollection().ByName(args[1])
+	dstRepo, err := collectionFactory.LocalRepoCollection().ByName(dstName)
 	if err != nil {
 		return fmt.Errorf("unable to %s: %s", command, err)
 	}
@@ -36,7 +46,7 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	)
 
 	if command == "copy" || command == "move" { // nolint: goconst
-		srcRepo, err = collectionFactory.LocalRepoCollection().ByName(args[0])
+		srcRepo, err = collectionFactory.LocalRepoCollection().ByName(srcName)
 		if err != nil {
 			return fmt.Errorf("unable to %s: %s", command, err)
 		}
@@ -54,7 +64,7 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	} else if command == "import" { // nolint: goconst
 		var srcRemoteRepo *deb.RemoteRepo
 
-		srcRemoteRepo, err = collectionFactory.RemoteRepoCollection().ByName(args[0])
+		srcRemoteRepo, err = collectionFactory.RemoteRepoCollection().ByName(srcName)
 		if err != nil {
 			return fmt.Errorf("unable to %s: %s", command, err)
 		}
@@ -108,16 +118,9 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 		}
 	}
 
-	queries := make([]deb.PackageQuery, len(args)-2)
-	for i := 0; i < len(args)-2; i++ {
-		value, err := GetStringOrFileContent(args[i+2])
-		if err != nil {
-			return fmt.Errorf("unable to read package query from file %s: %w", args[i+2], err)
-		}
-		queries[i], err = query.Parse(value)
-		if err != nil {
-			return fmt.Errorf("unable to %s: %s", command, err)
-		}
+	queries, err := buildQueries()
+	if err != nil {
+		return err
 	}
 
 	toProcess, err := srcList.Filter(deb.FilterOptions{
@@ -142,6 +145,14 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 		verb = "imported"
 	}
 
+	// check against the contents of the repo before the packages are added
+	err = toProcess.ForEach(func(p *deb.Package) error {
+		return dstRepo.VersionPolicy.Check(dstList, p)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to %s: %s", command, err)
+	}
+
 	err = toProcess.ForEach(func(p *deb.Package) error {
 		err = dstList.Add(p)
 		if err != nil {
@@ -161,6 +172,7 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 	if context.Flags().Lookup("dry-run").Value.Get().(bool) {
 		context.Progress().Printf("\nChanges not saved, as dry run has been requested.\n")
 	} else {
+		dstBefore := dstRepo.RefList()
 		dstRepo.UpdateRefList(deb.NewPackageRefListFromPackageList(dstList))
 
 		err = collectionFactory.LocalRepoCollection().Update(dstRepo)
@@ -168,6 +180,18 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 			return fmt.Errorf("unable to save: %s", err)
 		}
 
+		action := deb.PackageEventCopy
+		if command == "move" { // nolint: goconst
+			action = deb.PackageEventMove
+		} else if command == "import" { // nolint: goconst
+			action = deb.PackageEventMirrorImport
+		}
+
+		err = recordPackageHistory(collectionFactory, dstBefore, dstRepo.RefList(), action, srcName, dstRepo.Name)
+		if err != nil {
+			return err
+		}
+
 		if command == "move" { // nolint: goconst
 			srcRepo.UpdateRefList(deb.NewPackageRefListFromPackageList(srcList))
 
@@ -175,6 +199,11 @@ func aptlyRepoMoveCopyImport(cmd *commander.Command, args []string) error {
 			if err != nil {
 				return fmt.Errorf("unable to save: %s", err)
 			}
+
+			err = recordPackageHistory(collectionFactory, srcRefList, srcRepo.RefList(), deb.PackageEventRemove, "", srcRepo.Name)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
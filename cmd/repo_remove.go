@@ -10,13 +10,38 @@ import (
 )
 
 func aptlyRepoRemove(cmd *commander.Command, args []string) error {
-	var err error
 	if len(args) < 2 {
 		cmd.Usage()
 		return commander.ErrCommandError
 	}
 
-	name := args[0]
+	return repoRemovePackages(args[0], func() ([]deb.PackageQuery, error) {
+		return parsePackageQueries("remove", args[1:])
+	})
+}
+
+// parsePackageQueries parses package queries from command line arguments
+func parsePackageQueries(command string, args []string) ([]deb.PackageQuery, error) {
+	queries := make([]deb.PackageQuery, len(args))
+	for i := range args {
+		value, err := GetStringOrFileContent(args[i])
+		if err != nil {
+			return nil, fmt.Errorf("unable to read package query from file %s: %w", args[i], err)
+		}
+		queries[i], err = query.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to %s: %s", command, err)
+		}
+	}
+
+	return queries, nil
+}
+
+// repoRemovePackages removes packages matching queries from local repo
+//
+// Queries are built once packages are loaded.
+func repoRemovePackages(name string, buildQueries func() ([]deb.PackageQuery, error)) error {
+	var err error
 
 	collectionFactory := context.NewCollectionFactory()
 	repo, err := collectionFactory.LocalRepoCollection().ByName(name)
@@ -36,16 +61,9 @@ func aptlyRepoRemove(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to load packages: %s", err)
 	}
 
-	queries := make([]deb.PackageQuery, len(args)-1)
-	for i := 0; i < len(args)-1; i++ {
-		value, err := GetStringOrFileContent(args[i+1])
-		if err != nil {
-			return fmt.Errorf("unable to read package query from file %s: %w", args[i+1], err)
-		}
-		queries[i], err = query.Parse(value)
-		if err != nil {
-			return fmt.Errorf("unable to remove: %s", err)
-		}
+	queries, err := buildQueries()
+	if err != nil {
+		return err
 	}
 
 	list.PrepareIndex()
@@ -63,12 +81,15 @@ func aptlyRepoRemove(cmd *commander.Command, args []string) error {
 	if context.Flags().Lookup("dry-run").Value.Get().(bool) {
 		context.Progress().Printf("\nChanges not saved, as dry run has been requested.\n")
 	} else {
+		before := repo.RefList()
 		repo.UpdateRefList(deb.NewPackageRefListFromPackageList(list))
 
 		err = collectionFactory.LocalRepoCollection().Update(repo)
 		if err != nil {
 			return fmt.Errorf("unable to save: %s", err)
 		}
+
+		err = recordPackageHistory(collectionFactory, before, repo.RefList(), deb.PackageEventRemove, "", repo.Name)
 	}
 
 	return err
@@ -45,9 +45,21 @@ func aptlyRepoShowTxt(_ *commander.Command, args []string) error {
 	fmt.Printf("Comment: %s\n", repo.Comment)
 	fmt.Printf("Default Distribution: %s\n", repo.DefaultDistribution)
 	fmt.Printf("Default Component: %s\n", repo.DefaultComponent)
+	if repo.VersionPolicy != deb.VersionPolicyNone {
+		fmt.Printf("Version Policy: %s\n", repo.VersionPolicy)
+	}
 	if repo.Uploaders != nil {
 		fmt.Printf("Uploaders: %s\n", repo.Uploaders)
 	}
+	if repo.RequireDebsig {
+		fmt.Printf("Require Embedded Signatures: yes\n")
+	}
+	if repo.LintPolicy != nil {
+		fmt.Printf("Lint Policy: %s\n", repo.LintPolicy)
+	}
+	if len(repo.Labels) > 0 {
+		fmt.Printf("Labels: %s\n", deb.FormatLabels(repo.Labels))
+	}
 	fmt.Printf("Number of packages: %d\n", repo.NumPackages())
 
 	withPackages := context.Flags().Lookup("with-packages").Value.Get().(bool)
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlyRepoRemoveSource(cmd *commander.Command, args []string) error {
+	if len(args) < 2 || len(args) > 3 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	version := ""
+	if len(args) == 3 {
+		version = args[2]
+	}
+
+	return repoRemovePackages(args[0], func() ([]deb.PackageQuery, error) {
+		return []deb.PackageQuery{deb.SourcePackageQuery(args[1], version)}, nil
+	})
+}
+
+func aptlyRepoMoveCopySource(cmd *commander.Command, args []string) error {
+	if len(args) < 3 || len(args) > 4 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	version := ""
+	if len(args) == 4 {
+		version = args[3]
+	}
+
+	return repoMoveCopyImport(strings.TrimSuffix(cmd.Name(), "-source"), args[0], args[1], func() ([]deb.PackageQuery, error) {
+		return []deb.PackageQuery{deb.SourcePackageQuery(args[2], version)}, nil
+	})
+}
+
+func makeCmdRepoRemoveSource() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoRemoveSource,
+		UsageLine: "remove-source <name> <source> [<version>]",
+		Short:     "remove source package and binaries built from it from local repository",
+		Long: `
+Command remove-source removes source package <source> and all the binary packages
+built from it (including -dbgsym packages and udebs) from local repository <name>.
+If <version> is not specified, all versions of the source package are removed.
+
+Binary packages are matched by their Source field, so binNMUs are removed
+together with the source version they were built from.
+
+Example:
+
+  $ aptly repo remove-source testing myapp 0.1.12
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-remove-source", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't remove, just show what would be removed")
+
+	return cmd
+}
+
+func makeCmdRepoCopySource() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoMoveCopySource,
+		UsageLine: "copy-source <src-name> <dst-name> <source> [<version>]",
+		Short:     "copy source package and binaries built from it between local repositories",
+		Long: `
+Command copy-source copies source package <source> and all the binary packages
+built from it (including -dbgsym packages and udebs) from local repo <src-name>
+to local repo <dst-name>. If <version> is not specified, all versions of the
+source package are copied.
+
+Example:
+
+  $ aptly repo copy-source testing stable myapp 0.1.12
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-copy-source", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't copy, just show what would be copied")
+	cmd.Flag.Bool("with-deps", false, "also copy dependencies of the source package and its binaries")
+
+	return cmd
+}
+
+func makeCmdRepoMoveSource() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoMoveCopySource,
+		UsageLine: "move-source <src-name> <dst-name> <source> [<version>]",
+		Short:     "move source package and binaries built from it between local repositories",
+		Long: `
+Command move-source moves source package <source> and all the binary packages
+built from it (including -dbgsym packages and udebs) from local repo <src-name>
+to local repo <dst-name>. If <version> is not specified, all versions of the
+source package are moved.
+
+Example:
+
+  $ aptly repo move-source testing stable myapp 0.1.12
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-move-source", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't move, just show what would be moved")
+	cmd.Flag.Bool("with-deps", false, "also move dependencies of the source package and its binaries")
+
+	return cmd
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/aptly-dev/aptly/query"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func makeCmdRepoUploaders() *commander.Command {
+	return &commander.Command{
+		UsageLine: "uploaders",
+		Short:     "check uploaders rules of local repositories",
+		Subcommands: []*commander.Command{
+			makeCmdRepoUploadersTest(),
+		},
+	}
+}
+
+func aptlyRepoUploadersTest(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	verifier, err := getVerifier(context.Flags())
+	if err != nil {
+		return fmt.Errorf("unable to initialize GPG verifier: %s", err)
+	}
+
+	acceptUnsigned := context.Flags().Lookup("accept-unsigned").Value.Get().(bool)
+	ignoreSignatures := LookupOption(context.Config().GpgDisableVerify, context.Flags(), "ignore-signatures")
+
+	repoTemplate, err := template.New("repo").Parse(context.Flags().Lookup("repo").Value.Get().(string))
+	if err != nil {
+		return fmt.Errorf("error parsing -repo template: %s", err)
+	}
+
+	uploaders, err := loadUploaders(context.Flags().Lookup("uploaders-file").Value.Get().(string))
+	if err != nil {
+		return err
+	}
+
+	changes, err := deb.NewChanges(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", args[0], err)
+	}
+	defer func() { _ = changes.Cleanup() }()
+
+	err = changes.VerifyAndParse(acceptUnsigned, ignoreSignatures, verifier)
+	if err != nil {
+		return fmt.Errorf("unable to verify %s: %s", args[0], err)
+	}
+
+	repoName := &bytes.Buffer{}
+	err = repoTemplate.Execute(repoName, changes.Stanza)
+	if err != nil {
+		return fmt.Errorf("error applying template to repo: %s", err)
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	repo, err := collectionFactory.LocalRepoCollection().ByName(repoName.String())
+	if err != nil {
+		return fmt.Errorf("unable to test: %s", err)
+	}
+
+	err = collectionFactory.LocalRepoCollection().LoadComplete(repo)
+	if err != nil {
+		return fmt.Errorf("unable to test: %s", err)
+	}
+
+	list, err := deb.NewPackageListFromRefList(repo.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	if repo.Uploaders != nil {
+		uploaders = repo.Uploaders
+		if err = uploaders.Compile(query.Parse); err != nil {
+			return err
+		}
+		context.Progress().Printf("Using uploaders rules of local repo [%s].\n", repo.Name)
+	} else if uploaders == nil {
+		return fmt.Errorf("unable to test: local repo [%s] has no uploaders rules and -uploaders-file is not specified", repo.Name)
+	}
+
+	context.Progress().Printf("Upload: %s (source %s, version %s, distribution %s) into local repo [%s]\n",
+		changes.ChangesName, changes.Source, changes.Stanza["Version"], changes.Distribution, repo.Name)
+	context.Progress().Printf("Signed by: %v\n", changes.SignatureKeys)
+	context.Progress().Printf("\n")
+
+	decision := uploaders.Check(changes, list)
+	for _, line := range decision.Trace {
+		context.Progress().Printf("  %s\n", line)
+	}
+	context.Progress().Printf("\n")
+
+	if !decision.Allowed {
+		return fmt.Errorf("upload would be rejected: %s", decision.Reason)
+	}
+
+	context.Progress().Printf("Upload would be accepted: %s\n", decision.Reason)
+	return nil
+}
+
+func makeCmdRepoUploadersTest() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlyRepoUploadersTest,
+		UsageLine: "test <file.changes>",
+		Short:     "explain whether upload would be accepted by uploaders rules",
+		Long: `
+Command test evaluates uploaders rules for .changes file the same way 'aptly repo include'
+does and explains which rule allows or denies the upload. Rules of the target local repository
+are used if set, otherwise rules from -uploaders-file. Nothing is imported.
+
+Example:
+
+  $ aptly repo uploaders test -repo=foo-release incoming/hardlink_0.2.1_amd64.changes
+`,
+		Flag: *flag.NewFlagSet("aptly-repo-uploaders-test", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("repo", "{{.Distribution}}", "which repo should files go to, defaults to Distribution field of .changes file")
+	cmd.Flag.Var(&keyRingsFlag{}, "keyring", "gpg keyring to use when verifying .changes file (could be specified multiple times)")
+	cmd.Flag.Bool("ignore-signatures", false, "disable verification of .changes file signature")
+	cmd.Flag.Bool("accept-unsigned", false, "accept unsigned .changes files")
+	cmd.Flag.String("uploaders-file", "", "path to uploaders.json file")
+
+	return cmd
+}
@@ -13,13 +13,24 @@ func makeCmdSnapshot() *commander.Command {
 			makeCmdSnapshotList(),
 			makeCmdSnapshotShow(),
 			makeCmdSnapshotVerify(),
+			makeCmdSnapshotCheckInstallability(),
+			makeCmdSnapshotVulns(),
 			makeCmdSnapshotPull(),
 			makeCmdSnapshotDiff(),
+			makeCmdSnapshotChangelog(),
 			makeCmdSnapshotMerge(),
 			makeCmdSnapshotDrop(),
 			makeCmdSnapshotRename(),
+			makeCmdSnapshotEdit(),
+			makeCmdSnapshotProtect(),
+			makeCmdSnapshotUnprotect(),
 			makeCmdSnapshotSearch(),
 			makeCmdSnapshotFilter(),
+			makeCmdSnapshotPrune(),
+			makeCmdSnapshotExport(),
+			makeCmdSnapshotExportLock(),
+			makeCmdSnapshotSBOM(),
+			makeCmdSnapshotImport(),
 		},
 	}
 }
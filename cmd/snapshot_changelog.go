@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotChangelog(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	format := context.Flags().Lookup("format").Value.Get().(string)
+	if format != "text" && format != "markdown" && format != "json" {
+		return fmt.Errorf("unable to build changelog: unknown format %s", format)
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	// Load <old> snapshot
+	snapshotOld, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to load snapshot %s: %s", args[0], err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshotOld)
+	if err != nil {
+		return fmt.Errorf("unable to load snapshot %s: %s", args[0], err)
+	}
+
+	// Load <new> snapshot
+	snapshotNew, err := collectionFactory.SnapshotCollection().ByName(args[1])
+	if err != nil {
+		return fmt.Errorf("unable to load snapshot %s: %s", args[1], err)
+	}
+
+	err = collectionFactory.SnapshotCollection().LoadComplete(snapshotNew)
+	if err != nil {
+		return fmt.Errorf("unable to load snapshot %s: %s", args[1], err)
+	}
+
+	diff, err := snapshotOld.RefList().Diff(snapshotNew.RefList(), collectionFactory.PackageCollection())
+	if err != nil {
+		return fmt.Errorf("unable to calculate diff: %s", err)
+	}
+
+	changelogs := deb.ChangelogsForDiff(diff, context.PackagePool(), context.Progress())
+
+	switch format {
+	case "json":
+		var output []byte
+		if output, err = json.MarshalIndent(changelogs, "", "  "); err == nil {
+			fmt.Println(string(output))
+		}
+	case "markdown":
+		fmt.Printf("# Changes from %s to %s\n", snapshotOld.Name, snapshotNew.Name)
+		if len(changelogs) == 0 {
+			fmt.Printf("\nNo packages were upgraded.\n")
+		}
+		for _, changelog := range changelogs {
+			fmt.Printf("\n## %s (%s): %s → %s\n", changelog.Name, changelog.Architecture, changelog.OldVersion, changelog.NewVersion)
+			if changelog.Error != "" {
+				fmt.Printf("\n_Changelog is not available: %s_\n", changelog.Error)
+			}
+			for _, entry := range changelog.Entries {
+				fmt.Printf("\n### %s (%s)\n\n", entry.Version, entry.Distribution)
+				if entry.Maintainer != "" {
+					fmt.Printf("*%s, %s*\n\n", entry.Maintainer, entry.Date)
+				}
+				fmt.Printf("```\n%s\n```\n", entry.Changes)
+			}
+		}
+	default:
+		if len(changelogs) == 0 {
+			fmt.Printf("No packages were upgraded between %s and %s.\n", snapshotOld.Name, snapshotNew.Name)
+		}
+		for i, changelog := range changelogs {
+			if i > 0 {
+				fmt.Printf("\n")
+			}
+			header := fmt.Sprintf("%s [%s]: %s -> %s", changelog.Name, changelog.Architecture, changelog.OldVersion, changelog.NewVersion)
+			fmt.Printf("%s\n%s\n", header, strings.Repeat("=", len(header)))
+			if changelog.Error != "" {
+				fmt.Printf("\n  changelog is not available: %s\n", changelog.Error)
+			}
+			for _, entry := range changelog.Entries {
+				fmt.Printf("\n%s (%s) %s\n\n%s\n\n -- %s  %s\n", entry.Source, entry.Version, entry.Distribution,
+					entry.Changes, entry.Maintainer, entry.Date)
+			}
+		}
+	}
+
+	return err
+}
+
+func makeCmdSnapshotChangelog() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotChangelog,
+		UsageLine: "changelog <old-name> <new-name>",
+		Short:     "changelog of packages upgraded between two snapshots",
+		Long: `
+Command changelog displays changelog entries for every package which has been
+upgraded between snapshots <old-name> and <new-name>. Changelog is extracted
+from changelog.Debian.gz of the new package version in the package pool, only
+entries newer than the old version are displayed.
+
+Report can be printed as plain text, Markdown or JSON.
+
+Example:
+
+    $ aptly snapshot changelog -format=markdown wheezy-main-2024-01 wheezy-main-2024-02
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-changelog", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("format", "text", "output format: text, markdown or json")
+
+	return cmd
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotCheckInstallability(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) < 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	jsonFlag := context.Flags().Lookup("json").Value.Get().(bool)
+
+	var progress aptly.Progress
+	if !jsonFlag {
+		progress = context.Progress()
+	}
+
+	snapshots := make([]*deb.Snapshot, len(args))
+	collectionFactory := context.NewCollectionFactory()
+	for i := range snapshots {
+		snapshots[i], err = collectionFactory.SnapshotCollection().ByName(args[i])
+		if err != nil {
+			return fmt.Errorf("unable to check installability: %s", err)
+		}
+
+		err = collectionFactory.SnapshotCollection().LoadComplete(snapshots[i])
+		if err != nil {
+			return fmt.Errorf("unable to check installability: %s", err)
+		}
+	}
+
+	if progress != nil {
+		progress.Printf("Loading packages...\n")
+	}
+
+	packageList, err := deb.NewPackageListFromRefList(snapshots[0].RefList(), collectionFactory.PackageCollection(), progress)
+	if err != nil {
+		return fmt.Errorf("unable to load packages: %s", err)
+	}
+
+	sourcePackageList := deb.NewPackageList()
+	err = sourcePackageList.Append(packageList)
+	if err != nil {
+		return fmt.Errorf("unable to merge sources: %s", err)
+	}
+
+	var pL *deb.PackageList
+	for i := 1; i < len(snapshots); i++ {
+		pL, err = deb.NewPackageListFromRefList(snapshots[i].RefList(), collectionFactory.PackageCollection(), progress)
+		if err != nil {
+			return fmt.Errorf("unable to load packages: %s", err)
+		}
+
+		err = sourcePackageList.Append(pL)
+		if err != nil {
+			return fmt.Errorf("unable to merge sources: %s", err)
+		}
+	}
+
+	var architecturesList []string
+
+	if len(context.ArchitecturesList()) > 0 {
+		architecturesList = context.ArchitecturesList()
+	} else {
+		architecturesList = packageList.Architectures(false)
+	}
+
+	if len(architecturesList) == 0 {
+		return fmt.Errorf("unable to determine list of architectures, please specify explicitly")
+	}
+
+	if progress != nil {
+		progress.Printf("Checking installability...\n")
+	}
+
+	results, err := packageList.CheckInstallability(context.DependencyOptions(), architecturesList, sourcePackageList, progress)
+	if err != nil {
+		return fmt.Errorf("unable to check installability: %s", err)
+	}
+
+	broken := 0
+	for _, result := range results {
+		if !result.Installable {
+			broken++
+		}
+	}
+
+	if jsonFlag {
+		if results == nil {
+			results = []deb.InstallabilityResult{}
+		}
+
+		var output []byte
+		if output, err = json.MarshalIndent(results, "", "  "); err != nil {
+			return err
+		}
+		fmt.Println(string(output))
+	} else if broken == 0 {
+		progress.Printf("All %d packages are installable.\n", len(results))
+	} else {
+		progress.Printf("Not installable packages (%d of %d):\n", broken, len(results))
+
+		for _, result := range results {
+			if result.Installable {
+				continue
+			}
+
+			progress.Printf("  %s [%s]:\n", result.Package, result.Architecture)
+			for _, reason := range result.Reasons {
+				progress.Printf("    %s\n", reason.String())
+				for _, chain := range reason.Chains {
+					if len(chain) > 0 {
+						progress.Printf("      %s\n", strings.Join(chain, " -> "))
+					}
+				}
+			}
+		}
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d packages are not installable", broken)
+	}
+
+	return nil
+}
+
+func makeCmdSnapshotCheckInstallability() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotCheckInstallability,
+		UsageLine: "check-installability <name> [<source> ...]",
+		Short:     "check that every package in snapshot could be installed",
+		Long: `
+Command check-installability checks whether each package in snapshot <name>
+could be installed, possibly using additional snapshots <source> as dependency
+sources. Unlike verify, it looks for complete set of packages satisfying all the
+dependencies recursively, taking into account version constraints, alternatives,
+virtual packages, Conflicts and Breaks. Each package is checked for every architecture.
+
+For every package which can't be installed, minimal explanation is printed: missing
+dependency or conflicting packages with chains of dependencies leading to the problem.
+Command exits with error if some package can't be installed.
+
+Example:
+
+    $ aptly snapshot check-installability -json wheezy-main wheezy-contrib
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-check-installability", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("json", false, "display results for all packages in JSON format")
+
+	return cmd
+}
@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
+	"github.com/smira/flag"
 )
 
 func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
@@ -59,6 +61,36 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 		if err != nil {
 			return fmt.Errorf("unable to create snapshot: %s", err)
 		}
+	} else if len(args) >= 5 && args[1] == "from" && args[2] == "lockfile" {
+		// aptly snapshot create snap from lockfile file source...
+		var (
+			file     *os.File
+			lockfile *deb.Lockfile
+			sources  []deb.LockfileSource
+		)
+
+		snapshotName, lockfileName := args[0], args[3]
+
+		file, err = os.Open(lockfileName)
+		if err != nil {
+			return fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		lockfile, err = deb.ReadLockfile(file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		sources, err = deb.LoadLockfileSources(args[4:], collectionFactory, context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to create snapshot: %s", err)
+		}
+
+		snapshot, err = deb.NewSnapshotFromLockfile(snapshotName, lockfile, sources, context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to create snapshot: %s", err)
+		}
 	} else if len(args) == 2 && args[1] == "empty" {
 		// aptly snapshot create snap empty
 		snapshotName := args[0]
@@ -71,6 +103,11 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 		return commander.ErrCommandError
 	}
 
+	snapshot.Labels, err = updateLabelsFromFlags(context.Flags(), nil)
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %s", err)
+	}
+
 	err = collectionFactory.SnapshotCollection().Add(snapshot)
 	if err != nil {
 		return fmt.Errorf("unable to add snapshot: %s", err)
@@ -84,7 +121,7 @@ func aptlySnapshotCreate(cmd *commander.Command, args []string) error {
 func makeCmdSnapshotCreate() *commander.Command {
 	cmd := &commander.Command{
 		Run:       aptlySnapshotCreate,
-		UsageLine: "create <name> (from mirror <mirror-name> | from repo <repo-name> | empty)",
+		UsageLine: "create <name> (from mirror <mirror-name> | from repo <repo-name> | from lockfile <lockfile> <source> ... | empty)",
 		Short:     "creates snapshot of mirror (local repository) contents",
 		Long: `
 Command create <name> from mirror makes persistent immutable snapshot of remote
@@ -95,6 +132,12 @@ Command create <name> from repo makes persistent immutable snapshot of local
 repository. Snapshot could be processed as mirror snapshots, and mixed with
 snapshots of remote mirrors.
 
+Command create <name> from lockfile creates snapshot with exact set of packages
+listed in lockfile (see aptly snapshot export-lock). Packages are looked up in
+sources (snapshots, local repositories or mirrors, first match wins), source name
+could be prefixed with snapshot:, repo: or mirror: to avoid ambiguity. Snapshot
+is not created if any package is missing or has different SHA256 checksum.
+
 Command create <name> empty creates empty snapshot that could be used as a
 basis for snapshot pull operations, for example. As snapshots are immutable,
 creating one empty snapshot should be enough.
@@ -103,8 +146,11 @@ Example:
 
   $ aptly snapshot create wheezy-main-today from mirror wheezy-main
 `,
+		Flag: *flag.NewFlagSet("aptly-snapshot-create", flag.ExitOnError),
 	}
 
+	addLabelFlags(&cmd.Flag, false)
+
 	return cmd
 
 }
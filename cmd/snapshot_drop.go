@@ -22,6 +22,11 @@ func aptlySnapshotDrop(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to drop: %s", err)
 	}
 
+	err = snapshot.CheckUnprotected()
+	if err != nil {
+		return fmt.Errorf("unable to drop: %s", err)
+	}
+
 	published := collectionFactory.PublishedRepoCollection().BySnapshot(snapshot)
 
 	if len(published) > 0 {
@@ -66,8 +71,8 @@ func makeCmdSnapshotDrop() *commander.Command {
 		UsageLine: "drop <name>",
 		Short:     "delete snapshot",
 		Long: `
-Drop removes information about a snapshot. If snapshot is published,
-it can't be dropped.
+Drop removes information about a snapshot. If snapshot is published
+or protected, it can't be dropped.
 
 Example:
 
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotEdit(cmd *commander.Command, args []string) error {
+	var (
+		err      error
+		snapshot *deb.Snapshot
+	)
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err = collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	snapshot.Labels, err = updateLabelsFromFlags(context.Flags(), snapshot.Labels)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	err = collectionFactory.SnapshotCollection().Update(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to edit: %s", err)
+	}
+
+	fmt.Printf("Snapshot %s successfully updated.\n", snapshot.Name)
+	return err
+}
+
+func makeCmdSnapshotEdit() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotEdit,
+		UsageLine: "edit <name>",
+		Short:     "edit labels of snapshot",
+		Long: `
+Command edit allows one to change labels of the snapshot. Snapshot contents
+are immutable and can't be changed.
+
+Example:
+
+  $ aptly snapshot edit -label env=prod -remove-label candidate wheezy-main
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-edit", flag.ExitOnError),
+	}
+
+	addLabelFlags(&cmd.Flag, true)
+
+	return cmd
+}
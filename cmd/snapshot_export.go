@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotExport(cmd *commander.Command, args []string) error {
+	var (
+		err  error
+		base *deb.Snapshot
+	)
+
+	if len(args) != 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	err = collection.LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	if baseName := context.Flags().Lookup("base").Value.String(); baseName != "" {
+		base, err = collection.ByName(baseName)
+		if err != nil {
+			return fmt.Errorf("unable to export: %s", err)
+		}
+
+		err = collection.LoadComplete(base)
+		if err != nil {
+			return fmt.Errorf("unable to export: %s", err)
+		}
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	err = deb.ExportSnapshotBundle(file, snapshot, base, collectionFactory.PackageCollection(), context.PackagePool(), context.Progress())
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(args[1])
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("unable to export: %s", err)
+	}
+
+	context.Progress().Printf("\nSnapshot %s has been exported to %s.\n", snapshot.Name, args[1])
+
+	return err
+}
+
+func makeCmdSnapshotExport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotExport,
+		UsageLine: "export <name> <bundle.tar>",
+		Short:     "export snapshot with packages into bundle",
+		Long: `
+Command export writes snapshot metadata, package stanzas and package files
+into one tar archive, which could be imported into another aptly instance with
+aptly snapshot import, e.g. to transfer snapshot to an air-gapped system.
+
+With -base flag, packages which are already in base snapshot are not included
+into the bundle. Such bundle could be imported only where base snapshot has been
+imported before.
+
+Example:
+
+  $ aptly snapshot export -base=wheezy-main-2024-01 wheezy-main-2024-02 wheezy-main.tar
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-export", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("base", "", "don't include packages from this snapshot into the bundle (incremental export)")
+
+	return cmd
+}
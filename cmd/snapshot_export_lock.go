@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotExportLock(cmd *commander.Command, args []string) error {
+	var err error
+
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	err = collection.LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	lockfile, err := deb.NewLockfile(snapshot.Name, list)
+	if err != nil {
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	if len(args) == 1 {
+		return lockfile.Write(os.Stdout)
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	err = lockfile.Write(file)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(args[1])
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("unable to export lockfile: %s", err)
+	}
+
+	context.Progress().Printf("\nLockfile of snapshot %s with %d packages has been written to %s.\n", snapshot.Name, len(lockfile.Packages), args[1])
+
+	return err
+}
+
+func makeCmdSnapshotExportLock() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotExportLock,
+		UsageLine: "export-lock <name> [<lockfile>]",
+		Short:     "export list of pinned packages in snapshot",
+		Long: `
+Command export-lock writes lockfile of the snapshot: JSON document listing
+name, version, architecture and SHA256 checksum of every package in the snapshot.
+If lockfile name is not specified, lockfile is written to standard output.
+
+Exact set of packages could be restored later (or on another aptly instance)
+with aptly snapshot create <name> from lockfile.
+
+Example:
+
+  $ aptly snapshot export-lock wheezy-main-today wheezy-main.lock
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-export-lock", flag.ExitOnError),
+	}
+
+	return cmd
+}
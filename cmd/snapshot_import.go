@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+)
+
+func aptlySnapshotImport(cmd *commander.Command, args []string) error {
+	var (
+		err  error
+		name string
+	)
+
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	if len(args) == 2 {
+		name = args[1]
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to import: %s", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := deb.ImportSnapshotBundle(file, name, collectionFactory, context.PackagePool(),
+		collectionFactory.ChecksumCollection(nil), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to import: %s", err)
+	}
+
+	context.Progress().Printf("\nSnapshot %s successfully imported.\nYou can run 'aptly publish snapshot %s' to publish snapshot as Debian repository.\n",
+		snapshot.Name, snapshot.Name)
+
+	return err
+}
+
+func makeCmdSnapshotImport() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotImport,
+		UsageLine: "import <bundle.tar> [<name>]",
+		Short:     "import snapshot from bundle",
+		Long: `
+Command import imports package files, packages and snapshot from the bundle
+created by aptly snapshot export. Snapshot is created with the name it had when
+exported, unless another name is specified. Package files are verified against
+checksums stored in the bundle.
+
+Bundle created with -base flag requires base snapshot to be imported first.
+
+Example:
+
+  $ aptly snapshot import wheezy-main.tar
+`,
+	}
+
+	return cmd
+}
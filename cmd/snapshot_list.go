@@ -24,30 +24,38 @@ func aptlySnapshotList(cmd *commander.Command, args []string) error {
 }
 
 func aptlySnapshotListTxt(cmd *commander.Command, _ []string) error {
-	var err error
-
 	raw := cmd.Flag.Lookup("raw").Value.Get().(bool)
 	sortMethodString := cmd.Flag.Lookup("sort").Value.Get().(string)
+	selector, err := labelSelectorFromFlags(&cmd.Flag)
+	if err != nil {
+		return err
+	}
 
 	collectionFactory := context.NewCollectionFactory()
 	collection := collectionFactory.SnapshotCollection()
 
+	snapshots := make([]*deb.Snapshot, 0, collection.Len())
+	listErr := collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
+		if selector.Matches(snapshot.Labels) {
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+
 	if raw {
-		_ = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
+		for _, snapshot := range snapshots {
 			fmt.Printf("%s\n", snapshot.Name)
-			return nil
-		})
+		}
 	} else {
-		if collection.Len() > 0 {
+		if listErr != nil {
+			return listErr
+		}
+
+		if len(snapshots) > 0 {
 			fmt.Printf("List of snapshots:\n")
 
-			err = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
+			for _, snapshot := range snapshots {
 				fmt.Printf(" * %s\n", snapshot.String())
-				return nil
-			})
-
-			if err != nil {
-				return err
 			}
 
 			fmt.Printf("\nTo get more information about snapshot, run `aptly snapshot show <name>`.\n")
@@ -60,17 +68,19 @@ func aptlySnapshotListTxt(cmd *commander.Command, _ []string) error {
 }
 
 func aptlySnapshotListJSON(cmd *commander.Command, _ []string) error {
-	var err error
-
 	sortMethodString := cmd.Flag.Lookup("sort").Value.Get().(string)
+	selector, err := labelSelectorFromFlags(&cmd.Flag)
+	if err != nil {
+		return err
+	}
 
 	collection := context.NewCollectionFactory().SnapshotCollection()
 
-	jsonSnapshots := make([]*deb.Snapshot, collection.Len())
-	i := 0
+	jsonSnapshots := make([]*deb.Snapshot, 0, collection.Len())
 	_ = collection.ForEachSorted(sortMethodString, func(snapshot *deb.Snapshot) error {
-		jsonSnapshots[i] = snapshot
-		i++
+		if selector.Matches(snapshot.Labels) {
+			jsonSnapshots = append(jsonSnapshots, snapshot)
+		}
 		return nil
 	})
 	if output, e := json.MarshalIndent(jsonSnapshots, "", "  "); e == nil {
@@ -88,17 +98,21 @@ func makeCmdSnapshotList() *commander.Command {
 		UsageLine: "list",
 		Short:     "list snapshots",
 		Long: `
-Command list shows full list of snapshots created.
+Command list shows full list of snapshots created. List could be narrowed
+down to snapshots with matching labels with -label.
 
 Example:
 
   $ aptly snapshot list
+
+  $ aptly snapshot list -label env=prod,approved
 `,
 	}
 
 	cmd.Flag.Bool("json", false, "display list in JSON format")
 	cmd.Flag.Bool("raw", false, "display list in machine-readable format")
 	cmd.Flag.String("sort", "name", "display list in 'name' or creation 'time' order")
+	addLabelSelectorFlag(&cmd.Flag)
 
 	return cmd
 }
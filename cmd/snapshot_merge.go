@@ -2,12 +2,82 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/aptly-dev/aptly/deb"
 	"github.com/smira/commander"
+	"github.com/smira/flag"
 )
 
+type mergePolicyFlag struct {
+	values []string
+}
+
+func (m *mergePolicyFlag) Set(value string) error {
+	m.values = append(m.values, value)
+	return nil
+}
+
+func (m *mergePolicyFlag) Get() interface{} {
+	return m.values
+}
+
+func (m *mergePolicyFlag) String() string {
+	return strings.Join(m.values, ",")
+}
+
+// mergePolicyFromFlags builds merge policy from -policy, -priority and -pin flags,
+// returns nil if none of the flags is specified
+func mergePolicyFromFlags() (*deb.MergePolicy, error) {
+	filename := context.Flags().Lookup("policy").Value.Get().(string)
+	priorities := context.Flags().Lookup("priority").Value.Get().([]string)
+	pins := context.Flags().Lookup("pin").Value.Get().([]string)
+
+	if filename == "" && len(priorities) == 0 && len(pins) == 0 {
+		return nil, nil
+	}
+
+	policy := &deb.MergePolicy{}
+	if filename != "" {
+		var err error
+		policy, err = deb.LoadMergePolicy(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, priority := range priorities {
+		pos := strings.LastIndex(priority, "=")
+		if pos == -1 {
+			return nil, fmt.Errorf("invalid priority %#v, expected snapshot=priority", priority)
+		}
+
+		value, err := strconv.Atoi(priority[pos+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority %#v, expected snapshot=priority", priority)
+		}
+
+		if policy.Priorities == nil {
+			policy.Priorities = map[string]int{}
+		}
+		policy.Priorities[priority[:pos]] = value
+	}
+
+	// pins from command line take precedence over pins from the file
+	inlinePins := make([]deb.MergePin, len(pins))
+	for i, pin := range pins {
+		var err error
+		inlinePins[i], err = deb.ParseMergePin(pin)
+		if err != nil {
+			return nil, err
+		}
+	}
+	policy.Pins = append(inlinePins, policy.Pins...)
+
+	return policy, nil
+}
+
 func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 	var err error
 	if len(args) < 2 {
@@ -37,15 +107,42 @@ func aptlySnapshotMerge(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("-no-remove and -latest can't be specified together")
 	}
 
-	overrideMatching := !latest && !noRemove
+	policy, err := mergePolicyFromFlags()
+	if err != nil {
+		return fmt.Errorf("unable to merge: %s", err)
+	}
 
-	result := sources[0].RefList()
-	for i := 1; i < len(sources); i++ {
-		result = result.Merge(sources[i].RefList(), overrideMatching, false)
+	if policy != nil && (latest || noRemove) {
+		return fmt.Errorf("merge policy can't be used together with -latest or -no-remove")
 	}
 
-	if latest {
-		result.FilterLatestRefs()
+	overrideMatching := !latest && !noRemove
+
+	var result *deb.PackageRefList
+
+	if policy != nil {
+		var conflicts []deb.MergeConflict
+
+		result, conflicts, err = deb.MergeWithPolicy(sources, policy)
+		if err != nil {
+			return fmt.Errorf("unable to merge: %s", err)
+		}
+
+		if len(conflicts) > 0 {
+			context.Progress().Printf("Conflicts resolved by merge policy (%d):\n", len(conflicts))
+			for i := range conflicts {
+				context.Progress().Printf("  %s\n", conflicts[i].String())
+			}
+		}
+	} else {
+		result = sources[0].RefList()
+		for i := 1; i < len(sources); i++ {
+			result = result.Merge(sources[i].RefList(), overrideMatching, false)
+		}
+
+		if latest {
+			result.FilterLatestRefs()
+		}
 	}
 
 	sourceDescription := make([]string, len(sources))
@@ -79,14 +176,34 @@ name-architecture pair are replaced during merge (package from latest snapshot
 on the list wins).  If run with only one source snapshot, merge copies <source> into
 <destination>.
 
+With merge policy (flags -policy, -priority or -pin) packages are chosen
+by priority, similar to apt pinning: each package gets priority of its source
+snapshot (default is 500), unless it matches a pin (first matching pin wins).
+For each name-architecture pair the package with highest priority is chosen,
+even if its version is lower; for equal priorities highest version wins.
+Packages with negative priority are never chosen. Policy file is JSON:
+
+    {"Priorities": {"internal": 1001},
+     "Pins": [{"Package": "nginx*", "Source": "ubuntu", "Version": "1.24*", "Priority": 1100}]}
+
+Pins from the command line take precedence over pins from the policy file.
+All the conflicts are reported with the package chosen.
+
 Example:
 
     $ aptly snapshot merge wheezy-w-backports wheezy-main wheezy-backports
+
+    $ aptly snapshot merge -priority=internal=1001 -pin=package=openssl*,source=ubuntu,priority=1100 \
+        jammy-internal jammy-main internal
 `,
+		Flag: *flag.NewFlagSet("aptly-snapshot-merge", flag.ExitOnError),
 	}
 
 	cmd.Flag.Bool("latest", false, "use only the latest version of each package")
 	cmd.Flag.Bool("no-remove", false, "don't remove duplicate arch/name packages")
+	cmd.Flag.String("policy", "", "merge policy file (JSON) with priorities of source snapshots and package pins")
+	cmd.Flag.Var(&mergePolicyFlag{}, "priority", "priority of source snapshot: <snapshot>=<priority> (could be specified multiple times)")
+	cmd.Flag.Var(&mergePolicyFlag{}, "pin", "package pin: package=<pattern>[,version=<pattern>][,source=<snapshot>],priority=<priority> (could be specified multiple times)")
 
 	return cmd
 }
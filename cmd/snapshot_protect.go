@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/smira/commander"
+)
+
+func aptlySnapshotProtectUnprotect(cmd *commander.Command, args []string, protect bool) error {
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	snapshot, err := collectionFactory.SnapshotCollection().ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	snapshot.Protected = protect
+	err = collectionFactory.SnapshotCollection().Update(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to update: %s", err)
+	}
+
+	if protect {
+		context.Progress().Printf("Snapshot %s is protected now.\n", snapshot.Name)
+	} else {
+		context.Progress().Printf("Snapshot %s is not protected anymore.\n", snapshot.Name)
+	}
+
+	return err
+}
+
+func aptlySnapshotProtect(cmd *commander.Command, args []string) error {
+	return aptlySnapshotProtectUnprotect(cmd, args, true)
+}
+
+func aptlySnapshotUnprotect(cmd *commander.Command, args []string) error {
+	return aptlySnapshotProtectUnprotect(cmd, args, false)
+}
+
+func makeCmdSnapshotProtect() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotProtect,
+		UsageLine: "protect <name>",
+		Short:     "protect snapshot from being dropped or renamed",
+		Long: `
+Command protect marks snapshot as protected: it can't be dropped (even with
+-force) or renamed, and it is never dropped by retention rules until
+it is unprotected with 'aptly snapshot unprotect'.
+
+Example:
+
+  $ aptly snapshot protect wheezy-main
+`,
+	}
+
+	return cmd
+}
+
+func makeCmdSnapshotUnprotect() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotUnprotect,
+		UsageLine: "unprotect <name>",
+		Short:     "remove protection from snapshot",
+		Long: `
+Command unprotect removes protection set with 'aptly snapshot protect'.
+
+Example:
+
+  $ aptly snapshot unprotect wheezy-main
+`,
+	}
+
+	return cmd
+}
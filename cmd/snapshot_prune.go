@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotPrune(cmd *commander.Command, args []string) error {
+	var err error
+	if len(args) != 0 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	dryRun := context.Flags().Lookup("dry-run").Value.Get().(bool)
+
+	rules := context.Config().SnapshotRetention
+	if pattern := context.Flags().Lookup("pattern").Value.Get().(string); pattern != "" {
+		rules = []utils.SnapshotRetentionRule{{
+			Pattern:    pattern,
+			KeepLast:   context.Flags().Lookup("keep-last").Value.Get().(int),
+			KeepWithin: context.Flags().Lookup("keep-within").Value.Get().(string),
+		}}
+	}
+
+	if len(rules) == 0 {
+		return fmt.Errorf("unable to prune: no retention rules configured, set snapshotRetention in config or use -pattern")
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	decisions, err := collection.PlanPrune(rules, collectionFactory.PublishedRepoCollection(), time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to prune: %s", err)
+	}
+
+	pruned := 0
+	for _, decision := range decisions {
+		if !decision.Prune {
+			context.Progress().ColoredPrintf("@g[keep]@| %s (%s)", decision.Name, decision.Reason)
+			continue
+		}
+
+		pruned++
+		if dryRun {
+			context.Progress().ColoredPrintf("@r[drop]@| %s (%s)", decision.Name, decision.Reason)
+			continue
+		}
+
+		err = collection.Drop(decision.Snapshot())
+		if err != nil {
+			return fmt.Errorf("unable to drop snapshot %s: %s", decision.Name, err)
+		}
+		context.Progress().ColoredPrintf("@r[drop]@| %s (%s) has been dropped", decision.Name, decision.Reason)
+	}
+
+	if dryRun {
+		context.Progress().Printf("\n%d snapshots would be dropped, %d kept (dry run).\n", pruned, len(decisions)-pruned)
+	} else {
+		context.Progress().Printf("\n%d snapshots dropped, %d kept.\n", pruned, len(decisions)-pruned)
+	}
+
+	return err
+}
+
+func makeCmdSnapshotPrune() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotPrune,
+		UsageLine: "prune",
+		Short:     "drop snapshots according to retention rules",
+		Long: `
+Command prune drops snapshots according to retention rules configured
+in snapshotRetention section of the configuration file. Each snapshot is
+matched against the first rule with matching name pattern, snapshots not
+matching any rule are never dropped. Rule keeps N most recent snapshots
+and/or snapshots younger than specified age (e.g. 72h, 30d, 4w).
+
+Snapshots which are protected, published or used as source of other
+snapshots which are kept are never dropped.
+
+Single rule could be specified on the command line with -pattern flag,
+in that case configured rules are ignored.
+
+Example:
+
+    $ aptly snapshot prune -dry-run -pattern='nightly-*' -keep-last=7
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-prune", flag.ExitOnError),
+	}
+
+	cmd.Flag.Bool("dry-run", false, "don't drop anything, just show what would be dropped")
+	cmd.Flag.String("pattern", "", "use single rule for snapshots matching pattern instead of configured rules")
+	cmd.Flag.Int("keep-last", 0, "with -pattern: number of most recent snapshots to keep")
+	cmd.Flag.String("keep-within", "", "with -pattern: keep snapshots younger than this age")
+
+	return cmd
+}
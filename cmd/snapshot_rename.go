@@ -26,6 +26,11 @@ func aptlySnapshotRename(cmd *commander.Command, args []string) error {
 		return fmt.Errorf("unable to rename: %s", err)
 	}
 
+	err = snapshot.CheckUnprotected()
+	if err != nil {
+		return fmt.Errorf("unable to rename: %s", err)
+	}
+
 	_, err = collectionFactory.SnapshotCollection().ByName(newName)
 	if err == nil {
 		return fmt.Errorf("unable to rename: snapshot %s already exists", newName)
@@ -49,6 +54,7 @@ func makeCmdSnapshotRename() *commander.Command {
 		Short:     "renames snapshot",
 		Long: `
 Command changes name of the snapshot. Snapshot name should be unique.
+Protected snapshot can't be renamed.
 
 Example:
 
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotSBOM(cmd *commander.Command, args []string) error {
+	var err error
+
+	if len(args) != 1 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	format := context.Flags().Lookup("format").Value.Get().(string)
+	if format != deb.SBOMFormatSPDX && format != deb.SBOMFormatCycloneDX {
+		return fmt.Errorf("unable to build SBOM: unknown format %s", format)
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+	collection := collectionFactory.SnapshotCollection()
+
+	snapshot, err := collection.ByName(args[0])
+	if err != nil {
+		return fmt.Errorf("unable to build SBOM: %s", err)
+	}
+
+	err = collection.LoadComplete(snapshot)
+	if err != nil {
+		return fmt.Errorf("unable to build SBOM: %s", err)
+	}
+
+	list, err := deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), context.Progress())
+	if err != nil {
+		return fmt.Errorf("unable to build SBOM: %s", err)
+	}
+
+	var packagePool aptly.PackagePool
+	if !context.Flags().Lookup("skip-licenses").Value.Get().(bool) {
+		packagePool = context.PackagePool()
+	}
+
+	vendor := context.Flags().Lookup("vendor").Value.Get().(string)
+	sbom := deb.NewSBOM(snapshot.Name, vendor, list, packagePool, context.Progress())
+
+	return sbom.Write(os.Stdout, format)
+}
+
+func makeCmdSnapshotSBOM() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotSBOM,
+		UsageLine: "sbom <name>",
+		Short:     "software bill of materials for snapshot",
+		Long: `
+Command sbom prints software bill of materials (SBOM) for the snapshot in SPDX
+or CycloneDX JSON format. Every package is listed with its version, architecture,
+source package and checksums. License information is extracted from
+usr/share/doc/<package>/copyright in the package file (if copyright file is
+in machine-readable format and package file is present in the package pool).
+
+Example:
+
+  $ aptly snapshot sbom -format=cyclonedx-json wheezy-main-today > sbom.json
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-sbom", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("format", deb.SBOMFormatSPDX, "output format: spdx-json or cyclonedx-json")
+	cmd.Flag.String("vendor", "debian", "vendor (namespace) used in package URLs, e.g. debian or ubuntu")
+	cmd.Flag.Bool("skip-licenses", false, "don't extract license information from package files")
+
+	return cmd
+}
@@ -43,6 +43,12 @@ func aptlySnapshotShowTxt(_ *commander.Command, args []string) error {
 	fmt.Printf("Name: %s\n", snapshot.Name)
 	fmt.Printf("Created At: %s\n", snapshot.CreatedAt.Format("2006-01-02 15:04:05 MST"))
 	fmt.Printf("Description: %s\n", snapshot.Description)
+	if len(snapshot.Labels) > 0 {
+		fmt.Printf("Labels: %s\n", deb.FormatLabels(snapshot.Labels))
+	}
+	if snapshot.Protected {
+		fmt.Printf("Protected: yes\n")
+	}
 	fmt.Printf("Number of packages: %d\n", snapshot.NumPackages())
 	if len(snapshot.SourceIDs) > 0 {
 		fmt.Printf("Sources:\n")
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aptly-dev/aptly/deb"
+	"github.com/smira/commander"
+	"github.com/smira/flag"
+)
+
+func aptlySnapshotVulns(cmd *commander.Command, args []string) error {
+	var err error
+
+	if len(args) < 1 || len(args) > 2 {
+		cmd.Usage()
+		return commander.ErrCommandError
+	}
+
+	trackerFile := context.Flags().Lookup("tracker").Value.String()
+	if trackerFile == "" {
+		return fmt.Errorf("unable to check vulnerabilities: -tracker should be specified")
+	}
+
+	tracker, err := deb.LoadVulnerabilityTracker(trackerFile, context.Flags().Lookup("release").Value.String())
+	if err != nil {
+		return fmt.Errorf("unable to check vulnerabilities: %s", err)
+	}
+
+	collectionFactory := context.NewCollectionFactory()
+
+	lists := make([]*deb.PackageList, len(args))
+	for i, name := range args {
+		var snapshot *deb.Snapshot
+
+		snapshot, err = collectionFactory.SnapshotCollection().ByName(name)
+		if err != nil {
+			return fmt.Errorf("unable to check vulnerabilities: %s", err)
+		}
+
+		err = collectionFactory.SnapshotCollection().LoadComplete(snapshot)
+		if err != nil {
+			return fmt.Errorf("unable to check vulnerabilities: %s", err)
+		}
+
+		lists[i], err = deb.NewPackageListFromRefList(snapshot.RefList(), collectionFactory.PackageCollection(), context.Progress())
+		if err != nil {
+			return fmt.Errorf("unable to load packages: %s", err)
+		}
+	}
+
+	jsonOutput := context.Flags().Lookup("json").Value.Get().(bool)
+
+	if len(args) == 2 {
+		diff := tracker.Diff(lists[0], lists[1])
+
+		if jsonOutput {
+			var output []byte
+			if output, err = json.MarshalIndent(diff, "", "  "); err == nil {
+				fmt.Println(string(output))
+			}
+			return err
+		}
+
+		printChanges := func(title string, changes []deb.VulnerabilityChange) {
+			fmt.Printf("%s between %s and %s:\n", title, args[0], args[1])
+			if len(changes) == 0 {
+				fmt.Printf("  none\n")
+			}
+			for _, change := range changes {
+				severity := ""
+				if change.Severity != "" {
+					severity = " [" + change.Severity + "]"
+				}
+				oldVersion, newVersion := change.OldVersion, change.NewVersion
+				if oldVersion == "" {
+					oldVersion = "(none)"
+				}
+				if newVersion == "" {
+					newVersion = "(removed)"
+				}
+				fmt.Printf("  %s%s: %s [%s] %s -> %s\n", change.ID, severity, change.Name, change.Architecture, oldVersion, newVersion)
+			}
+		}
+
+		printChanges("Vulnerabilities fixed", diff.Fixed)
+		fmt.Printf("\n")
+		printChanges("Vulnerabilities introduced", diff.Introduced)
+
+		return err
+	}
+
+	result := tracker.Check(lists[0])
+
+	if jsonOutput {
+		if result == nil {
+			result = []deb.VulnerablePackage{}
+		}
+
+		var output []byte
+		if output, err = json.MarshalIndent(result, "", "  "); err == nil {
+			fmt.Println(string(output))
+		}
+		return err
+	}
+
+	ids := map[string]bool{}
+	for _, p := range result {
+		fmt.Printf("%s (source %s %s):\n", p.Package, p.Source, p.SourceVersion)
+		for _, vuln := range p.Vulnerabilities {
+			ids[vuln.ID] = true
+			fmt.Printf("  %s\n", vuln.String())
+		}
+	}
+
+	if len(result) == 0 {
+		fmt.Printf("No known vulnerabilities found in snapshot %s.\n", args[0])
+	} else {
+		fmt.Printf("\n%d packages in snapshot %s are affected by %d vulnerabilities.\n", len(result), args[0], len(ids))
+	}
+
+	return err
+}
+
+func makeCmdSnapshotVulns() *commander.Command {
+	cmd := &commander.Command{
+		Run:       aptlySnapshotVulns,
+		UsageLine: "vulns <name> [<new-name>]",
+		Short:     "report packages in snapshot affected by known vulnerabilities",
+		Long: `
+Command vulns matches packages in the snapshot against security tracker data
+and reports packages affected by known vulnerabilities (CVEs) along with
+versions which fix them.
+
+Security tracker data is loaded from local file, which is either Debian
+Security Tracker JSON (https://security-tracker.debian.org/tracker/data/json)
+or Ubuntu OVAL definitions. Debian Security Tracker data is matched by source
+package name and version for the release specified with -release, OVAL
+definitions are matched by binary package name and version.
+
+If two snapshots are specified, command reports vulnerabilities fixed (and
+introduced) between <name> and <new-name>.
+
+Example:
+
+  $ aptly snapshot vulns -tracker=security-tracker.json -release=bookworm bookworm-main-today
+`,
+		Flag: *flag.NewFlagSet("aptly-snapshot-vulns", flag.ExitOnError),
+	}
+
+	cmd.Flag.String("tracker", "", "file with security tracker data (Debian Security Tracker JSON or OVAL)")
+	cmd.Flag.String("release", "", "release name in Debian Security Tracker data, e.g. bookworm")
+	cmd.Flag.Bool("json", false, "display report in JSON format")
+
+	return cmd
+}
@@ -0,0 +1,181 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Buildinfo is parsed .buildinfo file, which describes build environment of
+// source and binary packages
+type Buildinfo struct {
+	Source  string
+	Version string
+	// Files produced by the build (.deb, .dsc, ...)
+	Files PackageFiles
+}
+
+// ParseBuildinfo reads .buildinfo file (optionally clearsigned)
+func ParseBuildinfo(path string, verifier pgp.Verifier) (*Buildinfo, error) {
+	data, err := readRawControlFile(path, true, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	stanza, err := NewControlFileReader(bytes.NewReader(data), false, false).ReadStanza()
+	if err != nil {
+		return nil, err
+	}
+	if stanza == nil || stanza["Source"] == "" {
+		return nil, fmt.Errorf("malformed .buildinfo file %s: Source field is missing", filepath.Base(path))
+	}
+
+	result := &Buildinfo{
+		Source:  stanza["Source"],
+		Version: stanza["Version"],
+	}
+
+	// Source might be "name (version)" for binNMUs
+	if pos := strings.Index(result.Source, "("); pos != -1 {
+		result.Version = strings.Trim(strings.TrimSpace(result.Source[pos:]), "()")
+		result.Source = strings.TrimSpace(result.Source[:pos])
+	}
+
+	result.Files, err = result.Files.ParseSumField(stanza["Checksums-Md5"], func(sum *utils.ChecksumInfo, data string) { sum.MD5 = data }, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Files, err = result.Files.ParseSumFields(stanza)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Describes checks whether package has been produced by the build
+//
+// Binary packages should be listed in .buildinfo files with the same SHA256 checksum,
+// source package is matched either by .dsc file or by name and version.
+func (b *Buildinfo) Describes(p *Package) bool {
+	if p.IsSource && p.Name == b.Source && p.Version == b.Version {
+		return true
+	}
+
+	for _, pf := range p.Files() {
+		if p.IsSource && !strings.HasSuffix(pf.Filename, ".dsc") {
+			continue
+		}
+
+		for _, f := range b.Files {
+			if f.Filename == pf.Filename && f.Checksums.Size == pf.Checksums.Size &&
+				f.Checksums.SHA256 != "" && f.Checksums.SHA256 == pf.Checksums.SHA256 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AddBuildinfo associates .buildinfo file with the package, returns false if it is already there
+func (p *Package) AddBuildinfo(file PackageFile) bool {
+	for _, f := range p.Buildinfo {
+		if f.Filename == file.Filename && f.Checksums.SHA256 == file.Checksums.SHA256 {
+			return false
+		}
+	}
+
+	p.Buildinfo = append(p.Buildinfo, file)
+	return true
+}
+
+// ImportBuildinfoFiles imports .buildinfo files into the pool and associates them with
+// the packages they describe
+//
+// Only packages imported in the same operation (those in the list, but not in before) get
+// .buildinfo files attached, as records of packages already in the repository are shared with
+// snapshots and other repositories. .buildinfo files describing only packages which are already
+// in the repository (e.g. when the same upload is imported again) are processed without changes,
+// and .buildinfo files which don't describe any package in the list are reported as failed.
+func ImportBuildinfoFiles(list *PackageList, before *PackageRefList, buildinfoFiles []string, verifier pgp.Verifier, pool aptly.PackagePool, collection *PackageCollection,
+	reporter aptly.ResultReporter, checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, err error) {
+	checksumStorage := checksumStorageProvider(collection.db)
+
+	for _, file := range buildinfoFiles {
+		var buildinfo *Buildinfo
+
+		buildinfo, err = ParseBuildinfo(file, verifier)
+		if err != nil {
+			reporter.Warning("Unable to read file %s: %s", file, err)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
+		var described []*Package
+		alreadyPresent := false
+		_ = list.ForEach(func(p *Package) error {
+			if buildinfo.Describes(p) {
+				if before.Has(p) {
+					alreadyPresent = true
+				} else {
+					described = append(described, p)
+				}
+			}
+			return nil
+		})
+
+		if len(described) == 0 {
+			if alreadyPresent {
+				aptly.ReportNotice(reporter, "%s describes only packages already in the repository", file)
+				processedFiles = append(processedFiles, file)
+			} else {
+				reporter.Warning("%s doesn't describe any package being imported", file)
+				failedFiles = append(failedFiles, file)
+			}
+			continue
+		}
+
+		var checksums utils.ChecksumInfo
+		checksums, err = utils.ChecksumsForFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		buildinfoFile := PackageFile{
+			Filename:  filepath.Base(file),
+			Checksums: checksums,
+		}
+
+		buildinfoFile.PoolPath, err = pool.Import(file, buildinfoFile.Filename, &buildinfoFile.Checksums, false, checksumStorage)
+		if err != nil {
+			reporter.Warning("Unable to import file %s into pool: %s", file, err)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
+		for _, p := range described {
+			if !p.AddBuildinfo(buildinfoFile) {
+				continue
+			}
+
+			err = collection.Update(p)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			reporter.Added("%s attached to %s", buildinfoFile.Filename, p)
+		}
+
+		processedFiles = append(processedFiles, file)
+	}
+
+	err = nil
+	return
+}
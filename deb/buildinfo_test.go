@@ -0,0 +1,174 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type BuildinfoSuite struct {
+	dir             string
+	db              database.Storage
+	collection      *PackageCollection
+	pool            aptly.PackagePool
+	checksumStorage aptly.ChecksumStorage
+	reporter        *aptly.RecordingResultReporter
+}
+
+var _ = Suite(&BuildinfoSuite{})
+
+func (s *BuildinfoSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewPackageCollection(s.db)
+	s.pool = files.NewPackagePool(c.MkDir(), false)
+	s.checksumStorage = files.NewMockChecksumStorage()
+	s.reporter = &aptly.RecordingResultReporter{}
+
+	for _, name := range []string{"hardlink_0.2.1_amd64.deb", "hardlink_0.2.1.dsc", "hardlink_0.2.1.tar.gz", "hardlink_0.2.1_amd64.buildinfo"} {
+		c.Assert(utils.CopyFile(filepath.Join("testdata", "changes", name), filepath.Join(s.dir, name)), IsNil)
+	}
+}
+
+func (s *BuildinfoSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *BuildinfoSuite) checksumStorageProvider(database.ReaderWriter) aptly.ChecksumStorage {
+	return s.checksumStorage
+}
+
+func (s *BuildinfoSuite) importPackages(c *C) *PackageList {
+	list := NewPackageList()
+	_, failedFiles, err := ImportPackageFiles(list, []string{filepath.Join(s.dir, "hardlink_0.2.1_amd64.deb"), filepath.Join(s.dir, "hardlink_0.2.1.dsc")},
+		false, &pgp.GoVerifier{}, s.pool, s.collection, s.reporter, nil, VersionPolicyNone, nil, nil, s.checksumStorageProvider)
+	c.Assert(err, IsNil)
+	c.Assert(failedFiles, HasLen, 0)
+	return list
+}
+
+func (s *BuildinfoSuite) TestParse(c *C) {
+	buildinfo, err := ParseBuildinfo(filepath.Join(s.dir, "hardlink_0.2.1_amd64.buildinfo"), &pgp.GoVerifier{})
+	c.Assert(err, IsNil)
+	c.Check(buildinfo.Source, Equals, "hardlink")
+	c.Check(buildinfo.Version, Equals, "0.2.0")
+	c.Assert(buildinfo.Files, HasLen, 1)
+	c.Check(buildinfo.Files[0].Filename, Equals, "hardlink_0.2.1_amd64.deb")
+	c.Check(buildinfo.Files[0].Checksums, DeepEquals, utils.ChecksumInfo{
+		Size:   12468,
+		MD5:    "2081e20b36c47f82811c25841cc0e41b",
+		SHA1:   "1ac0e962854dff46f14fa7943746660d3cad1679",
+		SHA256: "668399580590bf1ffcd9eb161b6e574751e15f71820c6e08245dac7c5111a0ee",
+	})
+
+	path := filepath.Join(s.dir, "binnmu.buildinfo")
+	c.Assert(os.WriteFile(path, []byte("-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\n"+
+		"Format: 1.0\nSource: hardlink (0.2.1)\nBinary: hardlink\nVersion: 0.2.1+b1\n"+
+		"Checksums-Sha256:\n 0000 10 hardlink_0.2.1+b1_amd64.deb\n\n-----BEGIN PGP SIGNATURE-----\n\nabcd\n-----END PGP SIGNATURE-----\n"), 0644), IsNil)
+
+	buildinfo, err = ParseBuildinfo(path, &pgp.GoVerifier{})
+	c.Assert(err, IsNil)
+	c.Check(buildinfo.Source, Equals, "hardlink")
+	c.Check(buildinfo.Version, Equals, "0.2.1")
+	c.Check(buildinfo.Files, HasLen, 1)
+
+	c.Assert(os.WriteFile(path, []byte("Format: 1.0\nVersion: 0.2.1\n"), 0644), IsNil)
+	_, err = ParseBuildinfo(path, &pgp.GoVerifier{})
+	c.Check(err, ErrorMatches, "malformed .buildinfo file binnmu.buildinfo: Source field is missing")
+}
+
+func (s *BuildinfoSuite) TestDescribes(c *C) {
+	list := s.importPackages(c)
+
+	buildinfo, err := ParseBuildinfo(filepath.Join(s.dir, "hardlink_0.2.1_amd64.buildinfo"), &pgp.GoVerifier{})
+	c.Assert(err, IsNil)
+
+	binary := list.packages["Pamd64 hardlink 0.2.1"]
+	source := list.packages["Psource hardlink 0.2.1"]
+	c.Assert(binary, NotNil)
+	c.Assert(source, NotNil)
+
+	c.Check(buildinfo.Describes(binary), Equals, true)
+	c.Check(buildinfo.Describes(source), Equals, false)
+
+	buildinfo.Version = "0.2.1"
+	c.Check(buildinfo.Describes(source), Equals, true)
+
+	buildinfo.Version = "0.2.0"
+	buildinfo.Files[0].Checksums.SHA256 = "0000"
+	c.Check(buildinfo.Describes(binary), Equals, false)
+
+	// matching by name and size only is not enough
+	buildinfo.Files[0].Checksums.SHA256 = ""
+	c.Check(buildinfo.Describes(binary), Equals, false)
+}
+
+func (s *BuildinfoSuite) TestImport(c *C) {
+	list := s.importPackages(c)
+
+	buildinfoFile := filepath.Join(s.dir, "hardlink_0.2.1_amd64.buildinfo")
+	unrelated := filepath.Join(s.dir, "other_1.0_amd64.buildinfo")
+	c.Assert(os.WriteFile(unrelated, []byte("Format: 1.0\nSource: other\nVersion: 1.0\n"), 0644), IsNil)
+
+	processedFiles, failedFiles, err := ImportBuildinfoFiles(list, NewPackageRefList(), []string{buildinfoFile, unrelated}, &pgp.GoVerifier{}, s.pool, s.collection,
+		s.reporter, s.checksumStorageProvider)
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, DeepEquals, []string{buildinfoFile})
+	c.Check(failedFiles, DeepEquals, []string{unrelated})
+	c.Check(s.reporter.Warnings, DeepEquals, []string{unrelated + " doesn't describe any package being imported"})
+	c.Check(s.reporter.AddedLines[len(s.reporter.AddedLines)-1], Equals, "hardlink_0.2.1_amd64.buildinfo attached to hardlink_0.2.1_amd64")
+
+	stored, err := s.collection.ByKey(list.packages["Pamd64 hardlink 0.2.1"].Key(""))
+	c.Assert(err, IsNil)
+	c.Assert(stored.Buildinfo, HasLen, 1)
+	c.Check(stored.Buildinfo[0].Filename, Equals, "hardlink_0.2.1_amd64.buildinfo")
+	c.Check(stored.ExtendedStanza()["Buildinfo"], Equals, "hardlink_0.2.1_amd64.buildinfo")
+
+	paths, err := stored.FilepathList(s.pool)
+	c.Assert(err, IsNil)
+	c.Check(paths, HasLen, 2)
+	c.Check(paths[1], Equals, stored.Buildinfo[0].PoolPath)
+
+	source, err := s.collection.ByKey(list.packages["Psource hardlink 0.2.1"].Key(""))
+	c.Assert(err, IsNil)
+	c.Check(source.Buildinfo, HasLen, 0)
+
+	// attaching the same file again is a no-op
+	addedLines := len(s.reporter.AddedLines)
+	_, failedFiles, err = ImportBuildinfoFiles(list, NewPackageRefList(), []string{buildinfoFile}, &pgp.GoVerifier{}, s.pool, s.collection, s.reporter, s.checksumStorageProvider)
+	c.Assert(err, IsNil)
+	c.Check(failedFiles, HasLen, 0)
+	c.Check(s.reporter.AddedLines, HasLen, addedLines)
+
+	// re-importing the package keeps .buildinfo attached
+	s.importPackages(c)
+	stored, err = s.collection.ByKey(stored.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(stored.Buildinfo, HasLen, 1)
+}
+
+func (s *BuildinfoSuite) TestImportOnlyNewPackages(c *C) {
+	list := s.importPackages(c)
+	buildinfoFile := filepath.Join(s.dir, "hardlink_0.2.1_amd64.buildinfo")
+
+	// packages already in the repository are shared, so they are left alone,
+	// but the file is not a failure (e.g. the same upload is included again)
+	processedFiles, failedFiles, err := ImportBuildinfoFiles(list, NewPackageRefListFromPackageList(list), []string{buildinfoFile},
+		&pgp.GoVerifier{}, s.pool, s.collection, s.reporter, s.checksumStorageProvider)
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, DeepEquals, []string{buildinfoFile})
+	c.Check(failedFiles, HasLen, 0)
+	c.Check(s.reporter.Notices, DeepEquals, []string{buildinfoFile + " describes only packages already in the repository"})
+
+	stored, err := s.collection.ByKey(list.packages["Pamd64 hardlink 0.2.1"].Key(""))
+	c.Assert(err, IsNil)
+	c.Check(stored.Buildinfo, HasLen, 0)
+}
@@ -0,0 +1,213 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/pkg/errors"
+)
+
+// ChangelogEntry is one entry of Debian changelog
+type ChangelogEntry struct {
+	// Source package name, version and target distribution
+	Source       string
+	Version      string
+	Distribution string
+	Urgency      string `json:",omitempty"`
+	// Change details, as written in the changelog
+	Changes string
+	// Trailer line: maintainer and date of the change
+	Maintainer string
+	Date       string
+}
+
+// PackageChangelog lists changelog entries between versions of upgraded package
+type PackageChangelog struct {
+	Name         string
+	Architecture string
+	OldVersion   string
+	NewVersion   string
+	// Changelog entries newer than OldVersion, most recent first
+	Entries []ChangelogEntry
+	// Error encountered while extracting changelog
+	Error string `json:",omitempty"`
+}
+
+var changelogHeaderRegexp = regexp.MustCompile(`^(\S+) \(([^)]+)\) ([^;]*);(.*)$`)
+
+// ParseChangelog parses Debian changelog, entries are returned in the same order
+// as in the changelog (most recent first)
+func ParseChangelog(r io.Reader) ([]ChangelogEntry, error) {
+	var (
+		result  []ChangelogEntry
+		current *ChangelogEntry
+		changes []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MaxFieldSize)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if current == nil {
+			matches := changelogHeaderRegexp.FindStringSubmatch(line)
+			if matches == nil {
+				// blank lines between entries, or old-style entries at the bottom of the changelog
+				continue
+			}
+
+			current = &ChangelogEntry{
+				Source:       matches[1],
+				Version:      matches[2],
+				Distribution: strings.TrimSpace(matches[3]),
+			}
+
+			for _, keyword := range strings.Split(matches[4], ",") {
+				keyword = strings.TrimSpace(keyword)
+				if strings.HasPrefix(strings.ToLower(keyword), "urgency=") {
+					current.Urgency = keyword[len("urgency="):]
+				}
+			}
+			changes = nil
+			continue
+		}
+
+		if strings.HasPrefix(line, " -- ") {
+			trailer := strings.TrimPrefix(line, " -- ")
+			if pos := strings.Index(trailer, ">  "); pos != -1 {
+				current.Maintainer = trailer[:pos+1]
+				current.Date = strings.TrimSpace(trailer[pos+3:])
+			} else {
+				current.Maintainer = trailer
+			}
+
+			current.Changes = strings.Trim(strings.Join(changes, "\n"), "\n")
+			result = append(result, *current)
+			current = nil
+			continue
+		}
+
+		changes = append(changes, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// entry without trailer line
+	if current != nil {
+		current.Changes = strings.Trim(strings.Join(changes, "\n"), "\n")
+		result = append(result, *current)
+	}
+
+	return result, nil
+}
+
+// GetChangelogFromDeb extracts changelog.Debian.gz (or changelog.gz for native packages)
+// from .deb package
+func GetChangelogFromDeb(file io.Reader, packageFile string, packageName string) ([]ChangelogEntry, error) {
+	candidates := []string{
+		"usr/share/doc/" + packageName + "/changelog.Debian.gz",
+		"usr/share/doc/" + packageName + "/changelog.gz",
+	}
+
+	name, data, err := readDataFileFromDeb(file, packageFile, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("changelog not found in %s", packageFile)
+	}
+
+	ungzip, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to ungzip %s from %s", name, packageFile)
+	}
+	defer func() { _ = ungzip.Close() }()
+
+	result, err := ParseChangelog(ungzip)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s from %s", name, packageFile)
+	}
+
+	return result, nil
+}
+
+// ChangelogsForDiff builds changelog report for packages upgraded between
+// snapshots, changelogs are extracted from new versions of the packages
+// in the package pool
+func ChangelogsForDiff(diff PackageDiffs, packagePool aptly.PackagePool, progress aptly.Progress) []PackageChangelog {
+	result := []PackageChangelog{}
+
+	for _, pdiff := range diff {
+		if pdiff.Left == nil || pdiff.Right == nil || pdiff.Right.IsSource {
+			continue
+		}
+
+		if CompareVersions(pdiff.Right.Version, pdiff.Left.Version) <= 0 {
+			continue
+		}
+
+		changelog := PackageChangelog{
+			Name:         pdiff.Right.Name,
+			Architecture: pdiff.Right.Architecture,
+			OldVersion:   pdiff.Left.Version,
+			NewVersion:   pdiff.Right.Version,
+			Entries:      []ChangelogEntry{},
+		}
+
+		entries, err := changelogForPackage(pdiff.Right, packagePool)
+		if err != nil {
+			changelog.Error = err.Error()
+			if progress != nil {
+				progress.ColoredPrintf("@y[!]@| @!unable to get changelog of %s: %s@|", pdiff.Right, err)
+			}
+		}
+
+		// changelog is maintained for source package, so source versions are compared
+		oldVersion := pdiff.Left.GetField("$SourceVersion")
+		newVersion := pdiff.Right.GetField("$SourceVersion")
+
+		for _, entry := range entries {
+			if CompareVersions(entry.Version, oldVersion) <= 0 {
+				break
+			}
+			if CompareVersions(entry.Version, newVersion) > 0 {
+				continue
+			}
+			changelog.Entries = append(changelog.Entries, entry)
+		}
+
+		result = append(result, changelog)
+	}
+
+	return result
+}
+
+func changelogForPackage(p *Package, packagePool aptly.PackagePool) ([]ChangelogEntry, error) {
+	files := p.Files()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("package has no files")
+	}
+
+	poolPath, err := files[0].GetPoolPath(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := packagePool.Open(poolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return GetChangelogFromDeb(file, poolPath, p.Name)
+}
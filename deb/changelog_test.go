@@ -0,0 +1,188 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+	ar "github.com/mkrautz/goar"
+
+	. "gopkg.in/check.v1"
+)
+
+type ChangelogSuite struct {
+	debFile string
+}
+
+var _ = Suite(&ChangelogSuite{})
+
+func (s *ChangelogSuite) SetUpSuite(c *C) {
+	_, _File, _, _ := runtime.Caller(0)
+	s.debFile = filepath.Join(filepath.Dir(_File), "../system/changes/hardlink_0.2.1_amd64.deb")
+}
+
+const exampleChangelog = `aptly (1.5.0-2) unstable; urgency=medium
+
+  * Fix build on arm64
+
+ -- Jane Doe <jane@example.com>  Mon, 01 Jan 2024 10:00:00 +0000
+
+aptly (1.5.0-1) experimental; urgency=low, binary-only=yes
+
+  [ John Doe ]
+  * New upstream release
+    - with details
+
+ -- John Doe <john@example.com>  Sun, 31 Dec 2023 10:00:00 +0000
+
+Local variables:
+mode: debian-changelog
+End:
+`
+
+func (s *ChangelogSuite) TestParseChangelog(c *C) {
+	entries, err := ParseChangelog(strings.NewReader(exampleChangelog))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+
+	c.Check(entries[0], DeepEquals, ChangelogEntry{
+		Source:       "aptly",
+		Version:      "1.5.0-2",
+		Distribution: "unstable",
+		Urgency:      "medium",
+		Changes:      "  * Fix build on arm64",
+		Maintainer:   "Jane Doe <jane@example.com>",
+		Date:         "Mon, 01 Jan 2024 10:00:00 +0000",
+	})
+	c.Check(entries[1].Distribution, Equals, "experimental")
+	c.Check(entries[1].Urgency, Equals, "low")
+	c.Check(entries[1].Changes, Equals, "  [ John Doe ]\n  * New upstream release\n    - with details")
+
+	entries, err = ParseChangelog(strings.NewReader("aptly (1.0) unstable; urgency=low\n\n  * Unfinished"))
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Changes, Equals, "  * Unfinished")
+}
+
+func (s *ChangelogSuite) TestGetChangelogFromDeb(c *C) {
+	file, err := os.Open(s.debFile)
+	c.Assert(err, IsNil)
+	defer func() { _ = file.Close() }()
+
+	entries, err := GetChangelogFromDeb(file, s.debFile, "hardlink")
+	c.Assert(err, IsNil)
+	c.Assert(len(entries) > 3, Equals, true)
+	c.Check(entries[0].Version, Equals, "0.2.1")
+	c.Check(entries[0].Changes, Equals, "  * Update just to try it out :)")
+	c.Check(entries[1].Version, Equals, "0.2.0")
+
+	_, err = file.Seek(0, 0)
+	c.Assert(err, IsNil)
+
+	_, err = GetChangelogFromDeb(file, s.debFile, "other")
+	c.Check(err, ErrorMatches, "changelog not found in .*")
+}
+
+// debWithDocSymlink builds .deb where usr/share/doc/libfoo-dev is a symlink
+// to documentation of libfoo1, symlink comes first in the data archive
+func debWithDocSymlink(c *C) []byte {
+	var changelog bytes.Buffer
+	gz := gzip.NewWriter(&changelog)
+	_, _ = gz.Write([]byte(exampleChangelog))
+	c.Assert(gz.Close(), IsNil)
+
+	var data bytes.Buffer
+	tw := tar.NewWriter(&data)
+	c.Assert(tw.WriteHeader(&tar.Header{Name: "./usr/share/doc/libfoo-dev", Typeflag: tar.TypeSymlink, Linkname: "libfoo1"}), IsNil)
+	c.Assert(tw.WriteHeader(&tar.Header{Name: "./usr/share/doc/libfoo1/", Typeflag: tar.TypeDir, Mode: 0755}), IsNil)
+	for name, contents := range map[string][]byte{
+		"./usr/share/doc/libfoo1/changelog.Debian.gz": changelog.Bytes(),
+		"./usr/share/doc/libfoo1/copyright":           []byte(exampleCopyright),
+	} {
+		c.Assert(tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(contents))}), IsNil)
+		_, err := tw.Write(contents)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(tw.Close(), IsNil)
+
+	var deb bytes.Buffer
+	writer := ar.NewWriter(&deb)
+	for _, member := range []struct {
+		name string
+		data []byte
+	}{{"debian-binary", []byte("2.0\n")}, {"data.tar", data.Bytes()}} {
+		c.Assert(writer.WriteHeader(&ar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.data))}), IsNil)
+		_, err := writer.Write(member.data)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(writer.Close(), IsNil)
+
+	return deb.Bytes()
+}
+
+func (s *ChangelogSuite) TestGetChangelogFromDebSymlink(c *C) {
+	entries, err := GetChangelogFromDeb(bytes.NewReader(debWithDocSymlink(c)), "libfoo-dev.deb", "libfoo-dev")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Version, Equals, "1.5.0-2")
+}
+
+func (s *ChangelogSuite) TestResolveArchivePath(c *C) {
+	links := map[string]string{
+		"usr/share/doc/a":     "b",
+		"usr/share/doc/b":     "/usr/share/doc/c",
+		"usr/share/doc/loop1": "loop2",
+		"usr/share/doc/loop2": "loop1",
+		"usr/lib/x":           "../share/doc/a/x",
+	}
+
+	c.Check(resolveArchivePath("usr/share/doc/a/copyright", links), Equals, "usr/share/doc/c/copyright")
+	c.Check(resolveArchivePath("usr/lib/x", links), Equals, "usr/share/doc/c/x")
+	c.Check(resolveArchivePath("usr/share/doc/d/copyright", links), Equals, "usr/share/doc/d/copyright")
+	c.Check(resolveArchivePath("usr/share/doc/loop1/copyright", links), Equals, "")
+}
+
+func (s *ChangelogSuite) TestChangelogsForDiff(c *C) {
+	packagePool := files.NewPackagePool(c.MkDir(), false)
+	cs := files.NewMockChecksumStorage()
+
+	checksums, err := utils.ChecksumsForFile(s.debFile)
+	c.Assert(err, IsNil)
+
+	poolPath, err := packagePool.Import(s.debFile, filepath.Base(s.debFile), &checksums, false, cs)
+	c.Assert(err, IsNil)
+
+	newPackage := &Package{Name: "hardlink", Version: "0.2.1", Architecture: "amd64"}
+	newPackage.UpdateFiles(PackageFiles{{Filename: filepath.Base(s.debFile), Checksums: checksums, PoolPath: poolPath}})
+
+	oldPackage := &Package{Name: "hardlink", Version: "0.2.0~rc1", Architecture: "amd64"}
+	missingPackage := &Package{Name: "hardlink", Version: "0.2.2", Architecture: "i386"}
+	missingPackage.UpdateFiles(PackageFiles{{Filename: "hardlink_0.2.2_i386.deb", Checksums: utils.ChecksumInfo{Size: 5, MD5: "0123456789abcdef0123456789abcdef"}}})
+
+	diff := PackageDiffs{
+		{Left: nil, Right: newPackage},
+		{Left: oldPackage, Right: newPackage},
+		{Left: newPackage, Right: oldPackage},
+		{Left: oldPackage, Right: missingPackage},
+	}
+
+	changelogs := ChangelogsForDiff(diff, packagePool, nil)
+	c.Assert(changelogs, HasLen, 2)
+
+	c.Check(changelogs[0].OldVersion, Equals, "0.2.0~rc1")
+	c.Check(changelogs[0].NewVersion, Equals, "0.2.1")
+	c.Check(changelogs[0].Error, Equals, "")
+	c.Assert(changelogs[0].Entries, HasLen, 2)
+	c.Check(changelogs[0].Entries[0].Version, Equals, "0.2.1")
+	c.Check(changelogs[0].Entries[1].Version, Equals, "0.2.0")
+
+	c.Check(changelogs[1].Architecture, Equals, "i386")
+	c.Check(changelogs[1].Entries, HasLen, 0)
+	c.Check(changelogs[1].Error, Not(Equals), "")
+}
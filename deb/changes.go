@@ -289,9 +289,12 @@ func CollectChangesFiles(locations []string, reporter aptly.ResultReporter) (cha
 }
 
 // ImportChangesFiles imports referenced files in changes files into local repository
+//
+// debsigVerifier is used to verify embedded .deb signatures for repositories which require them.
 func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, acceptUnsigned, ignoreSignatures, forceReplace, noRemoveFiles bool,
-	verifier pgp.Verifier, repoTemplate *template.Template, progress aptly.Progress, localRepoCollection *LocalRepoCollection, packageCollection *PackageCollection,
-	pool aptly.PackagePool, checksumStorageProvider aptly.ChecksumStorageProvider, uploaders *Uploaders, parseQuery parseQuery) (processedFiles []string, failedFiles []string, err error) {
+	verifier, debsigVerifier pgp.Verifier, repoTemplate *template.Template, progress aptly.Progress, localRepoCollection *LocalRepoCollection, packageCollection *PackageCollection,
+	pool aptly.PackagePool, checksumStorageProvider aptly.ChecksumStorageProvider, uploaders *Uploaders, parseQuery parseQuery,
+	history *PackageHistoryCollection, actor string) (processedFiles []string, failedFiles []string, err error) {
 
 	for _, path := range changesFiles {
 		var changes *Changes
@@ -341,21 +344,8 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 		currentUploaders := uploaders
 		if repo.Uploaders != nil {
 			currentUploaders = repo.Uploaders
-			for i := range currentUploaders.Rules {
-				currentUploaders.Rules[i].CompiledCondition, err = parseQuery(currentUploaders.Rules[i].Condition)
-				if err != nil {
-					return nil, nil, fmt.Errorf("error parsing query %s: %s", currentUploaders.Rules[i].Condition, err)
-				}
-			}
-		}
-
-		if currentUploaders != nil {
-			if err = currentUploaders.IsAllowed(changes); err != nil {
-				failedFiles = append(failedFiles, path)
-				reporter.Warning("changes file skipped due to uploaders config: %s, keys %#v: %s",
-					changes.ChangesName, changes.SignatureKeys, err)
-				_ = changes.Cleanup()
-				continue
+			if err = currentUploaders.Compile(parseQuery); err != nil {
+				return nil, nil, err
 			}
 		}
 
@@ -370,18 +360,46 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 			return nil, nil, fmt.Errorf("unable to load packages: %s", err)
 		}
 
+		if currentUploaders != nil {
+			if err = currentUploaders.IsAllowedTo(changes, list); err != nil {
+				failedFiles = append(failedFiles, path)
+				reporter.Warning("changes file skipped due to uploaders config: %s, keys %#v: %s",
+					changes.ChangesName, changes.SignatureKeys, err)
+				_ = changes.Cleanup()
+				continue
+			}
+		}
+
 		packageFiles, otherFiles, _ := CollectPackageFiles([]string{changes.TempDir}, reporter)
 
 		restriction := changes.PackageQuery()
 		var processedFiles2, failedFiles2 []string
 
+		var repoDebsigVerifier pgp.Verifier
+		if repo.RequireDebsig {
+			if debsigVerifier == nil {
+				return nil, nil, fmt.Errorf("local repo %s requires embedded .deb signatures, but debsigKeyrings are not configured", repo.Name)
+			}
+			repoDebsigVerifier = debsigVerifier
+		}
+
 		processedFiles2, failedFiles2, err = ImportPackageFiles(list, packageFiles, forceReplace, verifier, pool,
-			packageCollection, reporter, restriction, checksumStorageProvider)
+			packageCollection, reporter, restriction, repo.VersionPolicy, repo.LintPolicy, repoDebsigVerifier, checksumStorageProvider)
 
 		if err != nil {
 			return nil, nil, fmt.Errorf("unable to import package files: %s", err)
 		}
 
+		var processedBuildinfo, failedBuildinfo []string
+		processedBuildinfo, failedBuildinfo, err = ImportBuildinfoFiles(list, repo.RefList(), otherFiles, verifier, pool, packageCollection, reporter, checksumStorageProvider)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to import .buildinfo files: %s", err)
+		}
+
+		processedFiles2 = append(processedFiles2, processedBuildinfo...)
+		failedFiles2 = append(failedFiles2, failedBuildinfo...)
+
+		before := repo.RefList()
 		repo.UpdateRefList(NewPackageRefListFromPackageList(list))
 
 		err = localRepoCollection.Update(repo)
@@ -389,6 +407,19 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 			return nil, nil, fmt.Errorf("unable to save: %s", err)
 		}
 
+		if history != nil {
+			err = history.RecordRefListChange(before, repo.RefList(), PackageEvent{
+				Action:     PackageEventInclude,
+				Actor:      actor,
+				Source:     changes.ChangesName,
+				Target:     repo.Name,
+				TargetType: PackageEventTargetRepo,
+			})
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to record package history: %s", err)
+			}
+		}
+
 		err = changes.Cleanup()
 		if err != nil {
 			return nil, nil, err
@@ -402,10 +433,6 @@ func ImportChangesFiles(changesFiles []string, reporter aptly.ResultReporter, ac
 			processedFiles = append(processedFiles, filepath.Join(changes.BasePath, filepath.Base(file)))
 		}
 
-		for _, file := range otherFiles {
-			processedFiles = append(processedFiles, filepath.Join(changes.BasePath, filepath.Base(file)))
-		}
-
 		processedFiles = append(processedFiles, path)
 	}
 
@@ -121,14 +121,68 @@ func (s *ChangesSuite) TestImportChangesFiles(c *C) {
 	changesFiles, failedFiles := CollectChangesFiles([]string{s.Dir}, s.Reporter)
 	c.Check(failedFiles, HasLen, 0)
 
+	history := NewPackageHistoryCollection(s.db)
+
 	processedFiles, failedFiles, err := ImportChangesFiles(
 		append(changesFiles, "testdata/changes/notexistent.changes"),
-		s.Reporter, true, true, false, false, &NullVerifier{},
+		s.Reporter, true, true, false, false, &NullVerifier{}, nil,
 		template.Must(template.New("test").Parse("test")), s.progress, s.localRepoCollection, s.packageCollection, s.packagePool, func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage },
-		nil, nil)
+		nil, nil, history, "cli:jane")
 	c.Assert(err, IsNil)
 	c.Check(failedFiles, DeepEquals, append(expectedFailedFiles, "testdata/changes/notexistent.changes"))
 	c.Check(processedFiles, DeepEquals, expectedProcessedFiles)
+
+	hardlink := s.packageCollection.SearchByKey("amd64", "hardlink", "0.2.1")
+	c.Assert(hardlink.Len(), Equals, 1)
+	_ = hardlink.ForEach(func(p *Package) error {
+		c.Check(p.Buildinfo, HasLen, 1)
+		c.Check(p.Buildinfo[0].Filename, Equals, "hardlink_0.2.1_amd64.buildinfo")
+
+		events, err := history.ByKey(p.Key(""))
+		c.Assert(err, IsNil)
+		c.Assert(events, HasLen, 1)
+		c.Check(events[0].Action, Equals, PackageEventInclude)
+		c.Check(events[0].Actor, Equals, "cli:jane")
+		c.Check(events[0].Source, Equals, "hardlink_0.2.1_amd64.changes")
+		c.Check(events[0].Target, Equals, "test")
+		return nil
+	})
+}
+
+func (s *ChangesSuite) TestImportChangesFilesTwice(c *C) {
+	repo := NewLocalRepo("test", "Test Comment")
+	c.Assert(s.localRepoCollection.Add(repo), IsNil)
+
+	for _, name := range []string{"hardlink_0.2.1.dsc", "hardlink_0.2.1.tar.gz", "hardlink_0.2.1_amd64.deb",
+		"hardlink_0.2.0_i386.deb", "hardlink_0.2.1_amd64.buildinfo", "hardlink_0.2.1_amd64.changes"} {
+		c.Assert(utils.CopyFile(filepath.Join("testdata/changes", name), filepath.Join(s.Dir, name)), IsNil)
+	}
+	changesFiles := []string{filepath.Join(s.Dir, "hardlink_0.2.1_amd64.changes")}
+
+	for i := 0; i < 2; i++ {
+		reporter := &aptly.RecordingResultReporter{}
+
+		processedFiles, failedFiles, err := ImportChangesFiles(
+			changesFiles, reporter, true, true, false, true, &NullVerifier{}, nil,
+			template.Must(template.New("test").Parse("test")), s.progress, s.localRepoCollection, s.packageCollection, s.packagePool, func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage },
+			nil, nil, nil, "")
+		c.Assert(err, IsNil)
+		// hardlink_0.2.0_i386.deb doesn't belong to the upload
+		c.Check(failedFiles, DeepEquals, []string{filepath.Join(s.Dir, "hardlink_0.2.0_i386.deb")}, Commentf("run %d", i+1))
+		c.Check(processedFiles, HasLen, 5, Commentf("run %d", i+1))
+
+		if i == 1 {
+			c.Assert(reporter.Notices, HasLen, 1)
+			c.Check(reporter.Notices[0], Matches, ".*/hardlink_0.2.1_amd64.buildinfo describes only packages already in the repository")
+		}
+	}
+
+	hardlink := s.packageCollection.SearchByKey("amd64", "hardlink", "0.2.1")
+	c.Assert(hardlink.Len(), Equals, 1)
+	_ = hardlink.ForEach(func(p *Package) error {
+		c.Check(p.Buildinfo, HasLen, 1)
+		return nil
+	})
 }
 
 func (s *ChangesSuite) TestImportDbgsymWithVersionedSourceField(c *C) {
@@ -141,9 +195,9 @@ func (s *ChangesSuite) TestImportDbgsymWithVersionedSourceField(c *C) {
 	c.Check(failedFiles, HasLen, 0)
 
 	_, failedFiles, err := ImportChangesFiles(
-		changesFiles, s.Reporter, true, true, false, true, &NullVerifier{},
+		changesFiles, s.Reporter, true, true, false, true, &NullVerifier{}, nil,
 		template.Must(template.New("test").Parse("test")), s.progress, s.localRepoCollection, s.packageCollection, s.packagePool, func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage },
-		nil, nil)
+		nil, nil, nil, "")
 	c.Assert(err, IsNil)
 	c.Check(failedFiles, IsNil)
 }
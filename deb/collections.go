@@ -17,6 +17,8 @@ type CollectionFactory struct {
 	localRepos     *LocalRepoCollection
 	publishedRepos *PublishedRepoCollection
 	checksums      *ChecksumCollection
+	schedules      *MirrorScheduleCollection
+	history        *PackageHistoryCollection
 }
 
 // NewCollectionFactory creates new factory
@@ -91,6 +93,30 @@ func (factory *CollectionFactory) PublishedRepoCollection() *PublishedRepoCollec
 	return factory.publishedRepos
 }
 
+// MirrorScheduleCollection returns (or creates) new MirrorScheduleCollection
+func (factory *CollectionFactory) MirrorScheduleCollection() *MirrorScheduleCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.schedules == nil {
+		factory.schedules = NewMirrorScheduleCollection(factory.db)
+	}
+
+	return factory.schedules
+}
+
+// PackageHistoryCollection returns (or creates) new PackageHistoryCollection
+func (factory *CollectionFactory) PackageHistoryCollection() *PackageHistoryCollection {
+	factory.Lock()
+	defer factory.Unlock()
+
+	if factory.history == nil {
+		factory.history = NewPackageHistoryCollection(factory.db)
+	}
+
+	return factory.history
+}
+
 // ChecksumCollection returns (or creates) new ChecksumCollection
 func (factory *CollectionFactory) ChecksumCollection(db database.ReaderWriter) aptly.ChecksumStorage {
 	factory.Lock()
@@ -118,4 +144,6 @@ func (factory *CollectionFactory) Flush() {
 	factory.publishedRepos = nil
 	factory.packages = nil
 	factory.checksums = nil
+	factory.schedules = nil
+	factory.history = nil
 }
@@ -3,11 +3,13 @@ package deb
 import (
 	"archive/tar"
 	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strings"
 
 	"github.com/h2non/filetype/matchers"
@@ -31,6 +33,17 @@ type parseQuery func(string) (PackageQuery, error)
 
 // GetControlFileFromDeb reads control file from deb package
 func GetControlFileFromDeb(packageFile string) (Stanza, error) {
+	control, err := GetRawControlFileFromDeb(packageFile)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := NewControlFileReader(bytes.NewReader(control), false, false)
+	return reader.ReadStanza()
+}
+
+// GetRawControlFileFromDeb reads contents of control file from .deb package
+func GetRawControlFileFromDeb(packageFile string) ([]byte, error) {
 	file, err := os.Open(packageFile)
 	if err != nil {
 		return nil, err
@@ -97,13 +110,7 @@ func GetControlFileFromDeb(packageFile string) (Stanza, error) {
 				}
 
 				if tarHeader.Name == "./control" || tarHeader.Name == "control" {
-					reader := NewControlFileReader(untar, false, false)
-					stanza, err := reader.ReadStanza()
-					if err != nil {
-						return nil, err
-					}
-
-					return stanza, nil
+					return io.ReadAll(untar)
 				}
 			}
 		}
@@ -149,14 +156,43 @@ func GetControlFileFromDsc(dscFile string, verifier pgp.Verifier) (Stanza, error
 
 // GetContentsFromDeb returns list of files installed by .deb package
 func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
+	var results []string
+
+	err := walkDataTarFromDeb(file, packageFile, func(untar *tar.Reader) error {
+		for {
+			tarHeader, err := untar.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+			}
+
+			if tarHeader.Typeflag == tar.TypeDir {
+				continue
+			}
+
+			tarHeader.Name = strings.TrimPrefix(tarHeader.Name[2:], "./")
+			results = append(results, tarHeader.Name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// walkDataTarFromDeb finds data.tar.* part of .deb package and passes it to handler
+func walkDataTarFromDeb(file io.Reader, packageFile string, handler func(untar *tar.Reader) error) error {
 	library := ar.NewReader(file)
 	for {
 		header, err := library.Next()
 		if err == io.EOF {
-			return nil, fmt.Errorf("unable to find data.tar.* part in %s", packageFile)
+			return fmt.Errorf("unable to find data.tar.* part in %s", packageFile)
 		}
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
+			return errors.Wrapf(err, "unable to read .deb archive from %s", packageFile)
 		}
 
 		if strings.HasPrefix(header.Name, "data.tar") {
@@ -179,7 +215,7 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 				} else {
 					ungzip, err := gzip.NewReader(bufReader)
 					if err != nil {
-						return nil, errors.Wrapf(err, "unable to ungzip data.tar.gz from %s", packageFile)
+						return errors.Wrapf(err, "unable to ungzip data.tar.gz from %s", packageFile)
 					}
 					defer func() { _ = ungzip.Close() }()
 					tarInput = ungzip
@@ -189,7 +225,7 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 			case "data.tar.xz":
 				unxz, err := xz.NewReader(bufReader)
 				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unxz data.tar.xz from %s", packageFile)
+					return errors.Wrapf(err, "unable to unxz data.tar.xz from %s", packageFile)
 				}
 				defer func() { _ = unxz.Close() }()
 				tarInput = unxz
@@ -200,32 +236,113 @@ func GetContentsFromDeb(file io.Reader, packageFile string) ([]string, error) {
 			case "data.tar.zst":
 				unzstd, err := zstd.NewReader(bufReader)
 				if err != nil {
-					return nil, errors.Wrapf(err, "unable to unzstd %s from %s", header.Name, packageFile)
+					return errors.Wrapf(err, "unable to unzstd %s from %s", header.Name, packageFile)
 				}
 				defer unzstd.Close()
 				tarInput = unzstd
 			default:
-				return nil, fmt.Errorf("unsupported tar compression in %s: %s", packageFile, header.Name)
+				return fmt.Errorf("unsupported tar compression in %s: %s", packageFile, header.Name)
 			}
 
-			untar := tar.NewReader(tarInput)
-			var results []string
-			for {
-				tarHeader, err := untar.Next()
-				if err == io.EOF {
-					return results, nil
+			return handler(tar.NewReader(tarInput))
+		}
+	}
+}
+
+// maxArchiveSymlinks limits number of symlinks followed while resolving path in data archive
+const maxArchiveSymlinks = 16
+
+// readDataFileFromDeb reads first of candidate files (paths relative to the root of data archive)
+// found in data.tar.* part of .deb package
+//
+// Symlinks and hardlinks within data archive are followed, as usr/share/doc/<package>
+// is often a symlink to documentation directory of another package built from the same source.
+// Empty name is returned if none of the candidates is present.
+func readDataFileFromDeb(file io.Reader, packageFile string, candidates []string) (string, []byte, error) {
+	basenames := map[string]bool{}
+	for _, candidate := range candidates {
+		basenames[path.Base(candidate)] = true
+	}
+
+	links := map[string]string{}
+	contents := map[string][]byte{}
+
+	err := walkDataTarFromDeb(file, packageFile, func(untar *tar.Reader) error {
+		for {
+			tarHeader, err := untar.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+			}
+
+			name := normalizeArchivePath(tarHeader.Name)
+
+			switch tarHeader.Typeflag {
+			case tar.TypeSymlink:
+				links[name] = tarHeader.Linkname
+			case tar.TypeLink:
+				// hardlink targets are relative to the root of the archive
+				links[name] = "/" + normalizeArchivePath(tarHeader.Linkname)
+			case tar.TypeReg:
+				if !basenames[path.Base(name)] {
+					continue
 				}
+
+				contents[name], err = io.ReadAll(untar)
 				if err != nil {
-					return nil, errors.Wrapf(err, "unable to read .tar archive from %s", packageFile)
+					return errors.Wrapf(err, "unable to read %s from %s", name, packageFile)
 				}
+			}
+		}
+	})
+	if err != nil {
+		return "", nil, err
+	}
 
-				if tarHeader.Typeflag == tar.TypeDir {
-					continue
-				}
+	for _, candidate := range candidates {
+		if data, ok := contents[resolveArchivePath(candidate, links)]; ok {
+			return candidate, data, nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+func normalizeArchivePath(name string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(name, "."), "/"), "/")
+}
 
-				tarHeader.Name = strings.TrimPrefix(tarHeader.Name[2:], "./")
-				results = append(results, tarHeader.Name)
+// resolveArchivePath follows symlinks in any component of the path, empty string
+// is returned for symlink loops
+func resolveArchivePath(name string, links map[string]string) string {
+	for i := 0; i <= maxArchiveSymlinks; i++ {
+		parts := strings.Split(name, "/")
+
+		resolved := false
+		for j := range parts {
+			prefix := strings.Join(parts[:j+1], "/")
+			target, ok := links[prefix]
+			if !ok {
+				continue
+			}
+
+			if strings.HasPrefix(target, "/") {
+				target = strings.TrimPrefix(target, "/")
+			} else {
+				target = path.Join(path.Dir(prefix), target)
 			}
+
+			name = path.Join(append([]string{target}, parts[j+1:]...)...)
+			resolved = true
+			break
+		}
+
+		if !resolved {
+			return name
 		}
 	}
+
+	return ""
 }
@@ -0,0 +1,107 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	ar "github.com/mkrautz/goar"
+
+	"github.com/aptly-dev/aptly/pgp"
+)
+
+// ErrDebUnsigned is returned when .deb package doesn't contain embedded signatures
+var ErrDebUnsigned = fmt.Errorf("package has no embedded signatures (_gpgorigin, _gpgbuilder, ...)")
+
+// debsigPrefix is prefix of ar archive members holding signatures created by debsigs,
+// e.g. _gpgorigin or _gpgbuilder
+const debsigPrefix = "_gpg"
+
+// VerifyDebSignatures verifies signatures embedded into .deb package by debsigs
+//
+// Each signature member (_gpgorigin, _gpgbuilder, ...) is a detached signature of
+// debian-binary, control.tar.* and data.tar.* members concatenated. All the signatures
+// should be good, fingerprints of signing keys are returned, sorted.
+func VerifyDebSignatures(packageFile string, verifier pgp.Verifier) ([]string, error) {
+	file, err := os.Open(packageFile)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	signed, err := os.CreateTemp("", "aptly-debsig")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = signed.Close()
+		_ = os.Remove(signed.Name())
+	}()
+
+	signatures := map[string][]byte{}
+
+	library := ar.NewReader(file)
+	for {
+		header, err := library.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read .deb archive %s: %s", packageFile, err)
+		}
+
+		name := strings.TrimSuffix(header.Name, "/")
+
+		switch {
+		case strings.HasPrefix(name, debsigPrefix):
+			signatures[name], err = io.ReadAll(library)
+		case name == "debian-binary" || strings.HasPrefix(name, "control.tar") || strings.HasPrefix(name, "data.tar"):
+			_, err = io.Copy(signed, library)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to read .deb archive %s: %s", packageFile, err)
+		}
+	}
+
+	if len(signatures) == 0 {
+		return nil, ErrDebUnsigned
+	}
+
+	roles := make([]string, 0, len(signatures))
+	for role := range signatures {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	fingerprints := map[string]bool{}
+
+	for _, role := range roles {
+		if _, err = signed.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		keyInfo, err := verifier.VerifyDetachedSignature(bytes.NewReader(signatures[role]), signed, false)
+		if err != nil {
+			return nil, fmt.Errorf("signature %s is not valid: %s", role, err)
+		}
+		if keyInfo == nil || len(keyInfo.GoodFingerprints) == 0 {
+			return nil, fmt.Errorf("signature %s is not valid: no good signatures found", role)
+		}
+
+		for _, fingerprint := range keyInfo.GoodFingerprints {
+			fingerprints[string(fingerprint)] = true
+		}
+	}
+
+	result := make([]string, 0, len(fingerprints))
+	for fingerprint := range fingerprints {
+		result = append(result, fingerprint)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
@@ -0,0 +1,192 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	ar "github.com/mkrautz/goar"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/pgp"
+
+	. "gopkg.in/check.v1"
+)
+
+type DebsigSuite struct {
+	dir      string
+	signer   *openpgp.Entity
+	stranger *openpgp.Entity
+	verifier pgp.Verifier
+}
+
+var _ = Suite(&DebsigSuite{})
+
+func (s *DebsigSuite) SetUpSuite(c *C) {
+	var err error
+	config := &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA}
+
+	s.signer, err = openpgp.NewEntity("Package Signer", "", "signer@example.com", config)
+	c.Assert(err, IsNil)
+	s.stranger, err = openpgp.NewEntity("Stranger", "", "stranger@example.com", config)
+	c.Assert(err, IsNil)
+
+	keyring := filepath.Join(c.MkDir(), "debsig.gpg")
+	f, err := os.Create(keyring)
+	c.Assert(err, IsNil)
+	c.Assert(s.signer.Serialize(f), IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	s.verifier = &pgp.GoVerifier{}
+	s.verifier.AddKeyring(keyring)
+	c.Assert(s.verifier.InitKeyring(false), IsNil)
+}
+
+func (s *DebsigSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+// signDeb copies hardlink .deb adding signature members, signed data is altered if tamper is set
+func (s *DebsigSuite) signDeb(c *C, name string, tamper bool, signers map[string]*openpgp.Entity) string {
+	input, err := os.Open("testdata/changes/hardlink_0.2.1_amd64.deb")
+	c.Assert(err, IsNil)
+	defer func() { _ = input.Close() }()
+
+	var (
+		headers []*ar.Header
+		members [][]byte
+		signed  bytes.Buffer
+	)
+
+	reader := ar.NewReader(input)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+
+		data, err := io.ReadAll(reader)
+		c.Assert(err, IsNil)
+
+		headers = append(headers, header)
+		members = append(members, data)
+		signed.Write(data)
+	}
+
+	if tamper {
+		signed.WriteString("extra")
+	}
+
+	for _, role := range []string{"_gpgbuilder", "_gpgorigin"} {
+		entity, ok := signers[role]
+		if !ok {
+			continue
+		}
+
+		var signature bytes.Buffer
+		c.Assert(openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(signed.Bytes()), nil), IsNil)
+
+		headers = append(headers, &ar.Header{Name: role, Mode: 0644, Size: int64(signature.Len())})
+		members = append(members, signature.Bytes())
+	}
+
+	path := filepath.Join(s.dir, name)
+	output, err := os.Create(path)
+	c.Assert(err, IsNil)
+	defer func() { _ = output.Close() }()
+
+	writer := ar.NewWriter(output)
+	for i := range headers {
+		c.Assert(writer.WriteHeader(headers[i]), IsNil)
+		_, err = writer.Write(members[i])
+		c.Assert(err, IsNil)
+	}
+	c.Assert(writer.Close(), IsNil)
+
+	return path
+}
+
+func (s *DebsigSuite) fingerprint(entity *openpgp.Entity) string {
+	return fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+}
+
+func (s *DebsigSuite) TestVerify(c *C) {
+	_, err := VerifyDebSignatures("testdata/changes/hardlink_0.2.1_amd64.deb", s.verifier)
+	c.Check(err, Equals, ErrDebUnsigned)
+
+	_, err = VerifyDebSignatures(filepath.Join(s.dir, "missing.deb"), s.verifier)
+	c.Check(err, NotNil)
+
+	path := s.signDeb(c, "signed.deb", false, map[string]*openpgp.Entity{"_gpgorigin": s.signer})
+	fingerprints, err := VerifyDebSignatures(path, s.verifier)
+	c.Assert(err, IsNil)
+	c.Check(fingerprints, DeepEquals, []string{s.fingerprint(s.signer)})
+
+	// package is still readable
+	stanza, err := GetControlFileFromDeb(path)
+	c.Assert(err, IsNil)
+	c.Check(stanza["Package"], Equals, "hardlink")
+
+	path = s.signDeb(c, "tampered.deb", true, map[string]*openpgp.Entity{"_gpgorigin": s.signer})
+	_, err = VerifyDebSignatures(path, s.verifier)
+	c.Check(err, ErrorMatches, "signature _gpgorigin is not valid: .*")
+
+	path = s.signDeb(c, "stranger.deb", false, map[string]*openpgp.Entity{"_gpgorigin": s.signer, "_gpgbuilder": s.stranger})
+	_, err = VerifyDebSignatures(path, s.verifier)
+	c.Check(err, ErrorMatches, "signature _gpgbuilder is not valid: .*")
+}
+
+func (s *DebsigSuite) TestImport(c *C) {
+	var db database.Storage
+	db, _ = goleveldb.NewOpenDB(c.MkDir())
+	defer func() { _ = db.Close() }()
+
+	collection := NewPackageCollection(db)
+	pool := files.NewPackagePool(c.MkDir(), false)
+	checksumStorage := files.NewMockChecksumStorage()
+	reporter := &aptly.RecordingResultReporter{}
+
+	signed := s.signDeb(c, "hardlink_0.2.1_amd64.deb", false, map[string]*openpgp.Entity{"_gpgorigin": s.signer})
+	unsigned := filepath.Join(c.MkDir(), "hardlink_0.2.0_i386.deb")
+	data, err := os.ReadFile("testdata/changes/hardlink_0.2.0_i386.deb")
+	c.Assert(err, IsNil)
+	c.Assert(os.WriteFile(unsigned, data, 0644), IsNil)
+
+	list := NewPackageList()
+	processedFiles, failedFiles, err := ImportPackageFiles(list, []string{signed, unsigned}, false, &NullVerifier{}, pool,
+		collection, reporter, nil, VersionPolicyNone, nil, s.verifier, func(database.ReaderWriter) aptly.ChecksumStorage { return checksumStorage })
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, DeepEquals, []string{signed})
+	c.Check(failedFiles, DeepEquals, []string{unsigned})
+	c.Check(reporter.Warnings, HasLen, 1)
+	c.Check(reporter.Warnings[0], Matches, "Unable to verify embedded signature of .*hardlink_0.2.0_i386.deb: package has no embedded signatures.*")
+
+	c.Assert(list.Len(), Equals, 1)
+	var p *Package
+	_ = list.ForEach(func(pkg *Package) error { p = pkg; return nil })
+	c.Check(p.SignedBy, DeepEquals, []string{s.fingerprint(s.signer)})
+	c.Check(p.GetField("$SignedBy"), Equals, s.fingerprint(s.signer))
+
+	stored, err := collection.ByKey(p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(stored.SignedBy, DeepEquals, p.SignedBy)
+
+	// importing the same package without verification keeps signers
+	list = NewPackageList()
+	_, failedFiles, err = ImportPackageFiles(list, []string{signed}, false, &NullVerifier{}, pool,
+		collection, reporter, nil, VersionPolicyNone, nil, nil, func(database.ReaderWriter) aptly.ChecksumStorage { return checksumStorage })
+	c.Assert(err, IsNil)
+	c.Check(failedFiles, HasLen, 0)
+
+	stored, err = collection.ByKey(p.Key(""))
+	c.Assert(err, IsNil)
+	c.Check(stored.SignedBy, DeepEquals, p.SignedBy)
+}
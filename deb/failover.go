@@ -0,0 +1,259 @@
+package deb
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+	"github.com/aptly-dev/aptly/utils"
+	grab "github.com/cavaliergopher/grab/v3"
+)
+
+// ArchiveRootHealth tracks download results for one of the mirror archive roots
+type ArchiveRootHealth struct {
+	// Archive root URL
+	URL string
+	// Time of the last successful download
+	LastSuccess time.Time
+	// Time and error of the last failed download
+	LastFailure time.Time
+	LastError   string `codec:",omitempty" json:",omitempty"`
+	// Number of failures since last successful download
+	ConsecutiveFailures int
+}
+
+// Healthy is true if last download from the archive root succeeded
+func (health *ArchiveRootHealth) Healthy() bool {
+	return health.ConsecutiveFailures == 0
+}
+
+// ArchiveRoots returns ordered list of archive roots: ArchiveRoot followed by fallbacks
+func (repo *RemoteRepo) ArchiveRoots() []string {
+	return append([]string{repo.ArchiveRoot}, repo.FallbackArchiveRoots...)
+}
+
+// SetFallbackArchiveRoots replaces list of fallback archive roots
+//
+// Fallback archive roots should serve exactly the same content as ArchiveRoot.
+func (repo *RemoteRepo) SetFallbackArchiveRoots(roots []string) error {
+	result := []string(nil)
+
+	for _, root := range roots {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+
+		if !strings.HasSuffix(root, "/") {
+			root += "/"
+		}
+
+		u, err := url.Parse(root)
+		if err != nil {
+			return fmt.Errorf("invalid fallback archive URL %s: %s", root, err)
+		}
+		if u.Scheme == "" || (u.Host == "" && u.Scheme != "file") {
+			return fmt.Errorf("invalid fallback archive URL %s: absolute URL required", root)
+		}
+
+		if root == repo.ArchiveRoot || utils.StrSliceHasItem(result, root) {
+			continue
+		}
+
+		result = append(result, root)
+	}
+
+	repo.FallbackArchiveRoots = result
+
+	// drop health information for archive roots which are gone
+	roots = repo.ArchiveRoots()
+	health := repo.ArchiveRootHealth[:0]
+	for _, h := range repo.ArchiveRootHealth {
+		if utils.StrSliceHasItem(roots, h.URL) {
+			health = append(health, h)
+		}
+	}
+	repo.ArchiveRootHealth = health
+
+	return nil
+}
+
+// HealthOf returns health information for archive root, or nil if nothing was downloaded yet
+func (repo *RemoteRepo) HealthOf(root string) *ArchiveRootHealth {
+	for i := range repo.ArchiveRootHealth {
+		if repo.ArchiveRootHealth[i].URL == root {
+			return &repo.ArchiveRootHealth[i]
+		}
+	}
+
+	return nil
+}
+
+func (repo *RemoteRepo) recordHealth(root string, err error, now time.Time) {
+	health := repo.HealthOf(root)
+	if health == nil {
+		repo.ArchiveRootHealth = append(repo.ArchiveRootHealth, ArchiveRootHealth{URL: root})
+		health = &repo.ArchiveRootHealth[len(repo.ArchiveRootHealth)-1]
+	}
+
+	if err == nil {
+		health.LastSuccess = now
+		health.ConsecutiveFailures = 0
+	} else {
+		health.LastFailure = now
+		health.LastError = err.Error()
+		health.ConsecutiveFailures++
+	}
+}
+
+// Downloader wraps downloader so that requests to ArchiveRoot fail over to
+// fallback archive roots on connection errors, HTTP 404 and 5xx errors
+//
+// Archive root which served last request is tried first for next requests.
+// Results of downloads are recorded in ArchiveRootHealth.
+func (repo *RemoteRepo) Downloader(d aptly.Downloader) aptly.Downloader {
+	return &failoverDownloader{Downloader: d, repo: repo}
+}
+
+type failoverDownloader struct {
+	aptly.Downloader
+
+	repo    *RemoteRepo
+	mu      sync.Mutex
+	current int
+}
+
+type failedAttempt struct {
+	root string
+	err  error
+}
+
+// shouldFailover checks whether download error justifies trying another archive root
+//
+// Only connection errors and HTTP errors trigger failover: checksum mismatches
+// and local errors are returned as is, so that corrupted files are reported
+// instead of being served from another archive root.
+func shouldFailover(err error) bool {
+	if errors.Is(err, gocontext.Canceled) {
+		return false
+	}
+
+	var httpErr *http.Error
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == 404 || httpErr.Code >= 500
+	}
+
+	var statusErr grab.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr == 404 || statusErr >= 500
+	}
+
+	// connection refused or reset, timeouts, DNS errors (*url.Error is a net.Error as well)
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// connection closed in the middle of the response
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func isNotFound(err error) bool {
+	var httpErr *http.Error
+	return errors.As(err, &httpErr) && httpErr.Code == 404
+}
+
+func (f *failoverDownloader) try(location string, download func(location string) error) error {
+	roots := f.repo.ArchiveRoots()
+	primary := f.repo.archiveRootURL.String()
+
+	if !strings.HasPrefix(location, primary) {
+		return download(location)
+	}
+	relative := strings.TrimPrefix(location, primary)
+
+	f.mu.Lock()
+	start := f.current
+	f.mu.Unlock()
+
+	var (
+		err    error
+		failed []failedAttempt
+	)
+
+	for i := range roots {
+		idx := (start + i) % len(roots)
+
+		target := roots[idx] + relative
+		if idx == 0 {
+			target = location
+		}
+
+		err = download(target)
+		if err == nil {
+			f.mu.Lock()
+			f.current = idx
+			for _, attempt := range failed {
+				f.repo.recordHealth(attempt.root, attempt.err, time.Now())
+			}
+			f.repo.recordHealth(roots[idx], nil, time.Now())
+			f.mu.Unlock()
+
+			return nil
+		}
+
+		if !shouldFailover(err) {
+			return err
+		}
+
+		failed = append(failed, failedAttempt{roots[idx], err})
+
+		if i < len(roots)-1 && f.GetProgress() != nil {
+			f.GetProgress().Printf("Download from %s failed, trying %s\n", roots[idx], roots[(idx+1)%len(roots)])
+		}
+	}
+
+	// file missing everywhere is not a problem of the archive root
+	f.mu.Lock()
+	for _, attempt := range failed {
+		if !isNotFound(attempt.err) {
+			f.repo.recordHealth(attempt.root, attempt.err, time.Now())
+		}
+	}
+	f.mu.Unlock()
+
+	return err
+}
+
+// Download starts new download task
+func (f *failoverDownloader) Download(ctx gocontext.Context, location string, destination string) error {
+	return f.DownloadWithChecksum(ctx, location, destination, nil, false)
+}
+
+// DownloadWithChecksum starts new download task with checksum verification
+func (f *failoverDownloader) DownloadWithChecksum(ctx gocontext.Context, location string, destination string,
+	expected *utils.ChecksumInfo, ignoreMismatch bool) error {
+	return f.try(location, func(location string) error {
+		return f.Downloader.DownloadWithChecksum(ctx, location, destination, expected, ignoreMismatch)
+	})
+}
+
+// GetLength returns size by heading object with url
+func (f *failoverDownloader) GetLength(ctx gocontext.Context, location string) (int64, error) {
+	var length int64
+
+	err := f.try(location, func(location string) (err error) {
+		length, err = f.Downloader.GetLength(ctx, location)
+		return
+	})
+
+	return length, err
+}
@@ -0,0 +1,131 @@
+package deb
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/http"
+	grab "github.com/cavaliergopher/grab/v3"
+
+	. "gopkg.in/check.v1"
+)
+
+type FailoverSuite struct {
+	repo *RemoteRepo
+	dir  string
+}
+
+var _ = Suite(&FailoverSuite{})
+
+func (s *FailoverSuite) SetUpTest(c *C) {
+	s.repo, _ = NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian", "squeeze", []string{"main"}, []string{}, false, false, false, false)
+	s.dir = c.MkDir()
+}
+
+func (s *FailoverSuite) TestSetFallbackArchiveRoots(c *C) {
+	c.Assert(s.repo.SetFallbackArchiveRoots([]string{"http://ftp.de.debian.org/debian", "", "http://mirror.yandex.ru/debian/",
+		"http://ftp.de.debian.org/debian/"}), IsNil)
+	c.Check(s.repo.FallbackArchiveRoots, DeepEquals, []string{"http://ftp.de.debian.org/debian/"})
+	c.Check(s.repo.ArchiveRoots(), DeepEquals, []string{"http://mirror.yandex.ru/debian/", "http://ftp.de.debian.org/debian/"})
+
+	c.Check(s.repo.SetFallbackArchiveRoots([]string{"ftp.de.debian.org/debian"}), ErrorMatches, ".*absolute URL required")
+
+	s.repo.recordHealth("http://ftp.de.debian.org/debian/", nil, s.repo.LastDownloadDate)
+	c.Assert(s.repo.SetFallbackArchiveRoots([]string{"http://ftp.us.debian.org/debian"}), IsNil)
+	c.Check(s.repo.HealthOf("http://ftp.de.debian.org/debian/"), IsNil)
+
+	c.Assert(s.repo.SetFallbackArchiveRoots(nil), IsNil)
+	c.Check(s.repo.FallbackArchiveRoots, IsNil)
+}
+
+func (s *FailoverSuite) TestFailover(c *C) {
+	_ = s.repo.SetFallbackArchiveRoots([]string{"http://ftp.de.debian.org/debian", "http://ftp.us.debian.org/debian"})
+
+	d := http.NewFakeDownloader().
+		ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/Release", &url.Error{Op: "Get",
+			URL: "http://mirror.yandex.ru/debian/dists/squeeze/Release", Err: errors.New("connection refused")}).
+		ExpectError("http://ftp.de.debian.org/debian/dists/squeeze/Release", &http.Error{Code: 404}).
+		ExpectResponse("http://ftp.us.debian.org/debian/dists/squeeze/Release", "Release").
+		// last successful archive root is tried first
+		ExpectResponse("http://ftp.us.debian.org/debian/pool/main/a/a.deb", "deb").
+		// file which is missing everywhere doesn't affect health
+		ExpectError("http://ftp.us.debian.org/debian/dists/squeeze/main/Contents.gz", &http.Error{Code: 404}).
+		ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/Contents.gz", &http.Error{Code: 404}).
+		ExpectError("http://ftp.de.debian.org/debian/dists/squeeze/main/Contents.gz", &http.Error{Code: 404}).
+		// non-transient HTTP errors are returned immediately
+		ExpectError("http://ftp.us.debian.org/debian/pool/main/b/b.deb", &http.Error{Code: 403})
+
+	downloader := s.repo.Downloader(d)
+
+	c.Assert(downloader.Download(gocontext.TODO(), s.repo.ReleaseURL("Release").String(), filepath.Join(s.dir, "Release")), IsNil)
+	contents, _ := os.ReadFile(filepath.Join(s.dir, "Release"))
+	c.Check(string(contents), Equals, "Release")
+
+	c.Assert(downloader.Download(gocontext.TODO(), s.repo.PackageURL("pool/main/a/a.deb").String(), filepath.Join(s.dir, "a.deb")), IsNil)
+
+	err := downloader.Download(gocontext.TODO(), s.repo.IndexesRootURL().String()+"main/Contents.gz", filepath.Join(s.dir, "Contents.gz"))
+	c.Check(err, ErrorMatches, "HTTP code 404.*")
+
+	err = downloader.Download(gocontext.TODO(), s.repo.PackageURL("pool/main/b/b.deb").String(), filepath.Join(s.dir, "b.deb"))
+	c.Check(err, ErrorMatches, "HTTP code 403.*")
+
+	// other URLs are passed through
+	err = downloader.Download(gocontext.TODO(), "http://example.com/key.gpg", filepath.Join(s.dir, "key.gpg"))
+	c.Check(err, ErrorMatches, "unexpected request for http://example.com/key.gpg")
+
+	c.Check(d.Empty(), Equals, true)
+
+	primary := s.repo.HealthOf("http://mirror.yandex.ru/debian/")
+	c.Assert(primary, NotNil)
+	c.Check(primary.Healthy(), Equals, false)
+	c.Check(primary.ConsecutiveFailures, Equals, 1)
+	c.Check(primary.LastError, Equals, "Get \"http://mirror.yandex.ru/debian/dists/squeeze/Release\": connection refused")
+
+	de := s.repo.HealthOf("http://ftp.de.debian.org/debian/")
+	c.Assert(de, NotNil)
+	c.Check(de.ConsecutiveFailures, Equals, 1)
+
+	us := s.repo.HealthOf("http://ftp.us.debian.org/debian/")
+	c.Assert(us, NotNil)
+	c.Check(us.Healthy(), Equals, true)
+	c.Check(us.LastSuccess.IsZero(), Equals, false)
+
+	repo := &RemoteRepo{}
+	c.Assert(repo.Decode(s.repo.Encode()), IsNil)
+	c.Check(repo.FallbackArchiveRoots, HasLen, 2)
+	c.Check(repo.ArchiveRootHealth, HasLen, 3)
+}
+
+func (s *FailoverSuite) TestNoFailoverOnChecksumMismatch(c *C) {
+	_ = s.repo.SetFallbackArchiveRoots([]string{"http://ftp.de.debian.org/debian"})
+
+	d := http.NewFakeDownloader().
+		ExpectError("http://mirror.yandex.ru/debian/pool/main/a/a.deb",
+			fmt.Errorf("http://mirror.yandex.ru/debian/pool/main/a/a.deb: sha256 hash mismatch \"a\" != \"b\""))
+
+	err := s.repo.Downloader(d).Download(gocontext.TODO(), s.repo.PackageURL("pool/main/a/a.deb").String(), filepath.Join(s.dir, "a.deb"))
+	c.Check(err, ErrorMatches, ".*sha256 hash mismatch.*")
+	c.Check(d.Empty(), Equals, true)
+	c.Check(s.repo.HealthOf("http://ftp.de.debian.org/debian/"), IsNil)
+
+	c.Check(shouldFailover(grab.ErrBadChecksum), Equals, false)
+	c.Check(shouldFailover(grab.StatusCodeError(502)), Equals, true)
+	c.Check(shouldFailover(&os.PathError{Op: "open", Path: "/tmp/a.deb", Err: errors.New("no space left on device")}), Equals, false)
+}
+
+func (s *FailoverSuite) TestFetchFailover(c *C) {
+	_ = s.repo.SetFallbackArchiveRoots([]string{"http://ftp.de.debian.org/debian"})
+
+	d := http.NewFakeDownloader().
+		ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/Release", &http.Error{Code: 503}).
+		ExpectResponse("http://ftp.de.debian.org/debian/dists/squeeze/Release", exampleReleaseFile)
+
+	c.Assert(s.repo.Fetch(s.repo.Downloader(d), nil, true), IsNil)
+	c.Check(s.repo.Components, DeepEquals, []string{"main"})
+	c.Check(d.Empty(), Equals, true)
+	c.Check(s.repo.HealthOf("http://mirror.yandex.ru/debian/").Healthy(), Equals, false)
+}
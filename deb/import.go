@@ -70,9 +70,12 @@ func CollectPackageFiles(locations []string, reporter aptly.ResultReporter) (pac
 }
 
 // ImportPackageFiles imports files into local repository
+//
+// If debsigVerifier is not nil, .deb packages should carry valid embedded signatures,
+// fingerprints of signing keys are recorded in SignedBy.
 func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace bool, verifier pgp.Verifier,
 	pool aptly.PackagePool, collection *PackageCollection, reporter aptly.ResultReporter, restriction PackageQuery,
-	checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, err error) {
+	versionPolicy VersionPolicy, lintPolicy *LintPolicy, debsigVerifier pgp.Verifier, checksumStorageProvider aptly.ChecksumStorageProvider) (processedFiles []string, failedFiles []string, err error) {
 	if forceReplace {
 		list.PrepareIndex()
 	}
@@ -130,6 +133,30 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			continue
 		}
 
+		if lintPolicy != nil {
+			results := lintPolicy.Lint(file, p, verifier)
+			for _, result := range results {
+				if result.Severity == LintError {
+					reporter.Warning("Lint %s: %s", file, result)
+				} else {
+					aptly.ReportNotice(reporter, "Lint %s: %s", file, result)
+				}
+			}
+			if HasLintErrors(results) {
+				failedFiles = append(failedFiles, file)
+				continue
+			}
+		}
+
+		if debsigVerifier != nil && !isSourcePackage {
+			p.SignedBy, err = VerifyDebSignatures(file, debsigVerifier)
+			if err != nil {
+				reporter.Warning("Unable to verify embedded signature of %s: %s", file, err)
+				failedFiles = append(failedFiles, file)
+				continue
+			}
+		}
+
 		var files PackageFiles
 
 		if isSourcePackage {
@@ -147,6 +174,35 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 			Checksums: checksums,
 		}
 
+		// pool import fills in missing checksums, do the same so that policy compares final contents
+		for i := range files {
+			if files[i].Checksums.MD5 != "" && files[i].Checksums.SHA256 != "" {
+				continue
+			}
+
+			sourceFile := filepath.Join(filepath.Dir(file), filepath.Base(files[i].Filename))
+			if info, e := utils.ChecksumsForFile(sourceFile); e == nil {
+				files[i].Checksums = info
+			}
+		}
+
+		// files are sorted in place while hashing, so copy is passed
+		p.UpdateFiles(append(append(PackageFiles{}, files...), mainPackageFile))
+
+		// package is checked before its files are imported, so that nothing is left in the pool if it's rejected
+		if restriction != nil && !restriction.Matches(p) {
+			reporter.Warning("%s has been ignored as it doesn't match restriction", p)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
+		err = versionPolicy.Check(list, p)
+		if err != nil {
+			reporter.Warning("%s", err)
+			failedFiles = append(failedFiles, file)
+			continue
+		}
+
 		mainPackageFile.PoolPath, err = pool.Import(file, mainPackageFile.Filename, &mainPackageFile.Checksums, false, checksumStorage)
 		if err != nil {
 			reporter.Warning("Unable to import file %s into pool: %s", file, err)
@@ -195,10 +251,12 @@ func ImportPackageFiles(list *PackageList, packageFiles []string, forceReplace b
 
 		p.UpdateFiles(append(files, mainPackageFile))
 
-		if restriction != nil && !restriction.Matches(p) {
-			reporter.Warning("%s has been ignored as it doesn't match restriction", p)
-			failedFiles = append(failedFiles, file)
-			continue
+		// keep signers verified and .buildinfo files attached when the same package has been imported before
+		if existing, e := collection.ByKey(p.Key("")); e == nil {
+			if len(p.SignedBy) == 0 {
+				p.SignedBy = existing.SignedBy
+			}
+			p.Buildinfo = existing.Buildinfo
 		}
 
 		err = collection.Update(p)
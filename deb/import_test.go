@@ -0,0 +1,80 @@
+package deb
+
+import (
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/pgp"
+
+	. "gopkg.in/check.v1"
+)
+
+type ImportSuite struct {
+	db              database.Storage
+	collection      *PackageCollection
+	pool            *files.PackagePool
+	checksumStorage aptly.ChecksumStorage
+	reporter        *aptly.RecordingResultReporter
+}
+
+var _ = Suite(&ImportSuite{})
+
+func (s *ImportSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewPackageCollection(s.db)
+	s.pool = files.NewPackagePool(c.MkDir(), false)
+	s.checksumStorage = files.NewMockChecksumStorage()
+	s.reporter = &aptly.RecordingResultReporter{}
+}
+
+func (s *ImportSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *ImportSuite) TestVersionPolicyRejectedNotInPool(c *C) {
+	list := NewPackageList()
+	c.Assert(list.Add(&Package{Name: "hardlink", Version: "9.0", Architecture: "amd64"}), IsNil)
+	c.Assert(list.Add(&Package{Name: "hardlink", Version: "9.0", Architecture: "source", IsSource: true}), IsNil)
+
+	packageFiles := []string{
+		filepath.Join("testdata", "changes", "hardlink_0.2.1_amd64.deb"),
+		filepath.Join("testdata", "changes", "hardlink_0.2.1.dsc"),
+	}
+
+	processedFiles, failedFiles, err := ImportPackageFiles(list, packageFiles, false, &pgp.GoVerifier{}, s.pool, s.collection,
+		s.reporter, nil, VersionPolicyRejectOlder, nil, nil, func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage })
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, HasLen, 0)
+	c.Check(failedFiles, DeepEquals, packageFiles)
+	c.Check(s.reporter.Warnings, HasLen, 2)
+
+	poolFiles, err := s.pool.FilepathList(nil)
+	c.Assert(err, IsNil)
+	c.Check(poolFiles, HasLen, 0)
+}
+
+func (s *ImportSuite) TestLintWarningsAreNotices(c *C) {
+	policy := &LintPolicy{Checks: map[string]string{LintMaxSize: LintWarning}, MaxSize: 1}
+
+	list := NewPackageList()
+	processedFiles, failedFiles, err := ImportPackageFiles(list, []string{filepath.Join("testdata", "changes", "hardlink_0.2.1_amd64.deb")},
+		false, &pgp.GoVerifier{}, s.pool, s.collection, s.reporter, nil, VersionPolicyNone, policy, nil,
+		func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage })
+	c.Assert(err, IsNil)
+	c.Check(processedFiles, HasLen, 1)
+	c.Check(failedFiles, HasLen, 0)
+	c.Check(s.reporter.Warnings, HasLen, 0)
+	c.Check(s.reporter.Notices, HasLen, 1)
+	c.Check(s.reporter.Notices[0], Matches, "Lint .*hardlink_0.2.1_amd64.deb: max-size \\(warning\\): .*")
+
+	policy.Checks[LintMaxSize] = LintError
+	_, failedFiles, err = ImportPackageFiles(NewPackageList(), []string{filepath.Join("testdata", "changes", "hardlink_0.2.0_i386.deb")},
+		false, &pgp.GoVerifier{}, s.pool, s.collection, s.reporter, nil, VersionPolicyNone, policy, nil,
+		func(database.ReaderWriter) aptly.ChecksumStorage { return s.checksumStorage })
+	c.Assert(err, IsNil)
+	c.Check(failedFiles, HasLen, 1)
+	c.Check(s.reporter.Warnings, HasLen, 1)
+}
@@ -0,0 +1,477 @@
+package deb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Defaults for incoming queue processing
+const (
+	// DefaultIncomingSettle is time incoming directory should stay unchanged before uploads are processed
+	DefaultIncomingSettle = 5 * time.Second
+	// DefaultIncomingTimeout is time after which incomplete upload is rejected
+	DefaultIncomingTimeout = time.Hour
+)
+
+// incomingTick is how often watcher checks whether some queue should be processed
+const incomingTick = time.Second
+
+// incomingRescanInterval is how often queues with incomplete uploads are rescanned
+// even if nothing has changed in the directory
+const incomingRescanInterval = time.Minute
+
+// IncomingImporter imports uploads described by .changes files, returning list of files which failed to be imported
+//
+// Successfully imported files should be removed by the importer.
+type IncomingImporter func(changesFiles []string, reporter aptly.ResultReporter) (failedFiles []string, err error)
+
+// IncomingLocker runs processing of the upload while holding lock on the queue, so that
+// the same upload isn't checked, imported and rejected concurrently
+type IncomingLocker func(changesFile string, process func() error) error
+
+// IncomingQueue is incoming directory where .changes uploads are placed
+//
+// Upload is processed once every file referenced by .changes file is present and
+// checksums match. Rejected uploads are moved to quarantine directory along with
+// file explaining the reason.
+type IncomingQueue struct {
+	// Directory being watched
+	Path string
+	// Directory rejected uploads are moved to
+	QuarantinePath string
+	// Uploads are processed once directory hasn't changed for this duration
+	Settle time.Duration
+	// Incomplete uploads are rejected after this duration, 0 to wait forever
+	Timeout time.Duration
+	// Verifier used to parse .changes files (signatures are checked by the importer)
+	Verifier pgp.Verifier
+	// Import processes complete uploads
+	Import IncomingImporter
+	// Reporter receives results of processing
+	Reporter aptly.ResultReporter
+	// Lock wraps processing of each upload, if nil uploads are processed without locking
+	Lock IncomingLocker
+
+	// .changes files with unprocessed uploads, and time they were first seen
+	pending map[string]time.Time
+}
+
+// NewIncomingQueue creates incoming queue for the directory, quarantine defaults to "quarantine" subdirectory
+func NewIncomingQueue(path, quarantinePath string, verifier pgp.Verifier, importer IncomingImporter, reporter aptly.ResultReporter) *IncomingQueue {
+	path = filepath.Clean(path)
+	if quarantinePath == "" {
+		quarantinePath = filepath.Join(path, "quarantine")
+	}
+
+	return &IncomingQueue{
+		Path:           path,
+		QuarantinePath: quarantinePath,
+		Settle:         DefaultIncomingSettle,
+		Timeout:        DefaultIncomingTimeout,
+		Verifier:       verifier,
+		Import:         importer,
+		Reporter:       reporter,
+		pending:        map[string]time.Time{},
+	}
+}
+
+// CheckUpload checks whether all the files referenced by .changes file are complete
+//
+// It returns names of referenced files already present in the directory. Upload is rejected
+// (error is returned) if .changes file can't be parsed or checksums of a file with expected
+// size don't match. Uploads are processed once directory settles, so .changes file is
+// expected to be uploaded completely by then.
+func (q *IncomingQueue) CheckUpload(changesFile string) (present []string, complete bool, err error) {
+	changes, err := NewChanges(changesFile)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = changes.Cleanup() }()
+
+	if err = changes.VerifyAndParse(true, true, q.Verifier); err != nil {
+		return nil, false, err
+	}
+
+	complete = true
+
+	var problem error
+
+	for _, file := range changes.Files {
+		if filepath.Dir(file.Filename) != "." {
+			problem = fmt.Errorf("file is not in the same folder as .changes file: %s", file.Filename)
+			continue
+		}
+
+		path := filepath.Join(changes.BasePath, file.Filename)
+
+		var stat os.FileInfo
+		stat, err = os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				complete = false
+				continue
+			}
+			return present, false, err
+		}
+
+		present = append(present, file.Filename)
+
+		if stat.Size() != file.Checksums.Size {
+			complete = false
+			continue
+		}
+
+		var info utils.ChecksumInfo
+		info, err = utils.ChecksumsForFile(path)
+		if err != nil {
+			return present, false, err
+		}
+
+		if (file.Checksums.MD5 != "" && info.MD5 != file.Checksums.MD5) ||
+			(file.Checksums.SHA1 != "" && info.SHA1 != file.Checksums.SHA1) ||
+			(file.Checksums.SHA256 != "" && info.SHA256 != file.Checksums.SHA256) ||
+			(file.Checksums.SHA512 != "" && info.SHA512 != file.Checksums.SHA512) {
+			problem = fmt.Errorf("checksum mismatch for %s", file.Filename)
+		}
+	}
+
+	if problem != nil {
+		return present, false, problem
+	}
+
+	return present, complete, nil
+}
+
+// HasPending returns true if some uploads are waiting to be processed
+func (q *IncomingQueue) HasPending() bool {
+	return len(q.pending) > 0
+}
+
+// Process scans incoming directory, importing complete uploads and rejecting broken ones
+//
+// Failure to import one upload doesn't stop processing of other uploads, such upload
+// stays pending and import is retried on next run. Combined import error is returned.
+func (q *IncomingQueue) Process(now time.Time) error {
+	entries, err := os.ReadDir(q.Path)
+	if err != nil {
+		return fmt.Errorf("unable to scan %s: %s", q.Path, err)
+	}
+
+	seen := map[string]bool{}
+	failed := []string(nil)
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".changes") {
+			continue
+		}
+
+		changesFile := filepath.Join(q.Path, entry.Name())
+		seen[changesFile] = true
+
+		if _, err = q.ProcessUpload(changesFile, now); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	for changesFile := range q.pending {
+		if !seen[changesFile] {
+			delete(q.pending, changesFile)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "\n"))
+	}
+
+	return nil
+}
+
+// FindUploads returns .changes files in the directory which reference the file, or the file
+// itself if it is .changes file
+//
+// .changes files which can't be parsed are skipped, they are rejected while being processed.
+func (q *IncomingQueue) FindUploads(fileName string) ([]string, error) {
+	if strings.HasSuffix(fileName, ".changes") {
+		return []string{filepath.Join(q.Path, fileName)}, nil
+	}
+
+	entries, err := os.ReadDir(q.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan %s: %s", q.Path, err)
+	}
+
+	var result []string
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".changes") {
+			continue
+		}
+
+		changesFile := filepath.Join(q.Path, entry.Name())
+		if q.references(changesFile, fileName) {
+			result = append(result, changesFile)
+		}
+	}
+
+	return result, nil
+}
+
+// references checks whether .changes file lists the file
+func (q *IncomingQueue) references(changesFile, fileName string) bool {
+	changes, err := NewChanges(changesFile)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = changes.Cleanup() }()
+
+	if changes.VerifyAndParse(true, true, q.Verifier) != nil {
+		return false
+	}
+
+	for _, file := range changes.Files {
+		if file.Filename == fileName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProcessUpload processes single upload described by .changes file under the queue lock,
+// returning names of the files moved to quarantine
+//
+// Nothing is done if upload has been processed already. Incomplete upload is left in the directory.
+func (q *IncomingQueue) ProcessUpload(changesFile string, now time.Time) (rejected []string, err error) {
+	process := func() error {
+		if _, statErr := os.Stat(changesFile); os.IsNotExist(statErr) {
+			return nil
+		}
+
+		rejected, err = q.processUpload(changesFile, now)
+		return err
+	}
+
+	if q.Lock == nil {
+		err = process()
+	} else {
+		err = q.Lock(changesFile, process)
+	}
+
+	return
+}
+
+// processUpload checks upload described by .changes file and imports it if it's complete,
+// returning names of the files moved to quarantine
+//
+// If import fails, upload stays pending and error is returned.
+func (q *IncomingQueue) processUpload(changesFile string, now time.Time) (rejected []string, err error) {
+	changesName := filepath.Base(changesFile)
+
+	firstSeen, ok := q.pending[changesFile]
+	if !ok {
+		firstSeen = now
+		q.pending[changesFile] = now
+	}
+
+	present, complete, err := q.CheckUpload(changesFile)
+	if err != nil {
+		delete(q.pending, changesFile)
+		rejected = append([]string{changesName}, present...)
+		q.reject(changesFile, rejected, []string{err.Error()}, now)
+		return rejected, nil
+	}
+
+	if !complete {
+		if q.Timeout > 0 && now.Sub(firstSeen) >= q.Timeout {
+			delete(q.pending, changesFile)
+			rejected = append([]string{changesName}, present...)
+			q.reject(changesFile, rejected, []string{fmt.Sprintf("upload is still incomplete after %s", q.Timeout)}, now)
+		}
+		return rejected, nil
+	}
+
+	reporter := &aptly.RecordingResultReporter{
+		Warnings:     []string{},
+		AddedLines:   []string{},
+		RemovedLines: []string{},
+	}
+
+	failedFiles, err := q.Import([]string{changesFile}, reporter)
+	for _, line := range reporter.AddedLines {
+		q.Reporter.Added("%s", line)
+	}
+	for _, line := range reporter.RemovedLines {
+		q.Reporter.Removed("%s", line)
+	}
+	for _, line := range reporter.Notices {
+		aptly.ReportNotice(q.Reporter, "%s", line)
+	}
+
+	if err != nil {
+		// upload stays pending, import would be retried later
+		return nil, fmt.Errorf("unable to import %s: %s", changesFile, err)
+	}
+
+	delete(q.pending, changesFile)
+
+	if len(failedFiles) > 0 {
+		// whole upload is rejected if .changes file failed, otherwise only some package files
+		rejected = make([]string, 0, len(failedFiles))
+		for _, file := range failedFiles {
+			if file == changesFile {
+				rejected = append([]string{changesName}, present...)
+				break
+			}
+			rejected = append(rejected, filepath.Base(file))
+		}
+
+		q.reject(changesFile, rejected, reporter.Warnings, now)
+	}
+
+	return rejected, nil
+}
+
+// reject moves files of the upload into quarantine directory, writing reason file next to them
+func (q *IncomingQueue) reject(changesFile string, files []string, reasons []string, now time.Time) {
+	changesName := filepath.Base(changesFile)
+
+	if len(reasons) == 0 {
+		reasons = []string{"upload failed to be imported"}
+	}
+
+	if len(files) > 0 && files[0] == changesName {
+		q.Reporter.Warning("Upload %s rejected: %s", changesName, strings.Join(reasons, "; "))
+	} else {
+		q.Reporter.Warning("Upload %s partially rejected: %s", changesName, strings.Join(reasons, "; "))
+	}
+
+	err := os.MkdirAll(q.QuarantinePath, 0777)
+	if err != nil {
+		q.Reporter.Warning("Unable to create quarantine directory %s: %s", q.QuarantinePath, err)
+		return
+	}
+
+	for _, file := range files {
+		err = moveFile(filepath.Join(q.Path, file), filepath.Join(q.QuarantinePath, file))
+		if err != nil && !os.IsNotExist(err) {
+			q.Reporter.Warning("Unable to move %s to quarantine: %s", file, err)
+		}
+	}
+
+	reason := fmt.Sprintf("Upload: %s\nRejected: %s\nFiles:\n  %s\nReason:\n  %s\n",
+		changesName, now.UTC().Format(time.RFC3339), strings.Join(files, "\n  "), strings.Join(reasons, "\n  "))
+
+	err = os.WriteFile(filepath.Join(q.QuarantinePath, changesName+".reason"), []byte(reason), 0666)
+	if err != nil {
+		q.Reporter.Warning("Unable to write reason file for %s: %s", changesName, err)
+	}
+}
+
+// moveFile renames file, falling back to copy & remove across filesystems
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || os.IsNotExist(err) {
+		return err
+	}
+
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return err
+	}
+
+	if err = utils.CopyFile(src, dst); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// WatchIncomingQueues watches incoming directories for changes and processes uploads
+// once directory contents settle, until stop is closed
+//
+// Directories are scanned once on start, so uploads placed while watcher wasn't running
+// are processed as well.
+func WatchIncomingQueues(queues []*IncomingQueue, stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to start watching: %s", err)
+	}
+	defer func() { _ = watcher.Close() }()
+
+	byPath := map[string]*IncomingQueue{}
+	// time of last change in the directory, queues which need processing
+	changed := map[*IncomingQueue]time.Time{}
+	lastRun := map[*IncomingQueue]time.Time{}
+
+	for _, q := range queues {
+		if err = os.MkdirAll(q.Path, 0777); err != nil {
+			return fmt.Errorf("unable to create %s: %s", q.Path, err)
+		}
+
+		if err = watcher.Add(q.Path); err != nil {
+			return fmt.Errorf("unable to watch %s: %s", q.Path, err)
+		}
+
+		byPath[q.Path] = q
+		changed[q] = time.Time{}
+	}
+
+	ticker := time.NewTicker(incomingTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if q := byPath[filepath.Dir(event.Name)]; q != nil {
+				changed[q] = time.Now()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				return fmt.Errorf("error watching incoming directories: %s", err)
+			}
+
+			// some events were lost, rescan everything
+			for _, q := range queues {
+				changed[q] = time.Now()
+			}
+		case now := <-ticker.C:
+			for _, q := range queues {
+				last, isChanged := changed[q]
+				due := isChanged && now.Sub(last) >= q.Settle
+				if !isChanged && q.HasPending() && now.Sub(lastRun[q]) >= incomingRescanInterval {
+					due = true
+				}
+
+				if !due {
+					continue
+				}
+
+				delete(changed, q)
+				lastRun[q] = now
+
+				if err = q.Process(now); err != nil {
+					q.Reporter.Warning("%s", err)
+				}
+			}
+		}
+	}
+}
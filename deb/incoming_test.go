@@ -0,0 +1,272 @@
+package deb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type IncomingSuite struct {
+	Dir      string
+	queue    *IncomingQueue
+	reporter *aptly.RecordingResultReporter
+	imported [][]string
+	failWith string
+	failFile string
+	errFirst bool
+}
+
+var _ = Suite(&IncomingSuite{})
+
+var incomingUpload = []string{
+	"hardlink_0.2.1_amd64.changes",
+	"hardlink_0.2.1.dsc",
+	"hardlink_0.2.1.tar.gz",
+	"hardlink_0.2.1_amd64.deb",
+	"hardlink_0.2.1_amd64.buildinfo",
+	"hardlink_0.2.0_i386.deb",
+}
+
+func (s *IncomingSuite) SetUpTest(c *C) {
+	s.Dir = c.MkDir()
+	s.imported = nil
+	s.failWith = ""
+	s.failFile = ""
+	s.errFirst = false
+	s.reporter = &aptly.RecordingResultReporter{
+		Warnings:     []string{},
+		AddedLines:   []string{},
+		RemovedLines: []string{},
+	}
+
+	s.queue = NewIncomingQueue(s.Dir, "", &NullVerifier{}, func(changesFiles []string, reporter aptly.ResultReporter) ([]string, error) {
+		s.imported = append(s.imported, changesFiles)
+
+		if s.errFirst && len(s.imported) == 1 {
+			return nil, errors.New("database is locked")
+		}
+
+		if s.failWith != "" {
+			reporter.Warning("%s", s.failWith)
+			if s.failFile == "" {
+				return changesFiles, nil
+			}
+		}
+
+		reporter.Added("hardlink_0.2.1_amd64 added")
+		for _, file := range incomingUpload {
+			if file != s.failFile {
+				_ = os.Remove(filepath.Join(s.Dir, file))
+			}
+		}
+
+		if s.failFile != "" {
+			return []string{filepath.Join(s.Dir, s.failFile)}, nil
+		}
+		return nil, nil
+	}, s.reporter)
+}
+
+func (s *IncomingSuite) upload(c *C, files ...string) {
+	for _, file := range files {
+		c.Assert(utils.CopyFile(filepath.Join("testdata/changes", file), filepath.Join(s.Dir, file)), IsNil)
+	}
+}
+
+func (s *IncomingSuite) TestCheckUpload(c *C) {
+	changesFile := filepath.Join(s.Dir, incomingUpload[0])
+
+	s.upload(c, incomingUpload[:3]...)
+	present, complete, err := s.queue.CheckUpload(changesFile)
+	c.Check(err, IsNil)
+	c.Check(complete, Equals, false)
+	c.Check(present, DeepEquals, []string{"hardlink_0.2.1.dsc", "hardlink_0.2.1.tar.gz"})
+
+	// partially uploaded file
+	c.Assert(os.WriteFile(filepath.Join(s.Dir, "hardlink_0.2.1_amd64.deb"), []byte("!<arch>"), 0644), IsNil)
+	_, complete, err = s.queue.CheckUpload(changesFile)
+	c.Check(err, IsNil)
+	c.Check(complete, Equals, false)
+
+	s.upload(c, incomingUpload[3:]...)
+	present, complete, err = s.queue.CheckUpload(changesFile)
+	c.Check(err, IsNil)
+	c.Check(complete, Equals, true)
+	c.Check(present, HasLen, 5)
+
+	// same size, different contents
+	data, _ := os.ReadFile(filepath.Join(s.Dir, "hardlink_0.2.1.dsc"))
+	data[0] ^= 0xff
+	c.Assert(os.WriteFile(filepath.Join(s.Dir, "hardlink_0.2.1.dsc"), data, 0644), IsNil)
+	present, _, err = s.queue.CheckUpload(changesFile)
+	c.Check(err, ErrorMatches, "checksum mismatch for hardlink_0.2.1.dsc")
+	c.Check(present, HasLen, 5)
+}
+
+func (s *IncomingSuite) TestCheckUploadTruncatedChanges(c *C) {
+	c.Assert(os.WriteFile(filepath.Join(s.Dir, "foo.changes"), []byte("Format: 1.8\nFiles:\n 0123"), 0644), IsNil)
+
+	present, complete, err := s.queue.CheckUpload(filepath.Join(s.Dir, "foo.changes"))
+	c.Check(err, NotNil)
+	c.Check(complete, Equals, false)
+	c.Check(present, HasLen, 0)
+}
+
+func (s *IncomingSuite) TestProcessRejectUnparsable(c *C) {
+	c.Assert(os.WriteFile(filepath.Join(s.Dir, "foo.changes"), []byte("Format: 1.8\nFiles:\n 0123"), 0644), IsNil)
+
+	c.Check(s.queue.Process(time.Now()), IsNil)
+	c.Check(s.imported, HasLen, 0)
+	c.Check(s.queue.HasPending(), Equals, false)
+
+	_, err := os.Stat(filepath.Join(s.queue.QuarantinePath, "foo.changes"))
+	c.Check(err, IsNil)
+	_, err = os.Stat(filepath.Join(s.queue.QuarantinePath, "foo.changes.reason"))
+	c.Check(err, IsNil)
+}
+
+func (s *IncomingSuite) TestProcessImportErrorContinues(c *C) {
+	s.errFirst = true
+	s.upload(c, incomingUpload...)
+	c.Assert(utils.CopyFile(filepath.Join(s.Dir, incomingUpload[0]), filepath.Join(s.Dir, "another.changes")), IsNil)
+
+	err := s.queue.Process(time.Now())
+	c.Check(err, ErrorMatches, "unable to import .*another.changes: database is locked")
+	c.Check(s.imported, DeepEquals, [][]string{
+		{filepath.Join(s.Dir, "another.changes")},
+		{filepath.Join(s.Dir, incomingUpload[0])},
+	})
+	c.Check(s.queue.HasPending(), Equals, true)
+
+	_, err = os.Stat(filepath.Join(s.Dir, "another.changes"))
+	c.Check(err, IsNil)
+}
+
+func (s *IncomingSuite) TestProcessImport(c *C) {
+	now := time.Now()
+
+	s.upload(c, incomingUpload[:4]...)
+	c.Check(s.queue.Process(now), IsNil)
+	c.Check(s.imported, HasLen, 0)
+	c.Check(s.queue.HasPending(), Equals, true)
+
+	s.upload(c, incomingUpload[4:]...)
+	c.Check(s.queue.Process(now.Add(time.Minute)), IsNil)
+	c.Check(s.imported, DeepEquals, [][]string{{filepath.Join(s.Dir, incomingUpload[0])}})
+	c.Check(s.queue.HasPending(), Equals, false)
+	c.Check(s.reporter.AddedLines, DeepEquals, []string{"hardlink_0.2.1_amd64 added"})
+	c.Check(s.reporter.Warnings, HasLen, 0)
+
+	_, err := os.Stat(s.queue.QuarantinePath)
+	c.Check(os.IsNotExist(err), Equals, true)
+}
+
+func (s *IncomingSuite) TestProcessRejectFailed(c *C) {
+	s.failWith = "unable to import: no uploaders rule matches"
+	s.upload(c, incomingUpload...)
+	s.upload(c, "calamares.changes")
+
+	c.Check(s.queue.Process(time.Now()), IsNil)
+	c.Check(s.imported, HasLen, 1)
+	c.Check(s.queue.HasPending(), Equals, true)
+
+	for _, file := range incomingUpload {
+		_, err := os.Stat(filepath.Join(s.Dir, file))
+		c.Check(os.IsNotExist(err), Equals, true)
+		_, err = os.Stat(filepath.Join(s.queue.QuarantinePath, file))
+		c.Check(err, IsNil)
+	}
+
+	// unrelated upload stays
+	_, err := os.Stat(filepath.Join(s.Dir, "calamares.changes"))
+	c.Check(err, IsNil)
+
+	reason, err := os.ReadFile(filepath.Join(s.queue.QuarantinePath, "hardlink_0.2.1_amd64.changes.reason"))
+	c.Assert(err, IsNil)
+	c.Check(string(reason), Matches, "(?s)Upload: hardlink_0.2.1_amd64.changes\n.*no uploaders rule matches\n")
+	c.Check(s.reporter.Warnings, HasLen, 1)
+}
+
+func (s *IncomingSuite) TestProcessRejectPartial(c *C) {
+	s.failWith = "hardlink_0.2.0_i386 has been ignored as it doesn't match restriction"
+	s.failFile = "hardlink_0.2.0_i386.deb"
+	s.upload(c, incomingUpload...)
+
+	c.Check(s.queue.Process(time.Now()), IsNil)
+	c.Check(s.reporter.AddedLines, HasLen, 1)
+	c.Check(s.reporter.Warnings, DeepEquals, []string{"Upload hardlink_0.2.1_amd64.changes partially rejected: " + s.failWith})
+
+	entries, _ := os.ReadDir(s.queue.QuarantinePath)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Name(), Equals, "hardlink_0.2.0_i386.deb")
+	c.Check(entries[1].Name(), Equals, "hardlink_0.2.1_amd64.changes.reason")
+}
+
+func (s *IncomingSuite) TestProcessRejectMismatch(c *C) {
+	s.upload(c, incomingUpload...)
+	c.Assert(os.WriteFile(filepath.Join(s.Dir, "hardlink_0.2.1.dsc"), []byte(strings.Repeat("x", 703)), 0644), IsNil)
+
+	c.Check(s.queue.Process(time.Now()), IsNil)
+	c.Check(s.imported, HasLen, 0)
+
+	reason, err := os.ReadFile(filepath.Join(s.queue.QuarantinePath, "hardlink_0.2.1_amd64.changes.reason"))
+	c.Assert(err, IsNil)
+	c.Check(string(reason), Matches, "(?s).*checksum mismatch for hardlink_0.2.1.dsc\n")
+
+	entries, _ := os.ReadDir(s.Dir)
+	c.Check(entries, HasLen, 1)
+}
+
+func (s *IncomingSuite) TestProcessTimeout(c *C) {
+	now := time.Now()
+	s.upload(c, incomingUpload[:2]...)
+
+	c.Check(s.queue.Process(now), IsNil)
+	c.Check(s.queue.Process(now.Add(s.queue.Timeout/2)), IsNil)
+	_, err := os.Stat(s.queue.QuarantinePath)
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	c.Check(s.queue.Process(now.Add(s.queue.Timeout)), IsNil)
+	c.Check(s.imported, HasLen, 0)
+	c.Check(s.queue.HasPending(), Equals, false)
+
+	for _, file := range incomingUpload[:2] {
+		_, err = os.Stat(filepath.Join(s.queue.QuarantinePath, file))
+		c.Check(err, IsNil)
+	}
+
+	reason, err := os.ReadFile(filepath.Join(s.queue.QuarantinePath, "hardlink_0.2.1_amd64.changes.reason"))
+	c.Assert(err, IsNil)
+	c.Check(string(reason), Matches, "(?s).*upload is still incomplete after 1h0m0s\n")
+}
+
+func (s *IncomingSuite) TestWatch(c *C) {
+	s.queue.Settle = 0
+	stop := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- WatchIncomingQueues([]*IncomingQueue{s.queue}, stop)
+	}()
+
+	s.upload(c, incomingUpload...)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(filepath.Join(s.Dir, incomingUpload[0])); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	close(stop)
+	c.Check(<-done, IsNil)
+	c.Check(s.imported, HasLen, 1)
+}
@@ -0,0 +1,432 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// Kinds of installability problems
+const (
+	// InstallabilityMissing means dependency can't be satisfied by any package
+	InstallabilityMissing = "missing"
+	// InstallabilityConflict means dependency could be satisfied only by conflicting packages
+	InstallabilityConflict = "conflict"
+	// InstallabilityLimit means solver gave up before finding an answer
+	InstallabilityLimit = "limit"
+)
+
+// installabilitySearchLimit is maximum number of solver steps per package
+const installabilitySearchLimit = 100000
+
+// maximum number of reasons reported for one package
+const installabilityMaxReasons = 10
+
+// InstallabilityReason explains why package can't be installed
+type InstallabilityReason struct {
+	// One of: missing, conflict, limit
+	Type string
+	// Dependency which can't be satisfied (for missing dependencies)
+	Dependency string `json:",omitempty"`
+	// Pair of conflicting packages (for conflicts)
+	Conflict []string `json:",omitempty"`
+	// Chains of dependencies leading from the package to the problem,
+	// for conflicts: one chain per conflicting package
+	Chains [][]string `json:",omitempty"`
+}
+
+// String returns human-readable description of the reason
+func (reason *InstallabilityReason) String() string {
+	switch reason.Type {
+	case InstallabilityMissing:
+		return fmt.Sprintf("missing %s", reason.Dependency)
+	case InstallabilityConflict:
+		return fmt.Sprintf("conflict between %s and %s", reason.Conflict[0], reason.Conflict[1])
+	}
+
+	return "search limit exceeded"
+}
+
+// InstallabilityResult is result of installability check for one package on one architecture
+type InstallabilityResult struct {
+	// Package full name
+	Package      string
+	Architecture string
+	Installable  bool
+	// Explanation why package is not installable
+	Reasons []InstallabilityReason `json:",omitempty"`
+}
+
+type installClause struct {
+	dep        string
+	candidates []int
+}
+
+type installNode struct {
+	p         *Package
+	clauses   []installClause
+	conflicts []int
+}
+
+type installVia struct {
+	parent int
+	dep    string
+}
+
+// installSolver looks for set of packages which could be installed together
+type installSolver struct {
+	nodes     []installNode
+	installed map[int]installVia
+	order     []int
+	forbidden []int
+	steps     int
+	reasons   map[string]InstallabilityReason
+}
+
+// buildInstallNodes prepares dependencies and conflicts for all packages of the architecture
+func buildInstallNodes(arch string, options int, sources *PackageList) ([]installNode, map[*Package]int, error) {
+	var nodes []installNode
+	ids := make(map[*Package]int)
+
+	for _, p := range sources.packagesIndex {
+		if p.IsSource || p.IsInstaller || !p.MatchesArchitecture(arch) {
+			continue
+		}
+
+		ids[p] = len(nodes)
+		nodes = append(nodes, installNode{p: p})
+	}
+
+	search := func(dep Dependency, self int) []int {
+		if dep.Architecture == "" {
+			dep.Architecture = arch
+		}
+
+		var result []int
+		seen := map[int]bool{}
+		for _, candidate := range sources.Search(dep, true, true) {
+			id, ok := ids[candidate]
+			if ok && id != self && !seen[id] {
+				seen[id] = true
+				result = append(result, id)
+			}
+		}
+
+		return result
+	}
+
+	byName := make(map[string][]int)
+	conflicts := make([]map[int]bool, len(nodes))
+
+	addConflict := func(a, b int) {
+		for _, pair := range [][2]int{{a, b}, {b, a}} {
+			if conflicts[pair[0]] == nil {
+				conflicts[pair[0]] = map[int]bool{}
+			}
+			conflicts[pair[0]][pair[1]] = true
+		}
+	}
+
+	for id := range nodes {
+		node := &nodes[id]
+		byName[node.p.Name] = append(byName[node.p.Name], id)
+
+		for _, dep := range node.p.GetDependencies(options) {
+			variants, err := ParseDependencyVariants(dep)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to process package %s: %s", node.p, err)
+			}
+
+			clause := installClause{dep: dep}
+			for _, variant := range variants {
+				clause.candidates = append(clause.candidates, search(variant, -1)...)
+			}
+
+			node.clauses = append(node.clauses, clause)
+		}
+
+		extra := node.p.Extra()
+		for _, field := range []string{"Conflicts", "Breaks"} {
+			for _, rel := range parseDependencies(Stanza{field: extra[field]}, field) {
+				dep, err := ParseDependency(rel)
+				if err != nil {
+					return nil, nil, fmt.Errorf("unable to process package %s: %s", node.p, err)
+				}
+
+				for _, other := range search(dep, id) {
+					addConflict(id, other)
+				}
+			}
+		}
+	}
+
+	// only one version of the package could be installed
+	for _, same := range byName {
+		for i := range same {
+			for j := i + 1; j < len(same); j++ {
+				addConflict(same[i], same[j])
+			}
+		}
+	}
+
+	for id := range nodes {
+		for other := range conflicts[id] {
+			nodes[id].conflicts = append(nodes[id].conflicts, other)
+		}
+		sort.Ints(nodes[id].conflicts)
+	}
+
+	return nodes, ids, nil
+}
+
+// reset uninstalls all the packages
+func (solver *installSolver) reset() {
+	for len(solver.order) > 0 {
+		solver.uninstall(solver.order[len(solver.order)-1])
+	}
+	solver.steps = 0
+	solver.reasons = map[string]InstallabilityReason{}
+}
+
+func (solver *installSolver) install(id int, via installVia) {
+	solver.installed[id] = via
+	solver.order = append(solver.order, id)
+	for _, other := range solver.nodes[id].conflicts {
+		solver.forbidden[other]++
+	}
+}
+
+func (solver *installSolver) uninstall(id int) {
+	delete(solver.installed, id)
+	solver.order = solver.order[:len(solver.order)-1]
+	for _, other := range solver.nodes[id].conflicts {
+		solver.forbidden[other]--
+	}
+}
+
+// chain returns dependency chain which led to installation of the package
+func (solver *installSolver) chain(id int) []string {
+	var result []string
+
+	for {
+		via := solver.installed[id]
+		if via.parent == -1 {
+			break
+		}
+
+		result = append([]string{fmt.Sprintf("%s depends on %s", solver.nodes[via.parent].p, via.dep)}, result...)
+		id = via.parent
+	}
+
+	return result
+}
+
+func (solver *installSolver) addReason(reason InstallabilityReason) {
+	key := fmt.Sprintf("%s %s %v %v", reason.Type, reason.Dependency, reason.Conflict, reason.Chains)
+	solver.reasons[key] = reason
+}
+
+// explain records why clause of the installed package can't be satisfied
+func (solver *installSolver) explain(id int, clause *installClause) {
+	step := append(solver.chain(id), fmt.Sprintf("%s depends on %s", solver.nodes[id].p, clause.dep))
+
+	if len(clause.candidates) == 0 {
+		solver.addReason(InstallabilityReason{
+			Type:       InstallabilityMissing,
+			Dependency: clause.dep,
+			Chains:     [][]string{step},
+		})
+		return
+	}
+
+	for _, candidate := range clause.candidates {
+		for _, other := range solver.nodes[candidate].conflicts {
+			if _, ok := solver.installed[other]; !ok {
+				continue
+			}
+
+			solver.addReason(InstallabilityReason{
+				Type:     InstallabilityConflict,
+				Conflict: []string{solver.nodes[other].p.String(), solver.nodes[candidate].p.String()},
+				Chains:   [][]string{solver.chain(other), step},
+			})
+			break
+		}
+	}
+}
+
+// solve satisfies dependencies of installed packages, backtracking on failures
+func (solver *installSolver) solve() bool {
+	solver.steps++
+	if solver.steps > installabilitySearchLimit {
+		return false
+	}
+
+	// pick unsatisfied dependency with fewest options
+	var (
+		best          *installClause
+		bestID        int
+		bestAvailable int
+	)
+
+	for _, id := range solver.order {
+		for i := range solver.nodes[id].clauses {
+			clause := &solver.nodes[id].clauses[i]
+
+			satisfied, available := false, 0
+			for _, candidate := range clause.candidates {
+				if _, ok := solver.installed[candidate]; ok {
+					satisfied = true
+					break
+				}
+				if solver.forbidden[candidate] == 0 {
+					available++
+				}
+			}
+
+			if satisfied {
+				continue
+			}
+
+			if available == 0 {
+				solver.explain(id, clause)
+				return false
+			}
+
+			if best == nil || available < bestAvailable {
+				best, bestID, bestAvailable = clause, id, available
+			}
+		}
+	}
+
+	if best == nil {
+		return true
+	}
+
+	for _, candidate := range best.candidates {
+		if solver.forbidden[candidate] > 0 {
+			continue
+		}
+
+		solver.install(candidate, installVia{parent: bestID, dep: best.dep})
+		if solver.solve() {
+			return true
+		}
+		solver.uninstall(candidate)
+
+		if solver.steps > installabilitySearchLimit {
+			return false
+		}
+	}
+
+	return false
+}
+
+// CheckInstallability checks whether each package in the list could be installed, i.e. whether
+// there's a set of packages in sources satisfying all the dependencies of the package
+// which doesn't have conflicting packages (Conflicts, Breaks or several versions of the same package)
+//
+// Sources should include all the packages of the list. Analysis is performed for each architecture,
+// architecture-independent packages are checked for each architecture.
+func (l *PackageList) CheckInstallability(options int, architectures []string, sources *PackageList,
+	progress aptly.Progress) ([]InstallabilityResult, error) {
+	if len(architectures) == 0 {
+		return nil, fmt.Errorf("no architectures defined, cannot check installability")
+	}
+
+	l.PrepareIndex()
+	sources.PrepareIndex()
+
+	// only hard dependencies matter, but recommends & suggests are followed if requested
+	options &= DepFollowRecommends | DepFollowSuggests
+
+	if progress != nil {
+		progress.InitBar(int64(l.Len())*int64(len(architectures)), false, aptly.BarGeneralVerifyDependencies)
+		defer progress.ShutdownBar()
+	}
+
+	var results []InstallabilityResult
+
+	for _, arch := range architectures {
+		nodes, ids, err := buildInstallNodes(arch, options, sources)
+		if err != nil {
+			return nil, err
+		}
+
+		installable := make([]bool, len(nodes))
+		solver := &installSolver{
+			nodes:     nodes,
+			installed: map[int]installVia{},
+			forbidden: make([]int, len(nodes)),
+		}
+
+		for _, p := range l.packagesIndex {
+			if progress != nil {
+				progress.AddBar(1)
+			}
+
+			id, ok := ids[p]
+			if !ok {
+				continue
+			}
+
+			result := InstallabilityResult{Package: p.String(), Architecture: arch, Installable: installable[id]}
+
+			if !result.Installable {
+				solver.reset()
+				solver.install(id, installVia{parent: -1})
+				result.Installable = solver.solve()
+
+				if result.Installable {
+					// every package in the found set is installable as well
+					for _, other := range solver.order {
+						installable[other] = true
+					}
+				} else if solver.steps > installabilitySearchLimit {
+					result.Reasons = []InstallabilityReason{{Type: InstallabilityLimit}}
+				} else {
+					result.Reasons = solver.sortedReasons()
+				}
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
+
+// sortedReasons returns most direct reasons first
+func (solver *installSolver) sortedReasons() []InstallabilityReason {
+	keys := make([]string, 0, len(solver.reasons))
+	for key := range solver.reasons {
+		keys = append(keys, key)
+	}
+
+	chainsLen := func(reason InstallabilityReason) (n int) {
+		for _, chain := range reason.Chains {
+			n += len(chain)
+		}
+		return
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		li, lj := chainsLen(solver.reasons[keys[i]]), chainsLen(solver.reasons[keys[j]])
+		if li != lj {
+			return li < lj
+		}
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) > installabilityMaxReasons {
+		keys = keys[:installabilityMaxReasons]
+	}
+
+	result := make([]InstallabilityReason, len(keys))
+	for i, key := range keys {
+		result[i] = solver.reasons[key]
+	}
+
+	return result
+}
@@ -0,0 +1,133 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type InstallabilitySuite struct {
+	list *PackageList
+}
+
+var _ = Suite(&InstallabilitySuite{})
+
+func (s *InstallabilitySuite) SetUpTest(c *C) {
+	s.list = NewPackageList()
+}
+
+func (s *InstallabilitySuite) add(c *C, name, version, arch string, fields ...string) {
+	stanza := Stanza{
+		"Package":      name,
+		"Version":      version,
+		"Architecture": arch,
+		"Filename":     "pool/main/" + name + "_" + version + "_" + arch + ".deb",
+	}
+	for i := 0; i < len(fields); i += 2 {
+		stanza[fields[i]] = fields[i+1]
+	}
+
+	c.Assert(s.list.Add(NewPackageFromControlFile(stanza)), IsNil)
+}
+
+func (s *InstallabilitySuite) check(c *C, archs ...string) map[string]InstallabilityResult {
+	results, err := s.list.CheckInstallability(0, archs, s.list, nil)
+	c.Assert(err, IsNil)
+
+	byName := map[string]InstallabilityResult{}
+	for _, result := range results {
+		byName[result.Package+"/"+result.Architecture] = result
+	}
+	return byName
+}
+
+func (s *InstallabilitySuite) TestInstallable(c *C) {
+	s.add(c, "app", "1.0", "amd64", "Depends", "lib (>= 1.0), data", "Pre-Depends", "dpkg")
+	s.add(c, "lib", "1.2", "amd64")
+	s.add(c, "data", "1.0", "all")
+	s.add(c, "dpkg", "1.19", "amd64")
+
+	results := s.check(c, "amd64")
+	c.Check(results, HasLen, 4)
+	for _, result := range results {
+		c.Check(result.Installable, Equals, true)
+		c.Check(result.Reasons, HasLen, 0)
+	}
+}
+
+func (s *InstallabilitySuite) TestMissing(c *C) {
+	s.add(c, "app", "1.0", "amd64", "Depends", "lib (>= 1.0)")
+	s.add(c, "lib", "1.2", "amd64", "Depends", "libc6 (>= 2.30)")
+	s.add(c, "libc6", "2.28", "amd64")
+
+	results := s.check(c, "amd64")
+	c.Check(results["libc6_2.28_amd64/amd64"].Installable, Equals, true)
+	c.Check(results["lib_1.2_amd64/amd64"].Installable, Equals, false)
+	c.Check(results["app_1.0_amd64/amd64"].Installable, Equals, false)
+	c.Check(results["app_1.0_amd64/amd64"].Reasons, DeepEquals, []InstallabilityReason{
+		{
+			Type:       InstallabilityMissing,
+			Dependency: "libc6 (>= 2.30)",
+			Chains:     [][]string{{"app_1.0_amd64 depends on lib (>= 1.0)", "lib_1.2_amd64 depends on libc6 (>= 2.30)"}},
+		},
+	})
+	c.Check(results["app_1.0_amd64/amd64"].Reasons[0].String(), Equals, "missing libc6 (>= 2.30)")
+}
+
+func (s *InstallabilitySuite) TestConflict(c *C) {
+	s.add(c, "app", "1.0", "amd64", "Depends", "foo, bar")
+	s.add(c, "foo", "1.0", "amd64", "Conflicts", "bar")
+	s.add(c, "bar", "1.0", "amd64")
+	s.add(c, "tool", "1.0", "amd64", "Depends", "baz, old-lib")
+	s.add(c, "baz", "1.0", "amd64", "Breaks", "old-lib (<< 2.0)")
+	s.add(c, "old-lib", "1.0", "amd64")
+
+	results := s.check(c, "amd64")
+	c.Check(results["foo_1.0_amd64/amd64"].Installable, Equals, true)
+	c.Check(results["bar_1.0_amd64/amd64"].Installable, Equals, true)
+	c.Check(results["app_1.0_amd64/amd64"].Installable, Equals, false)
+	c.Assert(results["app_1.0_amd64/amd64"].Reasons, HasLen, 1)
+	c.Check(results["app_1.0_amd64/amd64"].Reasons[0].Type, Equals, InstallabilityConflict)
+	c.Check(results["app_1.0_amd64/amd64"].Reasons[0].String(), Matches, "conflict between (foo|bar)_1.0_amd64 and (foo|bar)_1.0_amd64")
+	c.Check(results["tool_1.0_amd64/amd64"].Installable, Equals, false)
+}
+
+func (s *InstallabilitySuite) TestAlternatives(c *C) {
+	s.add(c, "app", "1.0", "amd64", "Depends", "mta | exim, mailx")
+	s.add(c, "mta", "1.0", "amd64", "Conflicts", "mailx")
+	s.add(c, "exim", "4.0", "amd64")
+	s.add(c, "mailx", "1.0", "amd64")
+	s.add(c, "server", "1.0", "amd64", "Depends", "httpd")
+	s.add(c, "nginx", "1.0", "amd64", "Provides", "httpd", "Depends", "missing-lib")
+	s.add(c, "apache", "2.4", "amd64", "Provides", "httpd")
+
+	results := s.check(c, "amd64")
+	c.Check(results["app_1.0_amd64/amd64"].Installable, Equals, true)
+	c.Check(results["server_1.0_amd64/amd64"].Installable, Equals, true)
+	c.Check(results["nginx_1.0_amd64/amd64"].Installable, Equals, false)
+}
+
+func (s *InstallabilitySuite) TestVersions(c *C) {
+	s.add(c, "app", "1.0", "amd64", "Depends", "lib (>= 2.0), plugin")
+	s.add(c, "plugin", "1.0", "amd64", "Depends", "lib (<< 2.0)")
+	s.add(c, "lib", "1.0", "amd64")
+	s.add(c, "lib", "2.0", "amd64")
+
+	results := s.check(c, "amd64")
+	c.Check(results["plugin_1.0_amd64/amd64"].Installable, Equals, true)
+	c.Check(results["app_1.0_amd64/amd64"].Installable, Equals, false)
+	c.Check(results["app_1.0_amd64/amd64"].Reasons[0].Conflict, DeepEquals, []string{"lib_2.0_amd64", "lib_1.0_amd64"})
+}
+
+func (s *InstallabilitySuite) TestArchitectures(c *C) {
+	s.add(c, "data", "1.0", "all", "Depends", "lib")
+	s.add(c, "lib", "1.0", "amd64")
+	s.add(c, "src", "1.0", "source")
+
+	results := s.check(c, "amd64", "i386")
+	c.Check(results, HasLen, 3)
+	c.Check(results["data_1.0_all/amd64"].Installable, Equals, true)
+	c.Check(results["data_1.0_all/i386"].Installable, Equals, false)
+	c.Check(results["lib_1.0_amd64/amd64"].Installable, Equals, true)
+
+	_, err := s.list.CheckInstallability(0, nil, s.list, nil)
+	c.Check(err, ErrorMatches, "no architectures defined, cannot check installability")
+}
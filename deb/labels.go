@@ -0,0 +1,162 @@
+package deb
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var labelKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+// ValidateLabelKey checks that label key is well-formed: alphanumeric characters,
+// '.', '_', '/' and '-', starting and ending with alphanumeric character
+func ValidateLabelKey(key string) error {
+	if !labelKeyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid label key %#v", key)
+	}
+
+	return nil
+}
+
+// ValidateLabels checks all the keys and values of the labels
+//
+// Values can't contain commas, as commas separate labels on the command line.
+func ValidateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("invalid value of label %s: newlines are not allowed", key)
+		}
+
+		if strings.Contains(value, ",") {
+			return fmt.Errorf("invalid value of label %s: commas are not allowed", key)
+		}
+	}
+
+	return nil
+}
+
+// ParseLabel parses label in key=value form
+func ParseLabel(label string) (key, value string, err error) {
+	pos := strings.Index(label, "=")
+	if pos == -1 {
+		return "", "", fmt.Errorf("invalid label %#v, expected key=value", label)
+	}
+
+	key, value = strings.TrimSpace(label[:pos]), label[pos+1:]
+	err = ValidateLabels(map[string]string{key: value})
+
+	return
+}
+
+// UpdateLabels sets and removes labels, returning updated labels (nil if no labels are left)
+func UpdateLabels(labels map[string]string, set map[string]string, remove []string) map[string]string {
+	result := make(map[string]string, len(labels)+len(set))
+	for key, value := range labels {
+		result[key] = value
+	}
+
+	for _, key := range remove {
+		delete(result, key)
+	}
+
+	for key, value := range set {
+		result[key] = value
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+// FormatLabels formats labels as sorted key=value list
+func FormatLabels(labels map[string]string) string {
+	result := make([]string, 0, len(labels))
+	for key, value := range labels {
+		result = append(result, key+"="+value)
+	}
+	sort.Strings(result)
+
+	return strings.Join(result, ", ")
+}
+
+type labelRequirement struct {
+	key, value string
+	// one of: =, !=, exists, !exists
+	op string
+}
+
+// LabelSelector selects objects by their labels, all requirements should match
+type LabelSelector []labelRequirement
+
+// ParseLabelSelector parses list of label requirements
+//
+// Each requirement is one of: key=value, key!=value, key (label is set) and !key
+// (label is not set). Several requirements could be joined with comma.
+func ParseLabelSelector(selectors []string) (LabelSelector, error) {
+	var result LabelSelector
+
+	for _, selector := range selectors {
+		for _, requirement := range strings.Split(selector, ",") {
+			requirement = strings.TrimSpace(requirement)
+			if requirement == "" {
+				continue
+			}
+
+			var r labelRequirement
+
+			if pos := strings.Index(requirement, "!="); pos != -1 {
+				r = labelRequirement{key: requirement[:pos], value: requirement[pos+2:], op: "!="}
+			} else if pos := strings.Index(requirement, "="); pos != -1 {
+				r = labelRequirement{key: requirement[:pos], value: requirement[pos+1:], op: "="}
+			} else if strings.HasPrefix(requirement, "!") {
+				r = labelRequirement{key: requirement[1:], op: "!exists"}
+			} else {
+				r = labelRequirement{key: requirement, op: "exists"}
+			}
+
+			r.key = strings.TrimSpace(r.key)
+			if err := ValidateLabelKey(r.key); err != nil {
+				return nil, fmt.Errorf("invalid label selector %#v: %s", requirement, err)
+			}
+
+			result = append(result, r)
+		}
+	}
+
+	return result, nil
+}
+
+// Matches checks whether labels satisfy all the requirements of the selector
+func (selector LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range selector {
+		value, exists := labels[r.key]
+
+		switch r.op {
+		case "=":
+			if !exists || value != r.value {
+				return false
+			}
+		case "!=":
+			if exists && value == r.value {
+				return false
+			}
+		case "exists":
+			if !exists {
+				return false
+			}
+		case "!exists":
+			if exists {
+				return false
+			}
+		}
+	}
+
+	return true
+}
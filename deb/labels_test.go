@@ -0,0 +1,87 @@
+package deb
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type LabelsSuite struct{}
+
+var _ = Suite(&LabelsSuite{})
+
+func (s *LabelsSuite) TestParseLabel(c *C) {
+	key, value, err := ParseLabel("env=prod")
+	c.Check(err, IsNil)
+	c.Check(key, Equals, "env")
+	c.Check(value, Equals, "prod")
+
+	key, value, err = ParseLabel("example.com/team=a=b")
+	c.Check(err, IsNil)
+	c.Check(key, Equals, "example.com/team")
+	c.Check(value, Equals, "a=b")
+
+	_, value, err = ParseLabel("empty=")
+	c.Check(err, IsNil)
+	c.Check(value, Equals, "")
+
+	_, _, err = ParseLabel("env")
+	c.Check(err, ErrorMatches, "invalid label \"env\", expected key=value")
+
+	_, _, err = ParseLabel("-env=prod")
+	c.Check(err, ErrorMatches, "invalid label key \"-env\"")
+
+	_, _, err = ParseLabel("env=a\nb")
+	c.Check(err, ErrorMatches, "invalid value of label env: .*")
+
+	_, _, err = ParseLabel("env=a,b")
+	c.Check(err, ErrorMatches, "invalid value of label env: commas are not allowed")
+}
+
+func (s *LabelsSuite) TestUpdateLabels(c *C) {
+	labels := map[string]string{"env": "prod", "team": "core"}
+
+	c.Check(UpdateLabels(labels, map[string]string{"env": "stage", "tier": "1"}, []string{"team"}), DeepEquals,
+		map[string]string{"env": "stage", "tier": "1"})
+	// original map is not modified
+	c.Check(labels, DeepEquals, map[string]string{"env": "prod", "team": "core"})
+
+	c.Check(UpdateLabels(labels, nil, []string{"env", "team", "missing"}), IsNil)
+	c.Check(UpdateLabels(nil, nil, nil), IsNil)
+}
+
+func (s *LabelsSuite) TestFormatLabels(c *C) {
+	c.Check(FormatLabels(map[string]string{"team": "core", "env": "prod"}), Equals, "env=prod, team=core")
+	c.Check(FormatLabels(nil), Equals, "")
+}
+
+func (s *LabelsSuite) TestLabelSelector(c *C) {
+	labels := map[string]string{"env": "prod", "team": "core"}
+
+	for _, t := range []struct {
+		selector []string
+		matches  bool
+	}{
+		{nil, true},
+		{[]string{"env=prod"}, true},
+		{[]string{"env=stage"}, false},
+		{[]string{"env!=stage"}, true},
+		{[]string{"env!=prod"}, false},
+		{[]string{"missing!=prod"}, true},
+		{[]string{"team"}, true},
+		{[]string{"!team"}, false},
+		{[]string{"!missing"}, true},
+		{[]string{"env=prod,team=core"}, true},
+		{[]string{"env=prod", "team=other"}, false},
+		{[]string{"env=prod, !missing"}, true},
+	} {
+		selector, err := ParseLabelSelector(t.selector)
+		c.Assert(err, IsNil)
+		c.Check(selector.Matches(labels), Equals, t.matches, Commentf("selector: %v", t.selector))
+	}
+
+	selector, err := ParseLabelSelector([]string{"env"})
+	c.Assert(err, IsNil)
+	c.Check(selector.Matches(nil), Equals, false)
+
+	_, err = ParseLabelSelector([]string{"=prod"})
+	c.Check(err, ErrorMatches, "invalid label selector \"=prod\": invalid label key \"\"")
+}
@@ -0,0 +1,339 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DisposaBoy/JsonConfigReader"
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Lint checks
+const (
+	// Package name, version, architecture and maintainer are well-formed
+	LintControlFields = "control-fields"
+	// Control file values don't have trailing whitespace
+	LintTrailingWhitespace = "trailing-whitespace"
+	// Fields listed in RequiredFields are present in control file
+	LintRequiredFields = "required-fields"
+	// File name matches package name, version and architecture
+	LintFilename = "filename"
+	// Files listed in .dsc match checksums and sizes from .dsc
+	LintDscChecksums = "dsc-checksums"
+	// Size of package files doesn't exceed MaxSize
+	LintMaxSize = "max-size"
+)
+
+// Lint severities
+const (
+	// Check is not run
+	LintOff = "off"
+	// Check failure is reported, but package is imported
+	LintWarning = "warning"
+	// Check failure is reported and package is not imported
+	LintError = "error"
+)
+
+var lintChecks = []string{LintControlFields, LintTrailingWhitespace, LintRequiredFields, LintFilename, LintDscChecksums, LintMaxSize}
+
+var (
+	lintNameRegexp         = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+	lintVersionRegexp      = regexp.MustCompile(`^([0-9]+:)?[0-9][A-Za-z0-9.+~:-]*$`)
+	lintArchitectureRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	lintMaintainerRegexp   = regexp.MustCompile(`^[^<>]+ <[^<>@\s]+@[^<>\s]+>$`)
+)
+
+// LintPolicy is a set of checks run on package files before they are imported into local repository
+type LintPolicy struct {
+	// Severity of each check by check name, checks which are not listed are off
+	Checks map[string]string `json:"checks"`
+	// Fields required by required-fields check, defaults to Maintainer (and Description for binary packages)
+	RequiredFields []string `json:"requiredFields,omitempty"`
+	// Maximum size of package files in bytes for max-size check
+	MaxSize int64 `json:"maxSize,omitempty"`
+}
+
+// LintResult is single failed check
+type LintResult struct {
+	Check    string
+	Severity string
+	Message  string
+}
+
+func (result LintResult) String() string {
+	return fmt.Sprintf("%s (%s): %s", result.Check, result.Severity, result.Message)
+}
+
+func (policy *LintPolicy) String() string {
+	b, _ := json.Marshal(policy)
+	return string(b)
+}
+
+// NewLintPolicyFromFile loads and validates LintPolicy from .json file
+func NewLintPolicyFromFile(path string) (*LintPolicy, error) {
+	policy := &LintPolicy{}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading lint policy file: %s", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	err = json.NewDecoder(JsonConfigReader.New(f)).Decode(&policy)
+	if err != nil {
+		return nil, fmt.Errorf("error loading lint policy file: %s", err)
+	}
+
+	err = policy.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("error loading lint policy file: %s", err)
+	}
+
+	return policy, nil
+}
+
+// Validate checks that policy refers to known checks and severities
+func (policy *LintPolicy) Validate() error {
+	names := make([]string, 0, len(policy.Checks))
+	for check := range policy.Checks {
+		names = append(names, check)
+	}
+	sort.Strings(names)
+
+	for _, check := range names {
+		if !utils.StrSliceHasItem(lintChecks, check) {
+			return fmt.Errorf("unknown lint check %q, supported checks: %s", check, strings.Join(lintChecks, ", "))
+		}
+
+		severity := policy.Checks[check]
+		if severity != LintOff && severity != LintWarning && severity != LintError {
+			return fmt.Errorf("unknown severity %q for lint check %s, should be one of: off, warning, error", severity, check)
+		}
+	}
+
+	if policy.Severity(LintMaxSize) != LintOff && policy.MaxSize <= 0 {
+		return fmt.Errorf("lint check %s requires positive maxSize", LintMaxSize)
+	}
+
+	return nil
+}
+
+// Severity returns severity of the check
+func (policy *LintPolicy) Severity(check string) string {
+	severity, ok := policy.Checks[check]
+	if !ok {
+		return LintOff
+	}
+	return severity
+}
+
+// HasLintErrors returns true if any of the results has error severity
+func HasLintErrors(results []LintResult) bool {
+	for _, result := range results {
+		if result.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint runs enabled checks on package file (.deb, .udeb or .dsc) and package parsed from it
+//
+// Package is expected to be read from the file, but not imported yet, so that
+// Files() of source package lists files referenced by .dsc.
+func (policy *LintPolicy) Lint(file string, p *Package, verifier pgp.Verifier) []LintResult {
+	var results []LintResult
+
+	report := func(check string, format string, args ...interface{}) {
+		results = append(results, LintResult{Check: check, Severity: policy.Severity(check), Message: fmt.Sprintf(format, args...)})
+	}
+
+	enabled := func(check string) bool {
+		return policy.Severity(check) != LintOff
+	}
+
+	var control []byte
+	if enabled(LintTrailingWhitespace) || enabled(LintRequiredFields) {
+		var err error
+		control, err = readRawControlFile(file, p.IsSource, verifier)
+		if err != nil {
+			report(LintRequiredFields, "unable to read control file: %s", err)
+			return results
+		}
+	}
+
+	if enabled(LintControlFields) {
+		if !lintNameRegexp.MatchString(p.Name) {
+			report(LintControlFields, "invalid package name %q", p.Name)
+		}
+		if !lintVersionRegexp.MatchString(p.Version) {
+			report(LintControlFields, "invalid version %q", p.Version)
+		}
+		if !lintArchitectureRegexp.MatchString(p.Architecture) {
+			report(LintControlFields, "invalid architecture %q", p.Architecture)
+		}
+		if maintainer := p.Extra()["Maintainer"]; maintainer != "" && !lintMaintainerRegexp.MatchString(maintainer) {
+			report(LintControlFields, "invalid maintainer %q, should be 'Full Name <email>'", maintainer)
+		}
+	}
+
+	if enabled(LintTrailingWhitespace) {
+		lineNo := 0
+		scanner := bufio.NewScanner(bytes.NewReader(control))
+		scanner.Buffer(nil, MaxFieldSize)
+		for scanner.Scan() {
+			lineNo++
+			line := scanner.Text()
+			trimmed := strings.TrimRight(line, " \t")
+			if trimmed == line {
+				continue
+			}
+			// field with empty value on the first line (multiline field) is allowed to have trailing space
+			if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && strings.HasSuffix(trimmed, ":") {
+				continue
+			}
+			report(LintTrailingWhitespace, "trailing whitespace in control file, line %d: %q", lineNo, line)
+		}
+	}
+
+	if enabled(LintRequiredFields) {
+		stanza, err := NewControlFileReader(bytes.NewReader(control), false, false).ReadStanza()
+		if err != nil {
+			report(LintRequiredFields, "unable to parse control file: %s", err)
+		} else {
+			required := policy.RequiredFields
+			if len(required) == 0 {
+				required = []string{"Maintainer"}
+				if !p.IsSource {
+					required = append(required, "Description")
+				}
+			}
+
+			for _, field := range required {
+				if strings.TrimSpace(stanza[field]) == "" {
+					report(LintRequiredFields, "required field %s is missing", field)
+				}
+			}
+		}
+	}
+
+	if enabled(LintFilename) {
+		policy.lintFilename(file, p, report)
+	}
+
+	if enabled(LintDscChecksums) && p.IsSource {
+		for _, f := range p.Files() {
+			sourceFile := filepath.Join(filepath.Dir(file), filepath.Base(f.Filename))
+			if _, err := os.Stat(sourceFile); err != nil {
+				// file might be already in the package pool, it's verified on import
+				continue
+			}
+
+			actual, err := utils.ChecksumsForFile(sourceFile)
+			if err != nil {
+				report(LintDscChecksums, "unable to checksum %s: %s", f.Filename, err)
+				continue
+			}
+
+			if mismatch := checksumsMismatch(&f.Checksums, &actual); mismatch != "" {
+				report(LintDscChecksums, "%s doesn't match .dsc: %s", f.Filename, mismatch)
+			}
+		}
+	}
+
+	if enabled(LintMaxSize) {
+		info, err := os.Stat(file)
+		if err != nil {
+			report(LintMaxSize, "unable to stat %s: %s", file, err)
+		} else {
+			size := info.Size()
+			if p.IsSource {
+				for _, f := range p.Files() {
+					size += f.Checksums.Size
+				}
+			}
+
+			if size > policy.MaxSize {
+				report(LintMaxSize, "package size %d exceeds maximum %d", size, policy.MaxSize)
+			}
+		}
+	}
+
+	return results
+}
+
+func (policy *LintPolicy) lintFilename(file string, p *Package, report func(check string, format string, args ...interface{})) {
+	base := filepath.Base(file)
+	ext := filepath.Ext(base)
+	parts := strings.Split(strings.TrimSuffix(base, ext), "_")
+
+	expected := 3
+	if p.IsSource {
+		expected = 2
+	}
+	if len(parts) != expected {
+		if p.IsSource {
+			report(LintFilename, "file name %s doesn't follow <name>_<version>%s", base, ext)
+		} else {
+			report(LintFilename, "file name %s doesn't follow <name>_<version>_<architecture>%s", base, ext)
+		}
+		return
+	}
+
+	if parts[0] != p.Name {
+		report(LintFilename, "name %s in file name doesn't match package name %s", parts[0], p.Name)
+	}
+
+	version := p.Version
+	if i := strings.Index(version, ":"); i != -1 {
+		version = version[i+1:]
+	}
+	if parts[1] != version && parts[1] != p.Version {
+		report(LintFilename, "version %s in file name doesn't match package version %s", parts[1], p.Version)
+	}
+
+	if !p.IsSource && parts[2] != p.Architecture {
+		report(LintFilename, "architecture %s in file name doesn't match package architecture %s", parts[2], p.Architecture)
+	}
+}
+
+// readRawControlFile returns contents of control file as is: control file from
+// .deb package or .dsc without clearsign armor
+func readRawControlFile(file string, isSource bool, verifier pgp.Verifier) ([]byte, error) {
+	if !isSource {
+		return GetRawControlFileFromDeb(file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	isClearSigned, err := verifier.IsClearSigned(f)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = f.Seek(0, 0)
+
+	if !isClearSigned {
+		return io.ReadAll(f)
+	}
+
+	text, err := verifier.ExtractClearsigned(f)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = text.Close() }()
+
+	return io.ReadAll(text)
+}
@@ -0,0 +1,167 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type LintSuite struct {
+	dir string
+}
+
+var _ = Suite(&LintSuite{})
+
+func (s *LintSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+func (s *LintSuite) copyTestFile(c *C, name, target string) string {
+	path := filepath.Join(s.dir, target)
+	c.Assert(utils.CopyFile(filepath.Join("testdata", "changes", name), path), IsNil)
+	return path
+}
+
+func (s *LintSuite) readPackage(c *C, file string) *Package {
+	if filepath.Ext(file) == ".dsc" {
+		stanza, err := GetControlFileFromDsc(file, &pgp.GoVerifier{})
+		c.Assert(err, IsNil)
+		stanza["Package"] = stanza["Source"]
+		delete(stanza, "Source")
+
+		p, err := NewSourcePackageFromControlFile(stanza)
+		c.Assert(err, IsNil)
+		return p
+	}
+
+	stanza, err := GetControlFileFromDeb(file)
+	c.Assert(err, IsNil)
+	return NewPackageFromControlFile(stanza)
+}
+
+func (s *LintSuite) TestValidate(c *C) {
+	c.Check((&LintPolicy{}).Validate(), IsNil)
+	c.Check((&LintPolicy{Checks: map[string]string{"filename": "error", "dsc-checksums": "off"}}).Validate(), IsNil)
+	c.Check((&LintPolicy{Checks: map[string]string{"spelling": "error"}}).Validate(), ErrorMatches, "unknown lint check \"spelling\", supported checks: .*")
+	c.Check((&LintPolicy{Checks: map[string]string{"filename": "fatal"}}).Validate(), ErrorMatches, "unknown severity \"fatal\" for lint check filename.*")
+	c.Check((&LintPolicy{Checks: map[string]string{"max-size": "error"}}).Validate(), ErrorMatches, "lint check max-size requires positive maxSize")
+	c.Check((&LintPolicy{Checks: map[string]string{"max-size": "error"}, MaxSize: 1024}).Validate(), IsNil)
+}
+
+func (s *LintSuite) TestNewLintPolicyFromFile(c *C) {
+	_, err := NewLintPolicyFromFile(filepath.Join(s.dir, "missing.json"))
+	c.Check(err, ErrorMatches, "error loading lint policy file: .*")
+
+	path := filepath.Join(s.dir, "lint.json")
+	c.Assert(os.WriteFile(path, []byte(`{
+		// comments are allowed
+		"checks": {"filename": "error", "required-fields": "warning"},
+		"requiredFields": ["Maintainer", "Homepage"]
+	}`), 0644), IsNil)
+
+	policy, err := NewLintPolicyFromFile(path)
+	c.Assert(err, IsNil)
+	c.Check(policy.Severity(LintFilename), Equals, LintError)
+	c.Check(policy.Severity(LintRequiredFields), Equals, LintWarning)
+	c.Check(policy.Severity(LintMaxSize), Equals, LintOff)
+	c.Check(policy.RequiredFields, DeepEquals, []string{"Maintainer", "Homepage"})
+
+	c.Assert(os.WriteFile(path, []byte(`{"checks": {"filename": "maybe"}}`), 0644), IsNil)
+	_, err = NewLintPolicyFromFile(path)
+	c.Check(err, ErrorMatches, "error loading lint policy file: unknown severity.*")
+}
+
+func (s *LintSuite) TestLintBinary(c *C) {
+	policy := &LintPolicy{
+		Checks: map[string]string{
+			LintControlFields:      LintError,
+			LintTrailingWhitespace: LintError,
+			LintRequiredFields:     LintWarning,
+			LintFilename:           LintError,
+			LintMaxSize:            LintWarning,
+		},
+		MaxSize: 1 << 20,
+	}
+
+	file := s.copyTestFile(c, "hardlink_0.2.1_amd64.deb", "hardlink_0.2.1_amd64.deb")
+	c.Check(policy.Lint(file, s.readPackage(c, file), &pgp.GoVerifier{}), HasLen, 0)
+
+	file = s.copyTestFile(c, "hardlink_0.2.1_amd64.deb", "hardlink_0.2.2_i386.deb")
+	results := policy.Lint(file, s.readPackage(c, file), &pgp.GoVerifier{})
+	c.Check(results, DeepEquals, []LintResult{
+		{Check: LintFilename, Severity: LintError, Message: "version 0.2.2 in file name doesn't match package version 0.2.1"},
+		{Check: LintFilename, Severity: LintError, Message: "architecture i386 in file name doesn't match package architecture amd64"},
+	})
+	c.Check(HasLintErrors(results), Equals, true)
+
+	file = s.copyTestFile(c, "hardlink_0.2.1_amd64.deb", "hardlink.deb")
+	c.Check(policy.Lint(file, s.readPackage(c, file), &pgp.GoVerifier{}), DeepEquals, []LintResult{
+		{Check: LintFilename, Severity: LintError, Message: "file name hardlink.deb doesn't follow <name>_<version>_<architecture>.deb"},
+	})
+
+	policy.RequiredFields = []string{"Maintainer", "Built-Using"}
+	policy.MaxSize = 1024
+	file = s.copyTestFile(c, "hardlink_0.2.1_amd64.deb", "hardlink_0.2.1_amd64.deb")
+	p := s.readPackage(c, file)
+	p.Extra()["Maintainer"] = "jak"
+	results = policy.Lint(file, p, &pgp.GoVerifier{})
+	c.Check(results, HasLen, 3)
+	c.Check(results[0].String(), Equals, "control-fields (error): invalid maintainer \"jak\", should be 'Full Name <email>'")
+	c.Check(results[1].String(), Equals, "required-fields (warning): required field Built-Using is missing")
+	c.Check(results[2].String(), Matches, "max-size \\(warning\\): package size [0-9]+ exceeds maximum 1024")
+}
+
+func (s *LintSuite) TestLintSource(c *C) {
+	policy := &LintPolicy{
+		Checks: map[string]string{
+			LintTrailingWhitespace: LintWarning,
+			LintRequiredFields:     LintError,
+			LintFilename:           LintError,
+			LintDscChecksums:       LintError,
+		},
+	}
+
+	file := s.copyTestFile(c, "hardlink_0.2.1.dsc", "hardlink_0.2.1.dsc")
+	c.Check(policy.Lint(file, s.readPackage(c, file), &pgp.GoVerifier{}), HasLen, 0)
+
+	// source tarball is not in the directory: checked on import against the pool
+	tarball := s.copyTestFile(c, "hardlink_0.2.1.tar.gz", "hardlink_0.2.1.tar.gz")
+	c.Check(policy.Lint(file, s.readPackage(c, file), &pgp.GoVerifier{}), HasLen, 0)
+
+	// same size, different contents
+	data, err := os.ReadFile(tarball)
+	c.Assert(err, IsNil)
+	data[100] ^= 0xff
+	c.Assert(os.WriteFile(tarball, data, 0644), IsNil)
+
+	results := policy.Lint(file, s.readPackage(c, file), &pgp.GoVerifier{})
+	c.Check(results, HasLen, 1)
+	c.Check(results[0].Check, Equals, LintDscChecksums)
+	c.Check(results[0].Message, Matches, "hardlink_0.2.1.tar.gz doesn't match .dsc: .*")
+
+	dsc := filepath.Join(s.dir, "broken_1:1.0-1.dsc")
+	c.Assert(os.WriteFile(dsc, []byte("Format: 3.0 (quilt)\nSource: broken\nVersion: 1:1.0-1 \nArchitecture: any\n"+
+		"Checksums-Sha256: \n e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 0 broken_1.0.orig.tar.gz\n"), 0644), IsNil)
+
+	results = policy.Lint(dsc, s.readPackage(c, dsc), &pgp.GoVerifier{})
+	c.Check(results, DeepEquals, []LintResult{
+		{Check: LintTrailingWhitespace, Severity: LintWarning, Message: "trailing whitespace in control file, line 3: \"Version: 1:1.0-1 \""},
+		{Check: LintRequiredFields, Severity: LintError, Message: "required field Maintainer is missing"},
+	})
+}
+
+func (s *LintSuite) TestReadRawControlFileClearsigned(c *C) {
+	dsc := filepath.Join(s.dir, "signed_1.0.dsc")
+	c.Assert(os.WriteFile(dsc, []byte("-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\n"+
+		"Source: signed\nVersion: 1.0\n- -dash\n\n-----BEGIN PGP SIGNATURE-----\n\nabcd\n-----END PGP SIGNATURE-----\n"), 0644), IsNil)
+
+	control, err := readRawControlFile(dsc, true, &pgp.GoVerifier{})
+	c.Assert(err, IsNil)
+	// line endings are canonicalized by the verifier
+	c.Check(strings.ReplaceAll(string(control), "\r\n", "\n"), Equals, "Source: signed\nVersion: 1.0\n-dash\n")
+}
@@ -175,18 +175,25 @@ func (l *PackageList) ForEach(handler func(*Package) error) error {
 // FilterLatest creates a copy of the package list containing only the
 // latest version for each package name/architecture pair.
 func (l *PackageList) FilterLatest() (*PackageList, error) {
+	return l.FilterNewest(1)
+}
+
+// FilterNewest creates a copy of the package list containing only the
+// n newest versions for each package name/architecture pair.
+func (l *PackageList) FilterNewest(n int) (*PackageList, error) {
 	if l == nil {
 		return nil, fmt.Errorf("package list is nil")
 	}
 
-	filtered := make(map[string]*Package, l.Len())
+	if n < 1 {
+		return nil, fmt.Errorf("number of versions to keep should be positive, got %d", n)
+	}
+
+	versions := make(map[string][]*Package, l.Len())
 
 	err := l.ForEach(func(p *Package) error {
 		key := p.Architecture + "|" + p.Name
-
-		if existing, found := filtered[key]; !found || CompareVersions(p.Version, existing.Version) > 0 {
-			filtered[key] = p
-		}
+		versions[key] = append(versions[key], p)
 
 		return nil
 	})
@@ -194,11 +201,21 @@ func (l *PackageList) FilterLatest() (*PackageList, error) {
 		return nil, err
 	}
 
-	result := NewPackageListWithDuplicates(l.duplicatesAllowed, len(filtered))
+	result := NewPackageListWithDuplicates(l.duplicatesAllowed, l.Len())
 
-	for _, pkg := range filtered {
-		if err = result.Add(pkg); err != nil {
-			return nil, err
+	for _, packages := range versions {
+		sort.SliceStable(packages, func(i, j int) bool {
+			return CompareVersions(packages[i].Version, packages[j].Version) > 0
+		})
+
+		if len(packages) > n {
+			packages = packages[:n]
+		}
+
+		for _, pkg := range packages {
+			if err = result.Add(pkg); err != nil {
+				return nil, err
+			}
 		}
 	}
 
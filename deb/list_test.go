@@ -531,6 +531,39 @@ func (s *PackageListSuite) TestFilterLatest(c *C) {
 	c.Check(filtered.Has(sharedPkg), Equals, true)
 }
 
+func (s *PackageListSuite) TestFilterNewest(c *C) {
+	list := NewPackageList()
+
+	packages := map[string]*Package{}
+	for _, version := range []string{"1.0", "1.10", "1.9", "2.0~rc1", "2.0"} {
+		stanza := packageStanza.Copy()
+		stanza["Version"] = version
+		packages[version] = NewPackageFromControlFile(stanza)
+		_ = list.Add(packages[version])
+	}
+
+	other := packageStanza.Copy()
+	other["Package"] = "other"
+	otherPkg := NewPackageFromControlFile(other)
+	_ = list.Add(otherPkg)
+
+	filtered, err := list.FilterNewest(3)
+	c.Assert(err, IsNil)
+	c.Assert(filtered.Len(), Equals, 4)
+	c.Check(filtered.Has(packages["2.0"]), Equals, true)
+	c.Check(filtered.Has(packages["2.0~rc1"]), Equals, true)
+	c.Check(filtered.Has(packages["1.10"]), Equals, true)
+	c.Check(filtered.Has(otherPkg), Equals, true)
+	c.Check(list.Len(), Equals, 6)
+
+	filtered, err = list.FilterNewest(10)
+	c.Assert(err, IsNil)
+	c.Check(filtered.Len(), Equals, 6)
+
+	_, err = list.FilterNewest(0)
+	c.Check(err, ErrorMatches, "number of versions to keep should be positive, got 0")
+}
+
 func (s *PackageListSuite) TestFilterLatestPreservesDuplicatesFlag(c *C) {
 	list := NewPackageListWithDuplicates(true, 2)
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/aptly-dev/aptly/database"
 	"github.com/google/uuid"
@@ -25,8 +26,94 @@ type LocalRepo struct {
 	DefaultComponent string `codec:",omitempty"`
 	// Uploaders configuration
 	Uploaders *Uploaders `codec:"Uploaders,omitempty" json:"-"`
+	// User-defined labels
+	Labels map[string]string `codec:",omitempty" json:",omitempty"`
+	// Policy on versions of packages being added
+	VersionPolicy VersionPolicy `codec:",omitempty" json:",omitempty"`
+	// Checks run on packages being imported
+	LintPolicy *LintPolicy `codec:",omitempty" json:",omitempty"`
+	// Require valid signatures embedded into .deb packages being added
+	RequireDebsig bool `codec:",omitempty" json:",omitempty"`
 	// "Snapshot" of current list of packages
 	packageRefs *PackageRefList
+	// Time packages have been added to the repository, by package key
+	importTimes map[string]time.Time
+}
+
+// VersionPolicy controls which versions of packages could be added to local repository
+type VersionPolicy string
+
+// Version policies, from the least to the most strict one
+const (
+	// VersionPolicyNone allows any version to be added
+	VersionPolicyNone VersionPolicy = ""
+	// VersionPolicyRejectOlder rejects packages older than the package with the same name and architecture in the repo
+	VersionPolicyRejectOlder VersionPolicy = "reject-older"
+	// VersionPolicyRejectEqualDifferent additionally rejects packages with the same version but different contents
+	VersionPolicyRejectEqualDifferent VersionPolicy = "reject-equal-different-content"
+)
+
+// VersionPolicyError is returned when package is rejected by version policy
+type VersionPolicyError struct {
+	// Package being added
+	Package *Package
+	// Conflicting package already in the repository
+	Existing *Package
+	reason   string
+}
+
+func (e *VersionPolicyError) Error() string {
+	return fmt.Sprintf("%s rejected by version policy: %s %s", e.Package, e.reason, e.Existing)
+}
+
+// ParseVersionPolicy parses version policy name
+func ParseVersionPolicy(name string) (VersionPolicy, error) {
+	switch name {
+	case "", "none":
+		return VersionPolicyNone, nil
+	case string(VersionPolicyRejectOlder), string(VersionPolicyRejectEqualDifferent):
+		return VersionPolicy(name), nil
+	}
+
+	return VersionPolicyNone, fmt.Errorf("unknown version policy %q, should be one of: none, %s, %s",
+		name, VersionPolicyRejectOlder, VersionPolicyRejectEqualDifferent)
+}
+
+// String returns name of the policy
+func (policy VersionPolicy) String() string {
+	if policy == VersionPolicyNone {
+		return "none"
+	}
+	return string(policy)
+}
+
+// Check verifies that package could be added to the list according to the policy,
+// returning *VersionPolicyError which mentions conflicting package otherwise
+//
+// List is indexed if it wasn't yet.
+func (policy VersionPolicy) Check(list *PackageList, p *Package) error {
+	if policy == VersionPolicyNone {
+		return nil
+	}
+
+	list.PrepareIndex()
+
+	for _, existing := range list.Search(Dependency{Pkg: p.Name, Relation: VersionDontCare}, true, false) {
+		if existing.Name != p.Name || existing.Architecture != p.Architecture || existing.IsSource != p.IsSource {
+			continue
+		}
+
+		r := CompareVersions(p.Version, existing.Version)
+		if r < 0 {
+			return &VersionPolicyError{Package: p, Existing: existing, reason: "repository already contains newer version"}
+		}
+
+		if r == 0 && policy == VersionPolicyRejectEqualDifferent && !existing.Equals(p) {
+			return &VersionPolicyError{Package: p, Existing: existing, reason: "contents differ from"}
+		}
+	}
+
+	return nil
 }
 
 // NewLocalRepo creates new instance of Debian local repository
@@ -60,8 +147,44 @@ func (repo *LocalRepo) RefList() *PackageRefList {
 }
 
 // UpdateRefList changes package list for local repo
+//
+// Packages which are not in the current list are recorded as added now.
 func (repo *LocalRepo) UpdateRefList(reflist *PackageRefList) {
+	if reflist == nil {
+		repo.packageRefs = nil
+		repo.importTimes = nil
+		return
+	}
+
+	added := reflist
+	if repo.packageRefs != nil {
+		added = reflist.Subtract(repo.packageRefs)
+	}
+
+	now := time.Now().UTC()
+	importTimes := make(map[string]time.Time, reflist.Len())
+
+	_ = reflist.ForEach(func(key []byte) error {
+		if t, ok := repo.importTimes[string(key)]; ok {
+			importTimes[string(key)] = t
+		}
+		return nil
+	})
+
+	_ = added.ForEach(func(key []byte) error {
+		importTimes[string(key)] = now
+		return nil
+	})
+
 	repo.packageRefs = reflist
+	repo.importTimes = importTimes
+}
+
+// ImportTime returns time when package has been added to the repository,
+// false is returned if it is unknown (package has been added by older version of aptly)
+func (repo *LocalRepo) ImportTime(p *Package) (time.Time, bool) {
+	t, ok := repo.importTimes[string(p.Key(""))]
+	return t, ok
 }
 
 // Encode does msgpack encoding of LocalRepo
@@ -90,6 +213,21 @@ func (repo *LocalRepo) RefKey() []byte {
 	return []byte("E" + repo.UUID)
 }
 
+// ImportTimesKey is a unique id for times packages have been added to the repo
+func (repo *LocalRepo) ImportTimesKey() []byte {
+	return []byte("I" + repo.UUID)
+}
+
+// encodeImportTimes does msgpack encoding of package import times
+func (repo *LocalRepo) encodeImportTimes() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(repo.importTimes)
+
+	return buf.Bytes()
+}
+
 // LocalRepoCollection does listing, updating/adding/deleting of LocalRepos
 type LocalRepoCollection struct {
 	db    database.Storage
@@ -163,14 +301,28 @@ func (collection *LocalRepoCollection) Update(repo *LocalRepo) error {
 	if repo.packageRefs != nil {
 		_ = batch.Put(repo.RefKey(), repo.packageRefs.Encode())
 	}
+	if repo.importTimes != nil {
+		_ = batch.Put(repo.ImportTimesKey(), repo.encodeImportTimes())
+	}
 	return batch.Write()
 }
 
 // LoadComplete loads additional information for local repo
 func (collection *LocalRepoCollection) LoadComplete(repo *LocalRepo) error {
 	repo.packageRefs = &PackageRefList{}
+	repo.importTimes = map[string]time.Time{}
+
+	encoded, err := collection.db.Get(repo.ImportTimesKey())
+	if err == nil {
+		decoder := codec.NewDecoderBytes(encoded, &codec.MsgpackHandle{})
+		if err = decoder.Decode(&repo.importTimes); err != nil {
+			return err
+		}
+	} else if err != database.ErrNotFound {
+		return err
+	}
 
-	encoded, err := collection.db.Get(repo.RefKey())
+	encoded, err = collection.db.Get(repo.RefKey())
 	if err == database.ErrNotFound {
 		return nil
 	}
@@ -250,5 +402,6 @@ func (collection *LocalRepoCollection) Drop(repo *LocalRepo) error {
 	batch := collection.db.CreateBatch()
 	_ = batch.Delete(repo.Key())
 	_ = batch.Delete(repo.RefKey())
+	_ = batch.Delete(repo.ImportTimesKey())
 	return batch.Write()
 }
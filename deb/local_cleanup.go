@@ -0,0 +1,145 @@
+package deb
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// LocalRepoCleanupOptions controls which versions of packages are removed from local repository
+type LocalRepoCleanupOptions struct {
+	// Number of newest versions to keep for each package name/architecture pair
+	Keep int
+	// Remove only versions added to the repository before this moment (zero time removes any)
+	OlderThan time.Time
+	// Repository packages belong to, used to find out when package has been added (required with OlderThan)
+	Repo *LocalRepo
+	// Keys of packages which should never be removed mapped to the reason, see PinnedPackageRefs
+	Pinned map[string]string
+}
+
+// LocalRepoCleanupDecision is result of applying cleanup options to one package version
+type LocalRepoCleanupDecision struct {
+	// Package name, version and architecture
+	Name string
+	// Package key
+	Key string
+	// Should package be removed?
+	Remove bool
+	// Why package is kept, if it is not removed
+	Reason string
+
+	pkg *Package
+}
+
+// Package returns package the decision is made for
+func (decision *LocalRepoCleanupDecision) Package() *Package {
+	return decision.pkg
+}
+
+// PlanLocalRepoCleanup finds versions of packages in the list which should be removed
+//
+// Versions are sorted with CompareVersions for each name/architecture pair and Keep newest
+// are kept, those are not included in the result. Rest of the versions are removed unless
+// they have been added to the repository after OlderThan or pinned. Versions added to the
+// repository before import times have been recorded are kept. Decisions are sorted by name, architecture and
+// version, newest first.
+func PlanLocalRepoCleanup(list *PackageList, options LocalRepoCleanupOptions) ([]*LocalRepoCleanupDecision, error) {
+	if options.Keep < 1 {
+		return nil, fmt.Errorf("number of versions to keep should be positive, got %d", options.Keep)
+	}
+
+	if !options.OlderThan.IsZero() && options.Repo == nil {
+		return nil, fmt.Errorf("repository is required to check age of packages")
+	}
+
+	versions := make(map[string][]*Package)
+
+	_ = list.ForEach(func(p *Package) error {
+		key := p.Name + "|" + p.Architecture
+		versions[key] = append(versions[key], p)
+		return nil
+	})
+
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var decisions []*LocalRepoCleanupDecision
+
+	for _, key := range keys {
+		packages := versions[key]
+		if len(packages) <= options.Keep {
+			continue
+		}
+
+		sort.SliceStable(packages, func(i, j int) bool {
+			return CompareVersions(packages[i].Version, packages[j].Version) > 0
+		})
+
+		for _, p := range packages[options.Keep:] {
+			decision := &LocalRepoCleanupDecision{Name: p.String(), Key: string(p.Key("")), Remove: true, pkg: p}
+
+			if reason, ok := options.Pinned[decision.Key]; ok {
+				decision.Remove = false
+				decision.Reason = reason
+			} else if !options.OlderThan.IsZero() {
+				imported, known := options.Repo.ImportTime(p)
+				if !known {
+					decision.Remove = false
+					decision.Reason = "import time unknown"
+				} else if imported.After(options.OlderThan) {
+					decision.Remove = false
+					decision.Reason = fmt.Sprintf("imported at %s", imported.Format(time.RFC3339))
+				}
+			}
+
+			decisions = append(decisions, decision)
+		}
+	}
+
+	return decisions, nil
+}
+
+// PinnedPackageRefs returns keys of packages referenced by protected or published snapshots,
+// mapped to the description of the snapshot
+func (collection *SnapshotCollection) PinnedPackageRefs(publishedCollection *PublishedRepoCollection) (map[string]string, error) {
+	var snapshots []*Snapshot
+
+	err := collection.ForEach(func(snapshot *Snapshot) error {
+		snapshots = append(snapshots, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+
+	for _, snapshot := range snapshots {
+		var reason string
+		if snapshot.Protected {
+			reason = fmt.Sprintf("referenced by protected snapshot %s", snapshot.Name)
+		} else if published := publishedCollection.BySnapshot(snapshot); len(published) > 0 {
+			reason = fmt.Sprintf("referenced by snapshot %s published at %s", snapshot.Name, published[0].GetPath())
+		} else {
+			continue
+		}
+
+		err = collection.LoadComplete(snapshot)
+		if err != nil {
+			return nil, err
+		}
+
+		_ = snapshot.RefList().ForEach(func(key []byte) error {
+			if _, exists := result[string(key)]; !exists {
+				result[string(key)] = reason
+			}
+			return nil
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package deb
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type LocalRepoCleanupSuite struct {
+	list *PackageList
+}
+
+var _ = Suite(&LocalRepoCleanupSuite{})
+
+func (s *LocalRepoCleanupSuite) SetUpTest(c *C) {
+	s.list = NewPackageList()
+	for _, version := range []string{"1.0", "1.10", "1.9", "1:0.1", "1.2~rc1"} {
+		c.Assert(s.list.Add(&Package{Name: "app", Version: version, Architecture: "amd64"}), IsNil)
+	}
+	c.Assert(s.list.Add(&Package{Name: "app", Version: "1.0", Architecture: "i386"}), IsNil)
+	c.Assert(s.list.Add(&Package{Name: "lib", Version: "2.0", Architecture: "amd64"}), IsNil)
+	c.Assert(s.list.Add(&Package{Name: "lib", Version: "2.1", Architecture: "amd64"}), IsNil)
+}
+
+func decisionNames(decisions []*LocalRepoCleanupDecision, remove bool) (result []string) {
+	for _, decision := range decisions {
+		if decision.Remove == remove {
+			result = append(result, decision.Name)
+		}
+	}
+	return
+}
+
+func (s *LocalRepoCleanupSuite) TestPlan(c *C) {
+	_, err := PlanLocalRepoCleanup(s.list, LocalRepoCleanupOptions{})
+	c.Check(err, ErrorMatches, "number of versions to keep should be positive, got 0")
+
+	decisions, err := PlanLocalRepoCleanup(s.list, LocalRepoCleanupOptions{Keep: 2})
+	c.Assert(err, IsNil)
+	c.Check(decisionNames(decisions, true), DeepEquals, []string{"app_1.9_amd64", "app_1.2~rc1_amd64", "app_1.0_amd64"})
+	c.Check(decisionNames(decisions, false), HasLen, 0)
+	c.Check(decisions[0].Package().Version, Equals, "1.9")
+	c.Check(decisions[0].Key, Equals, "Pamd64 app 1.9")
+
+	decisions, err = PlanLocalRepoCleanup(s.list, LocalRepoCleanupOptions{Keep: 1})
+	c.Assert(err, IsNil)
+	c.Check(decisionNames(decisions, true), DeepEquals,
+		[]string{"app_1.10_amd64", "app_1.9_amd64", "app_1.2~rc1_amd64", "app_1.0_amd64", "lib_2.0_amd64"})
+
+	decisions, err = PlanLocalRepoCleanup(s.list, LocalRepoCleanupOptions{Keep: 5})
+	c.Assert(err, IsNil)
+	c.Check(decisions, HasLen, 0)
+}
+
+func (s *LocalRepoCleanupSuite) TestPlanPinned(c *C) {
+	decisions, err := PlanLocalRepoCleanup(s.list, LocalRepoCleanupOptions{
+		Keep:   1,
+		Pinned: map[string]string{"Pamd64 app 1.9": "referenced by protected snapshot release"},
+	})
+	c.Assert(err, IsNil)
+	c.Check(decisionNames(decisions, true), DeepEquals, []string{"app_1.10_amd64", "app_1.2~rc1_amd64", "app_1.0_amd64", "lib_2.0_amd64"})
+	c.Check(decisionNames(decisions, false), DeepEquals, []string{"app_1.9_amd64"})
+	c.Check(decisions[1].Reason, Equals, "referenced by protected snapshot release")
+}
+
+func (s *LocalRepoCleanupSuite) TestPlanOlderThan(c *C) {
+	repo := NewLocalRepo("nightly", "")
+
+	list := NewPackageList()
+	c.Assert(list.Add(&Package{Name: "app", Version: "1.0", Architecture: "amd64"}), IsNil)
+	c.Assert(list.Add(&Package{Name: "app", Version: "1.1", Architecture: "amd64"}), IsNil)
+	repo.UpdateRefList(NewPackageRefListFromPackageList(list))
+
+	imported, known := repo.ImportTime(list.packages["Pamd64 app 1.0"])
+	c.Check(known, Equals, true)
+	c.Check(time.Since(imported) < time.Hour, Equals, true)
+
+	_, err := PlanLocalRepoCleanup(list, LocalRepoCleanupOptions{Keep: 1, OlderThan: time.Now()})
+	c.Check(err, ErrorMatches, "repository is required to check age of packages")
+
+	decisions, err := PlanLocalRepoCleanup(list, LocalRepoCleanupOptions{Keep: 1, OlderThan: time.Now().Add(-time.Hour), Repo: repo})
+	c.Assert(err, IsNil)
+	c.Check(decisionNames(decisions, true), HasLen, 0)
+	c.Check(decisionNames(decisions, false), DeepEquals, []string{"app_1.0_amd64"})
+	c.Check(decisions[0].Reason, Matches, "imported at .*")
+
+	decisions, err = PlanLocalRepoCleanup(list, LocalRepoCleanupOptions{Keep: 1, OlderThan: time.Now().Add(time.Hour), Repo: repo})
+	c.Assert(err, IsNil)
+	c.Check(decisionNames(decisions, true), DeepEquals, []string{"app_1.0_amd64"})
+
+	// packages added before import times were recorded are kept
+	decisions, err = PlanLocalRepoCleanup(list, LocalRepoCleanupOptions{Keep: 1, OlderThan: time.Now().Add(time.Hour), Repo: NewLocalRepo("old", "")})
+	c.Assert(err, IsNil)
+	c.Check(decisionNames(decisions, false), DeepEquals, []string{"app_1.0_amd64"})
+	c.Check(decisions[0].Reason, Equals, "import time unknown")
+}
+
+func (s *LocalRepoCleanupSuite) TestPinnedPackageRefs(c *C) {
+	var db database.Storage
+	db, _ = goleveldb.NewOpenDB(c.MkDir())
+	defer func() { _ = db.Close() }()
+
+	snapshotCollection := NewSnapshotCollection(db)
+
+	protected := NewSnapshotFromPackageList("release", nil, s.list, "")
+	protected.Protected = true
+	c.Assert(snapshotCollection.Add(protected), IsNil)
+
+	other := NewPackageList()
+	c.Assert(other.Add(&Package{Name: "tool", Version: "1.0", Architecture: "amd64"}), IsNil)
+	c.Assert(snapshotCollection.Add(NewSnapshotFromPackageList("nightly", nil, other, "")), IsNil)
+
+	pinned, err := NewSnapshotCollection(db).PinnedPackageRefs(NewPublishedRepoCollection(db))
+	c.Assert(err, IsNil)
+	c.Check(pinned, HasLen, s.list.Len())
+	c.Check(pinned["Pamd64 app 1.9"], Equals, "referenced by protected snapshot release")
+	_, ok := pinned["Pamd64 tool 1.0"]
+	c.Check(ok, Equals, false)
+}
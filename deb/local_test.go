@@ -166,6 +166,44 @@ func (s *LocalRepoCollectionSuite) TestUpdateLoadComplete(c *C) {
 	c.Assert(r.NumPackages(), Equals, 2)
 }
 
+func (s *LocalRepoCollectionSuite) TestImportTimes(c *C) {
+	repo := NewLocalRepo("local1", "Comment 1")
+	repo.UpdateRefList(s.reflist)
+	c.Assert(s.collection.Add(repo), IsNil)
+
+	lib := s.list.packages["Pi386 lib 1.7"]
+	added, known := repo.ImportTime(lib)
+	c.Assert(known, Equals, true)
+
+	r, err := NewLocalRepoCollection(s.db).ByName("local1")
+	c.Assert(err, IsNil)
+	_, known = r.ImportTime(lib)
+	c.Check(known, Equals, false)
+	c.Assert(s.collection.LoadComplete(r), IsNil)
+	loaded, known := r.ImportTime(lib)
+	c.Check(known, Equals, true)
+	c.Check(loaded.Equal(added), Equals, true)
+
+	// time of packages already in the repo is kept, new packages get current time
+	list := NewPackageList()
+	_ = list.Add(lib)
+	tool := &Package{Name: "tool", Version: "1.0", Architecture: "amd64"}
+	_ = list.Add(tool)
+	r.UpdateRefList(NewPackageRefListFromPackageList(list))
+
+	loaded, _ = r.ImportTime(lib)
+	c.Check(loaded.Equal(added), Equals, true)
+	toolAdded, known := r.ImportTime(tool)
+	c.Check(known, Equals, true)
+	c.Check(toolAdded.Before(added), Equals, false)
+	_, known = r.ImportTime(s.list.packages["Pamd64 app 1.9"])
+	c.Check(known, Equals, false)
+
+	c.Assert(s.collection.Drop(r), IsNil)
+	_, err = s.db.Get(r.ImportTimesKey())
+	c.Check(err, Equals, database.ErrNotFound)
+}
+
 func (s *LocalRepoCollectionSuite) TestForEachAndLen(c *C) {
 	repo := NewLocalRepo("local1", "Comment 1")
 	_ = s.collection.Add(repo)
@@ -223,3 +261,49 @@ func (s *LocalRepoCollectionSuite) TestDropNonExisting(c *C) {
 	_ = s.collection.Drop(repo)
 	c.Check(s.collection.Drop(repo), ErrorMatches, "local repo not found")
 }
+
+func (s *LocalRepoSuite) TestParseVersionPolicy(c *C) {
+	for _, name := range []string{"", "none"} {
+		policy, err := ParseVersionPolicy(name)
+		c.Check(err, IsNil)
+		c.Check(policy, Equals, VersionPolicyNone)
+		c.Check(policy.String(), Equals, "none")
+	}
+
+	policy, err := ParseVersionPolicy("reject-older")
+	c.Check(err, IsNil)
+	c.Check(policy, Equals, VersionPolicyRejectOlder)
+
+	policy, err = ParseVersionPolicy("reject-equal-different-content")
+	c.Check(err, IsNil)
+	c.Check(policy, Equals, VersionPolicyRejectEqualDifferent)
+
+	_, err = ParseVersionPolicy("reject-all")
+	c.Check(err, ErrorMatches, "unknown version policy \"reject-all\".*")
+}
+
+func (s *LocalRepoSuite) TestVersionPolicyCheck(c *C) {
+	older := &Package{Name: "lib", Version: "1.6", Architecture: "i386"}
+	same := &Package{Name: "lib", Version: "1.7", Architecture: "i386"}
+	changed := &Package{Name: "lib", Version: "1.7", Architecture: "i386", FilesHash: 0x1234}
+	other := &Package{Name: "lib", Version: "1.6", Architecture: "amd64"}
+	source := &Package{Name: "lib", Version: "1.6", Architecture: "source", IsSource: true}
+
+	for _, p := range []*Package{older, same, changed, other, source} {
+		c.Check(VersionPolicyNone.Check(s.list, p), IsNil)
+	}
+
+	err := VersionPolicyRejectOlder.Check(s.list, older)
+	c.Check(err, ErrorMatches, "lib_1.6_i386 rejected by version policy: repository already contains newer version lib_1.7_i386")
+	c.Check(err.(*VersionPolicyError).Existing.Version, Equals, "1.7")
+	c.Check(VersionPolicyRejectOlder.Check(s.list, same), IsNil)
+	c.Check(VersionPolicyRejectOlder.Check(s.list, changed), IsNil)
+	c.Check(VersionPolicyRejectOlder.Check(s.list, other), IsNil)
+	c.Check(VersionPolicyRejectOlder.Check(s.list, source), IsNil)
+
+	c.Check(VersionPolicyRejectEqualDifferent.Check(s.list, older), ErrorMatches, ".*already contains newer version lib_1.7_i386")
+	c.Check(VersionPolicyRejectEqualDifferent.Check(s.list, same), IsNil)
+	c.Check(VersionPolicyRejectEqualDifferent.Check(s.list, changed), ErrorMatches,
+		"lib_1.7_i386 rejected by version policy: contents differ from lib_1.7_i386")
+	c.Check(VersionPolicyRejectEqualDifferent.Check(s.list, other), IsNil)
+}
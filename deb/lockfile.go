@@ -0,0 +1,393 @@
+package deb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aptly-dev/aptly/aptly"
+)
+
+// LockfileFormat is current version of lockfile format
+const LockfileFormat = 1
+
+// LockfileEntry pins exact package
+type LockfileEntry struct {
+	Name         string
+	Version      string
+	Architecture string
+	// SHA256 of package file (.dsc file for source packages)
+	SHA256 string
+}
+
+// String returns package full name
+func (entry *LockfileEntry) String() string {
+	return fmt.Sprintf("%s_%s_%s", entry.Name, entry.Version, entry.Architecture)
+}
+
+// Lockfile is list of pinned packages, used to reproduce exact package set
+type Lockfile struct {
+	Format int
+	// Name of the snapshot lockfile was exported from
+	Snapshot string `json:",omitempty"`
+	Packages []LockfileEntry
+}
+
+// LockfileSource is list of packages lockfile entries are resolved against
+type LockfileSource struct {
+	// Human-readable description of the source, e.g. "mirror wheezy-main"
+	Name     string
+	Packages *PackageList
+	// Snapshot is set if source is a snapshot
+	Snapshot *Snapshot
+}
+
+// lockfileChecksum returns SHA256 checksum which pins the package
+func lockfileChecksum(p *Package) string {
+	files := p.Files()
+	for _, f := range files {
+		if !p.IsSource || strings.HasSuffix(f.Filename, ".dsc") {
+			return f.Checksums.SHA256
+		}
+	}
+
+	return ""
+}
+
+// NewLockfile creates lockfile pinning all the packages in the list
+//
+// Packages without SHA256 checksum can't be pinned, error is returned for such packages.
+func NewLockfile(snapshotName string, list *PackageList) (*Lockfile, error) {
+	lockfile := &Lockfile{
+		Format:   LockfileFormat,
+		Snapshot: snapshotName,
+		Packages: make([]LockfileEntry, 0, list.Len()),
+	}
+
+	err := list.ForEach(func(p *Package) error {
+		checksum := lockfileChecksum(p)
+		if checksum == "" {
+			return fmt.Errorf("package %s has no SHA256 checksum and can't be pinned", p)
+		}
+
+		lockfile.Packages = append(lockfile.Packages, LockfileEntry{
+			Name:         p.Name,
+			Version:      p.Version,
+			Architecture: p.Architecture,
+			SHA256:       checksum,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(lockfile.Packages, func(i, j int) bool {
+		a, b := &lockfile.Packages[i], &lockfile.Packages[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		return CompareVersions(a.Version, b.Version) < 0
+	})
+
+	return lockfile, nil
+}
+
+// ReadLockfile parses lockfile in JSON format
+func ReadLockfile(r io.Reader) (*Lockfile, error) {
+	var lockfile Lockfile
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&lockfile); err != nil {
+		return nil, fmt.Errorf("unable to parse lockfile: %s", err)
+	}
+
+	if err := lockfile.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &lockfile, nil
+}
+
+// Write writes lockfile in JSON format
+func (lockfile *Lockfile) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(lockfile)
+}
+
+// Validate checks lockfile for errors
+func (lockfile *Lockfile) Validate() error {
+	if lockfile.Format != LockfileFormat {
+		return fmt.Errorf("unsupported lockfile format %d", lockfile.Format)
+	}
+
+	for i := range lockfile.Packages {
+		entry := &lockfile.Packages[i]
+		if entry.Name == "" || entry.Version == "" || entry.Architecture == "" {
+			return fmt.Errorf("lockfile entry #%d: name, version and architecture are required", i+1)
+		}
+		if entry.SHA256 == "" {
+			return fmt.Errorf("lockfile entry #%d (%s): SHA256 is required to pin package contents", i+1, entry)
+		}
+	}
+
+	return nil
+}
+
+// Resolve finds all the packages pinned in the lockfile in sources (first matching source wins)
+//
+// All missing packages and packages with mismatched checksums are reported at once.
+func (lockfile *Lockfile) Resolve(sources []LockfileSource, progress aptly.Progress) (*PackageList, error) {
+	var missing, mismatched []string
+
+	result := NewPackageList()
+
+	if progress != nil {
+		progress.InitBar(int64(len(lockfile.Packages)), false, aptly.BarGeneralBuildPackageList)
+		defer progress.ShutdownBar()
+	}
+
+	for i := range lockfile.Packages {
+		entry := &lockfile.Packages[i]
+
+		if progress != nil {
+			progress.AddBar(1)
+		}
+
+		var (
+			found     *Package
+			checksums []string
+		)
+
+		for _, source := range sources {
+			candidates := source.Packages.SearchByKey(entry.Architecture, entry.Name, entry.Version)
+			if candidates.Len() == 0 {
+				continue
+			}
+
+			_ = candidates.ForEach(func(p *Package) error {
+				checksum := lockfileChecksum(p)
+				if checksum == entry.SHA256 {
+					found = p
+				} else {
+					checksums = append(checksums, fmt.Sprintf("%s in %s", checksum, source.Name))
+				}
+				return nil
+			})
+
+			if found != nil {
+				break
+			}
+		}
+
+		if found != nil {
+			if err := result.Add(found); err != nil {
+				return nil, err
+			}
+		} else if len(checksums) > 0 {
+			mismatched = append(mismatched, fmt.Sprintf("%s: expected SHA256 %s, found %s", entry, entry.SHA256, strings.Join(checksums, ", ")))
+		} else {
+			missing = append(missing, entry.String())
+		}
+	}
+
+	if len(missing) > 0 || len(mismatched) > 0 {
+		var problems []string
+		for _, p := range missing {
+			problems = append(problems, "missing package "+p)
+		}
+		for _, p := range mismatched {
+			problems = append(problems, "checksum mismatch for "+p)
+		}
+
+		return nil, fmt.Errorf("unable to resolve %d of %d lockfile entries:\n  %s",
+			len(problems), len(lockfile.Packages), strings.Join(problems, "\n  "))
+	}
+
+	return result, nil
+}
+
+// LockfileSourceNotFoundError is returned when source to resolve lockfile against doesn't exist
+type LockfileSourceNotFoundError struct {
+	Source string
+}
+
+func (e *LockfileSourceNotFoundError) Error() string {
+	return fmt.Sprintf("source %s not found", e.Source)
+}
+
+// lockfileSourceObject is snapshot, local repository or mirror found by source name
+type lockfileSourceObject struct {
+	kind     string
+	name     string
+	snapshot *Snapshot
+	repo     *LocalRepo
+	mirror   *RemoteRepo
+}
+
+func (object *lockfileSourceObject) key() []byte {
+	switch object.kind {
+	case "snapshot":
+		return object.snapshot.Key()
+	case "repo":
+		return object.repo.Key()
+	default:
+		return object.mirror.Key()
+	}
+}
+
+// findLockfileSource looks up source by name, optionally prefixed with kind
+func findLockfileSource(name string, collectionFactory *CollectionFactory) (*lockfileSourceObject, error) {
+	kind := ""
+	for _, k := range []string{"snapshot", "repo", "mirror"} {
+		if strings.HasPrefix(name, k+":") {
+			kind, name = k, strings.TrimPrefix(name, k+":")
+			break
+		}
+	}
+
+	var found []*lockfileSourceObject
+
+	if kind == "" || kind == "snapshot" {
+		if snapshot, err := collectionFactory.SnapshotCollection().ByName(name); err == nil {
+			found = append(found, &lockfileSourceObject{kind: "snapshot", name: name, snapshot: snapshot})
+		}
+	}
+
+	if kind == "" || kind == "repo" {
+		if repo, err := collectionFactory.LocalRepoCollection().ByName(name); err == nil {
+			found = append(found, &lockfileSourceObject{kind: "repo", name: name, repo: repo})
+		}
+	}
+
+	if kind == "" || kind == "mirror" {
+		if repo, err := collectionFactory.RemoteRepoCollection().ByName(name); err == nil {
+			found = append(found, &lockfileSourceObject{kind: "mirror", name: name, mirror: repo})
+		}
+	}
+
+	if len(found) == 0 {
+		if kind == "" {
+			return nil, &LockfileSourceNotFoundError{Source: name}
+		}
+		return nil, &LockfileSourceNotFoundError{Source: kind + ":" + name}
+	}
+
+	if len(found) > 1 {
+		kinds := make([]string, len(found))
+		for i := range found {
+			kinds[i] = found[i].kind
+		}
+		return nil, fmt.Errorf("source %s is ambiguous, use one of: %s:%s", name, strings.Join(kinds, ":"+name+", "), name)
+	}
+
+	return found[0], nil
+}
+
+// LockfileSourceKeys returns keys of snapshots, local repositories and mirrors lockfile
+// is resolved against, so that they could be locked while snapshot is created
+func LockfileSourceKeys(names []string, collectionFactory *CollectionFactory) ([]string, error) {
+	result := make([]string, 0, len(names))
+
+	for _, name := range names {
+		object, err := findLockfileSource(name, collectionFactory)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, string(object.key()))
+	}
+
+	return result, nil
+}
+
+// LoadLockfileSources loads package lists of sources to resolve lockfile against
+//
+// Each source is either snapshot, local repository or mirror name, optionally prefixed
+// with kind ("snapshot:", "repo:" or "mirror:"). Name without prefix should be unique
+// across snapshots, local repositories and mirrors.
+func LoadLockfileSources(names []string, collectionFactory *CollectionFactory, progress aptly.Progress) ([]LockfileSource, error) {
+	result := make([]LockfileSource, 0, len(names))
+
+	for _, name := range names {
+		object, err := findLockfileSource(name, collectionFactory)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			source  LockfileSource
+			refList *PackageRefList
+		)
+
+		switch object.kind {
+		case "snapshot":
+			if err = collectionFactory.SnapshotCollection().LoadComplete(object.snapshot); err != nil {
+				return nil, err
+			}
+			source.Snapshot, refList = object.snapshot, object.snapshot.RefList()
+		case "repo":
+			if err = collectionFactory.LocalRepoCollection().LoadComplete(object.repo); err != nil {
+				return nil, err
+			}
+			refList = object.repo.RefList()
+		case "mirror":
+			if err = object.mirror.CheckLock(); err != nil {
+				return nil, err
+			}
+			if err = collectionFactory.RemoteRepoCollection().LoadComplete(object.mirror); err != nil {
+				return nil, err
+			}
+			refList = object.mirror.RefList()
+		}
+
+		if refList == nil {
+			refList = NewPackageRefList()
+		}
+
+		packages, err := NewPackageListFromRefList(refList, collectionFactory.PackageCollection(), progress)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load packages of %s %s: %s", object.kind, object.name, err)
+		}
+
+		source.Name = object.kind + " " + object.name
+		source.Packages = packages
+
+		result = append(result, source)
+	}
+
+	return result, nil
+}
+
+// NewSnapshotFromLockfile creates snapshot with packages pinned in the lockfile
+func NewSnapshotFromLockfile(name string, lockfile *Lockfile, sources []LockfileSource, progress aptly.Progress) (*Snapshot, error) {
+	list, err := lockfile.Resolve(sources, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		snapshots []*Snapshot
+		names     []string
+	)
+	for _, source := range sources {
+		if source.Snapshot != nil {
+			snapshots = append(snapshots, source.Snapshot)
+		}
+		names = append(names, source.Name)
+	}
+
+	description := fmt.Sprintf("Created from lockfile with %d packages, resolved against %s", len(lockfile.Packages), strings.Join(names, ", "))
+	if lockfile.Snapshot != "" {
+		description = fmt.Sprintf("Created from lockfile of snapshot '%s', resolved against %s", lockfile.Snapshot, strings.Join(names, ", "))
+	}
+
+	return NewSnapshotFromPackageList(name, snapshots, list, description), nil
+}
@@ -0,0 +1,168 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type LockfileSuite struct {
+	db      database.Storage
+	factory *CollectionFactory
+}
+
+var _ = Suite(&LockfileSuite{})
+
+func (s *LockfileSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+}
+
+func (s *LockfileSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *LockfileSuite) addPackage(c *C, name, version, contents string) *Package {
+	p := NewPackageFromControlFile(Stanza{
+		"Package":      name,
+		"Version":      version,
+		"Architecture": "amd64",
+		"Filename":     fmt.Sprintf("pool/main/%s_%s_amd64.deb", name, version),
+		"Size":         "100",
+		"SHA256":       strings.Repeat(contents, 64),
+	})
+	c.Assert(s.factory.PackageCollection().Update(p), IsNil)
+	return p
+}
+
+func (s *LockfileSuite) addSnapshot(c *C, name string, packages ...*Package) *Snapshot {
+	list := NewPackageList()
+	for _, p := range packages {
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	snapshot := NewSnapshotFromPackageList(name, nil, list, "")
+	c.Assert(s.factory.SnapshotCollection().Add(snapshot), IsNil)
+	return snapshot
+}
+
+func (s *LockfileSuite) addRepo(c *C, name string, packages ...*Package) *LocalRepo {
+	list := NewPackageList()
+	for _, p := range packages {
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	repo := NewLocalRepo(name, "")
+	repo.UpdateRefList(NewPackageRefListFromPackageList(list))
+	c.Assert(s.factory.LocalRepoCollection().Add(repo), IsNil)
+	return repo
+}
+
+func (s *LockfileSuite) TestExportResolve(c *C) {
+	app := s.addPackage(c, "app", "1.0", "a")
+	lib := s.addPackage(c, "lib", "2.0", "b")
+	newLib := s.addPackage(c, "lib", "2.1", "c")
+
+	list := NewPackageList()
+	c.Assert(list.Add(lib), IsNil)
+	c.Assert(list.Add(app), IsNil)
+
+	lockfile, err := NewLockfile("release", list)
+	c.Assert(err, IsNil)
+	c.Check(lockfile, DeepEquals, &Lockfile{
+		Format:   LockfileFormat,
+		Snapshot: "release",
+		Packages: []LockfileEntry{
+			{Name: "app", Version: "1.0", Architecture: "amd64", SHA256: strings.Repeat("a", 64)},
+			{Name: "lib", Version: "2.0", Architecture: "amd64", SHA256: strings.Repeat("b", 64)},
+		},
+	})
+
+	var buf bytes.Buffer
+	c.Assert(lockfile.Write(&buf), IsNil)
+	parsed, err := ReadLockfile(&buf)
+	c.Assert(err, IsNil)
+	c.Check(parsed, DeepEquals, lockfile)
+
+	s.addSnapshot(c, "latest", app, newLib)
+	s.addRepo(c, "archive", lib, newLib)
+
+	sources, err := LoadLockfileSources([]string{"latest", "repo:archive"}, s.factory, nil)
+	c.Assert(err, IsNil)
+	c.Assert(sources, HasLen, 2)
+	c.Check(sources[0].Name, Equals, "snapshot latest")
+	c.Check(sources[0].Snapshot, NotNil)
+	c.Check(sources[1].Name, Equals, "repo archive")
+	c.Check(sources[1].Snapshot, IsNil)
+
+	snapshot, err := NewSnapshotFromLockfile("rebuilt", parsed, sources, nil)
+	c.Assert(err, IsNil)
+	c.Check(snapshot.RefList().Strings(), DeepEquals, NewPackageRefListFromPackageList(list).Strings())
+	c.Check(snapshot.Description, Equals, "Created from lockfile of snapshot 'release', resolved against snapshot latest, repo archive")
+	c.Check(snapshot.SourceIDs, DeepEquals, []string{sources[0].Snapshot.UUID})
+
+	// lib 2.0 is not in the snapshot
+	sources, err = LoadLockfileSources([]string{"snapshot:latest"}, s.factory, nil)
+	c.Assert(err, IsNil)
+	_, err = NewSnapshotFromLockfile("rebuilt", parsed, sources, nil)
+	c.Check(err, ErrorMatches, "unable to resolve 1 of 2 lockfile entries:\n  missing package lib_2.0_amd64")
+}
+
+func (s *LockfileSuite) TestChecksumMismatch(c *C) {
+	app := s.addPackage(c, "app", "1.0", "a")
+	s.addRepo(c, "rebuilt", s.addPackage(c, "app", "1.0", "d"))
+
+	list := NewPackageList()
+	c.Assert(list.Add(app), IsNil)
+	lockfile, err := NewLockfile("", list)
+	c.Assert(err, IsNil)
+	lockfile.Packages = append(lockfile.Packages, LockfileEntry{Name: "tool", Version: "3.0", Architecture: "amd64", SHA256: "x"})
+
+	sources, err := LoadLockfileSources([]string{"rebuilt"}, s.factory, nil)
+	c.Assert(err, IsNil)
+
+	_, err = lockfile.Resolve(sources, nil)
+	c.Check(err, ErrorMatches, "unable to resolve 2 of 2 lockfile entries:\n"+
+		"  missing package tool_3.0_amd64\n"+
+		"  checksum mismatch for app_1.0_amd64: expected SHA256 a+, found d+ in repo rebuilt")
+}
+
+func (s *LockfileSuite) TestSourcesAndValidation(c *C) {
+	s.addSnapshot(c, "stable")
+	s.addRepo(c, "stable")
+
+	_, err := LoadLockfileSources([]string{"stable"}, s.factory, nil)
+	c.Check(err, ErrorMatches, "source stable is ambiguous, use one of: snapshot:stable, repo:stable")
+
+	_, err = LoadLockfileSources([]string{"mirror:stable"}, s.factory, nil)
+	c.Check(err, ErrorMatches, "source mirror:stable not found")
+
+	_, err = LoadLockfileSources([]string{"unstable"}, s.factory, nil)
+	c.Check(err, ErrorMatches, "source unstable not found")
+	_, notFound := err.(*LockfileSourceNotFoundError)
+	c.Check(notFound, Equals, true)
+
+	keys, err := LockfileSourceKeys([]string{"snapshot:stable", "repo:stable"}, s.factory)
+	c.Assert(err, IsNil)
+	c.Check(keys, HasLen, 2)
+
+	_, err = LockfileSourceKeys([]string{"stable"}, s.factory)
+	c.Check(err, ErrorMatches, "source stable is ambiguous, .*")
+
+	_, err = ReadLockfile(strings.NewReader(`{"Format": 2, "Packages": []}`))
+	c.Check(err, ErrorMatches, "unsupported lockfile format 2")
+
+	_, err = ReadLockfile(strings.NewReader(`{"Format": 1, "Packages": [{"Name": "app"}]}`))
+	c.Check(err, ErrorMatches, "lockfile entry #1: name, version and architecture are required")
+
+	_, err = ReadLockfile(strings.NewReader(`{"Format": 1, "Packages": [{"Name": "app", "Version": "1.0", "Architecture": "amd64"}]}`))
+	c.Check(err, ErrorMatches, "lockfile entry #1 \\(app_1.0_amd64\\): SHA256 is required to pin package contents")
+
+	_, err = ReadLockfile(strings.NewReader(`[]`))
+	c.Check(err, ErrorMatches, "unable to parse lockfile: .*")
+}
@@ -0,0 +1,306 @@
+package deb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMergePriority is priority of source snapshots not listed in merge policy
+const DefaultMergePriority = 500
+
+// MergePin overrides priority of packages matching the pin
+type MergePin struct {
+	// Glob pattern for package name
+	Package string
+	// Glob pattern for package version (optional)
+	Version string `json:",omitempty"`
+	// Name of source snapshot (optional, pin applies to all sources if empty)
+	Source string `json:",omitempty"`
+	// Priority of matching packages
+	Priority int
+}
+
+// MergePolicy describes priority-based merge of snapshots, similar to apt pinning
+//
+// Each package gets priority of the source snapshot it comes from, unless it matches
+// one of the pins (first matching pin wins). For every name-architecture pair package
+// with highest priority is chosen, even if its version is lower than version of other
+// candidates. For equal priorities higher version wins, then package from later source.
+// Packages with negative priority are never chosen.
+type MergePolicy struct {
+	// Priorities of source snapshots by name
+	Priorities map[string]int `json:",omitempty"`
+	// Package pins
+	Pins []MergePin `json:",omitempty"`
+}
+
+// MergeCandidate is package considered during merge
+type MergeCandidate struct {
+	// Package full name
+	Package string
+	// Source snapshot the package was taken from
+	Source string
+	// Effective priority of the package
+	Priority int
+}
+
+// MergeConflict records resolution of conflict between several packages with the same name-architecture pair
+type MergeConflict struct {
+	Architecture string
+	Name         string
+	// Package chosen, empty if all the candidates have negative priority
+	Winner *MergeCandidate `json:",omitempty"`
+	// Other candidates, in order of preference
+	Losers []MergeCandidate
+}
+
+// String returns description of conflict resolution
+func (conflict *MergeConflict) String() string {
+	losers := make([]string, len(conflict.Losers))
+	for i := range conflict.Losers {
+		losers[i] = conflict.Losers[i].String()
+	}
+
+	if conflict.Winner == nil {
+		return fmt.Sprintf("%s [%s]: all excluded: %s", conflict.Name, conflict.Architecture, strings.Join(losers, ", "))
+	}
+
+	return fmt.Sprintf("%s [%s]: %s over %s", conflict.Name, conflict.Architecture, conflict.Winner, strings.Join(losers, ", "))
+}
+
+// String returns description of candidate
+func (candidate *MergeCandidate) String() string {
+	return fmt.Sprintf("%s from '%s' (priority %d)", candidate.Package, candidate.Source, candidate.Priority)
+}
+
+// ParseMergePin parses pin in comma-separated key=value form, e.g.:
+//
+//	package=libfoo*,source=internal,priority=1001
+func ParseMergePin(pin string) (MergePin, error) {
+	var (
+		result      MergePin
+		hasPriority bool
+	)
+
+	for _, part := range strings.Split(pin, ",") {
+		pos := strings.Index(part, "=")
+		if pos == -1 {
+			return result, fmt.Errorf("invalid pin %#v: expected key=value, got %#v", pin, part)
+		}
+
+		key, value := strings.TrimSpace(part[:pos]), strings.TrimSpace(part[pos+1:])
+		switch key {
+		case "package":
+			result.Package = value
+		case "version":
+			result.Version = value
+		case "source":
+			result.Source = value
+		case "priority":
+			priority, err := strconv.Atoi(value)
+			if err != nil {
+				return result, fmt.Errorf("invalid pin %#v: invalid priority %#v", pin, value)
+			}
+			result.Priority, hasPriority = priority, true
+		default:
+			return result, fmt.Errorf("invalid pin %#v: unknown key %#v", pin, key)
+		}
+	}
+
+	if !hasPriority {
+		return result, fmt.Errorf("invalid pin %#v: priority is required", pin)
+	}
+
+	return result, nil
+}
+
+// LoadMergePolicy reads merge policy from JSON file
+func LoadMergePolicy(filename string) (*MergePolicy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var policy MergePolicy
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", filename, err)
+	}
+
+	return &policy, nil
+}
+
+// Validate checks merge policy for errors, sources is list of names of snapshots being merged
+func (policy *MergePolicy) Validate(sources []string) error {
+	known := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		known[source] = true
+	}
+
+	for source := range policy.Priorities {
+		if !known[source] {
+			return fmt.Errorf("merge policy refers to snapshot %s which is not merged", source)
+		}
+	}
+
+	for _, pin := range policy.Pins {
+		if pin.Package == "" {
+			return fmt.Errorf("merge policy pin should have package pattern")
+		}
+
+		for _, pattern := range []string{pin.Package, pin.Version} {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return fmt.Errorf("merge policy pin %s: invalid pattern %#v: %s", pin.Package, pattern, err)
+			}
+		}
+
+		if pin.Source != "" && !known[pin.Source] {
+			return fmt.Errorf("merge policy pin %s refers to snapshot %s which is not merged", pin.Package, pin.Source)
+		}
+	}
+
+	return nil
+}
+
+// priority calculates priority of package coming from source
+func (policy *MergePolicy) priority(name, version, source string) int {
+	for _, pin := range policy.Pins {
+		if pin.Source != "" && pin.Source != source {
+			continue
+		}
+
+		if matched, _ := filepath.Match(pin.Package, name); !matched {
+			continue
+		}
+
+		if pin.Version != "" {
+			if matched, _ := filepath.Match(pin.Version, version); !matched {
+				continue
+			}
+		}
+
+		return pin.Priority
+	}
+
+	if priority, ok := policy.Priorities[source]; ok {
+		return priority
+	}
+
+	return DefaultMergePriority
+}
+
+type mergeGroupEntry struct {
+	ref      []byte
+	version  string
+	source   int
+	priority int
+}
+
+// MergeWithPolicy merges snapshots according to the policy, choosing one package
+// for each name-architecture pair. All conflicts between packages are reported.
+func MergeWithPolicy(sources []*Snapshot, policy *MergePolicy) (*PackageRefList, []MergeConflict, error) {
+	names := make([]string, len(sources))
+	for i := range sources {
+		names[i] = sources[i].Name
+	}
+
+	if err := policy.Validate(names); err != nil {
+		return nil, nil, err
+	}
+
+	groups := map[string][]mergeGroupEntry{}
+	var keys []string
+
+	for i, source := range sources {
+		for _, ref := range source.RefList().Refs {
+			parts := bytes.Split(ref, []byte(" "))
+			if len(parts) < 3 {
+				return nil, nil, fmt.Errorf("invalid package reference %s in snapshot %s", ref, source.Name)
+			}
+
+			key := string(parts[0][1:]) + " " + string(parts[1])
+			version := string(parts[2])
+			priority := policy.priority(string(parts[1]), version, source.Name)
+
+			group, exists := groups[key]
+			if !exists {
+				keys = append(keys, key)
+			}
+
+			found := false
+			for j := range group {
+				if bytes.Equal(group[j].ref, ref) {
+					// same package in several sources, keep the one with highest priority
+					if priority >= group[j].priority {
+						group[j].source, group[j].priority = i, priority
+					}
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				group = append(group, mergeGroupEntry{ref: ref, version: version, source: i, priority: priority})
+			}
+
+			groups[key] = group
+		}
+	}
+
+	sort.Strings(keys)
+
+	result := NewPackageRefList()
+	var conflicts []MergeConflict
+
+	for _, key := range keys {
+		group := groups[key]
+
+		sort.SliceStable(group, func(i, j int) bool {
+			if group[i].priority != group[j].priority {
+				return group[i].priority > group[j].priority
+			}
+			if cmp := CompareVersions(group[i].version, group[j].version); cmp != 0 {
+				return cmp > 0
+			}
+			return group[i].source > group[j].source
+		})
+
+		if group[0].priority >= 0 {
+			result.Refs = append(result.Refs, group[0].ref)
+		}
+
+		if len(group) == 1 && group[0].priority >= 0 {
+			continue
+		}
+
+		arch, name, _ := strings.Cut(key, " ")
+		conflict := MergeConflict{Architecture: arch, Name: name}
+
+		for _, entry := range group {
+			candidate := MergeCandidate{
+				Package:  fmt.Sprintf("%s_%s_%s", name, entry.version, arch),
+				Source:   names[entry.source],
+				Priority: entry.priority,
+			}
+
+			if conflict.Winner == nil && len(conflict.Losers) == 0 && entry.priority >= 0 {
+				conflict.Winner = &candidate
+			} else {
+				conflict.Losers = append(conflict.Losers, candidate)
+			}
+		}
+
+		conflicts = append(conflicts, conflict)
+	}
+
+	sort.Sort(result)
+
+	return result, conflicts, nil
+}
@@ -0,0 +1,128 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type MergePolicySuite struct {
+	ubuntu, internal, backports *Snapshot
+}
+
+var _ = Suite(&MergePolicySuite{})
+
+func mergePolicySnapshot(c *C, name string, packages ...string) *Snapshot {
+	list := NewPackageList()
+	for i := 0; i < len(packages); i += 2 {
+		c.Assert(list.Add(&Package{Name: packages[i], Version: packages[i+1], Architecture: "amd64", deps: &PackageDependencies{}}), IsNil)
+	}
+
+	return NewSnapshotFromRefList(name, nil, NewPackageRefListFromPackageList(list), "")
+}
+
+func (s *MergePolicySuite) SetUpTest(c *C) {
+	s.ubuntu = mergePolicySnapshot(c, "ubuntu", "nginx", "1.24", "libssl", "3.0.2", "curl", "8.5", "zlib", "1.3")
+	s.internal = mergePolicySnapshot(c, "internal", "nginx", "1.22-internal1", "libssl", "3.0.1-internal1", "tool", "1.0")
+	s.backports = mergePolicySnapshot(c, "backports", "curl", "8.9", "zlib", "1.3")
+}
+
+func (s *MergePolicySuite) TestParseMergePin(c *C) {
+	pin, err := ParseMergePin("package=lib*, source=internal,version=3.*,priority=1001")
+	c.Check(err, IsNil)
+	c.Check(pin, DeepEquals, MergePin{Package: "lib*", Source: "internal", Version: "3.*", Priority: 1001})
+
+	pin, err = ParseMergePin("package=curl,priority=-1")
+	c.Check(err, IsNil)
+	c.Check(pin, DeepEquals, MergePin{Package: "curl", Priority: -1})
+
+	_, err = ParseMergePin("package=curl")
+	c.Check(err, ErrorMatches, "invalid pin \"package=curl\": priority is required")
+	_, err = ParseMergePin("package=curl,priority=high")
+	c.Check(err, ErrorMatches, "invalid pin .*: invalid priority \"high\"")
+	_, err = ParseMergePin("package=curl,pin=1")
+	c.Check(err, ErrorMatches, "invalid pin .*: unknown key \"pin\"")
+	_, err = ParseMergePin("curl")
+	c.Check(err, ErrorMatches, "invalid pin .*: expected key=value, got \"curl\"")
+}
+
+func (s *MergePolicySuite) TestValidate(c *C) {
+	names := []string{"ubuntu", "internal"}
+
+	c.Check((&MergePolicy{Priorities: map[string]int{"internal": 1001}}).Validate(names), IsNil)
+	c.Check((&MergePolicy{Priorities: map[string]int{"debian": 1001}}).Validate(names), ErrorMatches,
+		"merge policy refers to snapshot debian which is not merged")
+	c.Check((&MergePolicy{Pins: []MergePin{{Priority: 1}}}).Validate(names), ErrorMatches,
+		"merge policy pin should have package pattern")
+	c.Check((&MergePolicy{Pins: []MergePin{{Package: "lib[", Priority: 1}}}).Validate(names), ErrorMatches,
+		"merge policy pin lib\\[: invalid pattern .*")
+	c.Check((&MergePolicy{Pins: []MergePin{{Package: "lib*", Source: "debian"}}}).Validate(names), ErrorMatches,
+		"merge policy pin lib\\* refers to snapshot debian which is not merged")
+}
+
+func (s *MergePolicySuite) TestDefaultPriorities(c *C) {
+	// same priority: highest version wins
+	result, conflicts, err := MergeWithPolicy([]*Snapshot{s.ubuntu, s.internal, s.backports}, &MergePolicy{})
+	c.Assert(err, IsNil)
+	c.Check(result.Strings(), DeepEquals, []string{
+		"Pamd64 curl 8.9", "Pamd64 libssl 3.0.2", "Pamd64 nginx 1.24", "Pamd64 tool 1.0", "Pamd64 zlib 1.3",
+	})
+
+	c.Assert(conflicts, HasLen, 3)
+	c.Check(conflicts[0].String(), Equals, "curl [amd64]: curl_8.9_amd64 from 'backports' (priority 500) over curl_8.5_amd64 from 'ubuntu' (priority 500)")
+}
+
+func (s *MergePolicySuite) TestPriorities(c *C) {
+	policy := &MergePolicy{
+		Priorities: map[string]int{"internal": 1001, "backports": 100},
+		Pins: []MergePin{
+			{Package: "nginx", Source: "ubuntu", Priority: 2000},
+			{Package: "curl", Version: "8.5*", Priority: -1},
+		},
+	}
+
+	result, conflicts, err := MergeWithPolicy([]*Snapshot{s.ubuntu, s.internal, s.backports}, policy)
+	c.Assert(err, IsNil)
+	c.Check(result.Strings(), DeepEquals, []string{
+		"Pamd64 curl 8.9", "Pamd64 libssl 3.0.1-internal1", "Pamd64 nginx 1.24", "Pamd64 tool 1.0", "Pamd64 zlib 1.3",
+	})
+
+	c.Assert(conflicts, HasLen, 3)
+	c.Check(conflicts[0], DeepEquals, MergeConflict{
+		Architecture: "amd64",
+		Name:         "curl",
+		Winner:       &MergeCandidate{Package: "curl_8.9_amd64", Source: "backports", Priority: 100},
+		Losers:       []MergeCandidate{{Package: "curl_8.5_amd64", Source: "ubuntu", Priority: -1}},
+	})
+	c.Check(conflicts[1].Winner, DeepEquals, &MergeCandidate{Package: "libssl_3.0.1-internal1_amd64", Source: "internal", Priority: 1001})
+	c.Check(conflicts[2].Winner, DeepEquals, &MergeCandidate{Package: "nginx_1.24_amd64", Source: "ubuntu", Priority: 2000})
+
+	// zlib is identical in ubuntu and backports, it's not a conflict
+	// exclude curl completely
+	policy.Pins = append(policy.Pins, MergePin{Package: "curl", Priority: -1})
+	result, conflicts, err = MergeWithPolicy([]*Snapshot{s.ubuntu, s.internal, s.backports}, policy)
+	c.Assert(err, IsNil)
+	c.Check(result.Len(), Equals, 4)
+	c.Check(conflicts[0].Winner, IsNil)
+	c.Check(conflicts[0].String(), Equals, "curl [amd64]: all excluded: curl_8.9_amd64 from 'backports' (priority -1), curl_8.5_amd64 from 'ubuntu' (priority -1)")
+
+	_, _, err = MergeWithPolicy([]*Snapshot{s.ubuntu}, policy)
+	c.Check(err, ErrorMatches, "merge policy refers to snapshot .* which is not merged")
+}
+
+func (s *MergePolicySuite) TestLoadMergePolicy(c *C) {
+	filename := filepath.Join(c.MkDir(), "policy.json")
+	c.Assert(os.WriteFile(filename, []byte(`{"Priorities": {"internal": 1001}, "Pins": [{"Package": "nginx", "Source": "ubuntu", "Priority": 990}]}`), 0644), IsNil)
+
+	policy, err := LoadMergePolicy(filename)
+	c.Assert(err, IsNil)
+	c.Check(policy, DeepEquals, &MergePolicy{
+		Priorities: map[string]int{"internal": 1001},
+		Pins:       []MergePin{{Package: "nginx", Source: "ubuntu", Priority: 990}},
+	})
+
+	c.Assert(os.WriteFile(filename, []byte(`{"Priority": {}}`), 0644), IsNil)
+	_, err = LoadMergePolicy(filename)
+	c.Check(err, ErrorMatches, "unable to parse .*: json: unknown field \"Priority\"")
+}
@@ -36,6 +36,10 @@ type Package struct {
 	IsUdeb bool
 	// Is this >= 0.6 package?
 	V06Plus bool
+	// Fingerprints of keys which signed .deb package (debsig), verified on import
+	SignedBy []string `codec:",omitempty"`
+	// .buildinfo files describing build of the package, stored in the package pool
+	Buildinfo PackageFiles `codec:",omitempty"`
 	// Offload fields
 	deps     *PackageDependencies
 	extra    *Stanza
@@ -238,6 +242,16 @@ func (p *Package) ExtendedStanza() Stanza {
 	stanza["FilesHash"] = fmt.Sprintf("%08x", p.FilesHash)
 	stanza["Key"] = string(p.Key(""))
 	stanza["ShortKey"] = string(p.ShortKey(""))
+	if len(p.SignedBy) > 0 {
+		stanza["SignedBy"] = strings.Join(p.SignedBy, " ")
+	}
+	if len(p.Buildinfo) > 0 {
+		buildinfo := make([]string, len(p.Buildinfo))
+		for i, f := range p.Buildinfo {
+			buildinfo[i] = f.Filename
+		}
+		stanza["Buildinfo"] = strings.Join(buildinfo, " ")
+	}
 
 	return stanza
 }
@@ -275,6 +289,8 @@ func (p *Package) GetField(name string) string {
 		return p.Version
 	case "$Architecture":
 		return p.Architecture
+	case "$SignedBy":
+		return strings.Join(p.SignedBy, " ")
 	case "$PackageType":
 		if p.IsSource {
 			return PackageTypeSource
@@ -710,6 +726,25 @@ func (p *Package) LinkFromPool(publishedStorage aptly.PublishedStorage, packageP
 	return nil
 }
 
+// LinkBuildinfoFromPool links attached .buildinfo files from pool to dist's buildinfo location
+func (p *Package) LinkBuildinfoFromPool(publishedStorage aptly.PublishedStorage, packagePool aptly.PackagePool,
+	prefix, relPath string, force bool) error {
+
+	for _, f := range p.Buildinfo {
+		sourcePoolPath, err := f.GetPoolPath(packagePool)
+		if err != nil {
+			return err
+		}
+
+		err = publishedStorage.LinkFromPool(prefix, relPath, f.Filename, packagePool, sourcePoolPath, f.Checksums, force)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // PoolDirectory returns directory in package pool of published repository for this package files
 func (p *Package) PoolDirectory() (string, error) {
 	source := p.Source
@@ -777,9 +812,11 @@ func (p *Package) VerifyFiles(packagePool aptly.PackagePool, checksumStorage apt
 }
 
 // FilepathList returns list of paths to files in package repository
+//
+// Attached .buildinfo files are included as well.
 func (p *Package) FilepathList(packagePool aptly.PackagePool) ([]string, error) {
 	var err error
-	result := make([]string, len(p.Files()))
+	result := make([]string, len(p.Files()), len(p.Files())+len(p.Buildinfo))
 
 	for i, f := range p.Files() {
 		result[i], err = f.GetPoolPath(packagePool)
@@ -788,5 +825,14 @@ func (p *Package) FilepathList(packagePool aptly.PackagePool) ([]string, error)
 		}
 	}
 
+	for _, f := range p.Buildinfo {
+		var poolPath string
+		poolPath, err = f.GetPoolPath(packagePool)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, poolPath)
+	}
+
 	return result, nil
 }
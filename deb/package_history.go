@@ -0,0 +1,171 @@
+package deb
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+)
+
+// Package provenance actions
+const (
+	PackageEventAdd          = "add"
+	PackageEventRemove       = "remove"
+	PackageEventCopy         = "copy"
+	PackageEventMove         = "move"
+	PackageEventInclude      = "include"
+	PackageEventMirrorImport = "mirror-import"
+)
+
+// Kinds of package event targets
+const (
+	PackageEventTargetRepo   = "repo"
+	PackageEventTargetMirror = "mirror"
+)
+
+// PackageEvent is a single entry in package provenance history: package
+// has been added to or removed from some repository
+type PackageEvent struct {
+	// Package key
+	Key string
+	// One of PackageEvent* actions
+	Action string
+	// Time event has been recorded
+	Timestamp time.Time
+	// Who has done that: CLI user or API client
+	Actor string
+	// Where package came from: file, .changes, repository or mirror
+	Source string `codec:",omitempty"`
+	// Repository or mirror package has been added to (or removed from)
+	Target string
+	// One of PackageEventTarget* kinds
+	TargetType string `codec:",omitempty"`
+}
+
+// String interface
+func (event *PackageEvent) String() string {
+	result := fmt.Sprintf("%s %s %s by %s", event.Timestamp.Format(time.RFC3339), event.Action, event.Target, event.Actor)
+	if event.Source != "" {
+		result += fmt.Sprintf(" (from %s)", event.Source)
+	}
+	return result
+}
+
+// packageHistoryPrefix returns DB prefix for all events of the package
+func packageHistoryPrefix(key []byte) []byte {
+	return append(append([]byte("H"), key...), 0)
+}
+
+// Encode does msgpack encoding of PackageEvent
+func (event *PackageEvent) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(event)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into PackageEvent
+func (event *PackageEvent) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(event)
+}
+
+// PackageHistoryCollection keeps provenance events of the packages
+//
+// Events are stored under package key followed by timestamp, so that
+// history of the package is listed in chronological order.
+type PackageHistoryCollection struct {
+	db database.Storage
+}
+
+// NewPackageHistoryCollection creates new PackageHistoryCollection
+func NewPackageHistoryCollection(db database.Storage) *PackageHistoryCollection {
+	return &PackageHistoryCollection{
+		db: db,
+	}
+}
+
+// Record saves events to DB, events without timestamp are stamped with current time
+func (collection *PackageHistoryCollection) Record(events ...*PackageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	batch := collection.db.CreateBatch()
+
+	for _, event := range events {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = now
+		}
+
+		key := append(packageHistoryPrefix([]byte(event.Key)),
+			[]byte(fmt.Sprintf("%016x%s", event.Timestamp.UnixNano(), uuid.NewString()))...)
+		if err := batch.Put(key, event.Encode()); err != nil {
+			return err
+		}
+	}
+
+	return batch.Write()
+}
+
+// RecordRefListChange records events for all the packages which differ in before and after
+//
+// Packages which appear in after get event's action, packages which are gone from
+// before are recorded as removed from the target.
+func (collection *PackageHistoryCollection) RecordRefListChange(before, after *PackageRefList, event PackageEvent) error {
+	if before == nil {
+		before = NewPackageRefList()
+	}
+	if after == nil {
+		after = NewPackageRefList()
+	}
+
+	var events []*PackageEvent
+
+	event.Timestamp = time.Now().UTC()
+
+	_ = after.Subtract(before).ForEach(func(key []byte) error {
+		added := event
+		added.Key = string(key)
+		events = append(events, &added)
+		return nil
+	})
+
+	_ = before.Subtract(after).ForEach(func(key []byte) error {
+		removed := event
+		removed.Key = string(key)
+		removed.Action = PackageEventRemove
+		removed.Source = ""
+		events = append(events, &removed)
+		return nil
+	})
+
+	return collection.Record(events...)
+}
+
+// ByKey returns history of the package, oldest events first
+func (collection *PackageHistoryCollection) ByKey(key []byte) ([]*PackageEvent, error) {
+	result := []*PackageEvent{}
+
+	err := collection.db.ProcessByPrefix(packageHistoryPrefix(key), func(_, blob []byte) error {
+		event := &PackageEvent{}
+		if err := event.Decode(blob); err != nil {
+			return err
+		}
+
+		result = append(result, event)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
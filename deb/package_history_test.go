@@ -0,0 +1,94 @@
+package deb
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type PackageHistorySuite struct {
+	db         database.Storage
+	collection *PackageHistoryCollection
+}
+
+var _ = Suite(&PackageHistorySuite{})
+
+func (s *PackageHistorySuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewPackageHistoryCollection(s.db)
+}
+
+func (s *PackageHistorySuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *PackageHistorySuite) TestRecordByKey(c *C) {
+	events, err := s.collection.ByKey([]byte("Pamd64 hardlink 0.2.1 abcd"))
+	c.Assert(err, IsNil)
+	c.Check(events, HasLen, 0)
+
+	t := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	c.Assert(s.collection.Record(
+		&PackageEvent{Key: "Pamd64 hardlink 0.2.1 abcd", Action: PackageEventRemove, Timestamp: t.Add(time.Hour), Actor: "cli:jane", Target: "stable"},
+		&PackageEvent{Key: "Pamd64 hardlink 0.2.1 abcd", Action: PackageEventAdd, Timestamp: t, Actor: "cli:jane", Source: "hardlink_0.2.1_amd64.deb", Target: "stable"},
+		&PackageEvent{Key: "Pamd64 hardlink 0.2.1 abcde", Action: PackageEventAdd, Actor: "api:127.0.0.1", Target: "testing"},
+	), IsNil)
+
+	events, err = s.collection.ByKey([]byte("Pamd64 hardlink 0.2.1 abcd"))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 2)
+	c.Check(events[0].Action, Equals, PackageEventAdd)
+	c.Check(events[0].Timestamp.Equal(t), Equals, true)
+	c.Check(events[0].String(), Equals, "2024-03-01T10:00:00Z add stable by cli:jane (from hardlink_0.2.1_amd64.deb)")
+	c.Check(events[1].Action, Equals, PackageEventRemove)
+	c.Check(events[1].String(), Equals, "2024-03-01T11:00:00Z remove stable by cli:jane")
+
+	events, err = s.collection.ByKey([]byte("Pamd64 hardlink 0.2.1 abcde"))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Timestamp.IsZero(), Equals, false)
+}
+
+func (s *PackageHistorySuite) TestRecordRefListChange(c *C) {
+	before := &PackageRefList{Refs: [][]byte{[]byte("Pall a 1.0"), []byte("Pall b 1.0")}}
+	after := &PackageRefList{Refs: [][]byte{[]byte("Pall b 1.0"), []byte("Pall c 1.0")}}
+
+	c.Assert(s.collection.RecordRefListChange(before, after, PackageEvent{
+		Action: PackageEventMove, Actor: "cli:jane", Source: "unstable", Target: "stable"}), IsNil)
+
+	events, err := s.collection.ByKey([]byte("Pall a 1.0"))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(*events[0], DeepEquals, PackageEvent{Key: "Pall a 1.0", Action: PackageEventRemove, Timestamp: events[0].Timestamp,
+		Actor: "cli:jane", Target: "stable"})
+
+	events, err = s.collection.ByKey([]byte("Pall b 1.0"))
+	c.Assert(err, IsNil)
+	c.Check(events, HasLen, 0)
+
+	events, err = s.collection.ByKey([]byte("Pall c 1.0"))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 1)
+	c.Check(*events[0], DeepEquals, PackageEvent{Key: "Pall c 1.0", Action: PackageEventMove, Timestamp: events[0].Timestamp,
+		Actor: "cli:jane", Source: "unstable", Target: "stable"})
+
+	// nil reflists are treated as empty
+	c.Assert(s.collection.RecordRefListChange(nil, after, PackageEvent{Action: PackageEventCopy, Target: "testing"}), IsNil)
+	c.Assert(s.collection.RecordRefListChange(after, nil, PackageEvent{Action: PackageEventCopy, Target: "testing"}), IsNil)
+
+	events, err = s.collection.ByKey([]byte("Pall c 1.0"))
+	c.Assert(err, IsNil)
+	c.Assert(events, HasLen, 3)
+	c.Check(events[1].Action, Equals, PackageEventCopy)
+	c.Check(events[2].Action, Equals, PackageEventRemove)
+}
+
+func (s *PackageHistorySuite) TestByKeyDecodeError(c *C) {
+	c.Assert(s.db.Put(append(packageHistoryPrefix([]byte("Pall a 1.0")), []byte("0000")...), []byte{0xc1}), IsNil)
+
+	_, err := s.collection.ByKey([]byte("Pall a 1.0"))
+	c.Check(err, NotNil)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"path/filepath"
 	"sort"
@@ -93,6 +94,12 @@ type PublishedRepo struct {
 
 	// Revision
 	Revision *PublishedRepoRevision
+
+	// Protected published repository can't be dropped or switched to other sources
+	Protected bool `codec:",omitempty"`
+
+	// Publish .buildinfo files attached to packages under buildinfo/
+	PublishBuildinfo bool `codec:",omitempty"`
 }
 
 type PublishedRepoRevision struct {
@@ -172,22 +179,38 @@ func (p *PublishedRepo) DropRevision() *PublishedRepoRevision {
 	return revision
 }
 
+// currentSources returns map of current sources: component name -> snapshot name/local repo name
+func (p *PublishedRepo) currentSources() map[string]string {
+	sources := make(map[string]string, len(p.Sources))
+	for _, component := range p.Components() {
+		item := p.sourceItems[component]
+		if item.snapshot != nil {
+			sources[component] = item.snapshot.Name
+		} else if item.localRepo != nil {
+			sources[component] = item.localRepo.Name
+		} else {
+			panic("no snapshot/localRepo")
+		}
+	}
+
+	return sources
+}
+
+// CheckRevisionAllowed returns error if pending revision changes sources
+// of protected published repository
+func (p *PublishedRepo) CheckRevisionAllowed() error {
+	if p.Revision != nil && !maps.Equal(p.Revision.Sources, p.currentSources()) {
+		return p.CheckUnprotected()
+	}
+
+	return nil
+}
+
 func (p *PublishedRepo) ObtainRevision() *PublishedRepoRevision {
 	revision := p.Revision
 	if revision == nil {
-		sources := make(map[string]string, len(p.Sources))
-		for _, component := range p.Components() {
-			item := p.sourceItems[component]
-			if item.snapshot != nil {
-				sources[component] = item.snapshot.Name
-			} else if item.localRepo != nil {
-				sources[component] = item.localRepo.Name
-			} else {
-				panic("no snapshot/localRepo")
-			}
-		}
 		revision = &PublishedRepoRevision{
-			Sources: sources,
+			Sources: p.currentSources(),
 		}
 		p.Revision = revision
 	}
@@ -201,6 +224,10 @@ func (p *PublishedRepo) Update(collectionFactory *CollectionFactory, _ aptly.Pro
 		RemovedSources: map[string]string{},
 	}
 
+	if err := p.CheckRevisionAllowed(); err != nil {
+		return result, fmt.Errorf("unable to update: %s", err)
+	}
+
 	revision := p.DropRevision()
 	if revision == nil {
 		if p.SourceKind == SourceLocalRepo {
@@ -539,9 +566,20 @@ func (p *PublishedRepo) MarshalJSON() ([]byte, error) {
 		"AcquireByHash":        p.AcquireByHash,
 		"SignedBy":             p.SignedBy,
 		"MultiDist":            p.MultiDist,
+		"Protected":            p.Protected,
+		"PublishBuildinfo":     p.PublishBuildinfo,
 	})
 }
 
+// CheckUnprotected returns error if published repository is protected
+func (p *PublishedRepo) CheckUnprotected() error {
+	if p.Protected {
+		return fmt.Errorf("published repository %s/%s is protected, unprotect it first", p.StoragePrefix(), p.Distribution)
+	}
+
+	return nil
+}
+
 // String returns human-readable representation of PublishedRepo
 func (p *PublishedRepo) String() string {
 	var sources = []string{}
@@ -940,7 +978,7 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 				if pkg.MatchesArchitecture(arch) {
 					hadUdebs = hadUdebs || pkg.IsUdeb
 
-					var relPath string
+					var relPath, buildinfoPath string
 					if !pkg.IsInstaller {
 						poolDir, err2 := pkg.PoolDirectory()
 						if err2 != nil {
@@ -948,8 +986,10 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 						}
 						if p.MultiDist {
 							relPath = filepath.Join("pool", p.Distribution, component, poolDir)
+							buildinfoPath = filepath.Join("buildinfo", p.Distribution, component, poolDir)
 						} else {
 							relPath = filepath.Join("pool", component, poolDir)
+							buildinfoPath = filepath.Join("buildinfo", component, poolDir)
 						}
 
 					} else {
@@ -964,6 +1004,13 @@ func (p *PublishedRepo) Publish(packagePool aptly.PackagePool, publishedStorageP
 					if err != nil {
 						return err
 					}
+
+					if p.PublishBuildinfo && buildinfoPath != "" {
+						err = pkg.LinkBuildinfoFromPool(publishedStorage, packagePool, p.Prefix, buildinfoPath, forceOverwrite)
+						if err != nil {
+							return err
+						}
+					}
 					break
 				}
 			}
@@ -1273,6 +1320,11 @@ func (p *PublishedRepo) RemoveFiles(publishedStorageProvider aptly.PublishedStor
 			return err
 		}
 
+		err = publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "buildinfo"), progress)
+		if err != nil {
+			return err
+		}
+
 		return publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "pool"), progress)
 	}
 
@@ -1288,6 +1340,11 @@ func (p *PublishedRepo) RemoveFiles(publishedStorageProvider aptly.PublishedStor
 		if err != nil {
 			return err
 		}
+
+		err = publishedStorage.RemoveDirs(filepath.Join(p.Prefix, "buildinfo", component), progress)
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -1540,7 +1597,16 @@ func (collection *PublishedRepoCollection) Len() int {
 
 func (collection *PublishedRepoCollection) listReferencedFilesByComponent(prefix string, components []string,
 	collectionFactory *CollectionFactory, progress aptly.Progress) (map[string][]string, error) {
+	referencedFiles, _, err := collection.listReferencedPoolAndBuildinfoFiles(prefix, components, collectionFactory, progress)
+	return referencedFiles, err
+}
+
+// listReferencedPoolAndBuildinfoFiles lists files referenced by published repositories under
+// prefix by component: package files in pool/ and .buildinfo files in buildinfo/
+func (collection *PublishedRepoCollection) listReferencedPoolAndBuildinfoFiles(prefix string, components []string,
+	collectionFactory *CollectionFactory, progress aptly.Progress) (map[string][]string, map[string][]string, error) {
 	referencedFiles := map[string][]string{}
+	referencedBuildinfoFiles := map[string][]string{}
 	processedComponentRefs := map[string]*PackageRefList{}
 
 	for _, r := range collection.list {
@@ -1561,13 +1627,20 @@ func (collection *PublishedRepoCollection) listReferencedFilesByComponent(prefix
 			}
 
 			if err := collection.LoadComplete(r, collectionFactory); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 
 			for _, component := range components {
 				if utils.StrSliceHasItem(repoComponents, component) {
+					// packages seen in repos not publishing .buildinfo should be processed again
+					// for repos which publish them
+					processedKey := component
+					if r.PublishBuildinfo {
+						processedKey += "/buildinfo"
+					}
+
 					unseenRefs := r.RefList(component)
-					processedRefs := processedComponentRefs[component]
+					processedRefs := processedComponentRefs[processedKey]
 					if processedRefs != nil {
 						unseenRefs = unseenRefs.Subtract(processedRefs)
 					} else {
@@ -1577,11 +1650,11 @@ func (collection *PublishedRepoCollection) listReferencedFilesByComponent(prefix
 					if unseenRefs.Len() == 0 {
 						continue
 					}
-					processedComponentRefs[component] = processedRefs.Merge(unseenRefs, false, true)
+					processedComponentRefs[processedKey] = processedRefs.Merge(unseenRefs, false, true)
 
 					packageList, err := NewPackageListFromRefList(unseenRefs, collectionFactory.PackageCollection(), progress)
 					if err != nil {
-						return nil, err
+						return nil, nil, err
 					}
 
 					_ = packageList.ForEach(func(p *Package) error {
@@ -1594,6 +1667,10 @@ func (collection *PublishedRepoCollection) listReferencedFilesByComponent(prefix
 							referencedFiles[component] = append(referencedFiles[component], filepath.Join(poolDir, f.Filename))
 						}
 
+						for _, f := range publishedBuildinfo(r, p) {
+							referencedBuildinfoFiles[component] = append(referencedBuildinfoFiles[component], filepath.Join(poolDir, f.Filename))
+						}
+
 						return nil
 					})
 				}
@@ -1601,7 +1678,7 @@ func (collection *PublishedRepoCollection) listReferencedFilesByComponent(prefix
 		}
 	}
 
-	return referencedFiles, nil
+	return referencedFiles, referencedBuildinfoFiles, nil
 }
 
 // CleanupAfterMultiDistToggle cleans up stale pool files left behind when the
@@ -1695,12 +1772,20 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(published
 		if err != nil {
 			return err
 		}
+
+		err = publishedStorage.RemoveDirs(filepath.Join(prefix, "buildinfo", distribution, component), nil)
+		if err != nil {
+			return err
+		}
 	}
 
 	referencedFiles := map[string][]string{}
+	referencedBuildinfoFiles := map[string][]string{}
+	var buildinfoRootPath string
 
 	if published.MultiDist {
 		rootPath = filepath.Join(prefix, "pool", distribution)
+		buildinfoRootPath = filepath.Join(prefix, "buildinfo", distribution)
 
 		// Get all referenced files by component for determining orphaned pool files.
 		for _, component := range publishedComponents {
@@ -1719,11 +1804,16 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(published
 					referencedFiles[component] = append(referencedFiles[component], filepath.Join(poolDir, file.Filename))
 				}
 
+				for _, file := range publishedBuildinfo(published, p) {
+					referencedBuildinfoFiles[component] = append(referencedBuildinfoFiles[component], filepath.Join(poolDir, file.Filename))
+				}
+
 				return nil
 			})
 		}
 	} else {
 		rootPath = filepath.Join(prefix, "pool")
+		buildinfoRootPath = filepath.Join(prefix, "buildinfo")
 
 		// In case of a shared component pool directory, we must check, if a component is no longer referenced by any other
 		// published repository within the same prefix.
@@ -1744,11 +1834,16 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(published
 				if err != nil {
 					return err
 				}
+
+				err = publishedStorage.RemoveDirs(filepath.Join(buildinfoRootPath, component), progress)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
 		// Get all referenced files by component for determining orphaned pool files.
-		referencedFiles, err = collection.listReferencedFilesByComponent(prefix, publishedComponents, collectionFactory, progress)
+		referencedFiles, referencedBuildinfoFiles, err = collection.listReferencedPoolAndBuildinfoFiles(prefix, publishedComponents, collectionFactory, progress)
 		if err != nil {
 			return err
 		}
@@ -1758,27 +1853,51 @@ func (collection *PublishedRepoCollection) CleanupPrefixComponentFiles(published
 		if progress != nil {
 			progress.Printf("Cleaning up component '%s'...\n", component)
 		}
-		sort.Strings(referencedFiles[component])
 
-		path := filepath.Join(rootPath, component)
-		existingFiles, err := publishedStorage.Filelist(path)
+		err = cleanupOrphanedFiles(publishedStorage, filepath.Join(rootPath, component), referencedFiles[component])
 		if err != nil {
 			return err
 		}
 
-		sort.Strings(existingFiles)
+		err = cleanupOrphanedFiles(publishedStorage, filepath.Join(buildinfoRootPath, component), referencedBuildinfoFiles[component])
+		if err != nil {
+			return err
+		}
+	}
 
-		orphanedFiles := utils.StrSlicesSubstract(existingFiles, referencedFiles[component])
+	return err
+}
 
-		for _, file := range orphanedFiles {
-			err = publishedStorage.Remove(filepath.Join(path, file))
-			if err != nil {
-				return err
-			}
+// publishedBuildinfo returns .buildinfo files of the package published by the repository
+func publishedBuildinfo(published *PublishedRepo, p *Package) PackageFiles {
+	if !published.PublishBuildinfo || p.IsInstaller {
+		return nil
+	}
+
+	return p.Buildinfo
+}
+
+// cleanupOrphanedFiles removes files under path in published storage which are not referenced
+func cleanupOrphanedFiles(publishedStorage aptly.PublishedStorage, path string, referencedFiles []string) error {
+	sort.Strings(referencedFiles)
+
+	existingFiles, err := publishedStorage.Filelist(path)
+	if err != nil {
+		return err
+	}
+
+	sort.Strings(existingFiles)
+
+	orphanedFiles := utils.StrSlicesSubstract(existingFiles, referencedFiles)
+
+	for _, file := range orphanedFiles {
+		err = publishedStorage.Remove(filepath.Join(path, file))
+		if err != nil {
+			return err
 		}
 	}
 
-	return err
+	return nil
 }
 
 // Remove removes published repository, cleaning up directories, files
@@ -1799,6 +1918,11 @@ func (collection *PublishedRepoCollection) Remove(publishedStorageProvider aptly
 		return err
 	}
 
+	err = repo.CheckUnprotected()
+	if err != nil {
+		return err
+	}
+
 	removePrefix := true
 	removePoolComponents := repo.Components()
 	cleanComponents := []string{}
@@ -379,6 +379,24 @@ func (s *PublishedRepoSuite) TestUpdate(c *C) {
 	c.Assert(result.RemovedComponents(), DeepEquals, []string{})
 }
 
+func (s *PublishedRepoSuite) TestUpdateProtected(c *C) {
+	s.repo2.Protected = true
+
+	// re-publishing local repository is allowed
+	s.repo2.ObtainRevision()
+	result, err := s.repo2.Update(s.factory, nil)
+	c.Assert(err, IsNil)
+	c.Check(result.UpdatedSources, DeepEquals, map[string]string{"main": "local1"})
+
+	s.repo2.ObtainRevision().Sources["test"] = "local1"
+	c.Check(s.repo2.CheckRevisionAllowed(), ErrorMatches, "published repository ppa/maverick is protected, unprotect it first")
+	_, err = s.repo2.Update(s.factory, nil)
+	c.Check(err, ErrorMatches, "unable to update: published repository ppa/maverick is protected, unprotect it first")
+
+	s.repo2.Protected = false
+	c.Check(s.repo2.CheckRevisionAllowed(), IsNil)
+}
+
 func (s *PublishedRepoSuite) TestPublish(c *C) {
 	err := s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, "")
 	c.Assert(err, IsNil)
@@ -426,6 +444,40 @@ func (s *PublishedRepoSuite) TestPublish(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *PublishedRepoSuite) TestPublishBuildinfo(c *C) {
+	buildinfoPath := filepath.Join(c.MkDir(), "alien-arena_7.40-2_i386.buildinfo")
+	c.Assert(os.WriteFile(buildinfoPath, []byte("Source: alien-arena\n"), 0644), IsNil)
+
+	checksums, err := utils.ChecksumsForFile(buildinfoPath)
+	c.Assert(err, IsNil)
+	buildinfo := PackageFile{Filename: "alien-arena_7.40-2_i386.buildinfo", Checksums: checksums}
+	buildinfo.PoolPath, err = s.packagePool.Import(buildinfoPath, buildinfo.Filename, &buildinfo.Checksums, false, s.cs)
+	c.Assert(err, IsNil)
+
+	s.p1.AddBuildinfo(buildinfo)
+	c.Assert(s.packageCollection.Update(s.p1), IsNil)
+
+	published := filepath.Join(s.publishedStorage.PublicPath(), "ppa/buildinfo/main/a/alien-arena/alien-arena_7.40-2_i386.buildinfo")
+
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, ""), IsNil)
+	c.Check(published, Not(PathExists))
+
+	s.repo.PublishBuildinfo = true
+	c.Assert(s.repo.Publish(s.packagePool, s.provider, s.factory, &NullSigner{}, nil, false, ""), IsNil)
+	c.Check(published, PathExists)
+
+	collection := s.factory.PublishedRepoCollection()
+	c.Assert(collection.Add(s.repo), IsNil)
+
+	c.Assert(collection.CleanupPrefixComponentFiles(s.provider, s.repo, []string{"main"}, s.factory, nil), IsNil)
+	c.Check(published, PathExists)
+
+	s.repo.PublishBuildinfo = false
+	c.Assert(collection.CleanupPrefixComponentFiles(s.provider, s.repo, []string{"main"}, s.factory, nil), IsNil)
+	c.Check(published, Not(PathExists))
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/pool/main/a/alien-arena/alien-arena-common_7.40-2_i386.deb"), PathExists)
+}
+
 func (s *PublishedRepoSuite) TestPublishAppStream(c *C) {
 	// Components + icons
 	content1 := []byte("DEP-11 test content for Components-amd64.yml.gz")
@@ -1066,6 +1118,18 @@ func (s *PublishedRepoRemoveSuite) TestRemoveRepo1and2(c *C) {
 	c.Check(filepath.Join(s.publishedStorage2.PublicPath(), "ppa/pool/contrib"), PathExists)
 }
 
+func (s *PublishedRepoRemoveSuite) TestRemoveProtected(c *C) {
+	s.repo1.Protected = true
+	c.Assert(s.collection.Update(s.repo1), IsNil)
+
+	err := s.collection.Remove(s.provider, "", "ppa", "anaconda", s.factory, nil, false, false)
+	c.Check(err, ErrorMatches, "published repository ppa/anaconda is protected, unprotect it first")
+
+	_, err = NewPublishedRepoCollection(s.db).ByStoragePrefixDistribution("", "ppa", "anaconda")
+	c.Check(err, IsNil)
+	c.Check(filepath.Join(s.publishedStorage.PublicPath(), "ppa/dists/anaconda"), PathExists)
+}
+
 func (s *PublishedRepoRemoveSuite) TestRemoveRepo1and2SkipCleanup(c *C) {
 	err := s.collection.Remove(s.provider, "", "ppa", "anaconda", s.factory, nil, false, true)
 	c.Check(err, IsNil)
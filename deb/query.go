@@ -298,3 +298,26 @@ func (q *MatchAllQuery) Query(list PackageCatalog) (result *PackageList) {
 func (q *MatchAllQuery) String() string {
 	return ""
 }
+
+// SourcePackageQuery builds query matching source package and all the binary packages
+// (including -dbgsym packages and udebs) built from it
+//
+// If version is empty, all versions of the source package are matched.
+func SourcePackageQuery(source, version string) PackageQuery {
+	var sourceQuery PackageQuery = &AndQuery{
+		L: &FieldQuery{Field: "$PackageType", Relation: VersionEqual, Value: PackageTypeSource},
+		R: &FieldQuery{Field: "Name", Relation: VersionEqual, Value: source},
+	}
+
+	var binaryQuery PackageQuery = &AndQuery{
+		L: &NotQuery{Q: &FieldQuery{Field: "$PackageType", Relation: VersionEqual, Value: PackageTypeSource}},
+		R: &FieldQuery{Field: "$Source", Relation: VersionEqual, Value: source},
+	}
+
+	if version != "" {
+		sourceQuery = &AndQuery{L: sourceQuery, R: &FieldQuery{Field: "Version", Relation: VersionEqual, Value: version}}
+		binaryQuery = &AndQuery{L: binaryQuery, R: &FieldQuery{Field: "$SourceVersion", Relation: VersionEqual, Value: version}}
+	}
+
+	return &OrQuery{L: sourceQuery, R: binaryQuery}
+}
@@ -21,3 +21,38 @@ func (s *QuerySuite) TestVersionCompare(c *C) {
 	c.Check(q.Matches(&p100), Equals, false)
 	c.Check(q.Matches(&p1), Equals, true)
 }
+
+func (s *QuerySuite) TestSourcePackageQuery(c *C) {
+	packages := []*Package{
+		{Name: "foo", Version: "1.0", Architecture: "source", SourceArchitecture: "any", IsSource: true},
+		{Name: "foo", Version: "1.0", Architecture: "amd64"},
+		{Name: "foo-dbgsym", Version: "1.0", Architecture: "amd64", Source: "foo"},
+		{Name: "foo-udeb", Version: "1.0", Architecture: "amd64", Source: "foo", IsUdeb: true},
+		{Name: "libfoo", Version: "1.0+b1", Architecture: "amd64", Source: "foo (1.0)"},
+		{Name: "foo", Version: "2.0", Architecture: "source", SourceArchitecture: "any", IsSource: true},
+		{Name: "foo", Version: "2.0", Architecture: "amd64"},
+		{Name: "bar", Version: "1.0", Architecture: "source", SourceArchitecture: "any", IsSource: true},
+		{Name: "foo", Version: "1.0", Architecture: "i386", Source: "bar"},
+		{Name: "bar-foo", Version: "1.0", Architecture: "amd64", Source: "bar"},
+	}
+
+	matches := func(q PackageQuery) (result []string) {
+		for _, p := range packages {
+			if q.Matches(p) {
+				result = append(result, p.String())
+			}
+		}
+		return
+	}
+
+	c.Check(matches(SourcePackageQuery("foo", "1.0")), DeepEquals, []string{
+		"foo_1.0_source", "foo_1.0_amd64", "foo-dbgsym_1.0_amd64", "foo-udeb_1.0_amd64", "libfoo_1.0+b1_amd64"})
+	c.Check(matches(SourcePackageQuery("foo", "")), DeepEquals, []string{
+		"foo_1.0_source", "foo_1.0_amd64", "foo-dbgsym_1.0_amd64", "foo-udeb_1.0_amd64", "libfoo_1.0+b1_amd64",
+		"foo_2.0_source", "foo_2.0_amd64"})
+	c.Check(matches(SourcePackageQuery("bar", "1.0")), DeepEquals, []string{"bar_1.0_source", "foo_1.0_i386", "bar-foo_1.0_amd64"})
+	c.Check(matches(SourcePackageQuery("baz", "")), HasLen, 0)
+
+	c.Check(SourcePackageQuery("foo", "1.0").String(), Equals,
+		"((($PackageType (= source)), (Name (= foo))), (Version (= 1.0))) | (((!($PackageType (= source))), ($Source (= foo))), ($SourceVersion (= 1.0)))")
+}
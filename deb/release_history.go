@@ -0,0 +1,192 @@
+package deb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// ReleaseHistoryEntry describes upstream Release file synced by the mirror
+type ReleaseHistoryEntry struct {
+	// Date and Valid-Until fields of the Release file
+	Date       string
+	ValidUntil string `codec:",omitempty" json:",omitempty"`
+	// Name of the file as fetched from upstream: InRelease or Release
+	Filename string
+	// Checksums of the file
+	Checksums utils.ChecksumInfo
+	// Path to the file in the package pool
+	PoolPath string
+	// Path to the detached signature (Release.gpg) in the package pool, if any
+	SignaturePoolPath string `codec:",omitempty" json:",omitempty"`
+	// First and last time this Release file was synced
+	FirstSynced time.Time
+	LastSynced  time.Time
+}
+
+// fetchedRelease is raw Release file as downloaded by last Fetch
+type fetchedRelease struct {
+	filename     string
+	data         []byte
+	signature    []byte
+	previousDate string
+}
+
+// releaseDateFormats are layouts used in Date and Valid-Until fields of Release files
+var releaseDateFormats = []string{
+	time.RFC1123,
+	time.RFC1123Z,
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+}
+
+// parseReleaseDate parses Date/Valid-Until field of the Release file
+func parseReleaseDate(value string) (time.Time, error) {
+	// some archives pad hours with spaces: "Thu, 05 Dec 2013  8:14:32 UTC"
+	value = strings.Join(strings.Fields(value), " ")
+
+	for _, layout := range releaseDateFormats {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date %#v", value)
+}
+
+// LatestRelease returns most recent entry of the Release history, or nil
+func (repo *RemoteRepo) LatestRelease() *ReleaseHistoryEntry {
+	if len(repo.ReleaseHistory) == 0 {
+		return nil
+	}
+
+	return &repo.ReleaseHistory[len(repo.ReleaseHistory)-1]
+}
+
+// CheckReleaseFreshness verifies Release file downloaded by Fetch against
+// previously synced one: Date should not go backwards and Valid-Until
+// (if present) should not be in the past
+func (repo *RemoteRepo) CheckReleaseFreshness(now time.Time) error {
+	if repo.fetchedRelease == nil {
+		return nil
+	}
+
+	previousDate := repo.fetchedRelease.previousDate
+	if latest := repo.LatestRelease(); latest != nil {
+		previousDate = latest.Date
+	}
+
+	if repo.Meta["Date"] != "" && previousDate != "" {
+		date, err := parseReleaseDate(repo.Meta["Date"])
+		if err != nil {
+			return err
+		}
+
+		previous, err := parseReleaseDate(previousDate)
+		if err == nil && date.Before(previous) {
+			return fmt.Errorf("release file date %s is older than previously synced %s, possible rollback attack, use -force-release to override",
+				repo.Meta["Date"], previousDate)
+		}
+	}
+
+	if repo.Meta["Valid-Until"] != "" {
+		validUntil, err := parseReleaseDate(repo.Meta["Valid-Until"])
+		if err != nil {
+			return err
+		}
+
+		if now.After(validUntil) {
+			return fmt.Errorf("release file has expired on %s, use -force-release to override", repo.Meta["Valid-Until"])
+		}
+	}
+
+	return nil
+}
+
+// RecordRelease stores Release file downloaded by Fetch in the package pool
+// and appends it to the Release history
+//
+// If the file is the same as the latest one in the history, only
+// last sync time is updated. History should be loaded with
+// RemoteRepoCollection.LoadReleaseHistory before.
+func (repo *RemoteRepo) RecordRelease(packagePool aptly.PackagePool, checksumStorage aptly.ChecksumStorage, now time.Time) error {
+	if repo.fetchedRelease == nil {
+		return nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "aptly-release-*")
+	if err != nil {
+		return fmt.Errorf("unable to create temp dir for Release file: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	importFile := func(filename string, data []byte) (string, utils.ChecksumInfo, error) {
+		tempPath := filepath.Join(tempDir, filename)
+
+		err := os.WriteFile(tempPath, data, 0644)
+		if err != nil {
+			return "", utils.ChecksumInfo{}, err
+		}
+
+		checksums, err := utils.ChecksumsForFile(tempPath)
+		if err != nil {
+			return "", utils.ChecksumInfo{}, err
+		}
+
+		poolPath, err := packagePool.Import(tempPath, filename, &checksums, true, checksumStorage)
+		return poolPath, checksums, err
+	}
+
+	poolPath, checksums, err := importFile(repo.fetchedRelease.filename, repo.fetchedRelease.data)
+	if err != nil {
+		return fmt.Errorf("unable to import %s file: %s", repo.fetchedRelease.filename, err)
+	}
+
+	if latest := repo.LatestRelease(); latest != nil && latest.Checksums.SHA256 == checksums.SHA256 {
+		latest.LastSynced = now
+		repo.fetchedRelease = nil
+		return nil
+	}
+
+	entry := ReleaseHistoryEntry{
+		Date:        repo.Meta["Date"],
+		ValidUntil:  repo.Meta["Valid-Until"],
+		Filename:    repo.fetchedRelease.filename,
+		Checksums:   checksums,
+		PoolPath:    poolPath,
+		FirstSynced: now,
+		LastSynced:  now,
+	}
+
+	if repo.fetchedRelease.signature != nil {
+		entry.SignaturePoolPath, _, err = importFile("Release.gpg", repo.fetchedRelease.signature)
+		if err != nil {
+			return fmt.Errorf("unable to import Release.gpg file: %s", err)
+		}
+	}
+
+	repo.ReleaseHistory = append(repo.ReleaseHistory, entry)
+	repo.fetchedRelease = nil
+
+	return nil
+}
+
+// ReleaseHistoryPoolPaths returns pool paths of all the files in the Release history
+func (repo *RemoteRepo) ReleaseHistoryPoolPaths() []string {
+	result := make([]string, 0, len(repo.ReleaseHistory))
+
+	for _, entry := range repo.ReleaseHistory {
+		result = append(result, entry.PoolPath)
+		if entry.SignaturePoolPath != "" {
+			result = append(result, entry.SignaturePoolPath)
+		}
+	}
+
+	return result
+}
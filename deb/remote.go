@@ -5,6 +5,7 @@ import (
 	gocontext "context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/url"
 	"os"
@@ -40,6 +41,10 @@ type RemoteRepo struct {
 	Name string
 	// Root of Debian archive, URL
 	ArchiveRoot string
+	// Equivalent archive roots tried in order when download from ArchiveRoot fails
+	FallbackArchiveRoots []string `codec:",omitempty" json:",omitempty"`
+	// Download results for ArchiveRoot and fallback archive roots
+	ArchiveRootHealth []ArchiveRootHealth `codec:",omitempty" json:",omitempty"`
 	// Distribution name, e.g. squeeze
 	Distribution string
 	// List of components to fetch, if empty, then fetch all components
@@ -60,6 +65,8 @@ type RemoteRepo struct {
 	WorkerPID int
 	// FilterWithDeps to include dependencies from filter query
 	FilterWithDeps bool
+	// KeepVersions limits number of newest versions kept per package/architecture (0 keeps all)
+	KeepVersions int `codec:",omitempty"`
 	// SkipComponentCheck skips component list verification
 	SkipComponentCheck bool
 	// SkipArchitectureCheck skips architecture list verification
@@ -74,6 +81,10 @@ type RemoteRepo struct {
 	DownloadAppStream bool
 	// AppStream files: relative path (e.g. "main/dep11/Components-amd64.yml.gz") → pool path
 	AppStreamFiles map[string]string `codec:"AppStreamFiles" json:"-"`
+	// History of synced upstream Release files, oldest first (stored separately, see LoadReleaseHistory)
+	ReleaseHistory []ReleaseHistoryEntry `codec:"-" json:"-"`
+	// User-defined labels
+	Labels map[string]string `codec:",omitempty" json:",omitempty"`
 	// Packages for json output
 	Packages []string `codec:"-" json:",omitempty"`
 	// "Snapshot" of current list of packages
@@ -82,6 +93,8 @@ type RemoteRepo struct {
 	archiveRootURL *url.URL
 	// Current list of packages (filled while updating mirror)
 	packageList *PackageList
+	// Raw Release file downloaded by Fetch
+	fetchedRelease *fetchedRelease
 }
 
 // NewRemoteRepo creates new instance of Debian remote repository with specified params
@@ -129,6 +142,7 @@ func NewRemoteRepo(name string, archiveRoot string, distribution string, compone
 func (repo *RemoteRepo) SetArchiveRoot(archiveRoot string) {
 	repo.ArchiveRoot = archiveRoot
 	_ = repo.prepare()
+	_ = repo.SetFallbackArchiveRoots(repo.FallbackArchiveRoots)
 }
 
 func (repo *RemoteRepo) prepare() error {
@@ -362,6 +376,7 @@ func (repo *RemoteRepo) PackageURL(filename string) *url.URL {
 func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreSignatures bool) error {
 	var (
 		release, inrelease, releasesig *os.File
+		raw                            fetchedRelease
 		err                            error
 	)
 
@@ -377,12 +392,23 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreS
 			if verifier == nil {
 				return fmt.Errorf("no verifier specified")
 			}
+			raw.filename = "InRelease"
+			raw.data, err = readAndRewind(inrelease)
+			if err != nil {
+				return err
+			}
 			release, err = verifier.ExtractClearsigned(inrelease)
 			if err != nil {
 				return err
 			}
 			goto ok
 		}
+
+		raw.filename = "Release"
+		raw.data, err = readAndRewind(release)
+		if err != nil {
+			return err
+		}
 	} else {
 		// 1. try InRelease file
 		inrelease, err = http.DownloadTemp(gocontext.TODO(), d, repo.ReleaseURL("InRelease").String())
@@ -398,6 +424,12 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreS
 
 		_, _ = inrelease.Seek(0, 0)
 
+		raw.filename = "InRelease"
+		raw.data, err = readAndRewind(inrelease)
+		if err != nil {
+			goto splitsignature
+		}
+
 		release, err = verifier.ExtractClearsigned(inrelease)
 		if err != nil {
 			goto splitsignature
@@ -417,7 +449,7 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreS
 			return err
 		}
 
-		err = verifier.VerifyDetachedSignature(releasesig, release, true)
+		_, err = verifier.VerifyDetachedSignature(releasesig, release, true)
 		if err != nil {
 			return err
 		}
@@ -426,6 +458,21 @@ func (repo *RemoteRepo) Fetch(d aptly.Downloader, verifier pgp.Verifier, ignoreS
 		if err != nil {
 			return err
 		}
+
+		_, err = releasesig.Seek(0, 0)
+		if err != nil {
+			return err
+		}
+
+		raw.filename = "Release"
+		raw.data, err = readAndRewind(release)
+		if err != nil {
+			return err
+		}
+		raw.signature, err = readAndRewind(releasesig)
+		if err != nil {
+			return err
+		}
 	}
 ok:
 
@@ -529,11 +576,24 @@ ok:
 		return err
 	}
 
+	raw.previousDate = repo.Meta["Date"]
+	repo.fetchedRelease = &raw
 	repo.Meta = stanza
 
 	return nil
 }
 
+// readAndRewind reads whole file and seeks back to the beginning
+func readAndRewind(f *os.File) ([]byte, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Seek(0, 0)
+	return data, err
+}
+
 // DownloadPackageIndexes downloads & parses package index files
 func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.Downloader, verifier pgp.Verifier, _ *CollectionFactory, ignoreSignatures bool, ignoreChecksums bool) error {
 	if repo.packageList != nil {
@@ -600,7 +660,7 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 						return err
 					}
 
-					err = verifier.VerifyDetachedSignature(filesig, packagesFile, false)
+					_, err = verifier.VerifyDetachedSignature(filesig, packagesFile, false)
 					if err != nil {
 						return err
 					}
@@ -677,12 +737,21 @@ func (repo *RemoteRepo) DownloadPackageIndexes(progress aptly.Progress, d aptly.
 
 // ApplyFilter applies filtering to already built PackageList
 func (repo *RemoteRepo) ApplyFilter(dependencyOptions int, filterQuery PackageQuery, progress aptly.Progress) (oldLen, newLen int, err error) {
+	oldLen = repo.packageList.Len()
+
+	// drop old versions first, so that dependencies are resolved against versions being kept
+	if repo.KeepVersions > 0 {
+		repo.packageList, err = repo.packageList.FilterNewest(repo.KeepVersions)
+		if err != nil {
+			return
+		}
+	}
+
 	repo.packageList.PrepareIndex()
 
 	emptyList := NewPackageList()
 	emptyList.PrepareIndex()
 
-	oldLen = repo.packageList.Len()
 	repo.packageList, err = repo.packageList.Filter(FilterOptions{
 		Queries:           []PackageQuery{filterQuery},
 		WithDependencies:  repo.FilterWithDeps,
@@ -699,6 +768,9 @@ func (repo *RemoteRepo) ApplyFilter(dependencyOptions int, filterQuery PackageQu
 }
 
 // BuildDownloadQueue builds queue, discards current PackageList
+//
+// If latestOnly is set, only latest version of each package is kept, otherwise
+// KeepVersions newest versions are kept (if set).
 func (repo *RemoteRepo) BuildDownloadQueue(packagePool aptly.PackagePool, packageCollection *PackageCollection, checksumStorage aptly.ChecksumStorage, skipExistingPackages, latestOnly bool) (queue []PackageDownloadTask, downloadSize int64, err error) {
 	if repo.packageList == nil {
 		err = fmt.Errorf("package list is empty, please (re)download package indexes")
@@ -710,6 +782,11 @@ func (repo *RemoteRepo) BuildDownloadQueue(packagePool aptly.PackagePool, packag
 		if err != nil {
 			return
 		}
+	} else if repo.KeepVersions > 0 {
+		repo.packageList, err = repo.packageList.FilterNewest(repo.KeepVersions)
+		if err != nil {
+			return
+		}
 	}
 
 	queue = make([]PackageDownloadTask, 0, repo.packageList.Len())
@@ -877,6 +954,21 @@ func (repo *RemoteRepo) RefKey() []byte {
 	return []byte("E" + repo.UUID)
 }
 
+// ReleaseHistoryKey is a unique id for the history of synced Release files
+func (repo *RemoteRepo) ReleaseHistoryKey() []byte {
+	return []byte("Z" + repo.UUID)
+}
+
+// encodeReleaseHistory does msgpack encoding of Release history
+func (repo *RemoteRepo) encodeReleaseHistory() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(repo.ReleaseHistory)
+
+	return buf.Bytes()
+}
+
 // RemoteRepoCollection does listing, updating/adding/deleting of RemoteRepos
 type RemoteRepoCollection struct {
 	db    database.Storage
@@ -951,9 +1043,30 @@ func (collection *RemoteRepoCollection) Update(repo *RemoteRepo) error {
 	if repo.packageRefs != nil {
 		_ = batch.Put(repo.RefKey(), repo.packageRefs.Encode())
 	}
+	if repo.ReleaseHistory != nil {
+		_ = batch.Put(repo.ReleaseHistoryKey(), repo.encodeReleaseHistory())
+	}
 	return batch.Write()
 }
 
+// LoadReleaseHistory loads history of synced Release files for remote repo
+//
+// History is loaded only on demand, as it grows with every upstream Release.
+func (collection *RemoteRepoCollection) LoadReleaseHistory(repo *RemoteRepo) error {
+	repo.ReleaseHistory = []ReleaseHistoryEntry{}
+
+	encoded, err := collection.db.Get(repo.ReleaseHistoryKey())
+	if err == database.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	decoder := codec.NewDecoderBytes(encoded, &codec.MsgpackHandle{})
+	return decoder.Decode(&repo.ReleaseHistory)
+}
+
 // LoadComplete loads additional information for remote repo
 func (collection *RemoteRepoCollection) LoadComplete(repo *RemoteRepo) error {
 	encoded, err := collection.db.Get(repo.RefKey())
@@ -1037,5 +1150,6 @@ func (collection *RemoteRepoCollection) Drop(repo *RemoteRepo) error {
 	batch := collection.db.CreateBatch()
 	_ = batch.Delete(repo.Key())
 	_ = batch.Delete(repo.RefKey())
+	_ = batch.Delete(repo.ReleaseHistoryKey())
 	return batch.Write()
 }
@@ -6,6 +6,8 @@ import (
 	"io"
 	"os"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/aptly-dev/aptly/aptly"
 	"github.com/aptly-dev/aptly/console"
@@ -29,8 +31,8 @@ func (n *NullVerifier) InitKeyring(_ bool) error {
 func (n *NullVerifier) AddKeyring(keyring string) {
 }
 
-func (n *NullVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, hint bool) error {
-	return nil
+func (n *NullVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, hint bool) (*pgp.KeyInfo, error) {
+	return nil, nil
 }
 
 func (n *NullVerifier) VerifyClearsigned(clearsigned io.Reader, hint bool) (*pgp.KeyInfo, error) {
@@ -276,6 +278,91 @@ func (s *RemoteRepoSuite) TestFetchNullVerifier2(c *C) {
 	c.Assert(downloader.Empty(), Equals, true)
 }
 
+func (s *RemoteRepoSuite) TestFetchReleaseHistory(c *C) {
+	synced := time.Date(2013, 12, 6, 10, 0, 0, 0, time.UTC)
+
+	err := s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(s.repo.CheckReleaseFreshness(synced), IsNil)
+	c.Assert(s.repo.RecordRelease(s.packagePool, s.cs, synced), IsNil)
+
+	c.Assert(s.repo.ReleaseHistory, HasLen, 1)
+	entry := s.repo.LatestRelease()
+	c.Check(entry.Filename, Equals, "Release")
+	c.Check(entry.Date, Equals, "Thu, 05 Dec 2013  8:14:32 UTC")
+	c.Check(entry.SignaturePoolPath, Equals, "")
+	c.Check(entry.FirstSynced, Equals, synced)
+
+	file, err := s.packagePool.Open(entry.PoolPath)
+	c.Assert(err, IsNil)
+	contents, _ := io.ReadAll(file)
+	_ = file.Close()
+	c.Check(string(contents), Equals, exampleReleaseFile)
+	c.Check(s.repo.ReleaseHistoryPoolPaths(), DeepEquals, []string{entry.PoolPath})
+
+	// same Release file again, only last sync time is updated
+	downloader := http.NewFakeDownloader().ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	c.Assert(s.repo.Fetch(downloader, nil, true), IsNil)
+	c.Assert(s.repo.CheckReleaseFreshness(synced.Add(time.Hour)), IsNil)
+	c.Assert(s.repo.RecordRelease(s.packagePool, s.cs, synced.Add(time.Hour)), IsNil)
+	c.Assert(s.repo.ReleaseHistory, HasLen, 1)
+	c.Check(s.repo.LatestRelease().FirstSynced, Equals, synced)
+	c.Check(s.repo.LatestRelease().LastSynced, Equals, synced.Add(time.Hour))
+
+	// Release file going backwards in time
+	older := strings.Replace(exampleReleaseFile, "Thu, 05 Dec 2013  8:14:32 UTC", "Wed, 04 Dec 2013 08:14:32 UTC", 1)
+	downloader = http.NewFakeDownloader().ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", older)
+	c.Assert(s.repo.Fetch(downloader, nil, true), IsNil)
+	c.Check(s.repo.CheckReleaseFreshness(synced), ErrorMatches, "release file date .* is older than previously synced .*rollback.*")
+
+	// newer Release file with Valid-Until
+	newer := strings.Replace(exampleReleaseFile, "Thu, 05 Dec 2013  8:14:32 UTC", "Fri, 06 Dec 2013 08:14:32 UTC\nValid-Until: Fri, 13 Dec 2013 08:14:32 UTC", 1)
+	downloader = http.NewFakeDownloader().ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", newer)
+	c.Assert(s.repo.Fetch(downloader, nil, true), IsNil)
+	c.Check(s.repo.CheckReleaseFreshness(time.Date(2013, 12, 20, 0, 0, 0, 0, time.UTC)), ErrorMatches, "release file has expired on .*")
+	c.Assert(s.repo.CheckReleaseFreshness(synced), IsNil)
+	c.Assert(s.repo.RecordRelease(s.packagePool, s.cs, synced), IsNil)
+	c.Assert(s.repo.ReleaseHistory, HasLen, 2)
+	c.Check(s.repo.LatestRelease().ValidUntil, Equals, "Fri, 13 Dec 2013 08:14:32 UTC")
+
+	// history is not part of the mirror record
+	repo := &RemoteRepo{}
+	c.Assert(repo.Decode(s.repo.Encode()), IsNil)
+	c.Check(repo.ReleaseHistory, IsNil)
+}
+
+func (s *RemoteRepoSuite) TestFetchReleaseHistorySignature(c *C) {
+	downloader := http.NewFakeDownloader()
+	downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/InRelease", &http.Error{Code: 404})
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release.gpg", "GPG")
+
+	c.Assert(s.repo.Fetch(downloader, &NullVerifier{}, false), IsNil)
+	c.Assert(s.repo.RecordRelease(s.packagePool, s.cs, time.Now()), IsNil)
+	c.Assert(s.repo.ReleaseHistory, HasLen, 1)
+	c.Check(s.repo.ReleaseHistory[0].SignaturePoolPath, Not(Equals), "")
+	c.Check(s.repo.ReleaseHistoryPoolPaths(), HasLen, 2)
+
+	file, err := s.packagePool.Open(s.repo.ReleaseHistory[0].SignaturePoolPath)
+	c.Assert(err, IsNil)
+	contents, _ := io.ReadAll(file)
+	_ = file.Close()
+	c.Check(string(contents), Equals, "GPG")
+}
+
+func (s *RemoteRepoSuite) TestParseReleaseDate(c *C) {
+	t, err := parseReleaseDate("Thu, 05 Dec 2013  8:14:32 UTC")
+	c.Assert(err, IsNil)
+	c.Check(t.Equal(time.Date(2013, 12, 5, 8, 14, 32, 0, time.UTC)), Equals, true)
+
+	t, err = parseReleaseDate("Sat, 5 Oct 2024 09:00:00 +0200")
+	c.Assert(err, IsNil)
+	c.Check(t.Equal(time.Date(2024, 10, 5, 7, 0, 0, 0, time.UTC)), Equals, true)
+
+	_, err = parseReleaseDate("yesterday")
+	c.Check(err, ErrorMatches, "unable to parse date .*")
+}
+
 func (s *RemoteRepoSuite) TestFetchWrongArchitecture(c *C) {
 	s.repo, _ = NewRemoteRepo("s", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{"xyz"}, false, false, false, false)
 	err := s.repo.Fetch(s.downloader, nil, true)
@@ -452,6 +539,42 @@ func (s *RemoteRepoSuite) TestBuildDownloadQueueLatestOnly(c *C) {
 	c.Check(size, Equals, int64(187518))
 }
 
+func (s *RemoteRepoSuite) TestKeepVersions(c *C) {
+	s.repo.Architectures = []string{"i386"}
+	s.repo.KeepVersions = 2
+
+	err := s.repo.Fetch(s.downloader, nil, true)
+	c.Assert(err, IsNil)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	err = s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, true, false)
+	c.Assert(err, IsNil)
+
+	for _, version := range []string{"1:3.4.0-1", "1:3.5.0-1"} {
+		stanza := packageStanza.Copy()
+		stanza["Package"] = "amanda-client"
+		stanza["Version"] = version
+		stanza["Filename"] = "pool/main/a/amanda/amanda-client_" + strings.TrimPrefix(version, "1:") + "_i386.deb"
+		_ = s.repo.packageList.Add(NewPackageFromControlFile(stanza))
+	}
+
+	oldLen, newLen, err := s.repo.ApplyFilter(0, &FieldQuery{Field: "Name", Relation: VersionEqual, Value: "amanda-client"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(oldLen, Equals, 3)
+	c.Check(newLen, Equals, 2)
+
+	queue, _, err := s.repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(queue, HasLen, 2)
+
+	urls := []string{queue[0].File.DownloadURL(), queue[1].File.DownloadURL()}
+	sort.Strings(urls)
+	c.Check(urls, DeepEquals, []string{"pool/main/a/amanda/amanda-client_3.4.0-1_i386.deb", "pool/main/a/amanda/amanda-client_3.5.0-1_i386.deb"})
+}
+
 func (s *RemoteRepoSuite) TestDownloadWithSources(c *C) {
 	s.repo.Architectures = []string{"i386"}
 	s.repo.DownloadSources = true
@@ -867,6 +990,36 @@ func (s *RemoteRepoCollectionSuite) TestUpdateLoadComplete(c *C) {
 	c.Assert(r.NumPackages(), Equals, 3)
 }
 
+func (s *RemoteRepoCollectionSuite) TestUpdateLoadReleaseHistory(c *C) {
+	repo, _ := NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false, false)
+	c.Assert(s.collection.Update(repo), IsNil)
+
+	r, err := NewRemoteRepoCollection(s.db).ByName("yandex")
+	c.Assert(err, IsNil)
+	c.Assert(s.collection.LoadReleaseHistory(r), IsNil)
+	c.Check(r.ReleaseHistory, HasLen, 0)
+	c.Check(r.LatestRelease(), IsNil)
+
+	repo.ReleaseHistory = []ReleaseHistoryEntry{{Date: "Thu, 05 Dec 2013 08:14:32 UTC", Filename: "InRelease", PoolPath: "ab/cd/InRelease"}}
+	c.Assert(s.collection.Update(repo), IsNil)
+
+	r, err = NewRemoteRepoCollection(s.db).ByName("yandex")
+	c.Assert(err, IsNil)
+	c.Check(r.ReleaseHistory, IsNil)
+
+	// updating mirror without loaded history keeps it
+	c.Assert(s.collection.Update(r), IsNil)
+	c.Assert(s.collection.LoadComplete(r), IsNil)
+	c.Check(r.ReleaseHistory, IsNil)
+	c.Assert(s.collection.LoadReleaseHistory(r), IsNil)
+	c.Assert(r.ReleaseHistory, HasLen, 1)
+	c.Check(r.LatestRelease().PoolPath, Equals, "ab/cd/InRelease")
+
+	c.Assert(s.collection.Drop(r), IsNil)
+	_, err = s.db.Get(r.ReleaseHistoryKey())
+	c.Check(err, Equals, database.ErrNotFound)
+}
+
 func (s *RemoteRepoCollectionSuite) TestForEachAndLen(c *C) {
 	repo, _ := NewRemoteRepo("yandex", "http://mirror.yandex.ru/debian/", "squeeze", []string{"main"}, []string{}, false, false, false, false)
 	_ = s.collection.Add(repo)
@@ -0,0 +1,571 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// Supported SBOM formats
+const (
+	SBOMFormatSPDX      = "spdx-json"
+	SBOMFormatCycloneDX = "cyclonedx-json"
+)
+
+// SBOMPackage is package listed in software bill of materials
+type SBOMPackage struct {
+	Name          string
+	Version       string
+	Architecture  string
+	Source        string
+	SourceVersion string
+	Maintainer    string `json:",omitempty"`
+	Homepage      string `json:",omitempty"`
+	// Package files with checksums
+	Files PackageFiles
+	// License short names as listed in machine-readable debian/copyright
+	Licenses []string `json:",omitempty"`
+}
+
+// SBOM is software bill of materials for snapshot or published repository
+type SBOM struct {
+	// Name of the snapshot or published repository
+	Name string
+	// Vendor is used as namespace in package URLs, e.g. debian or ubuntu
+	Vendor   string
+	Created  time.Time
+	Serial   string
+	Packages []SBOMPackage
+}
+
+// NewSBOM builds SBOM for the package list
+//
+// If packagePool is not nil, license information is extracted from
+// usr/share/doc/<package>/copyright in .deb files when available.
+func NewSBOM(name, vendor string, list *PackageList, packagePool aptly.PackagePool, progress aptly.Progress) *SBOM {
+	sbom := &SBOM{
+		Name:     name,
+		Vendor:   vendor,
+		Created:  time.Now().UTC(),
+		Serial:   uuid.NewString(),
+		Packages: make([]SBOMPackage, 0, list.Len()),
+	}
+
+	if progress != nil {
+		progress.InitBar(int64(list.Len()), false, aptly.BarGeneralBuildPackageList)
+		defer progress.ShutdownBar()
+	}
+
+	_ = list.ForEach(func(p *Package) error {
+		if progress != nil {
+			progress.AddBar(1)
+		}
+
+		entry := SBOMPackage{
+			Name:          p.Name,
+			Version:       p.Version,
+			Architecture:  p.Architecture,
+			Source:        p.Name,
+			SourceVersion: p.Version,
+			Maintainer:    p.Extra()["Maintainer"],
+			Homepage:      p.Extra()["Homepage"],
+			Files:         p.Files(),
+		}
+
+		if !p.IsSource {
+			entry.Source, entry.SourceVersion = p.GetField("$Source"), p.GetField("$SourceVersion")
+
+			if packagePool != nil {
+				// license information is optional, packages might be missing from the pool (e.g. mirrors without download)
+				entry.Licenses, _ = licensesForPackage(p, packagePool)
+			}
+		}
+
+		sbom.Packages = append(sbom.Packages, entry)
+		return nil
+	})
+
+	sort.Slice(sbom.Packages, func(i, j int) bool {
+		a, b := &sbom.Packages[i], &sbom.Packages[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		return CompareVersions(a.Version, b.Version) < 0
+	})
+
+	return sbom
+}
+
+// ParseCopyrightLicenses returns license short names from machine-readable debian/copyright
+//
+// For copyright files not in machine-readable format nil is returned.
+func ParseCopyrightLicenses(r io.Reader) ([]string, error) {
+	var (
+		result []string
+		first  = true
+	)
+
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MaxFieldSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if first {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, "Format:") {
+				return nil, nil
+			}
+			first = false
+			continue
+		}
+
+		if !strings.HasPrefix(line, "License:") {
+			continue
+		}
+
+		license := strings.TrimSpace(strings.TrimPrefix(line, "License:"))
+		if license != "" && !seen[license] {
+			seen[license] = true
+			result = append(result, license)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(result)
+	return result, nil
+}
+
+// GetLicensesFromDeb extracts licenses from usr/share/doc/<package>/copyright in .deb package
+func GetLicensesFromDeb(file io.Reader, packageFile string, packageName string) ([]string, error) {
+	name, data, err := readDataFileFromDeb(file, packageFile, []string{"usr/share/doc/" + packageName + "/copyright"})
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("copyright not found in %s", packageFile)
+	}
+
+	result, err := ParseCopyrightLicenses(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s from %s", name, packageFile)
+	}
+
+	return result, nil
+}
+
+func licensesForPackage(p *Package, packagePool aptly.PackagePool) ([]string, error) {
+	files := p.Files()
+	if len(files) == 0 {
+		return nil, fmt.Errorf("package has no files")
+	}
+
+	poolPath, err := files[0].GetPoolPath(packagePool)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := packagePool.Open(poolPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return GetLicensesFromDeb(file, poolPath, p.Name)
+}
+
+// spdxLicenseIDs maps Debian license short names to SPDX identifiers
+var spdxLicenseIDs = map[string]string{
+	"apache-2.0":   "Apache-2.0",
+	"artistic":     "Artistic-1.0-Perl",
+	"artistic-2.0": "Artistic-2.0",
+	"bsd-2-clause": "BSD-2-Clause",
+	"bsd-3-clause": "BSD-3-Clause",
+	"bsd-4-clause": "BSD-4-Clause",
+	"cc0-1.0":      "CC0-1.0",
+	"expat":        "MIT",
+	"mit":          "MIT",
+	"gfdl-1.2":     "GFDL-1.2-only",
+	"gfdl-1.2+":    "GFDL-1.2-or-later",
+	"gfdl-1.3":     "GFDL-1.3-only",
+	"gfdl-1.3+":    "GFDL-1.3-or-later",
+	"gpl-1":        "GPL-1.0-only",
+	"gpl-1+":       "GPL-1.0-or-later",
+	"gpl-2":        "GPL-2.0-only",
+	"gpl-2+":       "GPL-2.0-or-later",
+	"gpl-3":        "GPL-3.0-only",
+	"gpl-3+":       "GPL-3.0-or-later",
+	"isc":          "ISC",
+	"lgpl-2":       "LGPL-2.0-only",
+	"lgpl-2+":      "LGPL-2.0-or-later",
+	"lgpl-2.1":     "LGPL-2.1-only",
+	"lgpl-2.1+":    "LGPL-2.1-or-later",
+	"lgpl-3":       "LGPL-3.0-only",
+	"lgpl-3+":      "LGPL-3.0-or-later",
+	"mpl-1.1":      "MPL-1.1",
+	"mpl-2.0":      "MPL-2.0",
+	"openssl":      "OpenSSL",
+	"python-2.0":   "Python-2.0",
+	"zlib":         "Zlib",
+	"zope-2.1":     "ZPL-2.1",
+}
+
+var (
+	licenseOperatorRegexp = regexp.MustCompile(`(?i)\s+(or|and)\s+`)
+	licenseRefRegexp      = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+)
+
+// spdxLicenseID converts single Debian license short name to SPDX identifier,
+// unknown licenses are converted to LicenseRef- identifiers
+func spdxLicenseID(license string) (string, bool) {
+	license = strings.TrimSuffix(strings.TrimSpace(license), ",")
+	if id, ok := spdxLicenseIDs[strings.ToLower(license)]; ok {
+		return id, true
+	}
+
+	return "LicenseRef-" + strings.Trim(licenseRefRegexp.ReplaceAllString(license, "-"), "-"), false
+}
+
+// SPDXLicenseExpression converts Debian license expression, e.g. "GPL-2+ or Artistic"
+// to SPDX license expression
+func SPDXLicenseExpression(license string) string {
+	parts := licenseOperatorRegexp.Split(license, -1)
+	operators := licenseOperatorRegexp.FindAllStringSubmatch(license, -1)
+
+	var result strings.Builder
+	for i, part := range parts {
+		id, _ := spdxLicenseID(part)
+		result.WriteString(id)
+		if i < len(operators) {
+			result.WriteString(" " + strings.ToUpper(operators[i][1]) + " ")
+		}
+	}
+
+	return result.String()
+}
+
+// purl returns package URL of the package
+func (sbom *SBOM) purl(p *SBOMPackage) string {
+	qualifiers := url.Values{}
+	qualifiers.Set("arch", p.Architecture)
+	if p.Source != p.Name || p.SourceVersion != p.Version {
+		if p.SourceVersion != p.Version {
+			qualifiers.Set("upstream", p.Source+"@"+p.SourceVersion)
+		} else {
+			qualifiers.Set("upstream", p.Source)
+		}
+	}
+
+	return fmt.Sprintf("pkg:deb/%s/%s@%s?%s", url.PathEscape(sbom.Vendor), url.PathEscape(p.Name),
+		url.PathEscape(p.Version), qualifiers.Encode())
+}
+
+// licenseExpression returns SPDX license expression for all the licenses of the package
+func (p *SBOMPackage) licenseExpression() string {
+	if len(p.Licenses) == 0 {
+		return "NOASSERTION"
+	}
+
+	expressions := make([]string, len(p.Licenses))
+	for i, license := range p.Licenses {
+		expressions[i] = SPDXLicenseExpression(license)
+		if len(p.Licenses) > 1 && strings.Contains(expressions[i], " ") {
+			expressions[i] = "(" + expressions[i] + ")"
+		}
+	}
+
+	return strings.Join(expressions, " AND ")
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo"`
+	Supplier         string            `json:"supplier,omitempty"`
+	PackageFileName  string            `json:"packageFileName,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	Homepage         string            `json:"homepage,omitempty"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	SourceInfo       string            `json:"sourceInfo,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	SPDXVersion       string `json:"spdxVersion"`
+	DataLicense       string `json:"dataLicense"`
+	SPDXID            string `json:"SPDXID"`
+	Name              string `json:"name"`
+	DocumentNamespace string `json:"documentNamespace"`
+	CreationInfo      struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages                   []spdxPackage          `json:"packages"`
+	HasExtractedLicensingInfos []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+	Relationships              []spdxRelationship     `json:"relationships"`
+}
+
+// WriteSPDX writes SBOM as SPDX 2.3 JSON document
+func (sbom *SBOM) WriteSPDX(w io.Writer) error {
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              sbom.Name,
+		DocumentNamespace: fmt.Sprintf("https://www.aptly.info/spdx/%s-%s", url.PathEscape(sbom.Name), sbom.Serial),
+		Packages:          make([]spdxPackage, 0, len(sbom.Packages)),
+	}
+	doc.CreationInfo.Created = sbom.Created.UTC().Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{"Tool: aptly-" + aptly.Version}
+
+	extracted := map[string]string{}
+
+	for i := range sbom.Packages {
+		p := &sbom.Packages[i]
+
+		pkg := spdxPackage{
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i+1),
+			Name:             p.Name,
+			VersionInfo:      p.Version,
+			DownloadLocation: "NOASSERTION",
+			Homepage:         p.Homepage,
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  p.licenseExpression(),
+			CopyrightText:    "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  sbom.purl(p),
+			}},
+		}
+
+		if p.Maintainer != "" {
+			pkg.Supplier = "Person: " + p.Maintainer
+		}
+
+		if p.Source != p.Name || p.SourceVersion != p.Version {
+			pkg.SourceInfo = fmt.Sprintf("built package from: %s %s", p.Source, p.SourceVersion)
+		}
+
+		if len(p.Files) > 0 {
+			f := p.Files[0]
+			pkg.PackageFileName = f.Filename
+			for _, checksum := range []spdxChecksum{
+				{"MD5", f.Checksums.MD5},
+				{"SHA1", f.Checksums.SHA1},
+				{"SHA256", f.Checksums.SHA256},
+				{"SHA512", f.Checksums.SHA512},
+			} {
+				if checksum.ChecksumValue != "" {
+					pkg.Checksums = append(pkg.Checksums, checksum)
+				}
+			}
+		}
+
+		for _, license := range p.Licenses {
+			for _, part := range licenseOperatorRegexp.Split(license, -1) {
+				if id, known := spdxLicenseID(part); !known {
+					extracted[id] = strings.TrimSpace(part)
+				}
+			}
+		}
+
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      "SPDXRef-DOCUMENT",
+			RelationshipType:   "DESCRIBES",
+			RelatedSPDXElement: pkg.SPDXID,
+		})
+	}
+
+	ids := make([]string, 0, len(extracted))
+	for id := range extracted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		doc.HasExtractedLicensingInfos = append(doc.HasExtractedLicensingInfos, spdxExtractedLicense{
+			LicenseID:     id,
+			Name:          extracted[id],
+			ExtractedText: fmt.Sprintf("License %s, see /usr/share/doc/<package>/copyright", extracted[id]),
+		})
+	}
+
+	return writeSBOMJSON(w, doc)
+}
+
+type cyclonedxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cyclonedxLicenseID struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type cyclonedxLicense struct {
+	License    *cyclonedxLicenseID `json:"license,omitempty"`
+	Expression string              `json:"expression,omitempty"`
+}
+
+type cyclonedxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cyclonedxComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Publisher  string              `json:"publisher,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Hashes     []cyclonedxHash     `json:"hashes,omitempty"`
+	Licenses   []cyclonedxLicense  `json:"licenses,omitempty"`
+	Properties []cyclonedxProperty `json:"properties,omitempty"`
+}
+
+type cyclonedxDocument struct {
+	BOMFormat    string `json:"bomFormat"`
+	SpecVersion  string `json:"specVersion"`
+	SerialNumber string `json:"serialNumber"`
+	Version      int    `json:"version"`
+	Metadata     struct {
+		Timestamp string `json:"timestamp"`
+		Tools     struct {
+			Components []cyclonedxComponent `json:"components"`
+		} `json:"tools"`
+		Component cyclonedxComponent `json:"component"`
+	} `json:"metadata"`
+	Components []cyclonedxComponent `json:"components"`
+}
+
+// WriteCycloneDX writes SBOM as CycloneDX 1.5 JSON document
+func (sbom *SBOM) WriteCycloneDX(w io.Writer) error {
+	doc := cyclonedxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + sbom.Serial,
+		Version:      1,
+		Components:   make([]cyclonedxComponent, 0, len(sbom.Packages)),
+	}
+	doc.Metadata.Timestamp = sbom.Created.UTC().Format(time.RFC3339)
+	doc.Metadata.Tools.Components = []cyclonedxComponent{{Type: "application", Name: "aptly", Version: aptly.Version}}
+	doc.Metadata.Component = cyclonedxComponent{Type: "operating-system", Name: sbom.Name}
+
+	for i := range sbom.Packages {
+		p := &sbom.Packages[i]
+		purl := sbom.purl(p)
+
+		component := cyclonedxComponent{
+			Type:      "library",
+			BOMRef:    purl,
+			Name:      p.Name,
+			Version:   p.Version,
+			Publisher: p.Maintainer,
+			PURL:      purl,
+			Properties: []cyclonedxProperty{
+				{"aptly:architecture", p.Architecture},
+				{"aptly:source", p.Source},
+				{"aptly:source-version", p.SourceVersion},
+			},
+		}
+
+		if len(p.Files) > 0 {
+			f := p.Files[0]
+			for _, hash := range []cyclonedxHash{
+				{"MD5", f.Checksums.MD5},
+				{"SHA-1", f.Checksums.SHA1},
+				{"SHA-256", f.Checksums.SHA256},
+				{"SHA-512", f.Checksums.SHA512},
+			} {
+				if hash.Content != "" {
+					component.Hashes = append(component.Hashes, hash)
+				}
+			}
+		}
+
+		if len(p.Licenses) == 1 && !licenseOperatorRegexp.MatchString(p.Licenses[0]) {
+			if id, known := spdxLicenseID(p.Licenses[0]); known {
+				component.Licenses = []cyclonedxLicense{{License: &cyclonedxLicenseID{ID: id}}}
+			} else {
+				component.Licenses = []cyclonedxLicense{{License: &cyclonedxLicenseID{Name: p.Licenses[0]}}}
+			}
+		} else if len(p.Licenses) > 0 {
+			component.Licenses = []cyclonedxLicense{{Expression: p.licenseExpression()}}
+		}
+
+		doc.Components = append(doc.Components, component)
+	}
+
+	return writeSBOMJSON(w, doc)
+}
+
+// Write writes SBOM in specified format
+func (sbom *SBOM) Write(w io.Writer, format string) error {
+	switch format {
+	case SBOMFormatSPDX:
+		return sbom.WriteSPDX(w)
+	case SBOMFormatCycloneDX:
+		return sbom.WriteCycloneDX(w)
+	}
+
+	return fmt.Errorf("unknown SBOM format %s, expected %s or %s", format, SBOMFormatSPDX, SBOMFormatCycloneDX)
+}
+
+func writeSBOMJSON(w io.Writer, doc interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(doc)
+}
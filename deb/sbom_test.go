@@ -0,0 +1,163 @@
+package deb
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type SBOMSuite struct {
+	debFile string
+}
+
+var _ = Suite(&SBOMSuite{})
+
+func (s *SBOMSuite) SetUpSuite(c *C) {
+	_, _File, _, _ := runtime.Caller(0)
+	s.debFile = filepath.Join(filepath.Dir(_File), "../system/changes/hardlink_0.2.1_amd64.deb")
+}
+
+const exampleCopyright = `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: example
+
+Files: *
+Copyright: 2024 John Doe
+License: GPL-2+ or Artistic
+
+Files: debian/*
+Copyright: 2024 Jane Doe
+License: Expat
+
+License: Expat
+ Permission is hereby granted...
+`
+
+func (s *SBOMSuite) TestParseCopyrightLicenses(c *C) {
+	licenses, err := ParseCopyrightLicenses(strings.NewReader(exampleCopyright))
+	c.Assert(err, IsNil)
+	c.Check(licenses, DeepEquals, []string{"Expat", "GPL-2+ or Artistic"})
+
+	licenses, err = ParseCopyrightLicenses(strings.NewReader("This package was debianized by John Doe.\n\nLicense: GPL\n"))
+	c.Assert(err, IsNil)
+	c.Check(licenses, IsNil)
+}
+
+func (s *SBOMSuite) TestSPDXLicenseExpression(c *C) {
+	c.Check(SPDXLicenseExpression("GPL-2+ or Artistic"), Equals, "GPL-2.0-or-later OR Artistic-1.0-Perl")
+	c.Check(SPDXLicenseExpression("expat"), Equals, "MIT")
+	c.Check(SPDXLicenseExpression("public-domain and BSD-3-clause"), Equals, "LicenseRef-public-domain AND BSD-3-Clause")
+}
+
+func (s *SBOMSuite) TestGetLicensesFromDeb(c *C) {
+	file, err := os.Open(s.debFile)
+	c.Assert(err, IsNil)
+	defer func() { _ = file.Close() }()
+
+	licenses, err := GetLicensesFromDeb(file, s.debFile, "hardlink")
+	c.Assert(err, IsNil)
+	c.Check(licenses, DeepEquals, []string{"Expat"})
+
+	_, err = file.Seek(0, 0)
+	c.Assert(err, IsNil)
+	_, err = GetLicensesFromDeb(file, s.debFile, "missing")
+	c.Check(err, ErrorMatches, "copyright not found in .*")
+}
+
+func (s *SBOMSuite) TestGetLicensesFromDebSymlink(c *C) {
+	licenses, err := GetLicensesFromDeb(bytes.NewReader(debWithDocSymlink(c)), "libfoo-dev.deb", "libfoo-dev")
+	c.Assert(err, IsNil)
+	c.Check(licenses, DeepEquals, []string{"Expat", "GPL-2+ or Artistic"})
+}
+
+func (s *SBOMSuite) sbom(c *C) *SBOM {
+	list := NewPackageList()
+	p := NewPackageFromControlFile(Stanza{
+		"Package":      "libfoo1",
+		"Version":      "1.0-1",
+		"Architecture": "amd64",
+		"Source":       "foo (1.0)",
+		"Maintainer":   "John Doe <john@example.com>",
+		"Filename":     "pool/main/f/foo/libfoo1_1.0-1_amd64.deb",
+		"Size":         "100",
+		"SHA256":       "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+	})
+	c.Assert(list.Add(p), IsNil)
+
+	sbom := NewSBOM("release", "debian", list, nil, nil)
+	c.Assert(sbom.Packages, HasLen, 1)
+	c.Check(sbom.Packages[0].Source, Equals, "foo")
+	c.Check(sbom.Packages[0].SourceVersion, Equals, "1.0")
+	c.Assert(sbom.Packages[0].Files, HasLen, 1)
+	c.Check(sbom.Packages[0].Files[0].Checksums, DeepEquals,
+		utils.ChecksumInfo{Size: 100, SHA256: "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"})
+
+	sbom.Packages[0].Licenses = []string{"GPL-2+ or Artistic", "public-domain"}
+	sbom.Created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	sbom.Serial = "00000000-0000-0000-0000-000000000001"
+
+	return sbom
+}
+
+func (s *SBOMSuite) TestWriteSPDX(c *C) {
+	var buf bytes.Buffer
+	c.Assert(s.sbom(c).Write(&buf, SBOMFormatSPDX), IsNil)
+
+	var doc map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &doc), IsNil)
+	c.Check(doc["spdxVersion"], Equals, "SPDX-2.3")
+	c.Check(doc["documentNamespace"], Equals, "https://www.aptly.info/spdx/release-00000000-0000-0000-0000-000000000001")
+
+	packages := doc["packages"].([]interface{})
+	c.Assert(packages, HasLen, 1)
+	pkg := packages[0].(map[string]interface{})
+	c.Check(pkg["name"], Equals, "libfoo1")
+	c.Check(pkg["versionInfo"], Equals, "1.0-1")
+	c.Check(pkg["supplier"], Equals, "Person: John Doe <john@example.com>")
+	c.Check(pkg["sourceInfo"], Equals, "built package from: foo 1.0")
+	c.Check(pkg["licenseDeclared"], Equals, "(GPL-2.0-or-later OR Artistic-1.0-Perl) AND LicenseRef-public-domain")
+	c.Check(pkg["checksums"], DeepEquals, []interface{}{map[string]interface{}{
+		"algorithm": "SHA256", "checksumValue": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"}})
+	c.Check(pkg["externalRefs"].([]interface{})[0].(map[string]interface{})["referenceLocator"], Equals,
+		"pkg:deb/debian/libfoo1@1.0-1?arch=amd64&upstream=foo%401.0")
+
+	extracted := doc["hasExtractedLicensingInfos"].([]interface{})
+	c.Assert(extracted, HasLen, 1)
+	c.Check(extracted[0].(map[string]interface{})["licenseId"], Equals, "LicenseRef-public-domain")
+}
+
+func (s *SBOMSuite) TestWriteCycloneDX(c *C) {
+	sbom := s.sbom(c)
+
+	var buf bytes.Buffer
+	c.Assert(sbom.Write(&buf, SBOMFormatCycloneDX), IsNil)
+
+	var doc map[string]interface{}
+	c.Assert(json.Unmarshal(buf.Bytes(), &doc), IsNil)
+	c.Check(doc["bomFormat"], Equals, "CycloneDX")
+	c.Check(doc["serialNumber"], Equals, "urn:uuid:00000000-0000-0000-0000-000000000001")
+	c.Check(doc["metadata"].(map[string]interface{})["timestamp"], Equals, "2024-01-02T03:04:05Z")
+
+	components := doc["components"].([]interface{})
+	c.Assert(components, HasLen, 1)
+	component := components[0].(map[string]interface{})
+	c.Check(component["purl"], Equals, "pkg:deb/debian/libfoo1@1.0-1?arch=amd64&upstream=foo%401.0")
+	c.Check(component["hashes"], DeepEquals, []interface{}{map[string]interface{}{
+		"alg": "SHA-256", "content": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"}})
+	c.Check(component["licenses"], DeepEquals, []interface{}{map[string]interface{}{
+		"expression": "(GPL-2.0-or-later OR Artistic-1.0-Perl) AND LicenseRef-public-domain"}})
+
+	sbom.Packages[0].Licenses = []string{"Expat"}
+	buf.Reset()
+	c.Assert(sbom.WriteCycloneDX(&buf), IsNil)
+	c.Check(buf.String(), Matches, `(?s).*"licenses": \[\s*\{\s*"license": \{\s*"id": "MIT"\s*\}.*`)
+
+	c.Check(sbom.Write(&buf, "xml"), ErrorMatches, "unknown SBOM format xml, expected spdx-json or cyclonedx-json")
+}
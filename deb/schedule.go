@@ -0,0 +1,301 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/utils"
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+)
+
+// MirrorSchedule describes periodic update of a mirror, optionally followed
+// by snapshot creation and switching published repository to the new snapshot
+type MirrorSchedule struct {
+	// Permanent internal ID
+	UUID string `codec:"UUID" json:"-"`
+	// User-assigned name
+	Name string
+	// Name of the mirror to update
+	Mirror string
+	// Cron expression (minute hour day-of-month month day-of-week)
+	Cron string
+	// Disabled schedules are never triggered automatically
+	Disabled bool
+
+	// Mirror update options
+	Keyrings             []string `codec:",omitempty"`
+	IgnoreSignatures     bool
+	IgnoreChecksums      bool
+	SkipExistingPackages bool
+	LatestOnly           bool
+
+	// Snapshot name template, if empty no snapshot is created (see ExpandSnapshotName)
+	SnapshotName string `codec:",omitempty"`
+	// Snapshot description
+	SnapshotDescription string `codec:",omitempty"`
+
+	// Published repository to switch to the new snapshot (requires SnapshotName)
+	PublishPrefix       string `codec:",omitempty"`
+	PublishDistribution string `codec:",omitempty"`
+	PublishComponent    string `codec:",omitempty"`
+
+	// Last time schedule was triggered
+	LastRun time.Time
+	// ID of the task started on last run
+	LastTaskID int
+	// Name of the snapshot created on last successful run
+	LastSnapshot string `codec:",omitempty"`
+}
+
+// NewMirrorSchedule creates new schedule for the mirror
+func NewMirrorSchedule(name, mirror, cron string) (*MirrorSchedule, error) {
+	schedule := &MirrorSchedule{
+		UUID:   uuid.NewString(),
+		Name:   name,
+		Mirror: mirror,
+		Cron:   cron,
+	}
+
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// String interface
+func (schedule *MirrorSchedule) String() string {
+	return fmt.Sprintf("[%s]: mirror %s at '%s'", schedule.Name, schedule.Mirror, schedule.Cron)
+}
+
+// Validate checks schedule for consistency
+func (schedule *MirrorSchedule) Validate() error {
+	if schedule.Mirror == "" {
+		return fmt.Errorf("mirror is required")
+	}
+
+	spec, err := utils.ParseCronSpec(schedule.Cron)
+	if err != nil {
+		return err
+	}
+
+	if schedule.PublishDistribution != "" && schedule.SnapshotName == "" {
+		return fmt.Errorf("publish switch requires snapshot name template")
+	}
+
+	if schedule.SnapshotName != "" && !schedule.uniqueSnapshotName(spec) {
+		if spec.AtMostDaily() {
+			return fmt.Errorf("snapshot name template %q should contain {date} or {timestamp}", schedule.SnapshotName)
+		}
+		return fmt.Errorf("snapshot name template %q should contain {timestamp} or {date} and {time}, as schedule runs more than once a day",
+			schedule.SnapshotName)
+	}
+
+	return nil
+}
+
+// uniqueSnapshotName checks that snapshot name template expands to different names on each run
+func (schedule *MirrorSchedule) uniqueSnapshotName(spec *utils.CronSpec) bool {
+	if strings.Contains(schedule.SnapshotName, "{timestamp}") {
+		return true
+	}
+
+	if !strings.Contains(schedule.SnapshotName, "{date}") {
+		return false
+	}
+
+	return spec.AtMostDaily() || strings.Contains(schedule.SnapshotName, "{time}")
+}
+
+// Spec returns parsed cron expression
+func (schedule *MirrorSchedule) Spec() (*utils.CronSpec, error) {
+	return utils.ParseCronSpec(schedule.Cron)
+}
+
+// IsDue checks whether schedule should be triggered at time t
+func (schedule *MirrorSchedule) IsDue(t time.Time) bool {
+	if schedule.Disabled {
+		return false
+	}
+
+	spec, err := schedule.Spec()
+	if err != nil {
+		return false
+	}
+
+	t = t.Truncate(time.Minute)
+	if !schedule.LastRun.IsZero() && !schedule.LastRun.Truncate(time.Minute).Before(t) {
+		// already triggered this minute
+		return false
+	}
+
+	return spec.Matches(t)
+}
+
+// NextRun returns next time schedule would be triggered after t
+func (schedule *MirrorSchedule) NextRun(t time.Time) time.Time {
+	spec, err := schedule.Spec()
+	if err != nil || schedule.Disabled {
+		return time.Time{}
+	}
+
+	return spec.Next(t)
+}
+
+// ExpandSnapshotName builds snapshot name from template
+//
+// Supported placeholders: {mirror}, {schedule}, {date} (YYYYMMDD),
+// {time} (HHMMSS) and {timestamp} (Unix time).
+func (schedule *MirrorSchedule) ExpandSnapshotName(t time.Time) string {
+	return strings.NewReplacer(
+		"{mirror}", schedule.Mirror,
+		"{schedule}", schedule.Name,
+		"{date}", t.Format("20060102"),
+		"{time}", t.Format("150405"),
+		"{timestamp}", strconv.FormatInt(t.Unix(), 10),
+	).Replace(schedule.SnapshotName)
+}
+
+// Key is a unique id in DB
+func (schedule *MirrorSchedule) Key() []byte {
+	return []byte("Y" + schedule.UUID)
+}
+
+// Encode does msgpack encoding of MirrorSchedule
+func (schedule *MirrorSchedule) Encode() []byte {
+	var buf bytes.Buffer
+
+	encoder := codec.NewEncoder(&buf, &codec.MsgpackHandle{})
+	_ = encoder.Encode(schedule)
+
+	return buf.Bytes()
+}
+
+// Decode decodes msgpack representation into MirrorSchedule
+func (schedule *MirrorSchedule) Decode(input []byte) error {
+	decoder := codec.NewDecoderBytes(input, &codec.MsgpackHandle{})
+	return decoder.Decode(schedule)
+}
+
+// MirrorScheduleCollection does listing, updating/adding/deleting of MirrorSchedules
+type MirrorScheduleCollection struct {
+	db database.Storage
+}
+
+// NewMirrorScheduleCollection loads MirrorSchedules from DB and makes up collection
+func NewMirrorScheduleCollection(db database.Storage) *MirrorScheduleCollection {
+	return &MirrorScheduleCollection{
+		db: db,
+	}
+}
+
+// Add appends new schedule to collection and saves it
+func (collection *MirrorScheduleCollection) Add(schedule *MirrorSchedule) error {
+	_, err := collection.ByName(schedule.Name)
+	if err == nil {
+		return fmt.Errorf("schedule with name %s already exists", schedule.Name)
+	}
+
+	return collection.Update(schedule)
+}
+
+// Update stores updated information about schedule in DB
+func (collection *MirrorScheduleCollection) Update(schedule *MirrorSchedule) error {
+	return collection.db.Put(schedule.Key(), schedule.Encode())
+}
+
+// ByName looks up schedule by name
+func (collection *MirrorScheduleCollection) ByName(name string) (*MirrorSchedule, error) {
+	var result *MirrorSchedule
+
+	err := collection.ForEach(func(s *MirrorSchedule) error {
+		if s.Name == name {
+			result = s
+			return errors.New("abort")
+		}
+		return nil
+	})
+	if result != nil {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("schedule with name %s not found", name)
+}
+
+// ByMirror looks up schedules updating specified mirror
+func (collection *MirrorScheduleCollection) ByMirror(mirror string) []*MirrorSchedule {
+	result := []*MirrorSchedule(nil)
+
+	_ = collection.ForEach(func(s *MirrorSchedule) error {
+		if s.Mirror == mirror {
+			result = append(result, s)
+		}
+		return nil
+	})
+
+	return result
+}
+
+// RenameMirror points schedules of the mirror to its new name
+func (collection *MirrorScheduleCollection) RenameMirror(oldName, newName string) error {
+	for _, schedule := range collection.ByMirror(oldName) {
+		schedule.Mirror = newName
+		if err := collection.Update(schedule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DropMirror removes schedules updating the mirror which is being dropped
+func (collection *MirrorScheduleCollection) DropMirror(mirror string) error {
+	for _, schedule := range collection.ByMirror(mirror) {
+		if err := collection.db.Delete(schedule.Key()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEach runs method for each schedule
+func (collection *MirrorScheduleCollection) ForEach(handler func(*MirrorSchedule) error) error {
+	return collection.db.ProcessByPrefix([]byte("Y"), func(_, blob []byte) error {
+		s := &MirrorSchedule{}
+		if err := s.Decode(blob); err != nil {
+			log.Printf("Error decoding schedule: %s\n", err)
+			return nil
+		}
+
+		return handler(s)
+	})
+}
+
+// Len returns number of schedules
+func (collection *MirrorScheduleCollection) Len() int {
+	return len(collection.db.KeysByPrefix([]byte("Y")))
+}
+
+// Drop removes schedule from collection
+func (collection *MirrorScheduleCollection) Drop(schedule *MirrorSchedule) error {
+	if _, err := collection.db.Get(schedule.Key()); err != nil {
+		if err == database.ErrNotFound {
+			return errors.New("schedule not found")
+		}
+
+		return err
+	}
+
+	return collection.db.Delete(schedule.Key())
+}
@@ -0,0 +1,141 @@
+package deb
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+
+	. "gopkg.in/check.v1"
+)
+
+type MirrorScheduleSuite struct{}
+
+var _ = Suite(&MirrorScheduleSuite{})
+
+func (s *MirrorScheduleSuite) TestNewMirrorSchedule(c *C) {
+	schedule, err := NewMirrorSchedule("nightly", "wheezy-main", "30 2 * * *")
+	c.Assert(err, IsNil)
+	c.Check(schedule.String(), Equals, "[nightly]: mirror wheezy-main at '30 2 * * *'")
+
+	_, err = NewMirrorSchedule("nightly", "wheezy-main", "30 2 * *")
+	c.Check(err, ErrorMatches, ".*expected 5 fields.*")
+
+	_, err = NewMirrorSchedule("nightly", "", "@daily")
+	c.Check(err, ErrorMatches, "mirror is required")
+
+	schedule.PublishDistribution = "wheezy"
+	c.Check(schedule.Validate(), ErrorMatches, "publish switch requires snapshot name template")
+}
+
+func (s *MirrorScheduleSuite) TestValidateSnapshotName(c *C) {
+	daily, _ := NewMirrorSchedule("nightly", "wheezy-main", "30 2 * * *")
+	hourly, _ := NewMirrorSchedule("hourly", "wheezy-main", "@hourly")
+
+	for _, template := range []string{"{mirror}-{date}", "{mirror}-{timestamp}", "{date}-{time}"} {
+		daily.SnapshotName = template
+		c.Check(daily.Validate(), IsNil, Commentf("template: %q", template))
+	}
+
+	for _, template := range []string{"{mirror}-{timestamp}", "{date}-{time}"} {
+		hourly.SnapshotName = template
+		c.Check(hourly.Validate(), IsNil, Commentf("template: %q", template))
+	}
+
+	daily.SnapshotName = "nightly"
+	c.Check(daily.Validate(), ErrorMatches, `snapshot name template "nightly" should contain \{date\} or \{timestamp\}`)
+	daily.SnapshotName = "{mirror}-{time}"
+	c.Check(daily.Validate(), ErrorMatches, `snapshot name template .* should contain \{date\} or \{timestamp\}`)
+
+	hourly.SnapshotName = "{mirror}-{date}"
+	c.Check(hourly.Validate(), ErrorMatches, `snapshot name template .* runs more than once a day`)
+}
+
+func (s *MirrorScheduleSuite) TestIsDue(c *C) {
+	schedule, _ := NewMirrorSchedule("nightly", "wheezy-main", "30 2 * * *")
+
+	at := time.Date(2026, 10, 18, 2, 30, 12, 0, time.UTC)
+	c.Check(schedule.IsDue(at), Equals, true)
+	c.Check(schedule.IsDue(at.Add(time.Minute)), Equals, false)
+
+	schedule.LastRun = at.Add(-10 * time.Second)
+	c.Check(schedule.IsDue(at), Equals, false)
+	c.Check(schedule.IsDue(at.Add(24*time.Hour)), Equals, true)
+
+	schedule.Disabled = true
+	c.Check(schedule.IsDue(at.Add(24*time.Hour)), Equals, false)
+	c.Check(schedule.NextRun(at).IsZero(), Equals, true)
+
+	schedule.Disabled = false
+	c.Check(schedule.NextRun(at), Equals, time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC))
+}
+
+func (s *MirrorScheduleSuite) TestExpandSnapshotName(c *C) {
+	schedule, _ := NewMirrorSchedule("nightly", "wheezy-main", "@daily")
+	schedule.SnapshotName = "{mirror}-{date}-{time}"
+
+	c.Check(schedule.ExpandSnapshotName(time.Date(2026, 10, 18, 2, 30, 5, 0, time.UTC)), Equals, "wheezy-main-20261018-023005")
+}
+
+type MirrorScheduleCollectionSuite struct {
+	db         database.Storage
+	collection *MirrorScheduleCollection
+}
+
+var _ = Suite(&MirrorScheduleCollectionSuite{})
+
+func (s *MirrorScheduleCollectionSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.collection = NewMirrorScheduleCollection(s.db)
+}
+
+func (s *MirrorScheduleCollectionSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *MirrorScheduleCollectionSuite) TestAddByNameDrop(c *C) {
+	_, err := s.collection.ByName("nightly")
+	c.Assert(err, ErrorMatches, "schedule with name nightly not found")
+
+	schedule, _ := NewMirrorSchedule("nightly", "wheezy-main", "@daily")
+	c.Assert(s.collection.Add(schedule), IsNil)
+	c.Assert(s.collection.Add(schedule), ErrorMatches, ".*already exists")
+
+	other, _ := NewMirrorSchedule("hourly", "wheezy-contrib", "@hourly")
+	c.Assert(s.collection.Add(other), IsNil)
+	c.Check(s.collection.Len(), Equals, 2)
+
+	loaded, err := s.collection.ByName("nightly")
+	c.Assert(err, IsNil)
+	c.Check(loaded.UUID, Equals, schedule.UUID)
+	c.Check(loaded.Mirror, Equals, "wheezy-main")
+
+	c.Check(s.collection.ByMirror("wheezy-contrib"), HasLen, 1)
+	c.Check(s.collection.ByMirror("nope"), HasLen, 0)
+
+	loaded.LastTaskID = 5
+	c.Assert(s.collection.Update(loaded), IsNil)
+	loaded, _ = s.collection.ByName("nightly")
+	c.Check(loaded.LastTaskID, Equals, 5)
+
+	c.Assert(s.collection.Drop(loaded), IsNil)
+	c.Check(s.collection.Drop(loaded), ErrorMatches, "schedule not found")
+	c.Check(s.collection.Len(), Equals, 1)
+}
+
+func (s *MirrorScheduleCollectionSuite) TestRenameDropMirror(c *C) {
+	for _, name := range []string{"nightly", "weekly"} {
+		schedule, _ := NewMirrorSchedule(name, "wheezy-main", "@daily")
+		c.Assert(s.collection.Add(schedule), IsNil)
+	}
+	other, _ := NewMirrorSchedule("hourly", "wheezy-contrib", "@hourly")
+	c.Assert(s.collection.Add(other), IsNil)
+
+	c.Assert(s.collection.RenameMirror("wheezy-main", "wheezy"), IsNil)
+	c.Check(s.collection.ByMirror("wheezy-main"), HasLen, 0)
+	c.Check(s.collection.ByMirror("wheezy"), HasLen, 2)
+
+	c.Assert(s.collection.DropMirror("wheezy"), IsNil)
+	c.Check(s.collection.ByMirror("wheezy"), HasLen, 0)
+	c.Check(s.collection.Len(), Equals, 1)
+}
@@ -43,6 +43,12 @@ type Snapshot struct {
 	// AppStream files: relative path → pool path (pass-through from mirror)
 	AppStreamFiles map[string]string `json:",omitempty"`
 
+	// User-defined labels
+	Labels map[string]string `codec:",omitempty" json:",omitempty"`
+
+	// Protected snapshot can't be dropped or renamed
+	Protected bool `codec:",omitempty" json:",omitempty"`
+
 	packageRefs *PackageRefList
 }
 
@@ -128,6 +134,15 @@ func (s *Snapshot) String() string {
 	return fmt.Sprintf("[%s]: %s", s.Name, s.Description)
 }
 
+// CheckUnprotected returns error if snapshot is protected
+func (s *Snapshot) CheckUnprotected() error {
+	if s.Protected {
+		return fmt.Errorf("snapshot %s is protected, unprotect it first", s.Name)
+	}
+
+	return nil
+}
+
 // NumPackages returns number of packages in snapshot
 func (s *Snapshot) NumPackages() int {
 	return s.packageRefs.Len()
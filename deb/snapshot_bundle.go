@@ -0,0 +1,472 @@
+package deb
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// SnapshotBundleFormat is version of snapshot bundle format
+const SnapshotBundleFormat = 1
+
+// Names of snapshot bundle entries, package files are stored under bundlePoolDir
+const (
+	bundleSnapshotEntry = "snapshot.json"
+	bundlePackagesEntry = "packages.json"
+	bundlePoolDir       = "pool/"
+)
+
+// SnapshotBundleManifest describes snapshot stored in the bundle
+type SnapshotBundleManifest struct {
+	// Version of bundle format
+	Format int
+	// Snapshot metadata
+	Name        string
+	Description string
+	CreatedAt   time.Time
+	Labels      map[string]string `json:",omitempty"`
+	// For incremental bundles: name of the snapshot packages of which are not included
+	BaseSnapshot string `json:",omitempty"`
+	// Keys of all the packages in the snapshot, including packages not in the bundle
+	PackageRefs []string
+}
+
+// SnapshotBundlePackage is package stored in the bundle
+type SnapshotBundlePackage struct {
+	Key string
+	// Package type: deb, udeb, source or installer
+	Type string
+	// Package stanza (control file)
+	Stanza Stanza
+	Files  []SnapshotBundleFile
+}
+
+// SnapshotBundleFile is package file stored in the bundle
+type SnapshotBundleFile struct {
+	Filename  string
+	Checksums utils.ChecksumInfo
+	// Path to the file in the bundle
+	Path string
+}
+
+func bundlePackageType(p *Package) string {
+	switch {
+	case p.IsInstaller:
+		return PackageTypeInstaller
+	case p.IsSource:
+		return PackageTypeSource
+	case p.IsUdeb:
+		return PackageTypeUdeb
+	}
+
+	return PackageTypeBinary
+}
+
+func writeBundleEntry(tw *tar.Writer, name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Now(), Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+// ExportSnapshotBundle writes snapshot metadata, package stanzas and package files to the tar archive
+//
+// If base snapshot is specified, packages present in the base snapshot are not included
+// into the bundle, so bundle could be imported only if base snapshot packages are already there.
+// Snapshots should be loaded completely.
+func ExportSnapshotBundle(w io.Writer, snapshot *Snapshot, base *Snapshot, packageCollection *PackageCollection,
+	packagePool aptly.PackagePool, progress aptly.Progress) error {
+	manifest := SnapshotBundleManifest{
+		Format:      SnapshotBundleFormat,
+		Name:        snapshot.Name,
+		Description: snapshot.Description,
+		CreatedAt:   snapshot.CreatedAt,
+		Labels:      snapshot.Labels,
+		PackageRefs: snapshot.RefList().Strings(),
+	}
+
+	inBase := map[string]bool{}
+	if base != nil {
+		manifest.BaseSnapshot = base.Name
+		for _, key := range base.RefList().Strings() {
+			inBase[key] = true
+		}
+	}
+
+	var (
+		packages  []SnapshotBundlePackage
+		poolPaths []string
+	)
+	seen := map[string]bool{}
+
+	for _, key := range manifest.PackageRefs {
+		if inBase[key] {
+			continue
+		}
+
+		p, err := packageCollection.ByKey([]byte(key))
+		if err != nil {
+			return fmt.Errorf("unable to load package %s: %s", key, err)
+		}
+
+		bundlePackage := SnapshotBundlePackage{
+			Key:    key,
+			Type:   bundlePackageType(p),
+			Stanza: p.Stanza(),
+		}
+
+		for _, f := range p.Files() {
+			poolPath, err := f.GetPoolPath(packagePool)
+			if err != nil {
+				return err
+			}
+
+			bundlePackage.Files = append(bundlePackage.Files, SnapshotBundleFile{
+				Filename:  f.Filename,
+				Checksums: f.Checksums,
+				Path:      bundlePoolDir + poolPath,
+			})
+
+			if !seen[poolPath] {
+				seen[poolPath] = true
+				poolPaths = append(poolPaths, poolPath)
+			}
+		}
+
+		packages = append(packages, bundlePackage)
+	}
+
+	tw := tar.NewWriter(w)
+
+	if err := writeBundleEntry(tw, bundleSnapshotEntry, manifest); err != nil {
+		return err
+	}
+
+	if err := writeBundleEntry(tw, bundlePackagesEntry, packages); err != nil {
+		return err
+	}
+
+	if progress != nil {
+		progress.Printf("Exporting %d packages (%d files)...\n", len(packages), len(poolPaths))
+		progress.InitBar(int64(len(poolPaths)), false, aptly.BarGeneralBuildFileList)
+		defer progress.ShutdownBar()
+	}
+
+	for _, poolPath := range poolPaths {
+		if err := exportBundleFile(tw, poolPath, packagePool); err != nil {
+			return fmt.Errorf("unable to export %s: %s", poolPath, err)
+		}
+
+		if progress != nil {
+			progress.AddBar(1)
+		}
+	}
+
+	return tw.Close()
+}
+
+func exportBundleFile(tw *tar.Writer, poolPath string, packagePool aptly.PackagePool) error {
+	size, err := packagePool.Size(poolPath)
+	if err != nil {
+		return err
+	}
+
+	file, err := packagePool.Open(poolPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	err = tw.WriteHeader(&tar.Header{Name: bundlePoolDir + poolPath, Mode: 0644, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, file)
+	return err
+}
+
+func readBundleEntry(tr *tar.Reader, name string, value interface{}) error {
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", name, err)
+	}
+
+	if header.Name != name {
+		return fmt.Errorf("unexpected entry %s, expected %s", header.Name, name)
+	}
+
+	return json.NewDecoder(tr).Decode(value)
+}
+
+// checksumsMatch checks that all the checksums present in expected match actual ones
+func checksumsMatch(expected, actual utils.ChecksumInfo) bool {
+	return expected.Size == actual.Size &&
+		(expected.MD5 == "" || expected.MD5 == actual.MD5) &&
+		(expected.SHA1 == "" || expected.SHA1 == actual.SHA1) &&
+		(expected.SHA256 == "" || expected.SHA256 == actual.SHA256) &&
+		(expected.SHA512 == "" || expected.SHA512 == actual.SHA512)
+}
+
+// packageFromBundle recreates package from the stanza and files stored in the bundle
+func packageFromBundle(bundlePackage *SnapshotBundlePackage, poolPaths map[string]string) (*Package, error) {
+	var (
+		p   *Package
+		err error
+	)
+
+	stanza := bundlePackage.Stanza.Copy()
+
+	switch bundlePackage.Type {
+	case PackageTypeBinary:
+		p = NewPackageFromControlFile(stanza)
+	case PackageTypeUdeb:
+		p = NewUdebPackageFromControlFile(stanza)
+	case PackageTypeSource:
+		p, err = NewSourcePackageFromControlFile(stanza)
+		if err != nil {
+			return nil, err
+		}
+	case PackageTypeInstaller:
+		p = &Package{
+			Name:         stanza["Package"],
+			Version:      stanza["Version"],
+			Architecture: stanza["Architecture"],
+			IsInstaller:  true,
+			V06Plus:      true,
+			deps:         &PackageDependencies{},
+			extra:        &Stanza{},
+		}
+	default:
+		return nil, fmt.Errorf("unknown package type %#v", bundlePackage.Type)
+	}
+
+	// files are restored from the bundle, as stanza might not have complete information
+	files := make(PackageFiles, len(bundlePackage.Files))
+	for i, f := range bundlePackage.Files {
+		poolPath, ok := poolPaths[f.Path]
+		if !ok {
+			return nil, fmt.Errorf("file %s is missing in the bundle", f.Path)
+		}
+
+		files[i] = PackageFile{Filename: f.Filename, Checksums: f.Checksums, PoolPath: poolPath}
+	}
+	p.UpdateFiles(files)
+
+	if string(p.Key("")) != bundlePackage.Key {
+		// packages imported by very old versions of aptly
+		p.V06Plus = false
+		if string(p.Key("")) != bundlePackage.Key {
+			return nil, fmt.Errorf("package %s doesn't match its key in the bundle", bundlePackage.Key)
+		}
+	}
+
+	return p, nil
+}
+
+// ImportSnapshotBundle imports snapshot bundle created by ExportSnapshotBundle
+//
+// Package files are imported into the package pool, packages into the package collection and
+// snapshot is recreated with the name from the bundle, unless name is specified.
+// Packages of incremental bundles which are not in the bundle should be already in the database.
+func ImportSnapshotBundle(r io.Reader, name string, collectionFactory *CollectionFactory, packagePool aptly.PackagePool,
+	checksumStorage aptly.ChecksumStorage, progress aptly.Progress) (*Snapshot, error) {
+	var (
+		manifest SnapshotBundleManifest
+		packages []SnapshotBundlePackage
+	)
+
+	tr := tar.NewReader(r)
+
+	if err := readBundleEntry(tr, bundleSnapshotEntry, &manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Format != SnapshotBundleFormat {
+		return nil, fmt.Errorf("unsupported bundle format %d", manifest.Format)
+	}
+
+	if name == "" {
+		name = manifest.Name
+	}
+
+	snapshotCollection := collectionFactory.SnapshotCollection()
+	packageCollection := collectionFactory.PackageCollection()
+
+	if _, err := snapshotCollection.ByName(name); err == nil {
+		return nil, fmt.Errorf("snapshot with name %s already exists", name)
+	}
+
+	if err := readBundleEntry(tr, bundlePackagesEntry, &packages); err != nil {
+		return nil, err
+	}
+
+	// check that all the packages not included into the bundle are available
+	inBundle := make(map[string]bool, len(packages))
+	files := map[string]SnapshotBundleFile{}
+	for _, bundlePackage := range packages {
+		inBundle[bundlePackage.Key] = true
+		for _, f := range bundlePackage.Files {
+			if err := checkBundleFilename(f.Filename); err != nil {
+				return nil, err
+			}
+			files[f.Path] = f
+		}
+	}
+
+	for _, key := range manifest.PackageRefs {
+		if inBundle[key] {
+			continue
+		}
+
+		if _, err := packageCollection.ByKey([]byte(key)); err != nil {
+			if manifest.BaseSnapshot != "" {
+				return nil, fmt.Errorf("package %s is not in the bundle, import base snapshot %s first", key, manifest.BaseSnapshot)
+			}
+			return nil, fmt.Errorf("package %s is not in the bundle", key)
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "aptly-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	if progress != nil {
+		progress.Printf("Importing %d packages (%d files)...\n", len(packages), len(files))
+		progress.InitBar(int64(len(files)), false, aptly.BarGeneralBuildFileList)
+		defer progress.ShutdownBar()
+	}
+
+	poolPaths := make(map[string]string, len(files))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read bundle: %s", err)
+		}
+
+		f, ok := files[header.Name]
+		if !ok || !strings.HasPrefix(header.Name, bundlePoolDir) {
+			return nil, fmt.Errorf("unexpected entry %s in the bundle", header.Name)
+		}
+
+		poolPaths[header.Name], err = importBundleFile(tr, &f, packagePool, checksumStorage, tempDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to import %s: %s", header.Name, err)
+		}
+
+		if progress != nil {
+			progress.AddBar(1)
+		}
+	}
+
+	for i := range packages {
+		p, err := packageFromBundle(&packages[i], poolPaths)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err = packageCollection.ByKey(p.Key("")); err == nil {
+			continue
+		}
+
+		if err = packageCollection.Update(p); err != nil {
+			return nil, err
+		}
+	}
+
+	refList := NewPackageRefList()
+	for _, key := range manifest.PackageRefs {
+		refList.Refs = append(refList.Refs, []byte(key))
+	}
+	sort.Sort(refList)
+
+	snapshot := NewSnapshotFromRefList(name, nil, refList, manifest.Description)
+	snapshot.Labels = manifest.Labels
+	if !manifest.CreatedAt.IsZero() {
+		// keep original creation time, so that retention rules apply to imported snapshot as well
+		snapshot.CreatedAt = manifest.CreatedAt
+	}
+
+	if err = snapshotCollection.Add(snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// checkBundleFilename verifies that name of package file in the bundle is a plain file name
+func checkBundleFilename(name string) error {
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid file name %#v in the bundle", name)
+	}
+
+	return nil
+}
+
+// importBundleFile imports file from the bundle into the package pool, verifying its checksums
+func importBundleFile(r io.Reader, f *SnapshotBundleFile, packagePool aptly.PackagePool,
+	checksumStorage aptly.ChecksumStorage, tempDir string) (string, error) {
+	checksums := f.Checksums
+	poolPath, exists, err := packagePool.Verify("", f.Filename, &checksums, checksumStorage)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return poolPath, nil
+	}
+
+	if err = checkBundleFilename(f.Filename); err != nil {
+		return "", err
+	}
+
+	tempPath := filepath.Join(tempDir, filepath.Base(f.Filename))
+	if filepath.Dir(tempPath) != filepath.Clean(tempDir) {
+		return "", fmt.Errorf("invalid file name %#v in the bundle", f.Filename)
+	}
+
+	tempFile, err := os.Create(tempPath)
+	if err != nil {
+		return "", err
+	}
+
+	w := utils.NewChecksumWriter()
+	_, err = io.Copy(io.MultiWriter(tempFile, w), r)
+	_ = tempFile.Close()
+	if err != nil {
+		return "", err
+	}
+
+	checksums = w.Sum()
+	if !checksumsMatch(f.Checksums, checksums) {
+		return "", fmt.Errorf("checksum mismatch for %s", f.Filename)
+	}
+
+	poolPath, err = packagePool.Import(tempPath, f.Filename, &checksums, true, checksumStorage)
+	if err != nil {
+		return "", err
+	}
+
+	return poolPath, checksumStorage.Update(poolPath, &checksums)
+}
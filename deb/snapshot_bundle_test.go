@@ -0,0 +1,262 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/files"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotBundleSuite struct {
+	db, targetDB           database.Storage
+	factory, targetFactory *CollectionFactory
+	pool, targetPool       aptly.PackagePool
+	cs, targetCS           aptly.ChecksumStorage
+	tempDir                string
+}
+
+var _ = Suite(&SnapshotBundleSuite{})
+
+func (s *SnapshotBundleSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.targetDB, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+	s.targetFactory = NewCollectionFactory(s.targetDB)
+	s.pool = files.NewPackagePool(c.MkDir(), false)
+	s.targetPool = files.NewPackagePool(c.MkDir(), false)
+	s.cs = files.NewMockChecksumStorage()
+	s.targetCS = files.NewMockChecksumStorage()
+	s.tempDir = c.MkDir()
+}
+
+func (s *SnapshotBundleSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+	_ = s.targetDB.Close()
+}
+
+func (s *SnapshotBundleSuite) importFile(c *C, filename, content string) utils.ChecksumInfo {
+	path := filepath.Join(s.tempDir, filename)
+	c.Assert(os.WriteFile(path, []byte(content), 0644), IsNil)
+
+	checksums, err := utils.ChecksumsForFile(path)
+	c.Assert(err, IsNil)
+	return checksums
+}
+
+func (s *SnapshotBundleSuite) addFiles(c *C, p *Package) {
+	files := p.Files()
+	for i := range files {
+		var err error
+		files[i].PoolPath, err = s.pool.Import(filepath.Join(s.tempDir, files[i].Filename), files[i].Filename, &files[i].Checksums, false, s.cs)
+		c.Assert(err, IsNil)
+	}
+	p.UpdateFiles(files)
+
+	c.Assert(s.factory.PackageCollection().Update(p), IsNil)
+}
+
+func (s *SnapshotBundleSuite) addBinary(c *C, name, version string) *Package {
+	filename := fmt.Sprintf("%s_%s_amd64.deb", name, version)
+	checksums := s.importFile(c, filename, "deb "+name+" "+version)
+
+	p := NewPackageFromControlFile(Stanza{
+		"Package":      name,
+		"Version":      version,
+		"Architecture": "amd64",
+		"Depends":      "libc6 (>= 2.7)",
+		"Description":  "test package",
+		"Filename":     "pool/main/" + filename,
+		"Size":         fmt.Sprintf("%d", checksums.Size),
+		"MD5sum":       checksums.MD5,
+		"SHA256":       checksums.SHA256,
+	})
+	s.addFiles(c, p)
+
+	return p
+}
+
+func (s *SnapshotBundleSuite) addSource(c *C, name, version string) *Package {
+	dsc := fmt.Sprintf("%s_%s.dsc", name, version)
+	orig := fmt.Sprintf("%s_%s.orig.tar.gz", name, version)
+	dscChecksums := s.importFile(c, dsc, "dsc "+name)
+	origChecksums := s.importFile(c, orig, "orig "+name)
+
+	p, err := NewSourcePackageFromControlFile(Stanza{
+		"Package":      name,
+		"Version":      version,
+		"Architecture": "any",
+		"Directory":    "pool/main/" + name,
+		"Files": fmt.Sprintf(" %s %d %s\n %s %d %s\n", dscChecksums.MD5, dscChecksums.Size, dsc,
+			origChecksums.MD5, origChecksums.Size, orig),
+		"Checksums-Sha256": fmt.Sprintf(" %s %d %s\n %s %d %s\n", dscChecksums.SHA256, dscChecksums.Size, dsc,
+			origChecksums.SHA256, origChecksums.Size, orig),
+	})
+	c.Assert(err, IsNil)
+	s.addFiles(c, p)
+
+	return p
+}
+
+func (s *SnapshotBundleSuite) addSnapshot(c *C, name string, packages ...*Package) *Snapshot {
+	list := NewPackageList()
+	for _, p := range packages {
+		c.Assert(list.Add(p), IsNil)
+	}
+
+	snapshot := NewSnapshotFromPackageList(name, nil, list, "Snapshot "+name)
+	c.Assert(s.factory.SnapshotCollection().Add(snapshot), IsNil)
+	return snapshot
+}
+
+func (s *SnapshotBundleSuite) export(c *C, snapshot, base *Snapshot) []byte {
+	var buf bytes.Buffer
+	c.Assert(ExportSnapshotBundle(&buf, snapshot, base, s.factory.PackageCollection(), s.pool, nil), IsNil)
+	return buf.Bytes()
+}
+
+func (s *SnapshotBundleSuite) importBundle(bundle []byte, name string) (*Snapshot, error) {
+	return ImportSnapshotBundle(bytes.NewReader(bundle), name, s.targetFactory, s.targetPool, s.targetCS, nil)
+}
+
+func (s *SnapshotBundleSuite) TestExportImport(c *C) {
+	p1 := s.addBinary(c, "app", "1.0")
+	p2 := s.addBinary(c, "lib", "2.0")
+	src := s.addSource(c, "app", "1.0")
+
+	snapshot := s.addSnapshot(c, "snap1", p1, p2, src)
+	snapshot.Labels = map[string]string{"env": "prod"}
+	snapshot.CreatedAt = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	imported, err := s.importBundle(s.export(c, snapshot, nil), "")
+	c.Assert(err, IsNil)
+	c.Check(imported.Name, Equals, "snap1")
+	c.Check(imported.Description, Equals, "Snapshot snap1")
+	c.Check(imported.Labels, DeepEquals, map[string]string{"env": "prod"})
+	c.Check(imported.CreatedAt.Equal(snapshot.CreatedAt), Equals, true)
+
+	imported, err = s.targetFactory.SnapshotCollection().ByName("snap1")
+	c.Assert(err, IsNil)
+	c.Assert(s.targetFactory.SnapshotCollection().LoadComplete(imported), IsNil)
+	c.Check(imported.RefList().Strings(), DeepEquals, snapshot.RefList().Strings())
+
+	for _, p := range []*Package{p1, p2, src} {
+		original, err := s.factory.PackageCollection().ByKey(p.Key(""))
+		c.Assert(err, IsNil)
+		restored, err := s.targetFactory.PackageCollection().ByKey(p.Key(""))
+		c.Assert(err, IsNil)
+		c.Check(restored.Stanza(), DeepEquals, original.Stanza())
+		c.Check(restored.Files(), HasLen, len(p.Files()))
+
+		for _, f := range restored.Files() {
+			ok, err := f.Verify(s.targetPool, s.targetCS)
+			c.Check(err, IsNil)
+			c.Check(ok, Equals, true)
+		}
+	}
+
+	_, err = s.importBundle(s.export(c, snapshot, nil), "")
+	c.Check(err, ErrorMatches, "snapshot with name snap1 already exists")
+
+	imported, err = s.importBundle(s.export(c, snapshot, nil), "snap1-copy")
+	c.Assert(err, IsNil)
+	c.Check(imported.Name, Equals, "snap1-copy")
+}
+
+func (s *SnapshotBundleSuite) TestIncremental(c *C) {
+	p1 := s.addBinary(c, "app", "1.0")
+	p2 := s.addBinary(c, "app", "1.1")
+	p3 := s.addBinary(c, "lib", "2.0")
+
+	snap1 := s.addSnapshot(c, "snap1", p1, p3)
+	snap2 := s.addSnapshot(c, "snap2", p2, p3)
+
+	incremental := s.export(c, snap2, snap1)
+	full := s.export(c, snap2, nil)
+	c.Check(len(incremental) < len(full), Equals, true)
+
+	_, err := s.importBundle(incremental, "")
+	c.Check(err, ErrorMatches, "package Pamd64 lib 2.0 .* is not in the bundle, import base snapshot snap1 first")
+
+	_, err = s.importBundle(s.export(c, snap1, nil), "")
+	c.Assert(err, IsNil)
+
+	imported, err := s.importBundle(incremental, "")
+	c.Assert(err, IsNil)
+	c.Check(imported.RefList().Strings(), DeepEquals, snap2.RefList().Strings())
+
+	_, err = s.targetFactory.PackageCollection().ByKey(p2.Key(""))
+	c.Check(err, IsNil)
+}
+
+func (s *SnapshotBundleSuite) TestImportCorrupt(c *C) {
+	p1 := s.addBinary(c, "app", "1.0")
+	snapshot := s.addSnapshot(c, "snap1", p1)
+
+	// same size, different contents
+	fullPath := s.pool.(aptly.LocalPackagePool).FullPath(p1.Files()[0].PoolPath)
+	c.Assert(os.Remove(fullPath), IsNil)
+	c.Assert(os.WriteFile(fullPath, []byte("deb app 1.1"), 0644), IsNil)
+
+	_, err := s.importBundle(s.export(c, snapshot, nil), "")
+	c.Check(err, ErrorMatches, "unable to import pool/.*: checksum mismatch for app_1.0_amd64.deb")
+
+	_, err = s.targetFactory.SnapshotCollection().ByName("snap1")
+	c.Check(err, NotNil)
+
+	_, err = s.importBundle([]byte("garbage"), "")
+	c.Check(err, ErrorMatches, "unable to read snapshot.json: .*")
+}
+
+// rewriteBundleEntry replaces old with new in the contents of bundle entry
+func rewriteBundleEntry(c *C, bundle []byte, entry, old, new string) []byte {
+	var buf bytes.Buffer
+
+	tr := tar.NewReader(bytes.NewReader(bundle))
+	tw := tar.NewWriter(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+
+		contents, err := io.ReadAll(tr)
+		c.Assert(err, IsNil)
+		if header.Name == entry {
+			contents = bytes.ReplaceAll(contents, []byte(old), []byte(new))
+			header.Size = int64(len(contents))
+		}
+
+		c.Assert(tw.WriteHeader(header), IsNil)
+		_, err = tw.Write(contents)
+		c.Assert(err, IsNil)
+	}
+	c.Assert(tw.Close(), IsNil)
+
+	return buf.Bytes()
+}
+
+func (s *SnapshotBundleSuite) TestImportInvalidFilename(c *C) {
+	p1 := s.addBinary(c, "app", "1.0")
+	bundle := s.export(c, s.addSnapshot(c, "snap1", p1), nil)
+
+	for _, filename := range []string{"../../app_1.0_amd64.deb", "sub/app_1.0_amd64.deb", "..", ""} {
+		_, err := s.importBundle(rewriteBundleEntry(c, bundle, bundlePackagesEntry,
+			`"Filename": "app_1.0_amd64.deb"`, fmt.Sprintf(`"Filename": %q`, filename)), "")
+		c.Check(err, ErrorMatches, "invalid file name .* in the bundle")
+	}
+
+	_, err := s.targetFactory.SnapshotCollection().ByName("snap1")
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,208 @@
+package deb
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// SnapshotPruneDecision is result of applying retention rules to the snapshot
+type SnapshotPruneDecision struct {
+	// Snapshot name
+	Name string
+	// Pattern of the retention rule matching the snapshot
+	Rule string
+	// Snapshot creation time
+	CreatedAt time.Time
+	// Should snapshot be dropped?
+	Prune bool
+	// Why snapshot is kept or dropped
+	Reason string
+
+	snapshot *Snapshot
+}
+
+// Snapshot returns snapshot the decision is made for
+func (decision *SnapshotPruneDecision) Snapshot() *Snapshot {
+	return decision.snapshot
+}
+
+// ParseRetentionAge parses age of snapshot in retention rule: Go duration,
+// or number of days (30d) or weeks (4w)
+func ParseRetentionAge(age string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(age, suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(age, suffix))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age %#v", age)
+			}
+
+			return time.Duration(n) * unit, nil
+		}
+	}
+
+	duration, err := time.ParseDuration(age)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid age %#v", age)
+	}
+
+	return duration, nil
+}
+
+// ValidateRetentionRules checks retention rules for errors
+func ValidateRetentionRules(rules []utils.SnapshotRetentionRule) error {
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return fmt.Errorf("retention rule should have pattern")
+		}
+
+		if _, err := filepath.Match(rule.Pattern, ""); err != nil {
+			return fmt.Errorf("retention rule %s: invalid pattern: %s", rule.Pattern, err)
+		}
+
+		if rule.KeepLast < 0 {
+			return fmt.Errorf("retention rule %s: number of snapshots to keep should not be negative", rule.Pattern)
+		}
+
+		if rule.KeepWithin != "" {
+			if _, err := ParseRetentionAge(rule.KeepWithin); err != nil {
+				return fmt.Errorf("retention rule %s: %s", rule.Pattern, err)
+			}
+		}
+
+		if rule.KeepLast == 0 && rule.KeepWithin == "" {
+			return fmt.Errorf("retention rule %s: either keepLast or keepWithin should be specified", rule.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// PlanPrune applies retention rules to snapshots in the collection
+//
+// Each snapshot is matched by the first rule with matching pattern, snapshots
+// which don't match any rule are not included in the result. Snapshots which
+// are protected, published or used as source of a snapshot which is not pruned are always kept.
+// Decisions are sorted by snapshot creation time, most recent first.
+func (collection *SnapshotCollection) PlanPrune(rules []utils.SnapshotRetentionRule, publishedCollection *PublishedRepoCollection,
+	now time.Time) ([]*SnapshotPruneDecision, error) {
+	err := ValidateRetentionRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		all       []*Snapshot
+		decisions []*SnapshotPruneDecision
+	)
+	kept := make(map[int]int)
+
+	err = collection.ForEachSorted("time", func(snapshot *Snapshot) error {
+		all = append(all, snapshot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// most recent first
+	for i := len(all) - 1; i >= 0; i-- {
+		snapshot := all[i]
+
+		for ruleIdx, rule := range rules {
+			if matched, _ := filepath.Match(rule.Pattern, snapshot.Name); !matched {
+				continue
+			}
+
+			decision := &SnapshotPruneDecision{
+				Name:      snapshot.Name,
+				Rule:      rule.Pattern,
+				CreatedAt: snapshot.CreatedAt,
+				Prune:     true,
+				Reason:    "expired",
+				snapshot:  snapshot,
+			}
+
+			if rule.KeepWithin != "" {
+				age, _ := ParseRetentionAge(rule.KeepWithin)
+				if now.Sub(snapshot.CreatedAt) < age {
+					decision.Prune = false
+					decision.Reason = fmt.Sprintf("younger than %s", rule.KeepWithin)
+				}
+			}
+
+			if decision.Prune && kept[ruleIdx] < rule.KeepLast {
+				decision.Prune = false
+				decision.Reason = fmt.Sprintf("one of %d most recent", rule.KeepLast)
+			}
+
+			if !decision.Prune {
+				kept[ruleIdx]++
+			}
+
+			decisions = append(decisions, decision)
+			break
+		}
+	}
+
+	// never drop snapshots which are still in use
+	pruned := make(map[string]bool)
+	for _, decision := range decisions {
+		if !decision.Prune {
+			continue
+		}
+
+		if decision.snapshot.Protected {
+			decision.Prune = false
+			decision.Reason = "protected"
+			continue
+		}
+
+		if published := publishedCollection.BySnapshot(decision.snapshot); len(published) > 0 {
+			decision.Prune = false
+			decision.Reason = fmt.Sprintf("published at %s", published[0].GetPath())
+			continue
+		}
+
+		pruned[decision.snapshot.UUID] = true
+	}
+
+	// snapshots using each snapshot as a source, same as BySnapshotSource,
+	// but without going through the database for every snapshot
+	users := make(map[string][]*Snapshot)
+	for _, snapshot := range all {
+		if snapshot.SourceKind == SourceSnapshot {
+			for _, sourceID := range snapshot.SourceIDs {
+				users[sourceID] = append(users[sourceID], snapshot)
+			}
+		}
+	}
+
+	// snapshot used as source by a snapshot which is kept is kept as well,
+	// repeat until nothing changes
+	for changed := true; changed; {
+		changed = false
+
+		for _, decision := range decisions {
+			if !decision.Prune {
+				continue
+			}
+
+			for _, user := range users[decision.snapshot.UUID] {
+				if !pruned[user.UUID] {
+					decision.Prune = false
+					decision.Reason = fmt.Sprintf("source of snapshot %s", user.Name)
+					delete(pruned, decision.snapshot.UUID)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	return decisions, nil
+}
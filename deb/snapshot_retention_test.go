@@ -0,0 +1,142 @@
+package deb
+
+import (
+	"time"
+
+	"github.com/aptly-dev/aptly/database"
+	"github.com/aptly-dev/aptly/database/goleveldb"
+	"github.com/aptly-dev/aptly/utils"
+
+	. "gopkg.in/check.v1"
+)
+
+type SnapshotRetentionSuite struct {
+	db                  database.Storage
+	factory             *CollectionFactory
+	collection          *SnapshotCollection
+	publishedCollection *PublishedRepoCollection
+	now                 time.Time
+}
+
+var _ = Suite(&SnapshotRetentionSuite{})
+
+func (s *SnapshotRetentionSuite) SetUpTest(c *C) {
+	s.db, _ = goleveldb.NewOpenDB(c.MkDir())
+	s.factory = NewCollectionFactory(s.db)
+	s.collection = s.factory.SnapshotCollection()
+	s.publishedCollection = s.factory.PublishedRepoCollection()
+	s.now = time.Date(2024, 6, 30, 12, 0, 0, 0, time.UTC)
+}
+
+func (s *SnapshotRetentionSuite) TearDownTest(c *C) {
+	_ = s.db.Close()
+}
+
+func (s *SnapshotRetentionSuite) addSnapshot(c *C, name string, age time.Duration, sources ...*Snapshot) *Snapshot {
+	snapshot := NewSnapshotFromRefList(name, sources, NewPackageRefList(), "")
+	snapshot.CreatedAt = s.now.Add(-age)
+	c.Assert(s.collection.Add(snapshot), IsNil)
+	return snapshot
+}
+
+func decisionsMap(decisions []*SnapshotPruneDecision) map[string]string {
+	result := make(map[string]string)
+	for _, decision := range decisions {
+		if decision.Prune {
+			result[decision.Name] = "drop: " + decision.Reason
+		} else {
+			result[decision.Name] = "keep: " + decision.Reason
+		}
+	}
+	return result
+}
+
+func (s *SnapshotRetentionSuite) TestParseRetentionAge(c *C) {
+	age, err := ParseRetentionAge("30d")
+	c.Check(err, IsNil)
+	c.Check(age, Equals, 30*24*time.Hour)
+
+	age, err = ParseRetentionAge("2w")
+	c.Check(err, IsNil)
+	c.Check(age, Equals, 14*24*time.Hour)
+
+	age, err = ParseRetentionAge("36h")
+	c.Check(err, IsNil)
+	c.Check(age, Equals, 36*time.Hour)
+
+	_, err = ParseRetentionAge("xd")
+	c.Check(err, ErrorMatches, "invalid age \"xd\"")
+
+	_, err = ParseRetentionAge("-1h")
+	c.Check(err, ErrorMatches, "invalid age \"-1h\"")
+}
+
+func (s *SnapshotRetentionSuite) TestValidateRetentionRules(c *C) {
+	c.Check(ValidateRetentionRules([]utils.SnapshotRetentionRule{{Pattern: "nightly-*", KeepLast: 3}}), IsNil)
+	c.Check(ValidateRetentionRules([]utils.SnapshotRetentionRule{{KeepLast: 3}}), ErrorMatches, "retention rule should have pattern")
+	c.Check(ValidateRetentionRules([]utils.SnapshotRetentionRule{{Pattern: "[", KeepLast: 3}}), ErrorMatches, ".*invalid pattern.*")
+	c.Check(ValidateRetentionRules([]utils.SnapshotRetentionRule{{Pattern: "a", KeepLast: -1}}), ErrorMatches, ".*should not be negative")
+	c.Check(ValidateRetentionRules([]utils.SnapshotRetentionRule{{Pattern: "a", KeepWithin: "1y"}}), ErrorMatches, ".*invalid age.*")
+	c.Check(ValidateRetentionRules([]utils.SnapshotRetentionRule{{Pattern: "a"}}), ErrorMatches, ".*either keepLast or keepWithin should be specified")
+}
+
+func (s *SnapshotRetentionSuite) TestPlanPrune(c *C) {
+	day := 24 * time.Hour
+
+	s.addSnapshot(c, "nightly-1", 1*day)
+	s.addSnapshot(c, "nightly-2", 2*day)
+	s.addSnapshot(c, "nightly-3", 3*day)
+	published := s.addSnapshot(c, "nightly-4", 4*day)
+	source := s.addSnapshot(c, "nightly-5", 5*day)
+	chained := s.addSnapshot(c, "nightly-6", 6*day)
+	s.addSnapshot(c, "nightly-7", 7*day)
+	protected := s.addSnapshot(c, "nightly-8", 8*day)
+	s.addSnapshot(c, "merged-1", 1*day, source)
+	s.addSnapshot(c, "merged-2", 20*day, chained)
+	s.addSnapshot(c, "release", 100*day)
+
+	protected.Protected = true
+	c.Assert(s.collection.Update(protected), IsNil)
+
+	repo, err := NewPublishedRepo("", "", "stable", []string{"amd64"}, []string{"main"}, []interface{}{published}, s.factory, false)
+	c.Assert(err, IsNil)
+	c.Assert(s.publishedCollection.Add(repo), IsNil)
+
+	rules := []utils.SnapshotRetentionRule{
+		{Pattern: "nightly-*", KeepLast: 2},
+		{Pattern: "merged-*", KeepWithin: "7d"},
+	}
+
+	decisions, err := s.collection.PlanPrune(rules, s.publishedCollection, s.now)
+	c.Assert(err, IsNil)
+	c.Check(decisionsMap(decisions), DeepEquals, map[string]string{
+		"nightly-1": "keep: one of 2 most recent",
+		"nightly-2": "keep: one of 2 most recent",
+		"nightly-3": "drop: expired",
+		"nightly-4": "keep: published at ./stable",
+		"nightly-5": "keep: source of snapshot merged-1",
+		"nightly-6": "drop: expired",
+		"nightly-7": "drop: expired",
+		"nightly-8": "keep: protected",
+		"merged-1":  "keep: younger than 7d",
+		"merged-2":  "drop: expired",
+	})
+
+	// most recent first
+	c.Check(decisions[0].Name, Matches, "nightly-1|merged-1")
+	c.Check(decisions[len(decisions)-1].Name, Equals, "merged-2")
+	c.Check(decisions[len(decisions)-1].Snapshot().UUID, Not(Equals), "")
+
+	// first matching rule wins
+	decisions, err = s.collection.PlanPrune([]utils.SnapshotRetentionRule{
+		{Pattern: "nightly-[12]", KeepWithin: "1h"},
+		{Pattern: "nightly-*", KeepLast: 10},
+	}, s.publishedCollection, s.now)
+	c.Assert(err, IsNil)
+	result := decisionsMap(decisions)
+	c.Check(result["nightly-1"], Equals, "drop: expired")
+	c.Check(result["nightly-3"], Equals, "keep: one of 10 most recent")
+
+	_, err = s.collection.PlanPrune([]utils.SnapshotRetentionRule{{Pattern: "*"}}, s.publishedCollection, s.now)
+	c.Check(err, ErrorMatches, ".*either keepLast or keepWithin should be specified")
+}
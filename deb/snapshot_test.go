@@ -157,6 +157,27 @@ func (s *SnapshotSuite) TestEncodeDecodeAppStream(c *C) {
 	c.Check(decoded.AppStreamFiles, DeepEquals, snapshot.AppStreamFiles)
 }
 
+func (s *SnapshotSuite) TestEncodeDecodeLabels(c *C) {
+	snapshot, _ := NewSnapshotFromRepository("snap-labels", s.repo)
+	snapshot.Labels = map[string]string{"env": "prod"}
+
+	decoded := &Snapshot{}
+	c.Assert(decoded.Decode(snapshot.Encode()), IsNil)
+	c.Check(decoded.Labels, DeepEquals, snapshot.Labels)
+}
+
+func (s *SnapshotSuite) TestProtected(c *C) {
+	snapshot, _ := NewSnapshotFromRepository("snap-protected", s.repo)
+	c.Check(snapshot.CheckUnprotected(), IsNil)
+
+	snapshot.Protected = true
+	c.Check(snapshot.CheckUnprotected(), ErrorMatches, "snapshot snap-protected is protected, unprotect it first")
+
+	decoded := &Snapshot{}
+	c.Assert(decoded.Decode(snapshot.Encode()), IsNil)
+	c.Check(decoded.Protected, Equals, true)
+}
+
 type SnapshotCollectionSuite struct {
 	PackageListMixinSuite
 	db                   database.Storage
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/DisposaBoy/JsonConfigReader"
 	"github.com/aptly-dev/aptly/pgp"
@@ -11,13 +13,39 @@ import (
 )
 
 // UploadersRule is single rule of format: what packages can group or key upload
+//
+// Rule applies to the upload if condition matches .changes file, source package
+// matches one of Sources patterns and every target distribution matches one of
+// Distributions patterns (empty condition or list matches anything).
 type UploadersRule struct {
-	Condition         string       `json:"condition"`
-	Allow             []string     `json:"allow"`
-	Deny              []string     `json:"deny"`
+	Condition string   `json:"condition"`
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+	// Glob patterns of source package names
+	Sources []string `json:"sources,omitempty"`
+	// Glob patterns of target distributions (Distribution field of .changes file)
+	Distributions []string `json:"distributions,omitempty"`
+	// Deny uploads with version lower than version of the source already in the repository
+	NoDowngrade bool `json:"noDowngrade,omitempty"`
+	// Deny uploads changing epoch of the source already in the repository
+	NoEpochChange bool `json:"noEpochChange,omitempty"`
+	// Maximum total size of files in the upload, in bytes
+	MaxSize int64 `json:"maxSize,omitempty"`
+
 	CompiledCondition PackageQuery `json:"-" codec:"-"`
 }
 
+// UploadersDecision explains result of uploaders rules evaluation
+type UploadersDecision struct {
+	Allowed bool
+	// Index of the rule which made the decision, -1 if no rule matched
+	Rule int
+	// Reason of the decision
+	Reason string
+	// Result of evaluation of each rule checked, in order
+	Trace []string
+}
+
 func (u UploadersRule) String() string {
 	b, _ := json.Marshal(u)
 	return string(b)
@@ -79,29 +107,189 @@ func (u *Uploaders) ExpandGroups(items []string) []string {
 	return utils.StrSliceDeduplicate(result)
 }
 
+// Compile parses conditions of all the rules
+func (u *Uploaders) Compile(parseQuery func(string) (PackageQuery, error)) error {
+	for i := range u.Rules {
+		rule := &u.Rules[i]
+
+		if rule.Condition == "" {
+			rule.CompiledCondition = nil
+			continue
+		}
+
+		var err error
+		rule.CompiledCondition, err = parseQuery(rule.Condition)
+		if err != nil {
+			return fmt.Errorf("error parsing query %s: %s", rule.Condition, err)
+		}
+	}
+
+	return nil
+}
+
 // IsAllowed checks whether listed keys are allowed to upload given .changes file
 func (u *Uploaders) IsAllowed(changes *Changes) error {
-	for _, rule := range u.Rules {
-		if rule.CompiledCondition.Matches(changes) {
-			deny := u.ExpandGroups(rule.Deny)
-			for _, key := range changes.SignatureKeys {
-				for _, item := range deny {
-					if item == "*" || key.Matches(pgp.Key(item)) {
-						return fmt.Errorf("denied according to rule: %s", rule)
-					}
+	return u.IsAllowedTo(changes, nil)
+}
+
+// IsAllowedTo checks whether listed keys are allowed to upload given .changes file into repository
+// with the list of packages (nil if unknown, version restrictions are not checked in that case)
+func (u *Uploaders) IsAllowedTo(changes *Changes, current *PackageList) error {
+	decision := u.Check(changes, current)
+	if decision.Allowed {
+		return nil
+	}
+
+	return fmt.Errorf("%s", decision.Reason)
+}
+
+// matchesAny checks whether value matches any of glob patterns
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, value); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches checks whether rule applies to the upload, returning explanation if it doesn't
+func (rule *UploadersRule) matches(changes *Changes) (bool, string) {
+	if rule.CompiledCondition != nil && !rule.CompiledCondition.Matches(changes) {
+		return false, fmt.Sprintf("condition %s doesn't match", rule.Condition)
+	}
+
+	if len(rule.Sources) > 0 && !matchesAny(rule.Sources, changes.Source) {
+		return false, fmt.Sprintf("source %s doesn't match %s", changes.Source, strings.Join(rule.Sources, ", "))
+	}
+
+	if len(rule.Distributions) > 0 {
+		for _, distribution := range strings.Fields(changes.Distribution) {
+			if !matchesAny(rule.Distributions, distribution) {
+				return false, fmt.Sprintf("distribution %s doesn't match %s", distribution, strings.Join(rule.Distributions, ", "))
+			}
+		}
+	}
+
+	return true, ""
+}
+
+// currentSourceVersion returns highest version of the source package in the list, "" if not found
+func currentSourceVersion(list *PackageList, source string) string {
+	result := ""
+
+	_ = list.ForEach(func(p *Package) error {
+		var name, version string
+		if p.IsSource {
+			name, version = p.Name, p.Version
+		} else {
+			name, version = p.GetField("$Source"), p.GetField("$SourceVersion")
+		}
+
+		if name == source && (result == "" || CompareVersions(version, result) > 0) {
+			result = version
+		}
+		return nil
+	})
+
+	return result
+}
+
+// checkRestrictions checks upload restrictions of the rule, returning explanation of violation
+func (rule *UploadersRule) checkRestrictions(changes *Changes, current *PackageList) string {
+	if rule.MaxSize > 0 {
+		var size int64
+		for _, file := range changes.Files {
+			size += file.Checksums.Size
+		}
+
+		if size > rule.MaxSize {
+			return fmt.Sprintf("upload size %d exceeds limit %d", size, rule.MaxSize)
+		}
+	}
+
+	if (rule.NoDowngrade || rule.NoEpochChange) && current != nil {
+		version := changes.Stanza["Version"]
+		existing := currentSourceVersion(current, changes.Source)
+
+		if existing != "" && version != "" {
+			if rule.NoDowngrade && CompareVersions(version, existing) < 0 {
+				return fmt.Sprintf("version %s is lower than version %s in the repository", version, existing)
+			}
+
+			if rule.NoEpochChange {
+				epoch, _, _ := parseVersion(version)
+				existingEpoch, _, _ := parseVersion(existing)
+				if epoch == "" {
+					epoch = "0"
+				}
+				if existingEpoch == "" {
+					existingEpoch = "0"
+				}
+
+				if epoch != existingEpoch {
+					return fmt.Sprintf("epoch of version %s differs from version %s in the repository", version, existing)
 				}
 			}
+		}
+	}
+
+	return ""
+}
 
-			allow := u.ExpandGroups(rule.Allow)
-			for _, key := range changes.SignatureKeys {
-				for _, item := range allow {
-					if item == "*" || key.Matches(pgp.Key(item)) {
-						return nil
+// Check evaluates rules for the upload into repository with the list of packages (nil if unknown)
+//
+// Rules are checked in order: first matching rule which denies any of the signature keys
+// denies the upload, first matching rule which allows any of the keys allows the upload
+// if restrictions of the rule are satisfied (and denies it otherwise).
+func (u *Uploaders) Check(changes *Changes, current *PackageList) *UploadersDecision {
+	decision := &UploadersDecision{Rule: -1}
+
+	for i := range u.Rules {
+		rule := &u.Rules[i]
+		prefix := fmt.Sprintf("rule #%d", i+1)
+
+		if ok, explanation := rule.matches(changes); !ok {
+			decision.Trace = append(decision.Trace, fmt.Sprintf("%s: skipped, %s", prefix, explanation))
+			continue
+		}
+
+		deny := u.ExpandGroups(rule.Deny)
+		for _, key := range changes.SignatureKeys {
+			for _, item := range deny {
+				if item == "*" || key.Matches(pgp.Key(item)) {
+					decision.Rule = i
+					decision.Reason = fmt.Sprintf("denied according to rule: %s", rule)
+					decision.Trace = append(decision.Trace, fmt.Sprintf("%s: key %s denied", prefix, key))
+					return decision
+				}
+			}
+		}
+
+		allow := u.ExpandGroups(rule.Allow)
+		for _, key := range changes.SignatureKeys {
+			for _, item := range allow {
+				if item == "*" || key.Matches(pgp.Key(item)) {
+					decision.Rule = i
+
+					if violation := rule.checkRestrictions(changes, current); violation != "" {
+						decision.Reason = fmt.Sprintf("denied according to rule: %s: %s", rule, violation)
+						decision.Trace = append(decision.Trace, fmt.Sprintf("%s: key %s allowed, but %s", prefix, key, violation))
+						return decision
 					}
+
+					decision.Allowed = true
+					decision.Reason = fmt.Sprintf("allowed according to rule: %s", rule)
+					decision.Trace = append(decision.Trace, fmt.Sprintf("%s: key %s allowed", prefix, key))
+					return decision
 				}
 			}
 		}
+
+		decision.Trace = append(decision.Trace, fmt.Sprintf("%s: matches, but none of the keys is allowed or denied", prefix))
 	}
 
-	return fmt.Errorf("denied as no rule matches")
+	decision.Reason = "denied as no rule matches"
+	return decision
 }
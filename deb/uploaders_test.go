@@ -2,6 +2,7 @@ package deb
 
 import (
 	"github.com/aptly-dev/aptly/pgp"
+	"github.com/aptly-dev/aptly/utils"
 	. "gopkg.in/check.v1"
 )
 
@@ -79,3 +80,110 @@ func (s *UploadersSuite) TestIsAllowed(c *C) {
 	c.Check(u.IsAllowed(&Changes{SignatureKeys: []pgp.Key{"ABCD1234", "45678901"}, Stanza: Stanza{"Source": "some-calamares"}}),
 		ErrorMatches, "denied according to rule: {\"condition\":\"\",\"allow\":null,\"deny\":\\[\"45678901\",\"12345678\"\\]}")
 }
+
+func (s *UploadersSuite) TestCompile(c *C) {
+	u := &Uploaders{
+		Rules: []UploadersRule{
+			{Condition: "", Allow: []string{"*"}},
+			{Condition: "Source (calamares)", Allow: []string{"*"}},
+		},
+	}
+
+	c.Check(u.Compile(func(q string) (PackageQuery, error) {
+		return &FieldQuery{Field: "Source", Relation: VersionEqual, Value: "calamares"}, nil
+	}), IsNil)
+	c.Check(u.Rules[0].CompiledCondition, IsNil)
+	c.Check(u.Rules[1].CompiledCondition, NotNil)
+
+	c.Check(u.IsAllowed(&Changes{SignatureKeys: []pgp.Key{"ABCD1234"}, Stanza: Stanza{"Source": "other"}}), IsNil)
+}
+
+func (s *UploadersSuite) TestSourcesAndDistributions(c *C) {
+	u := &Uploaders{
+		Rules: []UploadersRule{
+			{
+				Sources:       []string{"calamares", "lib*"},
+				Distributions: []string{"unstable", "experimental"},
+				Allow:         []string{"12345678"},
+			},
+			{
+				Sources: []string{"hardlink"},
+				Allow:   []string{"45678901"},
+			},
+		},
+	}
+
+	changes := func(source, distribution string, key pgp.Key) *Changes {
+		return &Changes{Source: source, Distribution: distribution, SignatureKeys: []pgp.Key{key}, Stanza: Stanza{"Source": source}}
+	}
+
+	c.Check(u.IsAllowed(changes("calamares", "unstable", "12345678")), IsNil)
+	c.Check(u.IsAllowed(changes("libfoo", "unstable experimental", "12345678")), IsNil)
+	c.Check(u.IsAllowed(changes("libfoo", "unstable stable", "12345678")), ErrorMatches, "denied as no rule matches")
+	c.Check(u.IsAllowed(changes("hardlink", "unstable", "12345678")), ErrorMatches, "denied as no rule matches")
+	c.Check(u.IsAllowed(changes("hardlink", "stable", "45678901")), IsNil)
+	c.Check(u.IsAllowed(changes("calamares", "unstable", "45678901")), ErrorMatches, "denied as no rule matches")
+
+	decision := u.Check(changes("libfoo", "stable", "45678901"), nil)
+	c.Check(decision.Allowed, Equals, false)
+	c.Check(decision.Rule, Equals, -1)
+	c.Check(decision.Trace, DeepEquals, []string{
+		"rule #1: skipped, distribution stable doesn't match unstable, experimental",
+		"rule #2: skipped, source libfoo doesn't match hardlink",
+	})
+
+	decision = u.Check(changes("hardlink", "stable", "45678901"), nil)
+	c.Check(decision.Allowed, Equals, true)
+	c.Check(decision.Rule, Equals, 1)
+	c.Check(decision.Trace, DeepEquals, []string{
+		"rule #1: skipped, source hardlink doesn't match calamares, lib*",
+		"rule #2: key 45678901 allowed",
+	})
+}
+
+func (s *UploadersSuite) TestRestrictions(c *C) {
+	u := &Uploaders{
+		Rules: []UploadersRule{
+			{
+				Sources:       []string{"hardlink"},
+				Allow:         []string{"*"},
+				NoDowngrade:   true,
+				NoEpochChange: true,
+				MaxSize:       1000,
+			},
+		},
+	}
+
+	list := NewPackageList()
+	c.Assert(list.Add(&Package{Name: "hardlink", Version: "1:0.2.1", Architecture: "source", IsSource: true}), IsNil)
+
+	changes := func(version string, size int64) *Changes {
+		return &Changes{
+			Source:        "hardlink",
+			SignatureKeys: []pgp.Key{"12345678"},
+			Stanza:        Stanza{"Source": "hardlink", "Version": version},
+			Files:         PackageFiles{{Filename: "hardlink.dsc", Checksums: utils.ChecksumInfo{Size: size}}},
+		}
+	}
+
+	c.Check(u.IsAllowedTo(changes("1:0.2.2", 100), list), IsNil)
+	c.Check(u.IsAllowedTo(changes("1:0.2.1", 100), list), IsNil)
+	c.Check(u.IsAllowedTo(changes("1:0.2.2", 1001), list), ErrorMatches, "denied according to rule: .*: upload size 1001 exceeds limit 1000")
+	c.Check(u.IsAllowedTo(changes("1:0.2.0", 100), list), ErrorMatches,
+		"denied according to rule: .*: version 1:0.2.0 is lower than version 1:0.2.1 in the repository")
+	c.Check(u.IsAllowedTo(changes("2:0.1", 100), list), ErrorMatches,
+		"denied according to rule: .*: epoch of version 2:0.1 differs from version 1:0.2.1 in the repository")
+
+	// versions are not checked without repository contents
+	c.Check(u.IsAllowedTo(changes("1:0.2.0", 100), nil), IsNil)
+
+	// new source package
+	c.Check(u.IsAllowedTo(changes("0.1", 100), NewPackageList()), IsNil)
+
+	decision := u.Check(changes("1:0.2.0", 100), list)
+	c.Check(decision.Allowed, Equals, false)
+	c.Check(decision.Rule, Equals, 0)
+	c.Check(decision.Trace, DeepEquals, []string{
+		"rule #1: key 12345678 allowed, but version 1:0.2.0 is lower than version 1:0.2.1 in the repository",
+	})
+}
@@ -0,0 +1,247 @@
+package deb
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/utils"
+)
+
+// Kinds of package file problems found by VerifyPackageFiles
+const (
+	FileMissing = "missing"
+	FileCorrupt = "corrupt"
+)
+
+// FileProblem describes package file missing from the pool or not matching its checksums
+type FileProblem struct {
+	// Short key (architecture, name and version) of the package referencing the file
+	packageKey string
+	// Full name of the package referencing the file
+	Package string
+	// Name of the file
+	Filename string
+	// Path to the file in the package pool
+	PoolPath string
+	// Kind of the problem: missing or corrupt
+	Problem string
+	// Problem details (e.g. checksum mismatch)
+	Details string `json:",omitempty"`
+	// Was file successfully repaired?
+	Repaired bool
+	// Error encountered while repairing file
+	RepairError string `json:",omitempty"`
+}
+
+// VerifyReport is result of package files verification
+type VerifyReport struct {
+	// Number of verified packages and files
+	Packages int
+	Files    int
+	// Missing and corrupt files
+	Problems []FileProblem
+}
+
+// Unrepaired returns number of problems which are not repaired
+func (report *VerifyReport) Unrepaired() int {
+	result := 0
+	for _, problem := range report.Problems {
+		if !problem.Repaired {
+			result++
+		}
+	}
+
+	return result
+}
+
+// checksumsMismatch compares checksums which are present in both expected and actual
+func checksumsMismatch(expected, actual *utils.ChecksumInfo) string {
+	switch {
+	case expected.Size != actual.Size:
+		return fmt.Sprintf("size %d != %d", actual.Size, expected.Size)
+	case expected.MD5 != "" && expected.MD5 != actual.MD5:
+		return fmt.Sprintf("md5 %s != %s", actual.MD5, expected.MD5)
+	case expected.SHA1 != "" && expected.SHA1 != actual.SHA1:
+		return fmt.Sprintf("sha1 %s != %s", actual.SHA1, expected.SHA1)
+	case expected.SHA256 != "" && expected.SHA256 != actual.SHA256:
+		return fmt.Sprintf("sha256 %s != %s", actual.SHA256, expected.SHA256)
+	case expected.SHA512 != "" && expected.SHA512 != actual.SHA512:
+		return fmt.Sprintf("sha512 %s != %s", actual.SHA512, expected.SHA512)
+	}
+
+	return ""
+}
+
+// VerifyPackageFiles checks that all the files of packages in the list are present
+// in the package pool, recalculating checksums of pool files and comparing them
+// to package metadata and ChecksumStorage
+func VerifyPackageFiles(list *PackageList, packagePool aptly.PackagePool, checksumStorage aptly.ChecksumStorage,
+	progress aptly.Progress) (*VerifyReport, error) {
+	report := &VerifyReport{Packages: list.Len()}
+	seen := make(map[string]bool)
+
+	if progress != nil {
+		progress.InitBar(int64(list.Len()), false, aptly.BarMirrorVerifyFiles)
+		defer progress.ShutdownBar()
+	}
+
+	err := list.ForEach(func(p *Package) error {
+		if progress != nil {
+			progress.AddBar(1)
+		}
+
+		for _, f := range p.Files() {
+			poolPath, err := f.GetPoolPath(packagePool)
+			if err != nil {
+				return err
+			}
+
+			if seen[poolPath] {
+				continue
+			}
+			seen[poolPath] = true
+			report.Files++
+
+			problem := FileProblem{
+				packageKey: string(p.ShortKey("")),
+				Package:    p.String(),
+				Filename:   f.Filename,
+				PoolPath:   poolPath,
+			}
+
+			reader, err := packagePool.Open(poolPath)
+			if err != nil {
+				problem.Problem = FileMissing
+				if !os.IsNotExist(err) {
+					problem.Details = err.Error()
+				}
+				report.Problems = append(report.Problems, problem)
+				continue
+			}
+
+			actual, err := utils.ChecksumsForReader(reader)
+			_ = reader.Close()
+			if err != nil {
+				return fmt.Errorf("unable to read %s: %s", poolPath, err)
+			}
+
+			expected := f.Checksums
+			mismatch := checksumsMismatch(&expected, &actual)
+
+			if mismatch == "" {
+				var stored *utils.ChecksumInfo
+				stored, err = checksumStorage.Get(poolPath)
+				if err != nil {
+					return err
+				}
+				if stored != nil {
+					mismatch = checksumsMismatch(stored, &actual)
+				}
+			}
+
+			if mismatch != "" {
+				problem.Problem = FileCorrupt
+				problem.Details = mismatch
+				report.Problems = append(report.Problems, problem)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(report.Problems, func(i, j int) bool {
+		return report.Problems[i].PoolPath < report.Problems[j].PoolPath
+	})
+
+	return report, nil
+}
+
+// RepairFiles re-downloads missing and corrupt files found by VerifyPackageFiles from upstream
+//
+// Package indexes should be downloaded with DownloadPackageIndexes first, as they
+// provide location of the package files on the mirror. Problems are updated in place.
+func (repo *RemoteRepo) RepairFiles(report *VerifyReport, d aptly.Downloader, packagePool aptly.PackagePool,
+	checksumStorage aptly.ChecksumStorage, progress aptly.Progress) error {
+	if repo.packageList == nil {
+		return fmt.Errorf("package list is empty, please (re)download package indexes")
+	}
+
+	upstream := make(map[string]*Package, repo.packageList.Len())
+	_ = repo.packageList.ForEach(func(p *Package) error {
+		upstream[string(p.ShortKey(""))] = p
+		return nil
+	})
+
+	tempDir, err := os.MkdirTemp("", "aptly-repair-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(tempDir) }()
+
+	for i := range report.Problems {
+		problem := &report.Problems[i]
+
+		err = repo.repairFile(problem, upstream[problem.packageKey], d, packagePool, checksumStorage, tempDir)
+		if err != nil {
+			problem.RepairError = err.Error()
+			if progress != nil {
+				progress.ColoredPrintf("@y[!]@| @!unable to repair %s: %s@|", problem.PoolPath, err)
+			}
+			continue
+		}
+
+		problem.Repaired = true
+		if progress != nil {
+			progress.ColoredPrintf("@g[+]@| %s repaired", problem.PoolPath)
+		}
+	}
+
+	return nil
+}
+
+func (repo *RemoteRepo) repairFile(problem *FileProblem, p *Package, d aptly.Downloader, packagePool aptly.PackagePool,
+	checksumStorage aptly.ChecksumStorage, tempDir string) error {
+	if p == nil {
+		return fmt.Errorf("package %s is not available upstream anymore", problem.Package)
+	}
+
+	for _, f := range p.Files() {
+		if f.Filename != problem.Filename {
+			continue
+		}
+
+		tempPath := filepath.Join(tempDir, f.Filename)
+		checksums := f.Checksums
+
+		err := d.DownloadWithChecksum(gocontext.TODO(), repo.PackageURL(f.DownloadURL()).String(), tempPath, &checksums, false)
+		if err != nil {
+			return err
+		}
+
+		if problem.Problem == FileCorrupt {
+			if _, err = packagePool.Remove(problem.PoolPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		poolPath, err := packagePool.Import(tempPath, f.Filename, &checksums, true, checksumStorage)
+		if err != nil {
+			return err
+		}
+
+		if poolPath != problem.PoolPath {
+			return fmt.Errorf("file imported to unexpected location %s", poolPath)
+		}
+
+		return checksumStorage.Update(poolPath, &checksums)
+	}
+
+	return fmt.Errorf("file %s not found in upstream package %s", problem.Filename, problem.Package)
+}
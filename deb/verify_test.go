@@ -0,0 +1,92 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aptly-dev/aptly/aptly"
+	"github.com/aptly-dev/aptly/http"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RemoteRepoSuite) downloadIndexes(c *C) {
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.bz2", &http.Error{Code: 404})
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages.gz", &http.Error{Code: 404})
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/main/binary-i386/Packages", examplePackagesFile)
+
+	c.Assert(s.repo.DownloadPackageIndexes(s.progress, s.downloader, nil, s.collectionFactory, true, false), IsNil)
+}
+
+func (s *RemoteRepoSuite) TestVerifyAndRepairFiles(c *C) {
+	s.repo.Architectures = []string{"i386"}
+
+	c.Assert(s.repo.Fetch(s.downloader, nil, true), IsNil)
+	s.downloadIndexes(c)
+
+	queue, _, err := s.repo.BuildDownloadQueue(s.packagePool, s.collectionFactory.PackageCollection(), s.cs, false, false)
+	c.Assert(err, IsNil)
+	c.Assert(queue, HasLen, 1)
+
+	tmpPath := filepath.Join(c.MkDir(), queue[0].File.Filename)
+	c.Assert(os.WriteFile(tmpPath, []byte("xyz"), 0644), IsNil)
+	queue[0].File.PoolPath, err = s.packagePool.Import(tmpPath, queue[0].File.Filename, &queue[0].File.Checksums, false, s.cs)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.repo.FinalizeDownload(s.collectionFactory, nil), IsNil)
+
+	list, err := NewPackageListFromRefList(s.repo.RefList(), s.collectionFactory.PackageCollection(), nil)
+	c.Assert(err, IsNil)
+
+	report, err := VerifyPackageFiles(list, s.packagePool, s.cs, nil)
+	c.Assert(err, IsNil)
+	c.Check(report.Packages, Equals, 1)
+	c.Check(report.Files, Equals, 1)
+	c.Check(report.Problems, HasLen, 0)
+
+	poolPath := queue[0].File.PoolPath
+	fullPath := s.packagePool.(aptly.LocalPackagePool).FullPath(poolPath)
+
+	// corrupt file
+	c.Assert(os.WriteFile(fullPath, []byte("xya"), 0644), IsNil)
+
+	report, err = VerifyPackageFiles(list, s.packagePool, s.cs, nil)
+	c.Assert(err, IsNil)
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].Problem, Equals, FileCorrupt)
+	c.Check(report.Problems[0].PoolPath, Equals, poolPath)
+	c.Check(report.Problems[0].Details, Matches, "md5 .* != d16fb36f0911f878998c136191af705e")
+	c.Check(report.Unrepaired(), Equals, 1)
+
+	// repair requires package indexes
+	c.Check(s.repo.RepairFiles(report, s.downloader, s.packagePool, s.cs, nil), ErrorMatches, "package list is empty.*")
+
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/dists/squeeze/Release", exampleReleaseFile)
+	c.Assert(s.repo.Fetch(s.downloader, nil, true), IsNil)
+	s.downloadIndexes(c)
+	s.downloader.ExpectResponse("http://mirror.yandex.ru/debian/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb", "xyz")
+
+	c.Assert(s.repo.RepairFiles(report, s.downloader, s.packagePool, s.cs, nil), IsNil)
+	c.Check(s.downloader.Empty(), Equals, true)
+	c.Check(report.Problems[0].Repaired, Equals, true)
+	c.Check(report.Unrepaired(), Equals, 0)
+
+	report, err = VerifyPackageFiles(list, s.packagePool, s.cs, nil)
+	c.Assert(err, IsNil)
+	c.Check(report.Problems, HasLen, 0)
+
+	// missing file, which can't be downloaded
+	c.Assert(os.Remove(fullPath), IsNil)
+
+	report, err = VerifyPackageFiles(list, s.packagePool, s.cs, nil)
+	c.Assert(err, IsNil)
+	c.Assert(report.Problems, HasLen, 1)
+	c.Check(report.Problems[0].Problem, Equals, FileMissing)
+
+	s.downloader.ExpectError("http://mirror.yandex.ru/debian/pool/main/a/amanda/amanda-client_3.3.1-3~bpo60+1_amd64.deb", &http.Error{Code: 404})
+
+	c.Assert(s.repo.RepairFiles(report, s.downloader, s.packagePool, s.cs, nil), IsNil)
+	c.Check(report.Problems[0].Repaired, Equals, false)
+	c.Check(report.Problems[0].RepairError, Matches, "HTTP code 404.*")
+	c.Check(report.Unrepaired(), Equals, 1)
+}
@@ -0,0 +1,475 @@
+package deb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Vulnerability tracker formats
+const (
+	TrackerFormatDebian = "debian"
+	TrackerFormatOVAL   = "oval"
+)
+
+// VulnerabilityRule describes which versions of the package are affected by vulnerability
+type VulnerabilityRule struct {
+	// Vulnerability ID, e.g. CVE-2024-1234
+	ID       string
+	Severity string `json:",omitempty"`
+	// Versions lower than FixedVersion are affected, empty if vulnerability is not fixed yet
+	FixedVersion string `json:",omitempty"`
+	Description  string `json:",omitempty"`
+}
+
+// VulnerabilityTracker is database of known vulnerabilities loaded from security tracker data
+type VulnerabilityTracker struct {
+	// One of TrackerFormatDebian or TrackerFormatOVAL
+	Format string
+	// Rules by package name: source package names for Debian Security Tracker,
+	// binary package names for OVAL
+	rules map[string][]VulnerabilityRule
+}
+
+// VulnerablePackage is package affected by one or more vulnerabilities
+type VulnerablePackage struct {
+	// Package full name
+	Package       string
+	Name          string
+	Version       string
+	Architecture  string
+	Source        string
+	SourceVersion string
+	// Vulnerabilities affecting the package, sorted by ID
+	Vulnerabilities []VulnerabilityRule
+}
+
+// VulnerabilityChange is vulnerability fixed (or introduced) between two package lists
+type VulnerabilityChange struct {
+	ID           string
+	Severity     string `json:",omitempty"`
+	Name         string
+	Architecture string
+	// Version of package in old list, empty if package is missing
+	OldVersion string `json:",omitempty"`
+	// Version of package in new list, empty if package is missing
+	NewVersion string `json:",omitempty"`
+}
+
+// VulnerabilityDiff lists changes in vulnerabilities between two package lists
+type VulnerabilityDiff struct {
+	// Vulnerabilities affecting old list, but not new list
+	Fixed []VulnerabilityChange
+	// Vulnerabilities affecting new list, but not old list
+	Introduced []VulnerabilityChange
+}
+
+// LoadVulnerabilityTracker loads Debian Security Tracker JSON or Ubuntu OVAL (XML) file,
+// format is detected automatically
+//
+// For Debian Security Tracker, release (e.g. bookworm) should be specified, as fixed
+// versions are tracked per release.
+func LoadVulnerabilityTracker(filename, release string) (*VulnerabilityTracker, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	reader := bufio.NewReader(file)
+
+	// skip UTF-8 BOM and leading whitespace to detect format
+	if bom, _ := reader.Peek(3); bytes.Equal(bom, []byte{0xef, 0xbb, 0xbf}) {
+		_, _ = reader.Discard(3)
+	}
+
+	var first byte
+	for {
+		first, err = reader.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %s", filename, err)
+		}
+		if first != ' ' && first != '\t' && first != '\r' && first != '\n' {
+			break
+		}
+	}
+	_ = reader.UnreadByte()
+
+	var tracker *VulnerabilityTracker
+
+	switch first {
+	case '{':
+		if release == "" {
+			return nil, fmt.Errorf("release should be specified for Debian Security Tracker data")
+		}
+		tracker, err = ParseDebianSecurityTracker(reader, release)
+	case '<':
+		tracker, err = ParseOVAL(reader)
+	default:
+		return nil, fmt.Errorf("unable to parse %s: unknown format, expected Debian Security Tracker JSON or OVAL", filename)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", filename, err)
+	}
+
+	return tracker, nil
+}
+
+type debianTrackerRelease struct {
+	Status       string `json:"status"`
+	FixedVersion string `json:"fixed_version"`
+	Urgency      string `json:"urgency"`
+}
+
+type debianTrackerIssue struct {
+	Description string                          `json:"description"`
+	Releases    map[string]debianTrackerRelease `json:"releases"`
+}
+
+// ParseDebianSecurityTracker parses Debian Security Tracker JSON data (as served at
+// https://security-tracker.debian.org/tracker/data/json) for the release
+func ParseDebianSecurityTracker(r io.Reader, release string) (*VulnerabilityTracker, error) {
+	var data map[string]map[string]debianTrackerIssue
+
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	tracker := &VulnerabilityTracker{Format: TrackerFormatDebian, rules: map[string][]VulnerabilityRule{}}
+
+	for source, issues := range data {
+		for id, issue := range issues {
+			info, ok := issue.Releases[release]
+			if !ok {
+				continue
+			}
+
+			rule := VulnerabilityRule{
+				ID:          id,
+				Severity:    strings.TrimRight(info.Urgency, "*"),
+				Description: issue.Description,
+			}
+
+			switch info.Status {
+			case "resolved":
+				// fixed version 0 means that release was never affected
+				if info.FixedVersion == "" || info.FixedVersion == "0" {
+					continue
+				}
+				rule.FixedVersion = info.FixedVersion
+			case "open", "undetermined":
+			default:
+				continue
+			}
+
+			tracker.rules[source] = append(tracker.rules[source], rule)
+		}
+	}
+
+	return tracker, nil
+}
+
+type ovalCriteria struct {
+	Criteria  []ovalCriteria `xml:"criteria"`
+	Criterion []struct {
+		TestRef string `xml:"test_ref,attr"`
+	} `xml:"criterion"`
+}
+
+type ovalDefinitions struct {
+	Definitions []struct {
+		ID         string `xml:"id,attr"`
+		Title      string `xml:"metadata>title"`
+		Severity   string `xml:"metadata>advisory>severity"`
+		References []struct {
+			Source string `xml:"source,attr"`
+			RefID  string `xml:"ref_id,attr"`
+		} `xml:"metadata>reference"`
+		Criteria ovalCriteria `xml:"criteria"`
+	} `xml:"definitions>definition"`
+	Tests []struct {
+		ID     string `xml:"id,attr"`
+		Object struct {
+			Ref string `xml:"object_ref,attr"`
+		} `xml:"object"`
+		State struct {
+			Ref string `xml:"state_ref,attr"`
+		} `xml:"state"`
+	} `xml:"tests>dpkginfo_test"`
+	Objects []struct {
+		ID   string `xml:"id,attr"`
+		Name struct {
+			VarRef string `xml:"var_ref,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"name"`
+	} `xml:"objects>dpkginfo_object"`
+	States []struct {
+		ID  string `xml:"id,attr"`
+		EVR struct {
+			Operation string `xml:"operation,attr"`
+			Value     string `xml:",chardata"`
+		} `xml:"evr"`
+	} `xml:"states>dpkginfo_state"`
+	Variables []struct {
+		ID     string   `xml:"id,attr"`
+		Values []string `xml:"value"`
+	} `xml:"variables>constant_variable"`
+}
+
+func (criteria *ovalCriteria) testRefs() []string {
+	var result []string
+	for _, criterion := range criteria.Criterion {
+		result = append(result, criterion.TestRef)
+	}
+	for i := range criteria.Criteria {
+		result = append(result, criteria.Criteria[i].testRefs()...)
+	}
+	return result
+}
+
+// ParseOVAL parses OVAL definitions (as published by Ubuntu), only dpkginfo tests
+// are taken into account
+func ParseOVAL(r io.Reader) (*VulnerabilityTracker, error) {
+	var data ovalDefinitions
+
+	if err := xml.NewDecoder(r).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	variables := map[string][]string{}
+	for _, variable := range data.Variables {
+		variables[variable.ID] = variable.Values
+	}
+
+	objects := map[string][]string{}
+	for _, object := range data.Objects {
+		if object.Name.VarRef != "" {
+			objects[object.ID] = variables[object.Name.VarRef]
+		} else {
+			objects[object.ID] = []string{strings.TrimSpace(object.Name.Value)}
+		}
+	}
+
+	states := map[string]string{}
+	for _, state := range data.States {
+		if state.EVR.Operation == "less than" {
+			fixed := strings.TrimSpace(state.EVR.Value)
+			states[state.ID] = strings.TrimPrefix(fixed, "0:")
+		}
+	}
+
+	// for each test: names of packages and fixed version
+	testPackages := map[string][]string{}
+	testFixed := map[string]string{}
+	for _, test := range data.Tests {
+		packages, ok := objects[test.Object.Ref]
+		if !ok {
+			continue
+		}
+		testPackages[test.ID] = packages
+		testFixed[test.ID] = states[test.State.Ref]
+	}
+
+	tracker := &VulnerabilityTracker{Format: TrackerFormatOVAL, rules: map[string][]VulnerabilityRule{}}
+
+	for _, definition := range data.Definitions {
+		var ids []string
+		for _, reference := range definition.References {
+			if reference.Source == "CVE" && reference.RefID != "" {
+				ids = append(ids, reference.RefID)
+			}
+		}
+		if len(ids) == 0 {
+			ids = []string{definition.ID}
+		}
+
+		for _, ref := range definition.Criteria.testRefs() {
+			packages, ok := testPackages[ref]
+			if !ok {
+				continue
+			}
+
+			for _, id := range ids {
+				rule := VulnerabilityRule{
+					ID:           id,
+					Severity:     strings.ToLower(definition.Severity),
+					FixedVersion: testFixed[ref],
+					Description:  strings.TrimSpace(definition.Title),
+				}
+
+				for _, name := range packages {
+					tracker.rules[name] = append(tracker.rules[name], rule)
+				}
+			}
+		}
+	}
+
+	return tracker, nil
+}
+
+// Len returns number of packages with known vulnerabilities
+func (tracker *VulnerabilityTracker) Len() int {
+	return len(tracker.rules)
+}
+
+// packageKey returns name and version of the package as tracked by the tracker
+func (tracker *VulnerabilityTracker) packageKey(p *Package) (string, string) {
+	if tracker.Format == TrackerFormatDebian && !p.IsSource {
+		return p.GetField("$Source"), p.GetField("$SourceVersion")
+	}
+
+	return p.Name, p.Version
+}
+
+// Vulnerabilities returns vulnerabilities affecting the package, sorted by ID
+func (tracker *VulnerabilityTracker) Vulnerabilities(p *Package) []VulnerabilityRule {
+	name, version := tracker.packageKey(p)
+
+	var result []VulnerabilityRule
+	seen := map[string]bool{}
+
+	for _, rule := range tracker.rules[name] {
+		if seen[rule.ID] {
+			continue
+		}
+
+		if rule.FixedVersion == "" || CompareVersions(version, rule.FixedVersion) < 0 {
+			seen[rule.ID] = true
+			result = append(result, rule)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result
+}
+
+// Check returns all the packages in the list affected by known vulnerabilities
+func (tracker *VulnerabilityTracker) Check(list *PackageList) []VulnerablePackage {
+	var result []VulnerablePackage
+
+	_ = list.ForEach(func(p *Package) error {
+		vulns := tracker.Vulnerabilities(p)
+		if len(vulns) == 0 {
+			return nil
+		}
+
+		source, sourceVersion := p.Name, p.Version
+		if !p.IsSource {
+			source, sourceVersion = p.GetField("$Source"), p.GetField("$SourceVersion")
+		}
+
+		result = append(result, VulnerablePackage{
+			Package:         p.String(),
+			Name:            p.Name,
+			Version:         p.Version,
+			Architecture:    p.Architecture,
+			Source:          source,
+			SourceVersion:   sourceVersion,
+			Vulnerabilities: vulns,
+		})
+		return nil
+	})
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := &result[i], &result[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		return CompareVersions(a.Version, b.Version) < 0
+	})
+
+	return result
+}
+
+type vulnerabilityKey struct {
+	id, name, arch string
+}
+
+func (tracker *VulnerabilityTracker) affected(list *PackageList) (map[vulnerabilityKey]VulnerabilityChange, map[string]string) {
+	result := map[vulnerabilityKey]VulnerabilityChange{}
+	versions := map[string]string{}
+
+	for _, p := range tracker.Check(list) {
+		for _, rule := range p.Vulnerabilities {
+			result[vulnerabilityKey{rule.ID, p.Name, p.Architecture}] = VulnerabilityChange{
+				ID: rule.ID, Severity: rule.Severity, Name: p.Name, Architecture: p.Architecture,
+			}
+		}
+	}
+
+	_ = list.ForEach(func(p *Package) error {
+		key := p.Name + " " + p.Architecture
+		if existing, ok := versions[key]; !ok || CompareVersions(p.Version, existing) > 0 {
+			versions[key] = p.Version
+		}
+		return nil
+	})
+
+	return result, versions
+}
+
+// Diff compares vulnerabilities affecting package lists, vulnerability is considered
+// fixed if none of the versions of the package in the new list is affected
+func (tracker *VulnerabilityTracker) Diff(oldList, newList *PackageList) *VulnerabilityDiff {
+	oldAffected, oldVersions := tracker.affected(oldList)
+	newAffected, newVersions := tracker.affected(newList)
+
+	diff := &VulnerabilityDiff{Fixed: []VulnerabilityChange{}, Introduced: []VulnerabilityChange{}}
+
+	collect := func(from, to map[vulnerabilityKey]VulnerabilityChange) []VulnerabilityChange {
+		var result []VulnerabilityChange
+		for key, change := range from {
+			if _, ok := to[key]; ok {
+				continue
+			}
+			change.OldVersion = oldVersions[key.name+" "+key.arch]
+			change.NewVersion = newVersions[key.name+" "+key.arch]
+			result = append(result, change)
+		}
+
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].ID != result[j].ID {
+				return result[i].ID < result[j].ID
+			}
+			if result[i].Name != result[j].Name {
+				return result[i].Name < result[j].Name
+			}
+			return result[i].Architecture < result[j].Architecture
+		})
+
+		return result
+	}
+
+	diff.Fixed = append(diff.Fixed, collect(oldAffected, newAffected)...)
+	diff.Introduced = append(diff.Introduced, collect(newAffected, oldAffected)...)
+
+	return diff
+}
+
+// String returns description of vulnerability rule
+func (rule *VulnerabilityRule) String() string {
+	var buf bytes.Buffer
+
+	buf.WriteString(rule.ID)
+	if rule.Severity != "" {
+		fmt.Fprintf(&buf, " [%s]", rule.Severity)
+	}
+	if rule.FixedVersion != "" {
+		fmt.Fprintf(&buf, ", fixed in %s", rule.FixedVersion)
+	} else {
+		buf.WriteString(", not fixed")
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,195 @@
+package deb
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type VulnerabilitySuite struct {
+	tempDir string
+}
+
+var _ = Suite(&VulnerabilitySuite{})
+
+func (s *VulnerabilitySuite) SetUpTest(c *C) {
+	s.tempDir = c.MkDir()
+}
+
+const exampleDebianTracker = `{
+  "openssl": {
+    "CVE-2024-0001": {
+      "description": "buffer overflow",
+      "releases": {
+        "bookworm": {"status": "resolved", "fixed_version": "3.0.11-1~deb12u2", "urgency": "high"},
+        "trixie": {"status": "resolved", "fixed_version": "3.1.0-1", "urgency": "high"}
+      }
+    },
+    "CVE-2024-0002": {
+      "releases": {
+        "bookworm": {"status": "open", "urgency": "low**"}
+      }
+    },
+    "CVE-2024-0003": {
+      "releases": {
+        "bookworm": {"status": "resolved", "fixed_version": "0", "urgency": "unimportant"}
+      }
+    }
+  },
+  "zlib": {
+    "CVE-2024-0004": {
+      "releases": {
+        "bookworm": {"status": "resolved", "fixed_version": "1:1.2.13.dfsg-1", "urgency": "medium"}
+      }
+    }
+  }
+}
+`
+
+const exampleOVAL = `<?xml version="1.0" encoding="UTF-8"?>
+<oval_definitions xmlns="http://oval.mitre.org/XMLSchema/oval-definitions-5"
+    xmlns:linux-def="http://oval.mitre.org/XMLSchema/oval-definitions-5#linux">
+  <definitions>
+    <definition class="vulnerability" id="oval:com.ubuntu.jammy:def:1" version="1">
+      <metadata>
+        <title>CVE-2024-0001 on Ubuntu 22.04 LTS (jammy) - high.</title>
+        <reference source="CVE" ref_id="CVE-2024-0001" ref_url="https://ubuntu.com/security/CVE-2024-0001"/>
+        <advisory><severity>High</severity></advisory>
+      </metadata>
+      <criteria operator="OR">
+        <criteria operator="AND">
+          <criterion test_ref="oval:com.ubuntu.jammy:tst:1" comment="openssl package in jammy was vulnerable but has been fixed"/>
+        </criteria>
+      </criteria>
+    </definition>
+    <definition class="vulnerability" id="oval:com.ubuntu.jammy:def:2" version="1">
+      <metadata>
+        <title>CVE-2024-0002 on Ubuntu 22.04 LTS (jammy) - low.</title>
+        <reference source="CVE" ref_id="CVE-2024-0002"/>
+        <advisory><severity>Low</severity></advisory>
+      </metadata>
+      <criteria>
+        <criterion test_ref="oval:com.ubuntu.jammy:tst:2" comment="openssl package in jammy is affected and may need fixing"/>
+      </criteria>
+    </definition>
+  </definitions>
+  <tests>
+    <linux-def:dpkginfo_test id="oval:com.ubuntu.jammy:tst:1" check="at least one" version="1">
+      <linux-def:object object_ref="oval:com.ubuntu.jammy:obj:1"/>
+      <linux-def:state state_ref="oval:com.ubuntu.jammy:ste:1"/>
+    </linux-def:dpkginfo_test>
+    <linux-def:dpkginfo_test id="oval:com.ubuntu.jammy:tst:2" check="at least one" version="1">
+      <linux-def:object object_ref="oval:com.ubuntu.jammy:obj:2"/>
+    </linux-def:dpkginfo_test>
+  </tests>
+  <objects>
+    <linux-def:dpkginfo_object id="oval:com.ubuntu.jammy:obj:1" version="1">
+      <linux-def:name var_ref="oval:com.ubuntu.jammy:var:1" var_check="at least one"/>
+    </linux-def:dpkginfo_object>
+    <linux-def:dpkginfo_object id="oval:com.ubuntu.jammy:obj:2" version="1">
+      <linux-def:name>openssl</linux-def:name>
+    </linux-def:dpkginfo_object>
+  </objects>
+  <states>
+    <linux-def:dpkginfo_state id="oval:com.ubuntu.jammy:ste:1" version="1">
+      <linux-def:evr datatype="debian_evr_string" operation="less than">0:3.0.2-0ubuntu1.15</linux-def:evr>
+    </linux-def:dpkginfo_state>
+  </states>
+  <variables>
+    <constant_variable id="oval:com.ubuntu.jammy:var:1" version="1" datatype="string">
+      <value>libssl3</value>
+      <value>openssl</value>
+    </constant_variable>
+  </variables>
+</oval_definitions>
+`
+
+func (s *VulnerabilitySuite) write(c *C, name, contents string) string {
+	path := filepath.Join(s.tempDir, name)
+	c.Assert(os.WriteFile(path, []byte(contents), 0644), IsNil)
+	return path
+}
+
+func (s *VulnerabilitySuite) list(c *C, stanzas ...Stanza) *PackageList {
+	list := NewPackageList()
+	for _, stanza := range stanzas {
+		c.Assert(list.Add(NewPackageFromControlFile(stanza)), IsNil)
+	}
+	return list
+}
+
+func (s *VulnerabilitySuite) TestDebianTracker(c *C) {
+	path := s.write(c, "tracker.json", "\n"+exampleDebianTracker)
+
+	_, err := LoadVulnerabilityTracker(path, "")
+	c.Check(err, ErrorMatches, "release should be specified for Debian Security Tracker data")
+
+	tracker, err := LoadVulnerabilityTracker(path, "bookworm")
+	c.Assert(err, IsNil)
+	c.Check(tracker.Format, Equals, TrackerFormatDebian)
+	c.Check(tracker.Len(), Equals, 2)
+
+	list := s.list(c,
+		Stanza{"Package": "libssl3", "Version": "3.0.11-1~deb12u1", "Architecture": "amd64", "Source": "openssl"},
+		Stanza{"Package": "zlib1g", "Version": "1:1.2.13.dfsg-1", "Architecture": "amd64", "Source": "zlib"},
+		Stanza{"Package": "bash", "Version": "5.2.15-2", "Architecture": "amd64"},
+	)
+
+	result := tracker.Check(list)
+	c.Assert(result, HasLen, 1)
+	c.Check(result[0].Package, Equals, "libssl3_3.0.11-1~deb12u1_amd64")
+	c.Check(result[0].Source, Equals, "openssl")
+	c.Check(result[0].Vulnerabilities, DeepEquals, []VulnerabilityRule{
+		{ID: "CVE-2024-0001", Severity: "high", FixedVersion: "3.0.11-1~deb12u2", Description: "buffer overflow"},
+		{ID: "CVE-2024-0002", Severity: "low"},
+	})
+	c.Check(result[0].Vulnerabilities[0].String(), Equals, "CVE-2024-0001 [high], fixed in 3.0.11-1~deb12u2")
+	c.Check(result[0].Vulnerabilities[1].String(), Equals, "CVE-2024-0002 [low], not fixed")
+}
+
+func (s *VulnerabilitySuite) TestOVAL(c *C) {
+	tracker, err := LoadVulnerabilityTracker(s.write(c, "oval.xml", exampleOVAL), "")
+	c.Assert(err, IsNil)
+	c.Check(tracker.Format, Equals, TrackerFormatOVAL)
+
+	list := s.list(c,
+		Stanza{"Package": "libssl3", "Version": "3.0.2-0ubuntu1.14", "Architecture": "amd64", "Source": "openssl"},
+		Stanza{"Package": "openssl", "Version": "3.0.2-0ubuntu1.15", "Architecture": "amd64"},
+	)
+
+	result := tracker.Check(list)
+	c.Assert(result, HasLen, 2)
+	c.Check(result[0].Name, Equals, "libssl3")
+	c.Check(result[0].Vulnerabilities, DeepEquals, []VulnerabilityRule{
+		{ID: "CVE-2024-0001", Severity: "high", FixedVersion: "3.0.2-0ubuntu1.15", Description: "CVE-2024-0001 on Ubuntu 22.04 LTS (jammy) - high."},
+	})
+	c.Check(result[1].Name, Equals, "openssl")
+	c.Check(result[1].Vulnerabilities, HasLen, 1)
+	c.Check(result[1].Vulnerabilities[0].ID, Equals, "CVE-2024-0002")
+
+	_, err = LoadVulnerabilityTracker(s.write(c, "garbage", "garbage"), "")
+	c.Check(err, ErrorMatches, "unable to parse .*: unknown format, expected Debian Security Tracker JSON or OVAL")
+}
+
+func (s *VulnerabilitySuite) TestDiff(c *C) {
+	tracker, err := LoadVulnerabilityTracker(s.write(c, "tracker.json", exampleDebianTracker), "bookworm")
+	c.Assert(err, IsNil)
+
+	oldList := s.list(c,
+		Stanza{"Package": "libssl3", "Version": "3.0.11-1~deb12u1", "Architecture": "amd64", "Source": "openssl"},
+		Stanza{"Package": "zlib1g", "Version": "1:1.2.13.dfsg-1", "Architecture": "amd64", "Source": "zlib"},
+	)
+	newList := s.list(c,
+		Stanza{"Package": "libssl3", "Version": "3.0.11-1~deb12u2", "Architecture": "amd64", "Source": "openssl"},
+		Stanza{"Package": "zlib1g", "Version": "1:1.2.13.dfsg-0", "Architecture": "amd64", "Source": "zlib"},
+	)
+
+	diff := tracker.Diff(oldList, newList)
+	c.Check(diff.Fixed, DeepEquals, []VulnerabilityChange{
+		{ID: "CVE-2024-0001", Severity: "high", Name: "libssl3", Architecture: "amd64", OldVersion: "3.0.11-1~deb12u1", NewVersion: "3.0.11-1~deb12u2"},
+	})
+	c.Check(diff.Introduced, DeepEquals, []VulnerabilityChange{
+		{ID: "CVE-2024-0004", Severity: "medium", Name: "zlib1g", Architecture: "amd64", OldVersion: "1:1.2.13.dfsg-1", NewVersion: "1:1.2.13.dfsg-0"},
+	})
+}
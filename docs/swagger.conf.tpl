@@ -12,6 +12,8 @@ package docs
 // @Tag.description.markdown
 // @Tag.name Mirrors
 // @Tag.description.markdown
+// @Tag.name Schedules
+// @Tag.description.markdown
 // @Tag.name Snapshots
 // @Tag.description.markdown
 // @Tag.name Publish
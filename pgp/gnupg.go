@@ -261,6 +261,14 @@ func (g *GpgVerifier) runGpgv(args []string, context string, showKeyTip bool) (*
 
 		if strings.HasPrefix(line, "[GNUPG:] GOODSIG ") {
 			result.GoodKeys = append(result.GoodKeys, Key(strings.Fields(line)[2]))
+		} else if strings.HasPrefix(line, "[GNUPG:] VALIDSIG ") {
+			// VALIDSIG <fingerprint> ... <primary key fingerprint>
+			fields := strings.Fields(line)
+			if len(fields) > 11 {
+				result.GoodFingerprints = append(result.GoodFingerprints, Key(fields[11]))
+			} else if len(fields) > 2 {
+				result.GoodFingerprints = append(result.GoodFingerprints, Key(fields[2]))
+			}
 		} else if strings.HasPrefix(line, "[GNUPG:] NO_PUBKEY ") {
 			result.MissingKeys = append(result.MissingKeys, Key(strings.Fields(line)[2]))
 		}
@@ -292,12 +300,12 @@ func (g *GpgVerifier) runGpgv(args []string, context string, showKeyTip bool) (*
 }
 
 // VerifyDetachedSignature verifies combination of signature and cleartext using gpgv
-func (g *GpgVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, showKeyTip bool) error {
+func (g *GpgVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, showKeyTip bool) (*KeyInfo, error) {
 	args := g.argsKeyrings()
 
 	sigf, err := os.CreateTemp("", "aptly-gpg")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		_ = os.Remove(sigf.Name())
@@ -306,12 +314,12 @@ func (g *GpgVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, sh
 
 	_, err = io.Copy(sigf, signature)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	clearf, err := os.CreateTemp("", "aptly-gpg")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer func() {
 		_ = os.Remove(clearf.Name())
@@ -320,12 +328,11 @@ func (g *GpgVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, sh
 
 	_, err = io.Copy(clearf, cleartext)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	args = append(args, sigf.Name(), clearf.Name())
-	_, err = g.runGpgv(args, "detached signature", showKeyTip)
-	return err
+	return g.runGpgv(args, "detached signature", showKeyTip)
 }
 
 // IsClearSigned returns true if file contains signature
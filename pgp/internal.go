@@ -392,7 +392,7 @@ func (g *GoVerifier) printLog(signers []signatureResult) {
 }
 
 // VerifyDetachedSignature verifies combination of signature and cleartext using gpgv
-func (g *GoVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, showKeyTip bool) error {
+func (g *GoVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, showKeyTip bool) (*KeyInfo, error) {
 	var signatureBuf bytes.Buffer
 
 	signers, missingKeys, err := checkArmoredDetachedSignature(g.trustedKeyring, cleartext, io.TeeReader(signature, &signatureBuf))
@@ -409,10 +409,10 @@ func (g *GoVerifier) VerifyDetachedSignature(signature, cleartext io.Reader, sho
 	}
 
 	if err != nil {
-		return errors.Wrap(err, "failed to verify detached signature")
+		return nil, errors.Wrap(err, "failed to verify detached signature")
 	}
 
-	return nil
+	return newKeyInfo(signers), nil
 }
 
 // IsClearSigned returns true if file contains signature
@@ -451,18 +451,24 @@ func (g *GoVerifier) VerifyClearsigned(clearsigned io.Reader, showKeyTip bool) (
 		return nil, errors.Wrap(err, "failed to verify signature")
 	}
 
+	return newKeyInfo(signers), nil
+}
+
+// newKeyInfo builds KeyInfo from results of signature verification
+func newKeyInfo(signers []signatureResult) *KeyInfo {
 	result := &KeyInfo{}
 
 	for _, signer := range signers {
 		if signer.Entity != nil {
 			result.GoodKeys = append(result.GoodKeys, KeyFromUint64(signer.IssuerKeyID))
+			result.GoodFingerprints = append(result.GoodFingerprints, Key(fmt.Sprintf("%X", signer.Entity.PrimaryKey.Fingerprint)))
 		} else {
 			result.MissingKeys = append(result.MissingKeys, KeyFromUint64(signer.IssuerKeyID))
 
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 // ExtractClearsigned extracts cleartext from clearsigned file WITHOUT signature verification
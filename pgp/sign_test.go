@@ -72,8 +72,9 @@ func (s *SignerSuite) testSignDetached(c *C) {
 	err := s.signer.DetachedSign(s.clearF.Name(), s.signedF.Name())
 	c.Assert(err, IsNil)
 
-	err = s.verifier.VerifyDetachedSignature(s.signedF, s.clearF, false)
+	keyInfo, err := s.verifier.VerifyDetachedSignature(s.signedF, s.clearF, false)
 	c.Assert(err, IsNil)
+	c.Check(keyInfo.GoodFingerprints, HasLen, 1)
 }
 
 func (s *SignerSuite) TestSignDetachedNoPassphrase(c *C) {
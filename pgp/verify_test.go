@@ -28,7 +28,7 @@ func (s *VerifierSuite) TestVerifyDetached(c *C) {
 		signature, err := os.Open(test.signatureName)
 		c.Assert(err, IsNil)
 
-		err = s.verifier.VerifyDetachedSignature(signature, cleartext, false)
+		_, err = s.verifier.VerifyDetachedSignature(signature, cleartext, false)
 		c.Assert(err, IsNil)
 
 		_ = signature.Close()
@@ -54,11 +54,18 @@ type ConfigStructure struct { // nolint: maligned
 	GpgDisableSign   bool     `json:"gpgDisableSign"                yaml:"gpg_disable_sign"`
 	GpgDisableVerify bool     `json:"gpgDisableVerify"              yaml:"gpg_disable_verify"`
 	GpgKeys          []string `json:"gpgKeys"                       yaml:"gpg_keys"`
+	DebsigKeyrings   []string `json:"debsigKeyrings,omitempty"      yaml:"debsig_keyrings,omitempty"`
 
 	// Publishing
 	SkipContentsPublishing bool `json:"skipContentsPublishing"        yaml:"skip_contents_publishing"`
 	SkipBz2Publishing      bool `json:"skipBz2Publishing"             yaml:"skip_bz2_publishing"`
 
+	// Snapshot retention
+	SnapshotRetention []SnapshotRetentionRule `json:"snapshotRetention,omitempty"   yaml:"snapshot_retention,omitempty"`
+
+	// Incoming queues
+	IncomingQueues map[string]IncomingQueue `json:"incomingQueues,omitempty"      yaml:"incoming_queues,omitempty"`
+
 	// Storage
 	FileSystemPublishRoots map[string]FileSystemPublishRoot `json:"FileSystemPublishEndpoints"    yaml:"filesystem_publish_endpoints"`
 	JFrogPublishRoots      map[string]JFrogPublishRoot      `json:"JFrogPublishEndpoints"         yaml:"jfrog_publish_endpoints"`
@@ -69,6 +76,38 @@ type ConfigStructure struct { // nolint: maligned
 	PackagePoolStorage     PackagePoolStorage               `json:"packagePoolStorage"            yaml:"packagepool_storage"`
 }
 
+// SnapshotRetentionRule describes which snapshots should be kept by `snapshot prune`
+type SnapshotRetentionRule struct {
+	// Shell pattern matching snapshot names
+	Pattern string `json:"pattern"               yaml:"pattern"`
+	// Number of most recent matching snapshots to keep
+	KeepLast int `json:"keepLast,omitempty"      yaml:"keep_last,omitempty"`
+	// Keep snapshots younger than this age (e.g. 72h, 30d, 4w)
+	KeepWithin string `json:"keepWithin,omitempty"  yaml:"keep_within,omitempty"`
+}
+
+// IncomingQueue describes incoming directory watched for .changes uploads
+type IncomingQueue struct {
+	// Directory uploads are placed into
+	Path string `json:"path"                        yaml:"path"`
+	// Directory rejected uploads are moved to, defaults to "quarantine" subdirectory
+	QuarantinePath string `json:"quarantinePath,omitempty"    yaml:"quarantine_path,omitempty"`
+	// Template of local repository name, defaults to {{.Distribution}}
+	Repo string `json:"repo,omitempty"              yaml:"repo,omitempty"`
+	// Path to uploaders.json file
+	UploadersFile string `json:"uploadersFile,omitempty"     yaml:"uploaders_file,omitempty"`
+	// Gpg keyrings to verify .changes signatures
+	Keyrings []string `json:"keyrings,omitempty"          yaml:"keyrings,omitempty"`
+	// Accept unsigned .changes files
+	AcceptUnsigned bool `json:"acceptUnsigned,omitempty"    yaml:"accept_unsigned,omitempty"`
+	// Don't verify .changes signatures
+	IgnoreSignatures bool `json:"ignoreSignatures,omitempty"  yaml:"ignore_signatures,omitempty"`
+	// Replace conflicting packages already in the repository
+	ForceReplace bool `json:"forceReplace,omitempty"      yaml:"force_replace,omitempty"`
+	// Maximum size of the file uploaded via API, in bytes
+	MaxUploadSize int64 `json:"maxUploadSize,omitempty"     yaml:"max_upload_size,omitempty"`
+}
+
 // DBConfig structure
 type DBConfig struct {
 	Type   string `json:"type"    yaml:"type"`
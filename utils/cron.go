@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSpec is parsed cron expression (minute, hour, day of month, month, day of week)
+type CronSpec struct {
+	minute, hour, dom, month, dow uint64
+	// domStar/dowStar are set when field was specified as "*"
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{0, 59, nil}
+	cronHour   = cronField{0, 23, nil}
+	cronDom    = cronField{1, 31, nil}
+	cronMonth  = cronField{1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronField{0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronShortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCronSpec parses standard 5-field cron expression
+//
+// Supported syntax: "*", lists ("1,15"), ranges ("1-5"), steps ("*/10", "0-30/5"),
+// month and weekday names and @hourly/@daily/@weekly/@monthly/@yearly shortcuts.
+func ParseCronSpec(expr string) (*CronSpec, error) {
+	expr = strings.TrimSpace(expr)
+	if shortcut, ok := cronShortcuts[strings.ToLower(expr)]; ok {
+		expr = shortcut
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	spec := &CronSpec{}
+
+	var err error
+	if spec.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: minute: %s", expr, err)
+	}
+	if spec.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: hour: %s", expr, err)
+	}
+	if spec.dom, err = cronDom.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of month: %s", expr, err)
+	}
+	if spec.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: month: %s", expr, err)
+	}
+	if spec.dow, err = cronDow.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of week: %s", expr, err)
+	}
+
+	// 7 is an alias for Sunday
+	if spec.dow&(1<<7) != 0 {
+		spec.dow |= 1
+	}
+
+	spec.domStar = strings.HasPrefix(fields[2], "*")
+	spec.dowStar = strings.HasPrefix(fields[4], "*")
+
+	return spec, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d out of range [%d-%d]", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (f cronField) parse(s string) (uint64, error) {
+	var result uint64
+
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+
+		if idx := strings.Index(part, "/"); idx != -1 {
+			var err error
+			rangePart = part[:idx]
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		var start, end int
+		switch {
+		case rangePart == "*":
+			start, end = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			var err error
+			if start, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			end = start
+			if step != 1 {
+				end = f.max
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			result |= 1 << uint(i)
+		}
+	}
+
+	return result, nil
+}
+
+// Matches checks if time t (truncated to the minute) is matched by spec
+func (spec *CronSpec) Matches(t time.Time) bool {
+	if spec.minute&(1<<uint(t.Minute())) == 0 ||
+		spec.hour&(1<<uint(t.Hour())) == 0 ||
+		spec.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	return spec.dayMatches(t)
+}
+
+// AtMostDaily checks if spec fires at most once a day (single minute and hour)
+func (spec *CronSpec) AtMostDaily() bool {
+	return bits.OnesCount64(spec.minute) == 1 && bits.OnesCount64(spec.hour) == 1
+}
+
+// Next returns first time strictly after t matched by spec, or zero time
+// if nothing matches in the next five years
+func (spec *CronSpec) Next(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, t.Location()).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if spec.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).AddDate(0, 1, 0)
+			continue
+		}
+		if !spec.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).AddDate(0, 0, 1)
+			continue
+		}
+		if spec.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(time.Hour)
+			continue
+		}
+		if spec.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (spec *CronSpec) dayMatches(t time.Time) bool {
+	// as in cron(8): if both day fields are restricted, either one matching is enough
+	domMatch := spec.dom&(1<<uint(t.Day())) != 0
+	dowMatch := spec.dow&(1<<uint(t.Weekday())) != 0
+
+	if !spec.domStar && !spec.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CronSuite struct{}
+
+var _ = Suite(&CronSuite{})
+
+func (s *CronSuite) TestParseErrors(c *C) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * foo *"} {
+		_, err := ParseCronSpec(expr)
+		c.Check(err, NotNil, Commentf("expr: %q", expr))
+	}
+}
+
+func (s *CronSuite) TestMatches(c *C) {
+	spec, err := ParseCronSpec("*/15 2 * * mon-fri")
+	c.Assert(err, IsNil)
+
+	// 2026-10-19 is Monday
+	c.Check(spec.Matches(time.Date(2026, 10, 19, 2, 30, 0, 0, time.UTC)), Equals, true)
+	c.Check(spec.Matches(time.Date(2026, 10, 19, 2, 31, 0, 0, time.UTC)), Equals, false)
+	c.Check(spec.Matches(time.Date(2026, 10, 19, 3, 30, 0, 0, time.UTC)), Equals, false)
+	c.Check(spec.Matches(time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC)), Equals, false)
+
+	spec, err = ParseCronSpec("0 0 1 * 7")
+	c.Assert(err, IsNil)
+	// either day of month or day of week
+	c.Check(spec.Matches(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Check(spec.Matches(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Check(spec.Matches(time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)), Equals, false)
+}
+
+func (s *CronSuite) TestAtMostDaily(c *C) {
+	for expr, expected := range map[string]bool{
+		"@daily":           true,
+		"30 2 * * mon-fri": true,
+		"0 0 1 * *":        true,
+		"@hourly":          false,
+		"0 2,14 * * *":     false,
+		"*/15 2 * * *":     false,
+	} {
+		spec, err := ParseCronSpec(expr)
+		c.Assert(err, IsNil)
+		c.Check(spec.AtMostDaily(), Equals, expected, Commentf("expr: %q", expr))
+	}
+}
+
+func (s *CronSuite) TestNext(c *C) {
+	spec, err := ParseCronSpec("@daily")
+	c.Assert(err, IsNil)
+	c.Check(spec.Next(time.Date(2026, 10, 18, 21, 40, 10, 0, time.UTC)), Equals, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+
+	spec, err = ParseCronSpec("30 4 29 feb *")
+	c.Assert(err, IsNil)
+	c.Check(spec.Next(time.Date(2026, 10, 18, 21, 40, 0, 0, time.UTC)), Equals, time.Date(2028, 2, 29, 4, 30, 0, 0, time.UTC))
+
+	spec, err = ParseCronSpec("0,20 * * * *")
+	c.Assert(err, IsNil)
+	c.Check(spec.Next(time.Date(2026, 10, 18, 21, 0, 0, 0, time.UTC)), Equals, time.Date(2026, 10, 18, 21, 20, 0, 0, time.UTC))
+}